@@ -9,6 +9,7 @@ import (
 
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apiserver/apiserver"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apiserver/webhookserver"
+	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -17,20 +18,42 @@ func main() {
 
 	port := flag.Int("port", 8000, "API Server Port. Default: 8000")
 	webhookport := flag.Int("whport", 8001, "WebHook Server Port. Default: 8001")
-	listrunningauth := flag.Bool("listingauth", false, "If true, /running requires authentication. Default: false")
+	listrunningauth := flag.Bool("listingauth", false, "If true, /running and the DedicatedGameServer reads of /api/v2 require authentication. Default: false")
+	accesscodeoverlap := flag.Duration("accesscodeoverlap", shared.DefaultAccessCodeOverlap, "Time that the previous access code is accepted after the apiaccesscode Secret changes. Default: 5m")
+	gamenamespaces := flag.String("gamenamespaces", shared.GameNamespacesFromEnv(shared.GameNamespace), "Comma separated list of the namespaces of the DedicatedGameServers, or * for all namespaces. Requests without a namespace use the first one. Default: $GAME_NAMESPACES or default")
 
 	flag.Parse()
 
+	err := shared.SetGameNamespaces(*gamenamespaces)
+	if err != nil {
+		log.Fatalf("Cannot set the game namespaces due to: %v", err)
+	}
+
+	client, dgsClient, err := shared.GetClientSet()
+	if err != nil {
+		log.Fatalf("Cannot initialize connection to cluster due to: %v", err)
+	}
+
 	// listening OS shutdown singal
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM)
 
-	apiserver := apiserver.Run(*port, *listrunningauth)
+	// the access codes follow the apiaccesscode Secret, so that it can be rotated without restarts
+	stopCh := make(chan struct{})
+	accessCodeStore := shared.NewAccessCodeStore(client, shared.DefaultGameNamespace(), *accesscodeoverlap)
+	shared.SetAccessCodeStore(accessCodeStore)
+	go accessCodeStore.Run(stopCh)
+
+	// the keys that verify the tokens of the DedicatedGameServers are created by the DedicatedGameServer controller
+	tokenKeys := shared.NewDGSTokenKeyStore(client, shared.DefaultGameNamespace(), false)
+
+	apiserver := apiserver.Run(*port, *listrunningauth, client, dgsClient, tokenKeys)
 	webhookserver := webhookserver.Run("/certificate/cert.pem", "/certificate/key.pem", *webhookport)
 
 	<-signalChan
 
 	log.Infof("Got OS shutdown signal, shutting down webhook and API servers gracefully...")
+	close(stopCh)
 	apiserver.Shutdown(context.Background())
 	webhookserver.Shutdown(context.Background())
 }
@@ -2,13 +2,14 @@ package main
 
 import (
 	"flag"
+	"os"
 	"reflect"
 	"time"
 
-	dgsinformers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions"
 	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/autoscale"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/dgs"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/dgsallocation"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/dgscollection"
 	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 	signals "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/signals"
@@ -16,17 +17,34 @@ import (
 	"github.com/jonboulle/clockwork"
 
 	log "github.com/sirupsen/logrus"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	informers "k8s.io/client-go/informers"
 )
 
 func main() {
 	podautoscalerenabled := flag.Bool("podautoscaler", false, "Determines whether Pod AutoScaler is enabled. Default: false")
+	autoscalerevaluationinterval := flag.Duration("autoscalerevaluationinterval", time.Minute, "Interval of the periodic evaluation of the DedicatedGameServerCollections by the Pod AutoScaler, 0 disables it. Default: 1m")
+	bufferautoscalerenabled := flag.Bool("bufferautoscaler", false, "Determines whether Buffer AutoScaler is enabled. Default: false")
+	webhookautoscalerenabled := flag.Bool("webhookautoscaler", false, "Determines whether Webhook AutoScaler is enabled. Default: false")
+	webhookautoscalerinterval := flag.Duration("webhookautoscalerinterval", 30*time.Second, "Interval of the webhook calls of the Webhook AutoScaler. Default: 30s")
+	portregistryscope := flag.String("portregistryscope", string(controllers.ClusterPortRegistryScope), "Set of Nodes in which a HostPort must be unique, one of Cluster, Node and NodePool. Default: Cluster")
+	nodepoollabel := flag.String("nodepoollabel", "agentpool", "Node label that groups Nodes into node pools, when portregistryscope is NodePool. Default: agentpool")
+	portreconciliationinterval := flag.Duration("portreconciliationinterval", 5*time.Minute, "Interval of the periodic comparison of the Port Registry with the DedicatedGameServers and their Pods, 0 disables it. Default: 5m")
 	controllerthreadiness := flag.Int("controllerthreadiness", 1, "Controller Threadiness. Default: 1")
+	gamenamespaces := flag.String("gamenamespaces", shared.GameNamespacesFromEnv(shared.AllGameNamespaces), "Comma separated list of the namespaces of the DedicatedGameServers, or * for all namespaces. Default: $GAME_NAMESPACES or *")
+	leaderelect := flag.Bool("leaderelect", false, "Determines whether the controller runs only while it holds a leader lease, so that many replicas can run as hot standbys. Default: false")
+	leaderelectnamespace := flag.String("leaderelectnamespace", getEnv("POD_NAMESPACE", "dgs-system"), "Namespace of the leader lease ConfigMap. Default: $POD_NAMESPACE or dgs-system")
+	leaderelectname := flag.String("leaderelectname", "aks-gaming-controller", "Name of the leader lease ConfigMap. Default: aks-gaming-controller")
+	leaderelectidentity := flag.String("leaderelectidentity", getHostname(), "Unique identity of the replica in the leader election. Default: the hostname, which is the Pod name")
+	leaseduration := flag.Duration("leaseduration", 15*time.Second, "Time that the standby replicas wait since the last renewal of the leader lease, before they take over. Default: 15s")
+	renewdeadline := flag.Duration("renewdeadline", 10*time.Second, "Time that the leader keeps retrying to renew the leader lease, before it stops leading. Default: 10s")
+	retryperiod := flag.Duration("retryperiod", 2*time.Second, "Interval between the attempts to acquire or renew the leader lease. Default: 2s")
 
 	flag.Parse()
 
+	err := shared.SetGameNamespaces(*gamenamespaces)
+	if err != nil {
+		log.Panicf("Cannot set the game namespaces due to: %v", err)
+	}
+
 	client, dgsclient, err := shared.GetClientSet()
 
 	if err != nil {
@@ -36,43 +54,95 @@ func main() {
 	// set up signals so we handle the first shutdown signal gracefully
 	stopCh := signals.SetupSignalHandler()
 
-	sharedInformerFactory := informers.NewSharedInformerFactory(client, 30*time.Minute)
-	dgsSharedInformerFactory := dgsinformers.NewSharedInformerFactory(dgsclient, 30*time.Minute)
-
-	log.Info("Initializing Port Registry")
-	portRegistry, err := controllers.NewPortRegistry(dgsclient, shared.MinPort, shared.MaxPort, metav1.NamespaceAll)
-	if err != nil {
-		log.Panicf("Cannot initialize Port Registry because of %s", err.Error())
+	// run starts the informers and the controllers, it blocks until stopCh is closed
+	// The Port Registry is built from the DedicatedGameServers of the cluster, so a new leader gets the HostPorts of the previous one
+	run := func(stopCh <-chan struct{}) {
+		sharedInformerFactory, dgsSharedInformerFactory := controllers.NewSharedInformerFactories(client, dgsclient, 30*time.Minute)
+
+		log.Info("Initializing Port Registry")
+		portRegistry, err := controllers.NewPortRegistry(dgsclient, shared.MinPort, shared.MaxPort, shared.InformerNamespace(), controllers.PortRegistryOptions{
+			Scope:         controllers.PortRegistryScope(*portregistryscope),
+			NodeLister:    sharedInformerFactory.Core().V1().Nodes().Lister(),
+			NodePoolLabel: *nodepoollabel,
+		})
+		if err != nil {
+			log.Panicf("Cannot initialize Port Registry because of %s", err.Error())
+		}
+
+		dgsColController, err := dgscollection.NewDedicatedGameServerCollectionController(client, dgsclient,
+			dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
+			dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServers(),
+			sharedInformerFactory.Apps().V1().ControllerRevisions(),
+			portRegistry)
+
+		if err != nil {
+			log.Panicf("Cannot initialize DGSCollection controller due to %s", err.Error())
+		}
+
+		dgsController := dgs.NewDedicatedGameServerController(client, dgsclient,
+			dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServers(),
+			sharedInformerFactory.Core().V1().Pods(), sharedInformerFactory.Core().V1().Nodes(), portRegistry, *portreconciliationinterval)
+
+		dgsAllocationController := dgsallocation.NewDGSAllocationController(client, dgsclient,
+			dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServerAllocations())
+
+		controllers := []controllerHelper{dgsColController, dgsController, dgsAllocationController}
+
+		if *podautoscalerenabled {
+			podAutoscalerController := autoscale.NewActivePlayersAutoScalerController(client, dgsclient,
+				dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
+				dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServers(), clockwork.NewRealClock(), *autoscalerevaluationinterval)
+			controllers = append(controllers, podAutoscalerController)
+		}
+
+		if *bufferautoscalerenabled {
+			bufferAutoscalerController := autoscale.NewBufferAutoScalerController(client, dgsclient,
+				dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
+				dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServers())
+			controllers = append(controllers, bufferAutoscalerController)
+		}
+
+		if *webhookautoscalerenabled {
+			webhookAutoscalerController := autoscale.NewWebhookAutoScalerController(client, dgsclient,
+				dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
+				dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServers(), clockwork.NewRealClock(), *webhookautoscalerinterval)
+			controllers = append(controllers, webhookAutoscalerController)
+		}
+
+		go sharedInformerFactory.Start(stopCh)
+		go dgsSharedInformerFactory.Start(stopCh)
+
+		runAllControllers(controllers, *controllerthreadiness, stopCh)
 	}
 
-	dgsColController, err := dgscollection.NewDedicatedGameServerCollectionController(client, dgsclient,
-		dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
-		dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServers(),
-		portRegistry)
-
-	if err != nil {
-		log.Errorf("Cannot initialize DGSCollection controller due to %s", err.Error())
+	if !*leaderelect {
+		run(stopCh)
 		return
 	}
 
-	dgsController := dgs.NewDedicatedGameServerController(client, dgsclient,
-		dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServers(),
-		sharedInformerFactory.Core().V1().Pods(), sharedInformerFactory.Core().V1().Nodes(), portRegistry)
-
-	controllers := []controllerHelper{dgsColController, dgsController}
-
-	if *podautoscalerenabled {
-		podAutoscalerController := autoscale.NewActivePlayersAutoScalerController(client, dgsclient,
-			dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
-			dgsSharedInformerFactory.Azuregaming().V1alpha1().DedicatedGameServers(), clockwork.NewRealClock())
-		controllers = append(controllers, podAutoscalerController)
+	elector, err := controllers.NewLeaderElector(controllers.LeaderElectionConfig{
+		Client:           client,
+		Namespace:        *leaderelectnamespace,
+		Name:             *leaderelectname,
+		Identity:         *leaderelectidentity,
+		LeaseDuration:    *leaseduration,
+		RenewDeadline:    *renewdeadline,
+		RetryPeriod:      *retryperiod,
+		OnStartedLeading: run,
+		OnStoppedLeading: func() {
+			select {
+			case <-stopCh:
+				log.Info("Stopped leading")
+			default:
+				// the controllers cannot be restarted, so the replica exits and comes back as a standby
+				log.Fatal("Lost the leader lease, exiting")
+			}
+		},
+	})
+	if err != nil {
+		log.Panicf("Cannot initialize leader election due to: %v", err)
 	}
-
-	go sharedInformerFactory.Start(stopCh)
-	go dgsSharedInformerFactory.Start(stopCh)
-
-	runAllControllers(controllers, *controllerthreadiness, stopCh)
-
+	elector.Run(stopCh)
 }
 
 // runAllControllers will set up the event handlers for types we are interested in, as well
@@ -99,6 +169,21 @@ func runAllControllers(controllers []controllerHelper, controllerThreadiness int
 
 }
 
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Errorf("Cannot get the hostname due to: %v", err)
+	}
+	return hostname
+}
+
 type controllerHelper interface {
 	Run(controllerThreadiness int, stopCh <-chan struct{}) error
 }
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+)
+
+const usage = `dgsctl is a command line tool for the API Server
+
+Usage:
+  dgsctl [flags] revisions <collection>                     lists the Template revisions of a DedicatedGameServerCollection
+  dgsctl [flags] rollback [-to-revision N] <collection>     rolls back a DedicatedGameServerCollection to revision N (default: the previous one)
+
+Flags:
+`
+
+func main() {
+	apiServerURL := flag.String("apiserver", getEnv("API_SERVER_URL", shared.APIServerURL), "API Server URL. Default: $API_SERVER_URL or the in-cluster API Server URL")
+	code := flag.String("code", os.Getenv("API_SERVER_CODE"), "API Server access code. Default: $API_SERVER_CODE")
+	token := flag.String("token", os.Getenv("API_SERVER_BEARER_TOKEN"), "Kubernetes bearer token, which is used instead of the access code if set. Default: $API_SERVER_BEARER_TOKEN")
+	namespace := flag.String("namespace", os.Getenv("GAME_NAMESPACE"), "Game namespace of the DedicatedGameServerCollection. Default: $GAME_NAMESPACE or the default game namespace of the API Server")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	c := &client{apiServerURL: *apiServerURL, code: *code, token: *token, namespace: *namespace}
+
+	var err error
+	switch flag.Arg(0) {
+	case "revisions":
+		err = revisions(c, flag.Args()[1:])
+	case "rollback":
+		err = rollback(c, flag.Args()[1:])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func revisions(c *client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("revisions requires exactly one DedicatedGameServerCollection name")
+	}
+
+	body, err := c.do("GET", "/revisions", url.Values{"name": {args[0]}})
+	if err != nil {
+		return err
+	}
+
+	var revisions []shared.DedicatedGameServerCollectionRevision
+	err = json.Unmarshal(body, &revisions)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tTEMPLATEHASH\tCREATED\tCURRENT")
+	for _, revision := range revisions {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%t\n", revision.Revision, revision.TemplateHash, revision.CreationTimestamp.String(), revision.Current)
+	}
+	return w.Flush()
+}
+
+func rollback(c *client, args []string) error {
+	flags := flag.NewFlagSet("rollback", flag.ExitOnError)
+	toRevision := flags.Int64("to-revision", 0, "Revision to roll back to. Default: 0, the previous revision")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("rollback requires exactly one DedicatedGameServerCollection name")
+	}
+
+	query := url.Values{"name": {flags.Arg(0)}}
+	if *toRevision != 0 {
+		query.Set("revision", strconv.FormatInt(*toRevision, 10))
+	}
+
+	body, err := c.do("POST", "/rollback", query)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(body))
+	return nil
+}
+
+// client calls the API Server methods
+type client struct {
+	apiServerURL string
+	code         string
+	token        string
+	namespace    string
+}
+
+func (c *client) do(method string, path string, query url.Values) ([]byte, error) {
+	if c.token == "" {
+		query.Set("code", c.code)
+	}
+	if c.namespace != "" {
+		query.Set("namespace", c.namespace)
+	}
+	req, err := http.NewRequest(method, c.apiServerURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API Server returned %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+func getEnv(key string, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
@@ -1,9 +1,9 @@
 #!/bin/bash
 echo "Start processing"
 
-SetHealthAPIServerURL="$API_SERVER_URL/setsdgshealth?code=$API_SERVER_CODE"
-SetStateAPIServerURL="$API_SERVER_URL/setdgsstate?code=$API_SERVER_CODE"
-SetActivePlayersAPIServerURL="$API_SERVER_URL/setactiveplayers?code=$API_SERVER_CODE"
+SetHealthAPIServerURL="$API_SERVER_URL/setsdgshealth"
+SetStateAPIServerURL="$API_SERVER_URL/setdgsstate"
+SetActivePlayersAPIServerURL="$API_SERVER_URL/setactiveplayers"
 
 while IFS= read -r line
 do
@@ -15,9 +15,9 @@ do
     if [ $init -eq 1 ]
     then
         echo "About to send data for server health: {\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"health\":\"Healthy\"}"
-        wget -O- --post-data="{\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"health\":\"Healthy\"}" --header=Content-Type:application/json "$SetHealthAPIServerURL"
+        wget -O- --post-data="{\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"health\":\"Healthy\"}" --header=Content-Type:application/json --header="Authorization: Bearer $API_SERVER_TOKEN" "$SetHealthAPIServerURL"
         echo "About to send data for server state: {\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"state\":\"Assigned\"}"
-        wget -O- --post-data="{\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"state\":\"Assigned\"}" --header=Content-Type:application/json "$SetStateAPIServerURL"
+        wget -O- --post-data="{\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"state\":\"Assigned\"}" --header=Content-Type:application/json --header="Authorization: Bearer $API_SERVER_TOKEN" "$SetStateAPIServerURL"
     fi
 
     #client connection
@@ -51,7 +51,7 @@ do
         echo $connected > /tmp/connected
 
         echo "About to send data for active players: {\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"playerCount\":$connected}"
-        wget -O- --post-data="{\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"playerCount\":$connected}" --header=Content-Type:application/json "$SetActivePlayersAPIServerURL"
+        wget -O- --post-data="{\"serverName\":\"$SERVER_NAME\", \"namespace\":\"$SERVER_NAMESPACE\", \"playerCount\":$connected}" --header=Content-Type:application/json --header="Authorization: Bearer $API_SERVER_TOKEN" "$SetActivePlayersAPIServerURL"
 
     fi 
 done
@@ -115,15 +115,15 @@ if (!process.env.API_SERVER_URL) {
   process.exit(-1);
 }
 
-if (!process.env.API_SERVER_CODE) {
-  console.log("$API_SERVER_CODE is not defined");
+if (!process.env.API_SERVER_TOKEN) {
+  console.log("$API_SERVER_TOKEN is not defined");
   process.exit(-1);
 }
 
-const healthMethodURL = `${process.env.API_SERVER_URL}/setsdgshealth?code=${process.env.API_SERVER_CODE}`;
-const stateMethodURL = `${process.env.API_SERVER_URL}/setsdgsstate?code=${process.env.API_SERVER_CODE}`;
-const activePlayersMethodURL = `${process.env.API_SERVER_URL}/setactiveplayers?code=${process.env.API_SERVER_CODE}`;
-const markedForDeletionMethodURL= `${process.env.API_SERVER_URL}/setdgsmarkedfordeletion?code=${process.env.API_SERVER_CODE}`;
+const healthMethodURL = `${process.env.API_SERVER_URL}/setsdgshealth`;
+const stateMethodURL = `${process.env.API_SERVER_URL}/setsdgsstate`;
+const activePlayersMethodURL = `${process.env.API_SERVER_URL}/setactiveplayers`;
+const markedForDeletionMethodURL= `${process.env.API_SERVER_URL}/setdgsmarkedfordeletion`;
 
 const healthPostData = {
   serverName: process.env.SERVER_NAME,
@@ -164,6 +164,8 @@ function sendData(url, postData, callback) {
     url: url,
     json: postData,
     method: 'POST',
+    // the token of the DGS is sent in the header, so that it does not end up in the access logs
+    headers: { Authorization: `Bearer ${process.env.API_SERVER_TOKEN}` },
     maxAttempts: 5, // (default) try 5 times
     retryDelay: 5000, // (default) wait for 5s before trying again
     retryStrategy: request.RetryStrategies.HTTPOrNetworkError // (default) retry on 5xx or network errors
@@ -44,14 +44,14 @@ func main() {
 	// we also set ActivePlayers for all DGS to 2
 	log.Info("Step 2a")
 	// scale out
-	increaseReplicasToTen()                                                    // kubectl scale dgsc ... --replicas=10
+	scaleDGSCol(10)                                                            // kubectl scale dgsc ... --replicas=10
 	validateClusterState(clusterState{totalPodCount: 10, healthyDGSCount: 10}) // check if there are 10 DGS and 10 pods
 	setAllActivePlayers(2)                                                     // set all ActivePlayers to two
 
 	// decrease from 10 to 7
 	// so our DGSCol should now contain 7 DGS, whereas 3 DGS should be out of the collection in MarkedForDeletion state
 	log.Info("Step 2b")
-	decreaseReplicasToSeven() // kubectl scale dgsc ... --replicas=7
+	scaleDGSCol(7) // kubectl scale dgsc ... --replicas=7
 	validateClusterState(clusterState{totalPodCount: 10, healthyDGSCount: 7, markedForDeletionDGSCount: 3})
 
 	// make players leave from the MarkedForDeletionServers
@@ -224,7 +224,7 @@ func main() {
 
 	// set again 9 players for all DGS - 1 new DGS will be created
 	log.Info("Step 7b")
-	setAutoscalerLastScaleOperationDateTimeToZeroValue()
+	resetAutoscalerLastScaleOperationTime()
 	setAllActivePlayers(9)
 	// verify that autoscaler has kicked in and we have one more DGS
 	validateClusterState(clusterState{
@@ -235,7 +235,7 @@ func main() {
 
 	// set again 9 players for all DGS - no new DGS will be created since we are at the maximum of 7
 	log.Info("Step 7c")
-	setAutoscalerLastScaleOperationDateTimeToZeroValue()
+	resetAutoscalerLastScaleOperationTime()
 	setAllActivePlayers(9)
 	validateClusterState(clusterState{
 		totalPodCount:   7,
@@ -245,7 +245,7 @@ func main() {
 
 	// set 5 players for all DGS -> 1 DGS less
 	log.Info("Step 7d")
-	setAutoscalerLastScaleOperationDateTimeToZeroValue()
+	resetAutoscalerLastScaleOperationTime()
 	setAllActivePlayers(5)
 	validateClusterState(clusterState{
 		totalPodCount:             7, // 7 pods: 6 in collection, 1 out
@@ -256,7 +256,7 @@ func main() {
 
 	// set again 5 players for all DGS -> 1 DGS less
 	log.Info("Step 7e")
-	setAutoscalerLastScaleOperationDateTimeToZeroValue()
+	resetAutoscalerLastScaleOperationTime()
 	setAllActivePlayers(5)
 	validateClusterState(clusterState{
 		totalPodCount:             7, // 7 pods: 5 in collection, 2 out
@@ -267,7 +267,7 @@ func main() {
 
 	// set 5 players for all DGS -> not going less than the minimum (5) replicas
 	log.Info("Step 7f")
-	setAutoscalerLastScaleOperationDateTimeToZeroValue()
+	resetAutoscalerLastScaleOperationTime()
 	setAllActivePlayers(5)
 	validateClusterState(clusterState{
 		totalPodCount:             7,
@@ -282,14 +282,16 @@ func handleError(err error) {
 	log.Panic(err)
 }
 
-func setAutoscalerLastScaleOperationDateTimeToZeroValue() {
+func resetAutoscalerLastScaleOperationTime() {
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		dgscol, err := dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Get(dgsColName, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
-		dgscol.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime = ""
-		_, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgscol)
+		if dgscol.Status.ActivePlayersAutoScalerStatus != nil {
+			dgscol.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime = nil
+		}
+		_, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).UpdateStatus(dgscol)
 		if err != nil {
 			return err
 		}
@@ -324,6 +326,9 @@ func addAutoScalerDetails() {
 			Enabled:             true,
 			CoolDownInMinutes:   5,
 			MaxPlayersPerServer: 10,
+			// scale one replica at a time, so the steps below can be validated
+			MaxScaleOutStep: 1,
+			MaxScaleInStep:  1,
 		}
 		_, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgscol)
 		return err
@@ -361,9 +366,14 @@ func resetClusterState(replicas int32, failbehavior dgsv1alpha1.DedicatedGameSer
 
 		dgscol.Spec.DGSFailBehavior = failbehavior
 		dgscol.Spec.Replicas = replicas
+		dgscol, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgscol)
+		if err != nil {
+			return err
+		}
+
 		dgscol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
 		dgscol.Status.DGSTimesFailed = 0
-		_, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgscol)
+		_, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).UpdateStatus(dgscol)
 		return err
 	})
 	if retryErr != nil {
@@ -394,7 +404,7 @@ func setRandomHealthyDGSToFailed() {
 				break
 			}
 		}
-		dgsUpdate, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Update(dgs)
+		dgsUpdate, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServers(namespace).UpdateStatus(dgs)
 		return err
 	})
 	if retryErr != nil {
@@ -449,7 +459,7 @@ func setActivePlayersOfMarkedForDeletionToZero() {
 			dgsCopy.Status.ActivePlayers = 0
 			var dgsUpdated *dgsv1alpha1.DedicatedGameServer
 			retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-				dgsUpdated, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Update(dgsCopy)
+				dgsUpdated, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServers(namespace).UpdateStatus(dgsCopy)
 				return err
 			})
 			if retryErr != nil {
@@ -477,7 +487,7 @@ func setAllActivePlayers(playerscount int) {
 				return err
 			}
 			dgsCopy.Status.ActivePlayers = playerscount
-			dgsUpdated, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Update(dgsCopy)
+			dgsUpdated, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServers(namespace).UpdateStatus(dgsCopy)
 			return err
 		})
 		if retryErr != nil {
@@ -489,35 +499,20 @@ func setAllActivePlayers(playerscount int) {
 	}
 }
 
-func increaseReplicasToTen() {
-	log.Info("    Increasing replicas of DGSCol to 10")
-	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		dgsCol, err := dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Get(dgsColName, metav1.GetOptions{})
-		if err != nil {
-			return err
-		}
-		dgsCol.Spec.Replicas = 10
-		_, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgsCol)
-		return err
-	})
-	if retryErr != nil {
-		handleError(fmt.Errorf("Cannot update DGSCol"))
-	}
-}
-
-func decreaseReplicasToSeven() {
-	log.Info("    Decreasing replicas of DGSCol to 7")
+// scaleDGSCol sets the replicas of the DGSCol via its scale subresource, the same way kubectl scale does
+func scaleDGSCol(replicas int32) {
+	log.Infof("    Scaling DGSCol to %d replicas", replicas)
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		dgsCol, err := dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Get(dgsColName, metav1.GetOptions{})
+		scale, err := dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).GetScale(dgsColName, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
-		dgsCol.Spec.Replicas = 7
-		_, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgsCol)
+		scale.Spec.Replicas = replicas
+		_, err = dgsclient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).UpdateScale(dgsColName, scale)
 		return err
 	})
 	if retryErr != nil {
-		handleError(fmt.Errorf("Cannot update DGSCol"))
+		handleError(fmt.Errorf("Cannot scale DGSCol because of %s", retryErr.Error()))
 	}
 }
 
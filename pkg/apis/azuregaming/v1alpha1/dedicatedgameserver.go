@@ -3,10 +3,11 @@ package v1alpha1
 import (
 	corev1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // +genclient
-// +genclient:noStatus
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // DedicatedGameServer describes a DedicatedGameServer resource
@@ -29,8 +30,24 @@ type DedicatedGameServer struct {
 
 // DedicatedGameServerSpec is the spec for a DedicatedGameServer resource
 type DedicatedGameServerSpec struct {
-	PortsToExpose []int32        `json:"portsToExpose"`
-	Template      corev1.PodSpec `json:"template"`
+	// PortsToExpose are the container ports that get a HostPort. Every item is a port number, a port name or a container/portName pair
+	PortsToExpose []intstr.IntOrString `json:"portsToExpose"`
+	Template      corev1.PodSpec       `json:"template"`
+	// SchedulingStrategy can be Packed, Distributed or None and controls the Pod affinity of the DGS. Default is Packed
+	SchedulingStrategy DedicatedGameServerSchedulingStrategy `json:"schedulingStrategy,omitempty"`
+	// PortAllocation records the HostPorts that were allocated to the DGS
+	PortAllocation *DGSPortAllocation `json:"portAllocation,omitempty"`
+	// AddressTemplate is a Go template that renders the Address of the DGS, e.g. "{{.PublicIP}}:{{index .Ports \"game\"}}"
+	// Default is PublicIP:port, with the HostPort of the first exposed port
+	AddressTemplate string `json:"addressTemplate,omitempty"`
+}
+
+// DGSPortAllocation records the HostPorts that the controller allocated to a DGS
+// It is kept in the spec, since status is not persisted on creation, so that allocations survive controller restarts
+type DGSPortAllocation struct {
+	// NodePool is the node pool that the HostPorts were allocated in, if the controller tracks HostPorts per node pool
+	NodePool  string  `json:"nodePool,omitempty"`
+	HostPorts []int32 `json:"hostPorts"`
 }
 
 // DedicatedGameServerStatus is the status for a DedicatedGameServer resource
@@ -42,6 +59,13 @@ type DedicatedGameServerStatus struct {
 	PublicIP          string          `json:"publicIP"`
 	NodeName          string          `json:"nodeName"`
 	ActivePlayers     int             `json:"activePlayers"`
+	// Ports are the exposed ports of the DGS, with the HostPorts that clients connect to
+	Ports []DGSPort `json:"ports,omitempty"`
+	// Address is the address that clients connect to, rendered with the AddressTemplate
+	Address string `json:"address,omitempty"`
+	// AllocationUID is the UID of the DedicatedGameServerAllocation that assigned the DGS,
+	// so that an allocation that is retried gets the same DGS
+	AllocationUID types.UID `json:"allocationUID,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,51 @@
+package v1alpha1
+
+import (
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +genclient:noStatus
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DedicatedGameServerAllocation describes a request to allocate an Idle DedicatedGameServer, e.g. from a matchmaker
+type DedicatedGameServerAllocation struct {
+	// TypeMeta is the metadata for the resource, like kind and apiversion
+	meta_v1.TypeMeta `json:",inline"`
+	// ObjectMeta contains the metadata for the particular object
+	meta_v1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the custom resource spec
+	Spec   DedicatedGameServerAllocationSpec   `json:"spec"`
+	Status DedicatedGameServerAllocationStatus `json:"status"`
+}
+
+// DedicatedGameServerAllocationSpec is the spec for a DedicatedGameServerAllocation resource
+// If both fields are empty, any Idle DedicatedGameServer in the namespace can be allocated
+type DedicatedGameServerAllocationSpec struct {
+	// DedicatedGameServerCollectionName restricts the allocation to the DedicatedGameServers of this collection
+	DedicatedGameServerCollectionName string `json:"dedicatedGameServerCollectionName,omitempty"`
+	// Selector restricts the allocation to the DedicatedGameServers whose labels match it
+	Selector *meta_v1.LabelSelector `json:"selector,omitempty"`
+}
+
+// DedicatedGameServerAllocationStatus is the status for a DedicatedGameServerAllocation resource
+type DedicatedGameServerAllocationStatus struct {
+	State                   DGSAllocationState `json:"state"`
+	DedicatedGameServerName string             `json:"dedicatedGameServerName,omitempty"`
+	PublicIP                string             `json:"publicIP,omitempty"`
+	NodeName                string             `json:"nodeName,omitempty"`
+	Ports                   []DGSPort          `json:"ports,omitempty"`
+	// Address is the address of the allocated DedicatedGameServer that clients connect to
+	Address string `json:"address,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DedicatedGameServerAllocationList is a list of DedicatedGameServerAllocation resources
+type DedicatedGameServerAllocationList struct {
+	meta_v1.TypeMeta `json:",inline"`
+	meta_v1.ListMeta `json:"metadata"`
+
+	Items []DedicatedGameServerAllocation `json:"items"`
+}
@@ -3,10 +3,12 @@ package v1alpha1
 import (
 	corev1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // +genclient
-// +genclient:noStatus
+// +genclient:method=GetScale,verb=get,subresource=scale,result=k8s.io/api/autoscaling/v1.Scale
+// +genclient:method=UpdateScale,verb=update,subresource=scale,input=k8s.io/api/autoscaling/v1.Scale,result=k8s.io/api/autoscaling/v1.Scale
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // DedicatedGameServerCollection describes a DedicatedGameServerCollection resource
@@ -31,23 +33,97 @@ type DedicatedGameServerCollection struct {
 type DedicatedGameServerCollectionSpec struct {
 	// this is where you would put your custom resource data
 	Replicas                          int32                              `json:"replicas"`
-	PortsToExpose                     []int32                            `json:"portsToExpose"`
+	PortsToExpose                     []intstr.IntOrString               `json:"portsToExpose"`
 	Template                          corev1.PodSpec                     `json:"template"`
 	DGSFailBehavior                   DedicatedGameServerFailBehavior    `json:"dgsFailBehavior,omitempty"`
 	DGSMaxFailures                    int32                              `json:"dgsMaxFailures,omitempty"`
 	DGSActivePlayersAutoScalerDetails *DGSActivePlayersAutoScalerDetails `json:"dgsActivePlayersAutoScalerDetails,omitempty"`
+	// DGSBufferAutoScalerDetails configures the autoscaler that keeps a buffer of Idle DGSs
+	DGSBufferAutoScalerDetails *DGSBufferAutoScalerDetails `json:"dgsBufferAutoScalerDetails,omitempty"`
+	// DGSWebhookAutoScalerDetails configures the autoscaler that gets the desired replicas from an external HTTP endpoint
+	DGSWebhookAutoScalerDetails *DGSWebhookAutoScalerDetails `json:"dgsWebhookAutoScalerDetails,omitempty"`
+	// UpdateStrategy describes how DGSs are replaced when the Template changes
+	UpdateStrategy DedicatedGameServerCollectionUpdateStrategy `json:"updateStrategy,omitempty"`
+	// ScaleInPolicy can be Ranked or Random and selects the DGSs that are removed when Replicas decrease. Default is Ranked
+	ScaleInPolicy DedicatedGameServerCollectionScaleInPolicy `json:"scaleInPolicy,omitempty"`
+	// SchedulingStrategy can be Packed, Distributed or None and controls the Pod affinity of the DGSs. Default is Packed
+	SchedulingStrategy DedicatedGameServerSchedulingStrategy `json:"schedulingStrategy,omitempty"`
+	// RevisionHistoryLimit is the number of old Templates that are kept (as ControllerRevisions) to allow rollback
+	// Defaults to 10
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// PortPolicy configures the HostPorts of the exposed ports. Default is a Dynamic HostPort for every exposed port
+	PortPolicy *DGSPortPolicy `json:"portPolicy,omitempty"`
+	// AddressTemplate is a Go template that renders the Address of the DGSs, e.g. "{{.PublicIP}}:{{index .Ports \"game\"}}"
+	// Default is PublicIP:port, with the HostPort of the first exposed port
+	AddressTemplate string `json:"addressTemplate,omitempty"`
 }
 
 // DGSActivePlayersAutoScalerDetails contains details about the autoscaling of the dedicated game server collection
 type DGSActivePlayersAutoScalerDetails struct {
-	MinimumReplicas            int    `json:"minimumReplicas"`
-	MaximumReplicas            int    `json:"maximumReplicas"`
-	ScaleInThreshold           int    `json:"scaleInThreshold"`
-	ScaleOutThreshold          int    `json:"scaleOutThreshold"`
-	Enabled                    bool   `json:"enabled"`
-	CoolDownInMinutes          int    `json:"coolDownInMinutes"`
-	LastScaleOperationDateTime string `json:"lastScaleOperationDateTime"`
-	MaxPlayersPerServer        int    `json:"maxPlayersPerServer"`
+	MinimumReplicas     int  `json:"minimumReplicas"`
+	MaximumReplicas     int  `json:"maximumReplicas"`
+	ScaleInThreshold    int  `json:"scaleInThreshold"`
+	ScaleOutThreshold   int  `json:"scaleOutThreshold"`
+	Enabled             bool `json:"enabled"`
+	CoolDownInMinutes   int  `json:"coolDownInMinutes"`
+	MaxPlayersPerServer int  `json:"maxPlayersPerServer"`
+	// MaxScaleOutStep is the maximum number of replicas that can be added on a single scale out. Zero means no limit
+	MaxScaleOutStep int `json:"maxScaleOutStep,omitempty"`
+	// MaxScaleInStep is the maximum number of replicas that can be removed on a single scale in. Zero means no limit
+	MaxScaleInStep int `json:"maxScaleInStep,omitempty"`
+	// Schedules override MinimumReplicas and MaximumReplicas, or set fixed replicas, during time windows
+	// If more than one schedules are active, the first one is used
+	Schedules []DGSScalingSchedule `json:"schedules,omitempty"`
+	// LastScaleOperationDateTime is deprecated, the time of the last scale operation is now kept in the DGSCol Status
+	// It's only read in order to migrate existing DGSCols
+	LastScaleOperationDateTime string `json:"lastScaleOperationDateTime,omitempty"`
+}
+
+// DGSScalingSchedule is a time window during which the ActivePlayers autoscaler uses different replica limits
+type DGSScalingSchedule struct {
+	Name string `json:"name"`
+	// Schedule is a cron expression (minute hour day-of-month month day-of-week) for the start of the window, e.g. "0 18 * * fri"
+	Schedule string `json:"schedule"`
+	// Duration is the length of the window, e.g. "4h". Maximum is one week
+	Duration meta_v1.Duration `json:"duration"`
+	// TimeZone is the IANA time zone of Schedule, e.g. "Europe/Athens". Default is UTC
+	TimeZone string `json:"timeZone,omitempty"`
+	// MinimumReplicas overrides the autoscaler MinimumReplicas during the window
+	MinimumReplicas *int `json:"minimumReplicas,omitempty"`
+	// MaximumReplicas overrides the autoscaler MaximumReplicas during the window
+	MaximumReplicas *int `json:"maximumReplicas,omitempty"`
+	// Replicas sets fixed replicas during the window, overriding MinimumReplicas and MaximumReplicas
+	Replicas *int32 `json:"replicas,omitempty"`
+}
+
+// DGSBufferAutoScalerDetails contains details about the buffer autoscaling of the dedicated game server collection
+// The autoscaler keeps at least BufferSize Idle and Healthy DGSs in the collection, within MinimumReplicas and MaximumReplicas
+type DGSBufferAutoScalerDetails struct {
+	Enabled bool `json:"enabled"`
+	// BufferSize is the number of Idle DGSs to keep, or their percentage of the collection's replicas (e.g. "20%")
+	BufferSize      intstr.IntOrString `json:"bufferSize"`
+	MinimumReplicas int                `json:"minimumReplicas"`
+	MaximumReplicas int                `json:"maximumReplicas"`
+}
+
+// DGSWebhookAutoScalerDetails contains details about the webhook autoscaling of the dedicated game server collection
+// The autoscaler POSTs the collection status and the players of its DGSs to URL and scales to the replicas it returns,
+// within MinimumReplicas and MaximumReplicas
+type DGSWebhookAutoScalerDetails struct {
+	Enabled bool `json:"enabled"`
+	// URL is the HTTP(S) endpoint that returns the desired replicas
+	URL             string `json:"url"`
+	MinimumReplicas int    `json:"minimumReplicas"`
+	MaximumReplicas int    `json:"maximumReplicas"`
+	// TimeoutSeconds is the timeout of the requests to URL. Default is 5
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// CABundle is a PEM encoded CA bundle that verifies the certificate of URL. Default is the system CAs
+	CABundle []byte `json:"caBundle,omitempty"`
+	// InsecureSkipVerify disables the verification of the certificate of URL
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// FallbackReplicas are used when URL is unavailable or returns an invalid response
+	// Default is to keep the current replicas
+	FallbackReplicas *int32 `json:"fallbackReplicas,omitempty"`
 }
 
 // DedicatedGameServerCollectionStatus is the status for a DedicatedGameServerCollection resource
@@ -56,6 +132,25 @@ type DedicatedGameServerCollectionStatus struct {
 	AvailableReplicas   int32           `json:"availableReplicas"`
 	PodCollectionState  corev1.PodPhase `json:"podsState"`
 	DGSCollectionHealth DGSColHealth    `json:"dgsHealth"`
+	// UpdatedReplicas is the number of DGSs in the collection that run the current Template
+	UpdatedReplicas int32 `json:"updatedReplicas"`
+	// OldReplicas is the number of DGSs in the collection that run a previous Template
+	OldReplicas int32 `json:"oldReplicas"`
+	// Selector is the label selector of the DGSs that belong to this collection, in string form
+	// It's used by the scale subresource
+	Selector string `json:"selector,omitempty"`
+	// ActivePlayersAutoScalerStatus contains the bookkeeping of the ActivePlayers autoscaler
+	ActivePlayersAutoScalerStatus *DGSActivePlayersAutoScalerStatus `json:"activePlayersAutoScalerStatus,omitempty"`
+}
+
+// DGSActivePlayersAutoScalerStatus contains the last scaling decision of the ActivePlayers autoscaler
+type DGSActivePlayersAutoScalerStatus struct {
+	// LastScaleOperationTime is the time of the last scale operation, used for the cooldown
+	LastScaleOperationTime *meta_v1.Time `json:"lastScaleOperationTime,omitempty"`
+	// LastDecision is the last scaling decision, i.e. ScaleOut or ScaleIn
+	LastDecision ActivePlayersAutoScalerDecision `json:"lastDecision,omitempty"`
+	// LastDecisionReason is a human readable description of the inputs of the last scaling decision
+	LastDecisionReason string `json:"lastDecisionReason,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -31,8 +31,10 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		SchemeGroupVersion,
 		&DedicatedGameServer{},
 		&DedicatedGameServerCollection{},
+		&DedicatedGameServerAllocation{},
 		&DedicatedGameServerList{},
 		&DedicatedGameServerCollectionList{},
+		&DedicatedGameServerAllocationList{},
 	)
 
 	// register the type in the scheme
@@ -1,5 +1,10 @@
 package v1alpha1
 
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
 // DGSState represents the DGS State
 type DGSState string
 
@@ -46,3 +51,116 @@ const (
 	Delete DedicatedGameServerFailBehavior = "Delete"
 	Remove DedicatedGameServerFailBehavior = "Remove"
 )
+
+// DedicatedGameServerCollectionUpdateStrategyType represents the way DGSs are replaced when the DGSCol template changes
+type DedicatedGameServerCollectionUpdateStrategyType string
+
+const (
+	// RollingUpdateDGSColStrategyType replaces the Idle DGSs with old template gradually, respecting MaxSurge and MaxUnavailable
+	RollingUpdateDGSColStrategyType DedicatedGameServerCollectionUpdateStrategyType = "RollingUpdate"
+	// RecreateDGSColStrategyType replaces all the Idle DGSs with old template at once
+	RecreateDGSColStrategyType DedicatedGameServerCollectionUpdateStrategyType = "Recreate"
+)
+
+// DedicatedGameServerSchedulingStrategy represents the way DGS Pods are placed on the Nodes of the cluster
+type DedicatedGameServerSchedulingStrategy string
+
+const (
+	// PackedSchedulingStrategy prefers to place DGS Pods on the Nodes that already run DGS Pods, so that unused Nodes can be removed
+	PackedSchedulingStrategy DedicatedGameServerSchedulingStrategy = "Packed"
+	// DistributedSchedulingStrategy prefers to spread DGS Pods across Nodes and zones, for fault isolation
+	DistributedSchedulingStrategy DedicatedGameServerSchedulingStrategy = "Distributed"
+	// NoneSchedulingStrategy leaves the placement of DGS Pods to the Kubernetes scheduler and the user supplied affinity
+	NoneSchedulingStrategy DedicatedGameServerSchedulingStrategy = "None"
+)
+
+// DedicatedGameServerCollectionScaleInPolicy represents the way DGSs are selected for removal when the DGSCol scales in
+type DedicatedGameServerCollectionScaleInPolicy string
+
+const (
+	// RankedScaleInPolicy removes the Failed DGSs first, then the Idle ones with zero players, then the ones with the fewest ActivePlayers
+	// and, between equals, the ones on the Node with the fewest DGSs, so that Nodes can drain
+	RankedScaleInPolicy DedicatedGameServerCollectionScaleInPolicy = "Ranked"
+	// RandomScaleInPolicy removes random DGSs
+	RandomScaleInPolicy DedicatedGameServerCollectionScaleInPolicy = "Random"
+)
+
+// DGSPortPolicyMode represents the way the HostPorts of the exposed ports are picked
+type DGSPortPolicyMode string
+
+const (
+	// DynamicPortPolicyMode picks free HostPorts from the port range
+	DynamicPortPolicyMode DGSPortPolicyMode = "Dynamic"
+	// PassthroughPortPolicyMode uses the ContainerPort as HostPort
+	PassthroughPortPolicyMode DGSPortPolicyMode = "Passthrough"
+)
+
+// DGSPortProtocol represents the protocol of the exposed ports
+type DGSPortProtocol string
+
+const (
+	// TCPPortProtocol exposes the ports over TCP
+	TCPPortProtocol DGSPortProtocol = "TCP"
+	// UDPPortProtocol exposes the ports over UDP
+	UDPPortProtocol DGSPortProtocol = "UDP"
+	// TCPAndUDPPortProtocol exposes the ports over both TCP and UDP, on the same HostPort
+	TCPAndUDPPortProtocol DGSPortProtocol = "TCPAndUDP"
+)
+
+// DGSPortPolicy configures the HostPorts of the exposed ports of the DGSs of a collection
+type DGSPortPolicy struct {
+	// Mode can be Dynamic or Passthrough. Default is Dynamic
+	Mode DGSPortPolicyMode `json:"mode,omitempty"`
+	// MinPort and MaxPort override the range of the Dynamic HostPorts. Default is the range of the controller
+	MinPort int32 `json:"minPort,omitempty"`
+	MaxPort int32 `json:"maxPort,omitempty"`
+	// BlockSize is the number of consecutive exposed ports, in the order of the Template, that get adjacent Dynamic HostPorts
+	// The number of exposed ports must be a multiple of BlockSize. Default is 1
+	BlockSize int32 `json:"blockSize,omitempty"`
+	// Protocol can be TCP, UDP or TCPAndUDP and overrides the protocol of the exposed ports. Default is the protocol of the Template
+	Protocol DGSPortProtocol `json:"protocol,omitempty"`
+}
+
+// DedicatedGameServerCollectionUpdateStrategy describes how DGSs are replaced when the DGSCol template changes
+type DedicatedGameServerCollectionUpdateStrategy struct {
+	// Type can be RollingUpdate or Recreate. Default is RollingUpdate
+	Type DedicatedGameServerCollectionUpdateStrategyType `json:"type,omitempty"`
+	// RollingUpdate contains the parameters for the RollingUpdate strategy
+	RollingUpdate *RollingUpdateDedicatedGameServerCollection `json:"rollingUpdate,omitempty"`
+}
+
+// RollingUpdateDedicatedGameServerCollection contains the parameters for the RollingUpdate strategy
+type RollingUpdateDedicatedGameServerCollection struct {
+	// MaxUnavailable is the maximum number (or percentage of Replicas) of DGSs that can be unavailable during the update. Default is 25%
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// MaxSurge is the maximum number (or percentage of Replicas) of DGSs that can be created above Replicas during the update. Default is 25%
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+}
+
+// ActivePlayersAutoScalerDecision represents a scaling decision of the ActivePlayers autoscaler
+type ActivePlayersAutoScalerDecision string
+
+const (
+	// ActivePlayersAutoScalerScaleOut represents a decision to add replicas
+	ActivePlayersAutoScalerScaleOut ActivePlayersAutoScalerDecision = "ScaleOut"
+	// ActivePlayersAutoScalerScaleIn represents a decision to remove replicas
+	ActivePlayersAutoScalerScaleIn ActivePlayersAutoScalerDecision = "ScaleIn"
+)
+
+// DGSAllocationState represents the outcome of a DedicatedGameServerAllocation
+type DGSAllocationState string
+
+const (
+	// DGSAllocationAllocated represents an allocation that was fulfilled by an Idle DGS, which is now Assigned
+	DGSAllocationAllocated DGSAllocationState = "Allocated"
+	// DGSAllocationUnAllocated represents an allocation that could not be fulfilled since there was no Idle DGS available
+	DGSAllocationUnAllocated DGSAllocationState = "UnAllocated"
+)
+
+// DGSPort represents a container port of a DGS that is exposed on the Node
+type DGSPort struct {
+	Name          string          `json:"name,omitempty"`
+	ContainerPort int32           `json:"containerPort"`
+	HostPort      int32           `json:"hostPort"`
+	Protocol      corev1.Protocol `json:"protocol,omitempty"`
+}
@@ -21,12 +21,21 @@ limitations under the License.
 package v1alpha1
 
 import (
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DGSActivePlayersAutoScalerDetails) DeepCopyInto(out *DGSActivePlayersAutoScalerDetails) {
 	*out = *in
+	if in.Schedules != nil {
+		in, out := &in.Schedules, &out.Schedules
+		*out = make([]DGSScalingSchedule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -40,13 +49,161 @@ func (in *DGSActivePlayersAutoScalerDetails) DeepCopy() *DGSActivePlayersAutoSca
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DGSActivePlayersAutoScalerStatus) DeepCopyInto(out *DGSActivePlayersAutoScalerStatus) {
+	*out = *in
+	if in.LastScaleOperationTime != nil {
+		in, out := &in.LastScaleOperationTime, &out.LastScaleOperationTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DGSActivePlayersAutoScalerStatus.
+func (in *DGSActivePlayersAutoScalerStatus) DeepCopy() *DGSActivePlayersAutoScalerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DGSActivePlayersAutoScalerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DGSBufferAutoScalerDetails) DeepCopyInto(out *DGSBufferAutoScalerDetails) {
+	*out = *in
+	out.BufferSize = in.BufferSize
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DGSBufferAutoScalerDetails.
+func (in *DGSBufferAutoScalerDetails) DeepCopy() *DGSBufferAutoScalerDetails {
+	if in == nil {
+		return nil
+	}
+	out := new(DGSBufferAutoScalerDetails)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DGSPort) DeepCopyInto(out *DGSPort) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DGSPort.
+func (in *DGSPort) DeepCopy() *DGSPort {
+	if in == nil {
+		return nil
+	}
+	out := new(DGSPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DGSPortAllocation) DeepCopyInto(out *DGSPortAllocation) {
+	*out = *in
+	if in.HostPorts != nil {
+		in, out := &in.HostPorts, &out.HostPorts
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DGSPortAllocation.
+func (in *DGSPortAllocation) DeepCopy() *DGSPortAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(DGSPortAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DGSPortPolicy) DeepCopyInto(out *DGSPortPolicy) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DGSPortPolicy.
+func (in *DGSPortPolicy) DeepCopy() *DGSPortPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(DGSPortPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DGSScalingSchedule) DeepCopyInto(out *DGSScalingSchedule) {
+	*out = *in
+	out.Duration = in.Duration
+	if in.MinimumReplicas != nil {
+		in, out := &in.MinimumReplicas, &out.MinimumReplicas
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaximumReplicas != nil {
+		in, out := &in.MaximumReplicas, &out.MaximumReplicas
+		*out = new(int)
+		**out = **in
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DGSScalingSchedule.
+func (in *DGSScalingSchedule) DeepCopy() *DGSScalingSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(DGSScalingSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DGSWebhookAutoScalerDetails) DeepCopyInto(out *DGSWebhookAutoScalerDetails) {
+	*out = *in
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.FallbackReplicas != nil {
+		in, out := &in.FallbackReplicas, &out.FallbackReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DGSWebhookAutoScalerDetails.
+func (in *DGSWebhookAutoScalerDetails) DeepCopy() *DGSWebhookAutoScalerDetails {
+	if in == nil {
+		return nil
+	}
+	out := new(DGSWebhookAutoScalerDetails)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DedicatedGameServer) DeepCopyInto(out *DedicatedGameServer) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -68,13 +225,116 @@ func (in *DedicatedGameServer) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedGameServerAllocation) DeepCopyInto(out *DedicatedGameServerAllocation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedGameServerAllocation.
+func (in *DedicatedGameServerAllocation) DeepCopy() *DedicatedGameServerAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedGameServerAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DedicatedGameServerAllocation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedGameServerAllocationList) DeepCopyInto(out *DedicatedGameServerAllocationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]DedicatedGameServerAllocation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedGameServerAllocationList.
+func (in *DedicatedGameServerAllocationList) DeepCopy() *DedicatedGameServerAllocationList {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedGameServerAllocationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *DedicatedGameServerAllocationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedGameServerAllocationSpec) DeepCopyInto(out *DedicatedGameServerAllocationSpec) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedGameServerAllocationSpec.
+func (in *DedicatedGameServerAllocationSpec) DeepCopy() *DedicatedGameServerAllocationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedGameServerAllocationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedGameServerAllocationStatus) DeepCopyInto(out *DedicatedGameServerAllocationStatus) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]DGSPort, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedGameServerAllocationStatus.
+func (in *DedicatedGameServerAllocationStatus) DeepCopy() *DedicatedGameServerAllocationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedGameServerAllocationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DedicatedGameServerCollection) DeepCopyInto(out *DedicatedGameServerCollection) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	return
 }
 
@@ -134,13 +394,34 @@ func (in *DedicatedGameServerCollectionSpec) DeepCopyInto(out *DedicatedGameServ
 	*out = *in
 	if in.PortsToExpose != nil {
 		in, out := &in.PortsToExpose, &out.PortsToExpose
-		*out = make([]int32, len(*in))
+		*out = make([]intstr.IntOrString, len(*in))
 		copy(*out, *in)
 	}
 	in.Template.DeepCopyInto(&out.Template)
 	if in.DGSActivePlayersAutoScalerDetails != nil {
 		in, out := &in.DGSActivePlayersAutoScalerDetails, &out.DGSActivePlayersAutoScalerDetails
 		*out = new(DGSActivePlayersAutoScalerDetails)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DGSBufferAutoScalerDetails != nil {
+		in, out := &in.DGSBufferAutoScalerDetails, &out.DGSBufferAutoScalerDetails
+		*out = new(DGSBufferAutoScalerDetails)
+		**out = **in
+	}
+	if in.DGSWebhookAutoScalerDetails != nil {
+		in, out := &in.DGSWebhookAutoScalerDetails, &out.DGSWebhookAutoScalerDetails
+		*out = new(DGSWebhookAutoScalerDetails)
+		(*in).DeepCopyInto(*out)
+	}
+	in.UpdateStrategy.DeepCopyInto(&out.UpdateStrategy)
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PortPolicy != nil {
+		in, out := &in.PortPolicy, &out.PortPolicy
+		*out = new(DGSPortPolicy)
 		**out = **in
 	}
 	return
@@ -159,6 +440,11 @@ func (in *DedicatedGameServerCollectionSpec) DeepCopy() *DedicatedGameServerColl
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DedicatedGameServerCollectionStatus) DeepCopyInto(out *DedicatedGameServerCollectionStatus) {
 	*out = *in
+	if in.ActivePlayersAutoScalerStatus != nil {
+		in, out := &in.ActivePlayersAutoScalerStatus, &out.ActivePlayersAutoScalerStatus
+		*out = new(DGSActivePlayersAutoScalerStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -172,6 +458,27 @@ func (in *DedicatedGameServerCollectionStatus) DeepCopy() *DedicatedGameServerCo
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DedicatedGameServerCollectionUpdateStrategy) DeepCopyInto(out *DedicatedGameServerCollectionUpdateStrategy) {
+	*out = *in
+	if in.RollingUpdate != nil {
+		in, out := &in.RollingUpdate, &out.RollingUpdate
+		*out = new(RollingUpdateDedicatedGameServerCollection)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DedicatedGameServerCollectionUpdateStrategy.
+func (in *DedicatedGameServerCollectionUpdateStrategy) DeepCopy() *DedicatedGameServerCollectionUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(DedicatedGameServerCollectionUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DedicatedGameServerList) DeepCopyInto(out *DedicatedGameServerList) {
 	*out = *in
@@ -210,10 +517,15 @@ func (in *DedicatedGameServerSpec) DeepCopyInto(out *DedicatedGameServerSpec) {
 	*out = *in
 	if in.PortsToExpose != nil {
 		in, out := &in.PortsToExpose, &out.PortsToExpose
-		*out = make([]int32, len(*in))
+		*out = make([]intstr.IntOrString, len(*in))
 		copy(*out, *in)
 	}
 	in.Template.DeepCopyInto(&out.Template)
+	if in.PortAllocation != nil {
+		in, out := &in.PortAllocation, &out.PortAllocation
+		*out = new(DGSPortAllocation)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -230,6 +542,11 @@ func (in *DedicatedGameServerSpec) DeepCopy() *DedicatedGameServerSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DedicatedGameServerStatus) DeepCopyInto(out *DedicatedGameServerStatus) {
 	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]DGSPort, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -242,3 +559,29 @@ func (in *DedicatedGameServerStatus) DeepCopy() *DedicatedGameServerStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingUpdateDedicatedGameServerCollection) DeepCopyInto(out *RollingUpdateDedicatedGameServerCollection) {
+	*out = *in
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingUpdateDedicatedGameServerCollection.
+func (in *RollingUpdateDedicatedGameServerCollection) DeepCopy() *RollingUpdateDedicatedGameServerCollection {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingUpdateDedicatedGameServerCollection)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,223 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	helpers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apiserver/helpers"
+	dgsclientsetversioned "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
+	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// api implements the methods of the API Server, which are exposed by both the v1 and the v2 routes
+type api struct {
+	client    kubernetes.Interface
+	dgsClient dgsclientsetversioned.Interface
+
+	// listRunningRequiresAuth is true if reading the DedicatedGameServers requires authentication
+	listRunningRequiresAuth bool
+
+	authenticateCaller func(r *http.Request) (*helpers.APICaller, error)
+	authorizeCaller    func(caller *helpers.APICaller, attributes authorizationv1.ResourceAttributes) (bool, error)
+	// authenticateDGS returns the DedicatedGameServer of the token of the call, if any
+	authenticateDGS func(r *http.Request) (*shared.DGSIdentity, error)
+}
+
+// newAPI returns a new api, which authenticates and authorizes the calls with the Kubernetes API
+// and verifies the tokens of the DedicatedGameServers with the keys of tokenKeys
+func newAPI(client kubernetes.Interface, dgsClient dgsclientsetversioned.Interface, listRunningRequiresAuth bool, tokenKeys *shared.DGSTokenKeyStore) *api {
+	authenticator := helpers.NewAPIAuthenticator(client)
+	return &api{
+		client:                  client,
+		dgsClient:               dgsClient,
+		listRunningRequiresAuth: listRunningRequiresAuth,
+		authenticateCaller:      authenticator.Authenticate,
+		authorizeCaller:         authenticator.Authorize,
+		authenticateDGS: func(r *http.Request) (*shared.DGSIdentity, error) {
+			return helpers.GetDGSTokenIdentity(r, tokenKeys)
+		},
+	}
+}
+
+// apiError is an error of an API call, with the HTTP status code and the reason of the response
+type apiError struct {
+	code    int
+	reason  metav1.StatusReason
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func newAPIError(code int, reason metav1.StatusReason, format string, args ...interface{}) *apiError {
+	return &apiError{code: code, reason: reason, message: fmt.Sprintf(format, args...)}
+}
+
+func newBadRequestError(format string, args ...interface{}) *apiError {
+	return newAPIError(http.StatusBadRequest, metav1.StatusReasonBadRequest, format, args...)
+}
+
+// toAPIError returns the apiError of err
+// The errors of the Kubernetes API keep their status code and reason, the other errors are internal errors
+func toAPIError(err error) *apiError {
+	if e, ok := err.(*apiError); ok {
+		return e
+	}
+	if status, ok := err.(k8serrors.APIStatus); ok {
+		s := status.Status()
+		return &apiError{code: int(s.Code), reason: s.Reason, message: s.Message}
+	}
+	return &apiError{code: http.StatusInternalServerError, reason: metav1.StatusReasonInternalError, message: err.Error()}
+}
+
+// authenticate returns the caller of the API call, or a 401 error if the call is not authenticated
+func (a *api) authenticate(r *http.Request) (*helpers.APICaller, error) {
+	caller, err := a.authenticateCaller(r)
+	if err != nil {
+		log.Errorf("Error in authentication: %v", err)
+		return nil, newAPIError(http.StatusInternalServerError, metav1.StatusReasonInternalError, "error in authentication")
+	}
+	if caller == nil {
+		return nil, newAPIError(http.StatusUnauthorized, metav1.StatusReasonUnauthorized, "Unauthorized")
+	}
+	return caller, nil
+}
+
+// authorize returns a 403 error if the caller is not allowed to perform the verb on the resource of the azuregaming API group
+func (a *api) authorize(caller *helpers.APICaller, verb, resource, subresource, namespace, name string) error {
+	return a.authorizeAttributes(caller, helpers.NewResourceAttributes(verb, resource, subresource, namespace, name))
+}
+
+// authorizeAttributes returns a 403 error if the caller is not allowed to perform the action of the attributes
+func (a *api) authorizeAttributes(caller *helpers.APICaller, attributes authorizationv1.ResourceAttributes) error {
+	allowed, err := a.authorizeCaller(caller, attributes)
+	if err != nil {
+		log.Errorf("Error in authorization: %v", err)
+		return newAPIError(http.StatusInternalServerError, metav1.StatusReasonInternalError, "error in authorization")
+	}
+	if !allowed {
+		return newAPIError(http.StatusForbidden, metav1.StatusReasonForbidden, "%s %s is not allowed for %s", attributes.Verb, attributes.Resource, caller.User.Username)
+	}
+	return nil
+}
+
+// authenticateAndAuthorize authenticates the API call and authorizes the verb on the resource of the azuregaming API group
+func (a *api) authenticateAndAuthorize(r *http.Request, verb, resource, subresource, namespace, name string) error {
+	caller, err := a.authenticate(r)
+	if err != nil {
+		return err
+	}
+	return a.authorize(caller, verb, resource, subresource, namespace, name)
+}
+
+// authorizeDGSStatusUpdate authorizes the update of the status of a DedicatedGameServer
+// The DedicatedGameServers authenticate with their token and can only update their own status,
+// the other callers need to be authorized to update the status subresource
+func (a *api) authorizeDGSStatusUpdate(r *http.Request, namespace, name string) error {
+	identity, err := a.authenticateDGS(r)
+	if err != nil {
+		log.Errorf("Error in authentication: %v", err)
+		return newAPIError(http.StatusInternalServerError, metav1.StatusReasonInternalError, "error in authentication")
+	}
+	if identity != nil {
+		if identity.Namespace != namespace || identity.Name != name {
+			return newAPIError(http.StatusForbidden, metav1.StatusReasonForbidden, "the token of DedicatedGameServer %s/%s cannot update DedicatedGameServer %s/%s",
+				identity.Namespace, identity.Name, namespace, name)
+		}
+		return nil
+	}
+	return a.authenticateAndAuthorize(r, "update", "dedicatedgameservers", "status", namespace, name)
+}
+
+// authorizeDGSRead authorizes reading the DedicatedGameServers, if the API Server requires authentication for it
+func (a *api) authorizeDGSRead(r *http.Request, verb, namespace, name string) error {
+	if !a.listRunningRequiresAuth {
+		return nil
+	}
+	return a.authenticateAndAuthorize(r, verb, "dedicatedgameservers", "", namespace, name)
+}
+
+// authorizeAccessCodeStatus authorizes reading the status of the access codes
+// The status describes the apiaccesscode Secret, so the caller needs to be allowed to read it
+func (a *api) authorizeAccessCodeStatus(r *http.Request) error {
+	caller, err := a.authenticate(r)
+	if err != nil {
+		return err
+	}
+	return a.authorizeAttributes(caller, authorizationv1.ResourceAttributes{
+		Verb:      "get",
+		Version:   "v1",
+		Resource:  "secrets",
+		Namespace: shared.DefaultGameNamespace(),
+		Name:      shared.APIAccessCodeSecretName,
+	})
+}
+
+func (a *api) getAccessCodeStatus() (*shared.AccessCodeStatus, error) {
+	store := shared.GetAccessCodeStore()
+	if store == nil {
+		return nil, newAPIError(http.StatusInternalServerError, metav1.StatusReasonInternalError, "the access code store has not been set")
+	}
+	status := store.Status()
+	return &status, nil
+}
+
+// allocate allocates a DedicatedGameServer and returns the DedicatedGameServerAllocation with its status
+// The lack of capacity is an expected outcome for the caller, so it is not an error but an UnAllocated status
+func (a *api) allocate(dgsAlloc *dgsv1alpha1.DedicatedGameServerAllocation) (*dgsv1alpha1.DedicatedGameServerAllocation, error) {
+	dgs, err := shared.AllocateDedicatedGameServer(a.dgsClient, dgsAlloc)
+	if err == shared.ErrNoCapacity {
+		dgsAlloc.Status = dgsv1alpha1.DedicatedGameServerAllocationStatus{State: dgsv1alpha1.DGSAllocationUnAllocated}
+	} else if err != nil {
+		log.Errorf("Error allocating DedicatedGameServer: %v", err)
+		return nil, err
+	} else {
+		dgsAlloc.Status = shared.NewAllocatedStatus(dgs)
+	}
+	return dgsAlloc, nil
+}
+
+func (a *api) listDGSs(namespace string, readyOnly bool) ([]dgsv1alpha1.DedicatedGameServer, error) {
+	return shared.ListDedicatedGameServers(a.dgsClient, namespace, readyOnly)
+}
+
+func (a *api) getDGS(namespace, name string) (*dgsv1alpha1.DedicatedGameServer, error) {
+	return a.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Get(name, metav1.GetOptions{})
+}
+
+// updateDGSStatus validates and sets the non-nil fields on the Status of the DedicatedGameServer
+func (a *api) updateDGSStatus(namespace, name string, fields shared.DGSStatusFields) (*dgsv1alpha1.DedicatedGameServer, error) {
+	if err := validateDGSStatusFields(fields); err != nil {
+		return nil, err
+	}
+	return shared.UpdateDedicatedGameServerStatus(a.dgsClient, namespace, name, fields)
+}
+
+// validateDGSStatusFields returns a 400 error if a field has a value that a DedicatedGameServer cannot report
+func validateDGSStatusFields(fields shared.DGSStatusFields) error {
+	if fields.DGSHealth != nil {
+		health := *fields.DGSHealth
+		if health != dgsv1alpha1.DGSCreating && health != dgsv1alpha1.DGSHealthy && health != dgsv1alpha1.DGSFailed {
+			return newBadRequestError("wrong value for health: %s", health)
+		}
+	}
+	if fields.DGSState != nil {
+		state := *fields.DGSState
+		if state != dgsv1alpha1.DGSIdle && state != dgsv1alpha1.DGSAssigned && state != dgsv1alpha1.DGSRunning && state != dgsv1alpha1.DGSPostMatch {
+			return newBadRequestError("wrong value for dgsState: %s", state)
+		}
+	}
+	if fields.ActivePlayers != nil && *fields.ActivePlayers < 0 {
+		return newBadRequestError("wrong value for activePlayers: %d", *fields.ActivePlayers)
+	}
+	return nil
+}
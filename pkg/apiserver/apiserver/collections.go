@@ -0,0 +1,194 @@
+package apiserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+var dgsColResource = dgsv1alpha1.Resource("dedicatedgameservercollections")
+
+// newCollection returns the DedicatedGameServerCollection to create in the namespace for the one of the request
+// It keeps the whole spec, the labels and the annotations of the request, the other fields are set by Kubernetes and the controller
+func newCollection(namespace string, requested *dgsv1alpha1.DedicatedGameServerCollection) *dgsv1alpha1.DedicatedGameServerCollection {
+	dgsCol := shared.NewDedicatedGameServerCollection(requested.Name, namespace, requested.Spec.Replicas, requested.Spec.Template)
+	setCollectionFields(dgsCol, requested)
+	return dgsCol
+}
+
+// setCollectionFields sets the fields that the callers can change on the DedicatedGameServerCollection:
+// the spec, the labels and the annotations
+func setCollectionFields(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, requested *dgsv1alpha1.DedicatedGameServerCollection) {
+	dgsCol.Spec = requested.Spec
+	dgsCol.Labels = requested.Labels
+	dgsCol.Annotations = requested.Annotations
+}
+
+// validateCollection returns a 422 error if the DedicatedGameServerCollection is not valid
+func validateCollection(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
+	if err := shared.ValidateDedicatedGameServerCollection(dgsCol); err != nil {
+		return newAPIError(http.StatusUnprocessableEntity, metav1.StatusReasonInvalid, "DedicatedGameServerCollection %s is invalid: %s", dgsCol.Name, err.Error())
+	}
+	return nil
+}
+
+// createCollection validates and creates the DedicatedGameServerCollection of the request in the namespace
+// On a dry run, the DedicatedGameServerCollection that would be created is returned without being created
+func (a *api) createCollection(namespace string, requested *dgsv1alpha1.DedicatedGameServerCollection, dryRun bool) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	dgsCol := newCollection(namespace, requested)
+	if err := validateCollection(dgsCol); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		_, err := a.getCollection(namespace, dgsCol.Name)
+		if err == nil {
+			return nil, k8serrors.NewAlreadyExists(dgsColResource, dgsCol.Name)
+		} else if !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+		return dgsCol, nil
+	}
+
+	log.Printf("Creating DedicatedGameServerCollection %s", dgsCol.Name)
+	return a.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Create(dgsCol)
+}
+
+func (a *api) getCollection(namespace, name string) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	return a.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (a *api) listCollections(namespace string) (*dgsv1alpha1.DedicatedGameServerCollectionList, error) {
+	return a.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).List(metav1.ListOptions{})
+}
+
+// updateCollection replaces the spec, the labels and the annotations of the DedicatedGameServerCollection with the ones of the request
+// If the request has a resourceVersion, the update fails with a conflict if the DedicatedGameServerCollection has been modified since then
+func (a *api) updateCollection(namespace string, requested *dgsv1alpha1.DedicatedGameServerCollection, dryRun bool) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	return a.modifyCollection(namespace, requested.Name, dryRun, func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
+		if requested.ResourceVersion != "" && requested.ResourceVersion != dgsCol.ResourceVersion {
+			// this is not a Kubernetes error, so that it is not retried
+			return newAPIError(http.StatusConflict, metav1.StatusReasonConflict,
+				"DedicatedGameServerCollection %s has been modified, resourceVersion %s is not the latest one", requested.Name, requested.ResourceVersion)
+		}
+		setCollectionFields(dgsCol, requested)
+		return nil
+	})
+}
+
+// patchCollection applies the JSON merge patch to the spec, the labels and the annotations of the DedicatedGameServerCollection
+// The changes of the other fields are ignored
+func (a *api) patchCollection(namespace, name string, patch []byte, dryRun bool) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	return a.modifyCollection(namespace, name, dryRun, func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
+		original, err := json.Marshal(dgsCol)
+		if err != nil {
+			return err
+		}
+		patched, err := jsonMergePatch(original, patch)
+		if err != nil {
+			return newBadRequestError("invalid patch: %s", err.Error())
+		}
+		var requested dgsv1alpha1.DedicatedGameServerCollection
+		if err := json.Unmarshal(patched, &requested); err != nil {
+			return newBadRequestError("invalid patch: %s", err.Error())
+		}
+		setCollectionFields(dgsCol, &requested)
+		return nil
+	})
+}
+
+// modifyCollection gets, modifies, validates and updates the DedicatedGameServerCollection, retrying on conflicts
+// On a dry run, the modified DedicatedGameServerCollection is returned without being updated
+func (a *api) modifyCollection(namespace, name string, dryRun bool, modify func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	var dgsColToReturn *dgsv1alpha1.DedicatedGameServerCollection
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		dgsCol, err := a.getCollection(namespace, name)
+		if err != nil {
+			return err
+		}
+		if err := modify(dgsCol); err != nil {
+			return err
+		}
+		if err := validateCollection(dgsCol); err != nil {
+			return err
+		}
+
+		if dryRun {
+			dgsColToReturn = dgsCol
+			return nil
+		}
+		dgsColToReturn, err = a.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgsCol)
+		return err
+	})
+	if retryErr != nil {
+		return nil, retryErr
+	}
+	return dgsColToReturn, nil
+}
+
+func (a *api) deleteCollection(namespace, name string) error {
+	return a.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Delete(name, nil)
+}
+
+func (a *api) getCollectionRevisions(namespace, name string) ([]shared.DedicatedGameServerCollectionRevision, error) {
+	return shared.GetDedicatedGameServerCollectionRevisions(a.client, a.dgsClient, namespace, name)
+}
+
+// rollbackCollection rolls back the Template of the DedicatedGameServerCollection to the revision,
+// or to the previous revision if it is 0. It returns a 404 error if the revision does not exist
+func (a *api) rollbackCollection(namespace, name string, revision int64) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	dgsCol, err := shared.RollbackDedicatedGameServerCollection(a.client, a.dgsClient, namespace, name, revision)
+	if err == shared.ErrRevisionNotFound {
+		return nil, newAPIError(http.StatusNotFound, metav1.StatusReasonNotFound, "revision %d of DedicatedGameServerCollection %s was not found", revision, name)
+	}
+	return dgsCol, err
+}
+
+// jsonMergePatch applies the JSON merge patch (RFC 7386) to the JSON document
+func jsonMergePatch(document, patch []byte) ([]byte, error) {
+	var documentValue, patchValue interface{}
+	if err := unmarshalUseNumber(document, &documentValue); err != nil {
+		return nil, err
+	}
+	if err := unmarshalUseNumber(patch, &patchValue); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatchValue(documentValue, patchValue))
+}
+
+// mergePatchValue merges the objects of the patch into the target, the null values remove the fields
+// and the other values replace them
+func mergePatchValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatchValue(targetObject[key], value)
+	}
+	return targetObject
+}
+
+// unmarshalUseNumber unmarshals the JSON data without converting the numbers to float64, so that they keep their precision
+func unmarshalUseNumber(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
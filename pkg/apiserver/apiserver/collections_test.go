@@ -0,0 +1,258 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// newTestDGSCol returns a valid DedicatedGameServerCollection with most of the optional fields of the spec set
+func newTestDGSCol(name string) *dgsv1alpha1.DedicatedGameServerCollection {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("64Mi"),
+	}
+	return &dgsv1alpha1.DedicatedGameServerCollection{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{"game": "racing"},
+			Annotations: map[string]string{"owner": "team1"},
+		},
+		Spec: dgsv1alpha1.DedicatedGameServerCollectionSpec{
+			Replicas:      3,
+			PortsToExpose: []intstr.IntOrString{intstr.FromString("game")},
+			Template: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:      "game",
+						Image:     "game:1.0",
+						Ports:     []corev1.ContainerPort{{Name: "game", ContainerPort: 7777, Protocol: corev1.ProtocolUDP}},
+						Resources: corev1.ResourceRequirements{Requests: resources, Limits: resources},
+					},
+				},
+			},
+			DGSFailBehavior: dgsv1alpha1.Remove,
+			DGSMaxFailures:  5,
+			DGSActivePlayersAutoScalerDetails: &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{
+				Enabled:             true,
+				MinimumReplicas:     1,
+				MaximumReplicas:     10,
+				ScaleInThreshold:    30,
+				ScaleOutThreshold:   80,
+				CoolDownInMinutes:   5,
+				MaxPlayersPerServer: 10,
+			},
+			SchedulingStrategy: dgsv1alpha1.DistributedSchedulingStrategy,
+			AddressTemplate:    "{{.PublicIP}}:{{index .Ports \"game\"}}",
+		},
+	}
+}
+
+func marshalOrFail(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestCreateCollectionKeepsSpecAndMetadata(t *testing.T) {
+	a, dgsClient := newTestAPI()
+	requested := newTestDGSCol("col1")
+
+	w := serve(a, "POST", "/api/v2/namespaces/default/collections", "Bearer allowed", marshalOrFail(t, requested))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created dgsv1alpha1.DedicatedGameServerCollection
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	stored, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections("default").Get("col1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	for _, dgsCol := range []*dgsv1alpha1.DedicatedGameServerCollection{&created, stored} {
+		assert.Equal(t, "default", dgsCol.Namespace)
+		assert.Equal(t, requested.Labels, dgsCol.Labels)
+		assert.Equal(t, requested.Annotations, dgsCol.Annotations)
+		assert.Equal(t, requested.Spec, dgsCol.Spec)
+		// the status is set by the controller
+		assert.Equal(t, dgsv1alpha1.DGSColCreating, dgsCol.Status.DGSCollectionHealth)
+	}
+}
+
+func TestCreateCollectionDryRun(t *testing.T) {
+	a, dgsClient := newTestAPI()
+
+	w := serve(a, "POST", "/api/v2/namespaces/default/collections?dryRun=All", "Bearer allowed", marshalOrFail(t, newTestDGSCol("col1")))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var dgsCol dgsv1alpha1.DedicatedGameServerCollection
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgsCol))
+	assert.Equal(t, int32(3), dgsCol.Spec.Replicas)
+	assert.Equal(t, dgsv1alpha1.Remove, dgsCol.Spec.DGSFailBehavior)
+
+	// nothing was created
+	for _, action := range dgsClient.Actions() {
+		assert.NotEqual(t, "create", action.GetVerb())
+	}
+	_, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections("default").Get("col1", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	invalid := newTestDGSCol("col1")
+	invalid.Spec.PortsToExpose = []intstr.IntOrString{intstr.FromString("missing")}
+	w = serve(a, "POST", "/api/v2/namespaces/default/collections?dryRun=All", "Bearer allowed", marshalOrFail(t, invalid))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	status := decodeStatus(t, w)
+	assert.Equal(t, metav1.StatusReasonInvalid, status.Reason)
+	assert.Contains(t, status.Message, "Invalid portsToExpose")
+
+	// a dry run detects the existing collections
+	w = serve(a, "POST", "/api/v2/namespaces/default/collections", "Bearer allowed", marshalOrFail(t, newTestDGSCol("col1")))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = serve(a, "POST", "/api/v2/namespaces/default/collections?dryRun=All", "Bearer allowed", marshalOrFail(t, newTestDGSCol("col1")))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, metav1.StatusReasonAlreadyExists, decodeStatus(t, w).Reason)
+
+	w = serve(a, "POST", "/api/v2/namespaces/default/collections?dryRun=maybe", "Bearer allowed", marshalOrFail(t, newTestDGSCol("col2")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUpdateCollection(t *testing.T) {
+	existing := newTestDGSCol("col1")
+	existing.Namespace = "default"
+	existing.ResourceVersion = "1"
+	existing.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	a, dgsClient := newTestAPI(existing)
+
+	requested := newTestDGSCol("")
+	requested.Labels = map[string]string{"game": "puzzle"}
+	requested.Spec.Replicas = 7
+	requested.Spec.DGSActivePlayersAutoScalerDetails = nil
+	requested.Status.DGSCollectionHealth = dgsv1alpha1.DGSColFailed
+
+	w := serve(a, "PUT", "/api/v2/namespaces/default/collections/col1?dryRun=All", "Bearer allowed", marshalOrFail(t, requested))
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections("default").Get("col1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), stored.Spec.Replicas)
+
+	w = serve(a, "PUT", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", marshalOrFail(t, requested))
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, err = dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections("default").Get("col1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, requested.Spec, stored.Spec)
+	assert.Equal(t, requested.Labels, stored.Labels)
+	// the status cannot be updated
+	assert.Equal(t, dgsv1alpha1.DGSColHealthy, stored.Status.DGSCollectionHealth)
+
+	requested.ResourceVersion = "0"
+	w = serve(a, "PUT", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", marshalOrFail(t, requested))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, metav1.StatusReasonConflict, decodeStatus(t, w).Reason)
+
+	w = serve(a, "PUT", "/api/v2/namespaces/default/collections/col2", "Bearer allowed", marshalOrFail(t, newTestDGSCol("")))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(a, "PUT", "/api/v2/namespaces/default/collections/col1", "Bearer denied", marshalOrFail(t, newTestDGSCol("")))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestPatchCollection(t *testing.T) {
+	existing := newTestDGSCol("col1")
+	existing.Namespace = "default"
+	existing.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	a, dgsClient := newTestAPI(existing)
+
+	// null removes a field, and the changes of the status and of the name are ignored
+	patch := `{"metadata":{"name":"col2","annotations":null},"spec":{"replicas":5,"dgsActivePlayersAutoScalerDetails":{"maximumReplicas":20}},"status":{"dgsHealth":"Failed"}}`
+	w := serve(a, "PATCH", "/api/v2/namespaces/default/collections/col1?dryRun=All", "Bearer allowed", patch)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var dgsCol dgsv1alpha1.DedicatedGameServerCollection
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgsCol))
+	assert.Equal(t, int32(5), dgsCol.Spec.Replicas)
+	stored, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections("default").Get("col1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), stored.Spec.Replicas)
+
+	w = serve(a, "PATCH", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", patch)
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, err = dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections("default").Get("col1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "col1", stored.Name)
+	assert.Equal(t, int32(5), stored.Spec.Replicas)
+	assert.Nil(t, stored.Annotations)
+	assert.Equal(t, existing.Labels, stored.Labels)
+	assert.Equal(t, 20, stored.Spec.DGSActivePlayersAutoScalerDetails.MaximumReplicas)
+	assert.Equal(t, 1, stored.Spec.DGSActivePlayersAutoScalerDetails.MinimumReplicas)
+	assert.Equal(t, existing.Spec.Template, stored.Spec.Template)
+	assert.Equal(t, dgsv1alpha1.DGSColHealthy, stored.Status.DGSCollectionHealth)
+
+	w = serve(a, "PATCH", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", `{"spec":{"replicas":-1}}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = serve(a, "PATCH", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", `{"spec":{"replicas":"many"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestV1CreateKeepsSpecAndMetadata(t *testing.T) {
+	a, dgsClient := newTestAPI()
+	requested := newTestDGSCol("col1")
+
+	w := serve(a, "POST", "/create?dryRun=true", "Bearer allowed", marshalOrFail(t, requested))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var validated dgsv1alpha1.DedicatedGameServerCollection
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &validated))
+	assert.Equal(t, "col1", validated.Name)
+	_, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections("default").Get("col1", metav1.GetOptions{})
+	assert.Error(t, err)
+
+	w = serve(a, "POST", "/create", "Bearer allowed", marshalOrFail(t, requested))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var created dgsv1alpha1.DedicatedGameServerCollection
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	stored, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections("default").Get("col1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	for _, dgsCol := range []*dgsv1alpha1.DedicatedGameServerCollection{&created, stored} {
+		assert.Equal(t, requested.Spec, dgsCol.Spec)
+		assert.Equal(t, requested.Labels, dgsCol.Labels)
+	}
+
+	// errors are returned with their status code, e.g. the collection exists or fails validation
+	w = serve(a, "POST", "/create", "Bearer allowed", marshalOrFail(t, requested))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	invalid := newTestDGSCol("col2")
+	invalid.Spec.Replicas = -1
+	w = serve(a, "POST", "/create", "Bearer allowed", marshalOrFail(t, invalid))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
+
+func TestJSONMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		patch    string
+		expected string
+	}{
+		{name: "merge objects", document: `{"a":{"b":1,"c":2}}`, patch: `{"a":{"b":3}}`, expected: `{"a":{"b":3,"c":2}}`},
+		{name: "remove field", document: `{"a":1,"b":2}`, patch: `{"a":null}`, expected: `{"b":2}`},
+		{name: "replace array", document: `{"a":[1,2,3]}`, patch: `{"a":[4]}`, expected: `{"a":[4]}`},
+		{name: "add object", document: `{"a":1}`, patch: `{"b":{"c":null,"d":1}}`, expected: `{"a":1,"b":{"d":1}}`},
+		{name: "keep big numbers", document: `{"a":9007199254740993}`, patch: `{}`, expected: `{"a":9007199254740993}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched, err := jsonMergePatch([]byte(tt.document), []byte(tt.patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(patched))
+		})
+	}
+
+	_, err := jsonMergePatch([]byte(`{}`), []byte(`{`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,325 @@
+package apiserver
+
+// openAPIDocument is the OpenAPI document of the v2 API, which is served at /api/v2/openapi.json
+// Every route of v2Routes has to be described here
+const openAPIDocument = `{
+  "openapi": "3.0.0",
+  "info": {
+    "title": "Azure Gaming API Server",
+    "description": "Manages the DedicatedGameServerCollections and the DedicatedGameServers. Errors are returned as a Kubernetes Status.",
+    "version": "v2"
+  },
+  "servers": [
+    {"url": "/api/v2"}
+  ],
+  "security": [
+    {"bearer": []},
+    {"accessCode": []}
+  ],
+  "paths": {
+    "/openapi.json": {
+      "get": {
+        "summary": "Returns this OpenAPI document",
+        "security": [],
+        "responses": {
+          "200": {"description": "The OpenAPI document", "content": {"application/json": {"schema": {"type": "object"}}}}
+        }
+      }
+    },
+    "/namespaces/{namespace}/collections": {
+      "parameters": [{"$ref": "#/components/parameters/namespace"}],
+      "get": {
+        "summary": "Lists the DedicatedGameServerCollections of the namespace",
+        "responses": {
+          "200": {"description": "The DedicatedGameServerCollections", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollectionList"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Creates a DedicatedGameServerCollection with its complete spec, labels and annotations",
+        "parameters": [{"$ref": "#/components/parameters/dryRun"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+        "responses": {
+          "201": {"description": "The created DedicatedGameServerCollection", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/namespaces/{namespace}/collections/{name}": {
+      "parameters": [{"$ref": "#/components/parameters/namespace"}, {"$ref": "#/components/parameters/name"}],
+      "get": {
+        "summary": "Returns a DedicatedGameServerCollection",
+        "responses": {
+          "200": {"description": "The DedicatedGameServerCollection", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "post": {
+        "summary": "Creates a DedicatedGameServerCollection with the name of the path",
+        "parameters": [{"$ref": "#/components/parameters/dryRun"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+        "responses": {
+          "201": {"description": "The created DedicatedGameServerCollection", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "put": {
+        "summary": "Replaces the spec, the labels and the annotations of a DedicatedGameServerCollection",
+        "description": "If the body has a metadata.resourceVersion, the update fails with 409 if the DedicatedGameServerCollection has been modified since then",
+        "parameters": [{"$ref": "#/components/parameters/dryRun"}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+        "responses": {
+          "200": {"description": "The updated DedicatedGameServerCollection", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Applies a JSON merge patch to the spec, the labels and the annotations of a DedicatedGameServerCollection",
+        "parameters": [{"$ref": "#/components/parameters/dryRun"}],
+        "requestBody": {"required": true, "content": {"application/merge-patch+json": {"schema": {"type": "object"}}, "application/json": {"schema": {"type": "object"}}}},
+        "responses": {
+          "200": {"description": "The patched DedicatedGameServerCollection", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "delete": {
+        "summary": "Deletes a DedicatedGameServerCollection and its DedicatedGameServers",
+        "responses": {
+          "204": {"description": "The DedicatedGameServerCollection was deleted"},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/namespaces/{namespace}/collections/{name}/revisions": {
+      "parameters": [{"$ref": "#/components/parameters/namespace"}, {"$ref": "#/components/parameters/name"}],
+      "get": {
+        "summary": "Lists the revisions of the Template of a DedicatedGameServerCollection",
+        "responses": {
+          "200": {"description": "The revisions", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/RevisionList"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/namespaces/{namespace}/collections/{name}/rollback": {
+      "parameters": [{"$ref": "#/components/parameters/namespace"}, {"$ref": "#/components/parameters/name"}],
+      "post": {
+        "summary": "Rolls back the Template of a DedicatedGameServerCollection to a revision, or to the previous one",
+        "requestBody": {"required": false, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Rollback"}}}},
+        "responses": {
+          "200": {"description": "The rolled back DedicatedGameServerCollection", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/namespaces/{namespace}/allocations": {
+      "parameters": [{"$ref": "#/components/parameters/namespace"}],
+      "post": {
+        "summary": "Allocates an Idle DedicatedGameServer",
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerAllocation"}}}},
+        "responses": {
+          "201": {"description": "A DedicatedGameServer was allocated", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerAllocation"}}}},
+          "200": {"description": "No DedicatedGameServer was available, the state is UnAllocated", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerAllocation"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/dgs": {
+      "get": {
+        "summary": "Lists the DedicatedGameServers of all the game namespaces",
+        "description": "Requires authentication only if the API Server runs with -listingauth",
+        "parameters": [{"$ref": "#/components/parameters/ready"}],
+        "responses": {
+          "200": {"description": "The DedicatedGameServers", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerList"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/namespaces/{namespace}/dgs": {
+      "parameters": [{"$ref": "#/components/parameters/namespace"}],
+      "get": {
+        "summary": "Lists the DedicatedGameServers of the namespace",
+        "description": "Requires authentication only if the API Server runs with -listingauth",
+        "parameters": [{"$ref": "#/components/parameters/ready"}],
+        "responses": {
+          "200": {"description": "The DedicatedGameServers", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerList"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/namespaces/{namespace}/dgs/{name}": {
+      "parameters": [{"$ref": "#/components/parameters/namespace"}, {"$ref": "#/components/parameters/name"}],
+      "get": {
+        "summary": "Returns a DedicatedGameServer",
+        "description": "Requires authentication only if the API Server runs with -listingauth",
+        "responses": {
+          "200": {"description": "The DedicatedGameServer", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServer"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      },
+      "patch": {
+        "summary": "Sets the status fields that a DedicatedGameServer reports",
+        "description": "A DedicatedGameServer can update its own status with the token of its API_SERVER_TOKEN environment variable, in the Authorization: Bearer header",
+        "security": [{"dgsToken": []}, {"bearer": []}, {"accessCode": []}],
+        "requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServerPatch"}}}},
+        "responses": {
+          "200": {"description": "The updated DedicatedGameServer", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DedicatedGameServer"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/accesscode/status": {
+      "get": {
+        "summary": "Returns the rotation status of the access codes, without the codes",
+        "responses": {
+          "200": {"description": "The status of the access codes", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/AccessCodeStatus"}}}},
+          "default": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearer": {"type": "http", "scheme": "bearer", "description": "A Kubernetes token, the caller is authorized with a SubjectAccessReview"},
+      "accessCode": {"type": "apiKey", "in": "query", "name": "code", "description": "The code of the apiaccesscode Secret, which is allowed to call every method"},
+      "dgsToken": {"type": "http", "scheme": "bearer", "description": "The token of a DedicatedGameServer"}
+    },
+    "parameters": {
+      "namespace": {"name": "namespace", "in": "path", "required": true, "description": "A game namespace", "schema": {"type": "string"}},
+      "name": {"name": "name", "in": "path", "required": true, "schema": {"type": "string"}},
+      "dryRun": {"name": "dryRun", "in": "query", "required": false, "description": "All (or true) to validate the request and return the resulting object without persisting it", "schema": {"type": "string", "enum": ["All", "true"]}},
+      "ready": {"name": "ready", "in": "query", "required": false, "description": "If true, only the DedicatedGameServers that are running, healthy and not marked for deletion are returned", "schema": {"type": "boolean"}}
+    },
+    "responses": {
+      "Error": {"description": "The error, e.g. 422 if the DedicatedGameServerCollection is invalid", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Status"}}}}
+    },
+    "schemas": {
+      "Status": {
+        "type": "object",
+        "properties": {
+          "kind": {"type": "string", "enum": ["Status"]},
+          "apiVersion": {"type": "string"},
+          "status": {"type": "string", "enum": ["Failure"]},
+          "message": {"type": "string"},
+          "reason": {"type": "string", "description": "A Kubernetes StatusReason, e.g. NotFound or Forbidden"},
+          "code": {"type": "integer"}
+        }
+      },
+      "ObjectMeta": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "namespace": {"type": "string"},
+          "labels": {"type": "object", "additionalProperties": {"type": "string"}},
+          "annotations": {"type": "object", "additionalProperties": {"type": "string"}}
+        },
+        "additionalProperties": true
+      },
+      "DedicatedGameServerCollection": {
+        "type": "object",
+        "properties": {
+          "metadata": {"$ref": "#/components/schemas/ObjectMeta"},
+          "spec": {
+            "type": "object",
+            "properties": {
+              "replicas": {"type": "integer", "format": "int32"},
+              "template": {"type": "object", "description": "A Kubernetes PodSpec"}
+            },
+            "additionalProperties": true
+          },
+          "status": {"type": "object", "additionalProperties": true}
+        }
+      },
+      "DedicatedGameServerCollectionList": {
+        "type": "object",
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/DedicatedGameServerCollection"}}
+        }
+      },
+      "DedicatedGameServer": {
+        "type": "object",
+        "properties": {
+          "metadata": {"$ref": "#/components/schemas/ObjectMeta"},
+          "spec": {"type": "object", "additionalProperties": true},
+          "status": {
+            "type": "object",
+            "properties": {
+              "podPhase": {"type": "string"},
+              "health": {"type": "string", "enum": ["Creating", "Healthy", "Failed"]},
+              "dgsState": {"type": "string", "enum": ["Idle", "Assigned", "Running", "PostMatch"]},
+              "markedForDeletion": {"type": "boolean"},
+              "publicIP": {"type": "string"},
+              "nodeName": {"type": "string"},
+              "activePlayers": {"type": "integer"},
+              "address": {"type": "string"}
+            },
+            "additionalProperties": true
+          }
+        }
+      },
+      "DedicatedGameServerList": {
+        "type": "object",
+        "properties": {
+          "items": {"type": "array", "items": {"$ref": "#/components/schemas/DedicatedGameServer"}}
+        }
+      },
+      "DedicatedGameServerPatch": {
+        "type": "object",
+        "properties": {
+          "status": {
+            "type": "object",
+            "description": "Only the given fields are set",
+            "properties": {
+              "health": {"type": "string", "enum": ["Creating", "Healthy", "Failed"]},
+              "dgsState": {"type": "string", "enum": ["Idle", "Assigned", "Running", "PostMatch"]},
+              "activePlayers": {"type": "integer", "minimum": 0},
+              "markedForDeletion": {"type": "boolean"}
+            },
+            "additionalProperties": false
+          }
+        },
+        "additionalProperties": false
+      },
+      "DedicatedGameServerAllocation": {
+        "type": "object",
+        "properties": {
+          "metadata": {"$ref": "#/components/schemas/ObjectMeta"},
+          "spec": {"type": "object", "additionalProperties": true},
+          "status": {"type": "object", "additionalProperties": true}
+        }
+      },
+      "RevisionList": {
+        "type": "object",
+        "properties": {
+          "items": {
+            "type": "array",
+            "items": {
+              "type": "object",
+              "properties": {
+                "revision": {"type": "integer", "format": "int64"},
+                "templateHash": {"type": "string"},
+                "current": {"type": "boolean"},
+                "creationTimestamp": {"type": "string", "format": "date-time"}
+              }
+            }
+          }
+        }
+      },
+      "Rollback": {
+        "type": "object",
+        "properties": {
+          "revision": {"type": "integer", "format": "int64", "minimum": 0, "description": "The revision, 0 or missing for the previous one"}
+        }
+      },
+      "AccessCodeStatus": {
+        "type": "object",
+        "properties": {
+          "secretFound": {"type": "boolean"},
+          "lastRotationTime": {"type": "string", "format": "date-time"},
+          "validCodes": {"type": "integer"},
+          "overlapEndTime": {"type": "string", "format": "date-time"}
+        }
+      }
+    }
+  }
+}
+`
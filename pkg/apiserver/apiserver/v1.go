@@ -0,0 +1,519 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	helpers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apiserver/helpers"
+	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/gorilla/mux"
+)
+
+// registerV1Routes registers the routes of the v1 API, which adapt the api methods to the plain text responses of the first API version
+func (a *api) registerV1Routes(router *mux.Router) {
+	router.HandleFunc("/create", a.createDGSColHandler).Methods("POST")
+	router.HandleFunc("/delete", a.deleteDGSColHandler).Queries("name", "{name}").Methods("GET")
+	router.HandleFunc("/allocate", a.allocateDGSHandler).Methods("POST")
+	router.HandleFunc("/revisions", a.getDGSColRevisionsHandler).Queries("name", "{name}").Methods("GET")
+	router.HandleFunc("/rollback", a.rollbackDGSColHandler).Queries("name", "{name}").Methods("POST")
+	router.HandleFunc("/accesscodestatus", a.getAccessCodeStatusHandler).Methods("GET")
+	router.HandleFunc("/running", a.getPodPhaseRunningDGSHandler).Methods("GET")
+
+	// Dedicated Game Server API methods
+	router.HandleFunc("/setactiveplayers", a.setActivePlayersHandler).Methods("POST")
+	router.HandleFunc("/setdgsstate", a.setServerStateHandler).Methods("POST")
+	router.HandleFunc("/setsdgshealth", a.setServerHealthHandler).Methods("POST")
+	router.HandleFunc("/setdgsmarkedfordeletion", a.setServerMarkedForDeletionHandler).Methods("POST")
+}
+
+func (a *api) createDGSColHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("createcollection was called")
+
+	caller, ok := a.authenticateV1(w, r)
+	if !ok {
+		return
+	}
+
+	var dgsCol dgsv1alpha1.DedicatedGameServerCollection
+	err := json.NewDecoder(r.Body).Decode(&dgsCol)
+
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Incorrect arguments: " + err.Error()))
+		return
+	}
+
+	namespace, err := helpers.GetRequestNamespace(r, dgsCol.Namespace)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Incorrect arguments: " + err.Error()))
+		return
+	}
+
+	dryRun, err := helpers.GetDryRun(r)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Incorrect arguments: " + err.Error()))
+		return
+	}
+
+	if !a.authorizeV1(w, caller, "create", "dedicatedgameservercollections", "", namespace, "") {
+		return
+	}
+
+	// the whole spec and metadata are kept, and on a dry run the collection is only validated
+	created, err := a.createCollection(namespace, &dgsCol, dryRun)
+
+	if err != nil {
+		log.Printf("error encountered: %s", err.Error())
+		w.WriteHeader(toAPIError(err).code)
+		w.Write([]byte(fmt.Sprintf("Error %s encountered", err.Error())))
+		return
+	}
+
+	// on a dry run, this is the collection that would have been created
+	response, err := json.Marshal(created)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error in marshaling to JSON: " + err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+func (a *api) deleteDGSColHandler(w http.ResponseWriter, r *http.Request) {
+
+	caller, ok := a.authenticateV1(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.FormValue("name")
+
+	namespace, err := helpers.GetRequestNamespace(r, "")
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Incorrect arguments: " + err.Error()))
+		return
+	}
+
+	if !a.authorizeV1(w, caller, "delete", "dedicatedgameservercollections", "", namespace, name) {
+		return
+	}
+
+	err = a.deleteCollection(namespace, name)
+	if err != nil {
+		msg := fmt.Sprintf("Cannot delete DedicatedGameServerCollection due to %s", err.Error())
+		log.Print(msg)
+		w.Write([]byte(msg))
+		return
+	}
+
+	w.Write([]byte(name + " was deleted"))
+}
+
+func (a *api) getPodPhaseRunningDGSHandler(w http.ResponseWriter, r *http.Request) {
+
+	// without a namespace, the DedicatedGameServers of all the game namespaces are returned
+	namespace := r.FormValue("namespace")
+	if namespace != "" && !shared.IsGameNamespace(namespace) {
+		w.WriteHeader(400)
+		w.Write([]byte(fmt.Sprintf("Incorrect arguments: namespace %s is not a game namespace", namespace)))
+		return
+	}
+
+	if err := a.authorizeDGSRead(r, "list", namespace, ""); err != nil {
+		writeV1AuthError(w, err)
+		return
+	}
+
+	entities, err := a.listDGSs(namespace, true)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error in listing DedicatedGameServers: " + err.Error()))
+		return
+	}
+	result, err := json.Marshal(entities)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error in marshaling to JSON: " + err.Error()))
+		return
+	}
+	w.Write(result)
+}
+
+func (a *api) allocateDGSHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := a.authenticateV1(w, r)
+	if !ok {
+		return
+	}
+
+	var dgsAlloc dgsv1alpha1.DedicatedGameServerAllocation
+	err := json.NewDecoder(r.Body).Decode(&dgsAlloc)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Incorrect arguments: " + err.Error()))
+		return
+	}
+
+	dgsAlloc.Namespace, err = helpers.GetRequestNamespace(r, dgsAlloc.Namespace)
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Incorrect arguments: " + err.Error()))
+		return
+	}
+
+	if !a.authorizeV1(w, caller, "create", "dedicatedgameserverallocations", "", dgsAlloc.Namespace, "") {
+		return
+	}
+
+	allocated, err := a.allocate(&dgsAlloc)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error allocating DedicatedGameServer: " + err.Error()))
+		return
+	}
+
+	response, err := json.Marshal(allocated)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error in marshaling to JSON: " + err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+func (a *api) getDGSColRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := a.authenticateV1(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.FormValue("name")
+
+	namespace, err := helpers.GetRequestNamespace(r, "")
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Incorrect arguments: " + err.Error()))
+		return
+	}
+
+	if !a.authorizeV1(w, caller, "get", "dedicatedgameservercollections", "", namespace, name) {
+		return
+	}
+
+	revisions, err := a.getCollectionRevisions(namespace, name)
+	if err != nil {
+		msg := fmt.Sprintf("Cannot get revisions of DedicatedGameServerCollection due to %s", err.Error())
+		log.Print(msg)
+		w.WriteHeader(500)
+		w.Write([]byte(msg))
+		return
+	}
+
+	response, err := json.Marshal(revisions)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error in marshaling to JSON: " + err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+// rollbackDGSColHandler rolls back the Template of a DedicatedGameServerCollection
+// to the revision in the optional 'revision' query parameter, or to the previous revision if it is missing
+func (a *api) rollbackDGSColHandler(w http.ResponseWriter, r *http.Request) {
+	caller, ok := a.authenticateV1(w, r)
+	if !ok {
+		return
+	}
+
+	name := r.FormValue("name")
+
+	var revision int64
+	if value := r.FormValue("revision"); value != "" {
+		var err error
+		revision, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || revision < 0 {
+			w.WriteHeader(400)
+			w.Write([]byte("Wrong value for revision"))
+			return
+		}
+	}
+
+	namespace, err := helpers.GetRequestNamespace(r, "")
+	if err != nil {
+		w.WriteHeader(400)
+		w.Write([]byte("Incorrect arguments: " + err.Error()))
+		return
+	}
+
+	if !a.authorizeV1(w, caller, "update", "dedicatedgameservercollections", "", namespace, name) {
+		return
+	}
+
+	_, err = a.rollbackCollection(namespace, name, revision)
+	if e, ok := err.(*apiError); ok && e.code == http.StatusNotFound {
+		w.WriteHeader(404)
+		w.Write([]byte(fmt.Sprintf("Revision %d of DedicatedGameServerCollection %s was not found", revision, name)))
+		return
+	} else if err != nil {
+		msg := fmt.Sprintf("Cannot rollback DedicatedGameServerCollection due to %s", err.Error())
+		log.Print(msg)
+		w.WriteHeader(500)
+		w.Write([]byte(msg))
+		return
+	}
+
+	w.Write([]byte("DedicatedGameServerCollection " + name + " was rolled back"))
+}
+
+// getAccessCodeStatusHandler returns the rotation status of the access codes, without the codes
+func (a *api) getAccessCodeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.authorizeAccessCodeStatus(r); err != nil {
+		writeV1AuthError(w, err)
+		return
+	}
+
+	status, err := a.getAccessCodeStatus()
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error"))
+		return
+	}
+
+	response, err := json.Marshal(status)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error in marshaling to JSON: " + err.Error()))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
+
+func (a *api) setActivePlayersHandler(w http.ResponseWriter, r *http.Request) {
+	a.setDGSStatusHandler(w, r, func(r io.ReadCloser) (interface{}, error) {
+		var serverActivePlayers helpers.ServerActivePlayers
+		err := json.NewDecoder(r).Decode(&serverActivePlayers)
+
+		//a very simple validation
+		if serverActivePlayers.PlayerCount < 0 {
+			w.WriteHeader(400)
+			w.Write([]byte("Wrong value for activePlayers"))
+			return "", fmt.Errorf("Error in active players, wrong value:%d", serverActivePlayers.PlayerCount)
+		}
+		return serverActivePlayers, err
+	})
+}
+
+func (a *api) setServerStateHandler(w http.ResponseWriter, r *http.Request) {
+	a.setDGSStatusHandler(w, r, func(r io.ReadCloser) (interface{}, error) {
+		var serverState helpers.ServerState
+		err := json.NewDecoder(r).Decode(&serverState)
+
+		//a very simple validation
+		state := dgsv1alpha1.DGSState(serverState.State)
+		if state != dgsv1alpha1.DGSIdle && state != dgsv1alpha1.DGSAssigned && state != dgsv1alpha1.DGSRunning && state != dgsv1alpha1.DGSPostMatch {
+			w.WriteHeader(400)
+			w.Write([]byte("Wrong value for serverState"))
+
+			return "", fmt.Errorf("Error in server state, wrong value:%s", state)
+		}
+		return serverState, err
+	})
+}
+
+func (a *api) setServerHealthHandler(w http.ResponseWriter, r *http.Request) {
+	a.setDGSStatusHandler(w, r, func(r io.ReadCloser) (interface{}, error) {
+		var serverHealth helpers.ServerHealth
+		err := json.NewDecoder(r).Decode(&serverHealth)
+
+		//a very simple validation
+		health := dgsv1alpha1.DGSHealth(serverHealth.Health)
+		if health != dgsv1alpha1.DGSCreating && health != dgsv1alpha1.DGSHealthy && health != dgsv1alpha1.DGSFailed {
+			w.WriteHeader(400)
+			w.Write([]byte("Wrong value for serverHealth"))
+			return "", fmt.Errorf("Error in server status, wrong value:%s", health)
+		}
+		return serverHealth, err
+	})
+}
+
+func (a *api) setServerMarkedForDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	a.setDGSStatusHandler(w, r, func(r io.ReadCloser) (interface{}, error) {
+		var serverMarkedForDeletion helpers.ServerMarkedForDeletion
+		err := json.NewDecoder(r).Decode(&serverMarkedForDeletion)
+		return serverMarkedForDeletion, err
+	})
+}
+
+func (a *api) setDGSStatusHandler(w http.ResponseWriter, r *http.Request, decode func(r io.ReadCloser) (interface{}, error)) {
+	// the DedicatedGameServers authenticate with their token, the other callers need to be authorized to update the status
+	identity, err := a.authenticateDGS(r)
+	if err != nil {
+		log.Errorf("Error in authentication: %v", err)
+		w.WriteHeader(500)
+		w.Write([]byte("Error"))
+		return
+	}
+
+	var caller *helpers.APICaller
+	if identity == nil {
+		var ok bool
+		caller, ok = a.authenticateV1(w, r)
+		if !ok {
+			return
+		}
+	}
+
+	decoded, err := decode(r.Body)
+
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error setting serverMarkedForDeletion: " + err.Error()))
+		log.Errorf("Error setting server marked for deletion:%s", err.Error())
+		return
+	}
+
+	if identity != nil {
+		decoded, err = setDecodedIdentity(decoded, identity)
+		if err != nil {
+			log.Warnf("Rejected DedicatedGameServer status update: %s", err.Error())
+			w.WriteHeader(403)
+			w.Write([]byte("Forbidden: " + err.Error()))
+			return
+		}
+	}
+
+	// the DedicatedGameServers can only update the status of DedicatedGameServers in the game namespaces
+	namespace, serverName := getDecodedDGS(decoded)
+	if !shared.IsGameNamespace(namespace) {
+		w.WriteHeader(400)
+		w.Write([]byte(fmt.Sprintf("Namespace %s is not a game namespace", namespace)))
+		return
+	}
+
+	if caller != nil && !a.authorizeV1(w, caller, "update", "dedicatedgameservers", "status", namespace, serverName) {
+		return
+	}
+
+	var fields shared.DGSStatusFields
+	switch v := decoded.(type) {
+	case helpers.ServerMarkedForDeletion:
+		fields.MarkedForDeletion = &v.MarkedForDeletion
+	case helpers.ServerState:
+		state := dgsv1alpha1.DGSState(v.State)
+		fields.DGSState = &state
+	case helpers.ServerHealth:
+		health := dgsv1alpha1.DGSHealth(v.Health)
+		fields.DGSHealth = &health
+	case helpers.ServerActivePlayers:
+		fields.ActivePlayers = &v.PlayerCount
+	}
+
+	_, err = a.updateDGSStatus(namespace, serverName, fields)
+	if err != nil {
+		w.WriteHeader(500)
+		w.Write([]byte("Error setting values: " + err.Error()))
+		log.Errorf("Error setting values %v because of %s", decoded, err.Error())
+		return
+	}
+
+	w.Write([]byte(fmt.Sprintf("Set values %v OK\n", decoded)))
+}
+
+// getDecodedDGS returns the namespace and the name of the DedicatedGameServer of a decoded DGS status request
+func getDecodedDGS(decoded interface{}) (string, string) {
+	switch v := decoded.(type) {
+	case helpers.ServerMarkedForDeletion:
+		return v.Namespace, v.ServerName
+	case helpers.ServerState:
+		return v.Namespace, v.ServerName
+	case helpers.ServerHealth:
+		return v.Namespace, v.ServerName
+	case helpers.ServerActivePlayers:
+		return v.Namespace, v.ServerName
+	}
+	return "", ""
+}
+
+// setDecodedIdentity returns the decoded DGS status request for the DedicatedGameServer of the token
+// The serverName and the namespace of the request are optional, but they cannot refer to another DedicatedGameServer
+func setDecodedIdentity(decoded interface{}, identity *shared.DGSIdentity) (interface{}, error) {
+	check := func(serverName, namespace *string) error {
+		if (*serverName != "" && *serverName != identity.Name) || (*namespace != "" && *namespace != identity.Namespace) {
+			return fmt.Errorf("the token of DedicatedGameServer %s/%s cannot update DedicatedGameServer %s/%s",
+				identity.Namespace, identity.Name, *namespace, *serverName)
+		}
+		*serverName = identity.Name
+		*namespace = identity.Namespace
+		return nil
+	}
+
+	var err error
+	switch v := decoded.(type) {
+	case helpers.ServerMarkedForDeletion:
+		err = check(&v.ServerName, &v.Namespace)
+		decoded = v
+	case helpers.ServerState:
+		err = check(&v.ServerName, &v.Namespace)
+		decoded = v
+	case helpers.ServerHealth:
+		err = check(&v.ServerName, &v.Namespace)
+		decoded = v
+	case helpers.ServerActivePlayers:
+		err = check(&v.ServerName, &v.Namespace)
+		decoded = v
+	default:
+		err = fmt.Errorf("Cannot recognize type %T", v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// authenticateV1 returns the caller of the API call. It responds with 401, or 500, if the call is not authenticated
+func (a *api) authenticateV1(w http.ResponseWriter, r *http.Request) (*helpers.APICaller, bool) {
+	caller, err := a.authenticate(r)
+	if err != nil {
+		writeV1AuthError(w, err)
+		return nil, false
+	}
+	return caller, true
+}
+
+// authorizeV1 returns true if the caller is allowed to perform the verb on the resource of the azuregaming API group
+// It responds with 403, or 500, if the caller is not allowed
+func (a *api) authorizeV1(w http.ResponseWriter, caller *helpers.APICaller, verb, resource, subresource, namespace, name string) bool {
+	if err := a.authorize(caller, verb, resource, subresource, namespace, name); err != nil {
+		writeV1AuthError(w, err)
+		return false
+	}
+	return true
+}
+
+// writeV1AuthError writes an error of authentication or authorization with the plain text messages of the v1 API
+func writeV1AuthError(w http.ResponseWriter, err error) {
+	e := toAPIError(err)
+	w.WriteHeader(e.code)
+	switch e.code {
+	case http.StatusUnauthorized:
+		w.Write([]byte("Unathorized"))
+	case http.StatusForbidden:
+		w.Write([]byte("Forbidden: " + e.message))
+	default:
+		w.Write([]byte("Error"))
+	}
+}
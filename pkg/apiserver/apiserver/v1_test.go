@@ -0,0 +1,100 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	helpers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apiserver/helpers"
+	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetDecodedIdentity(t *testing.T) {
+	identity := &shared.DGSIdentity{Namespace: "games", Name: "dgs1"}
+
+	tests := []struct {
+		name        string
+		decoded     interface{}
+		expected    interface{}
+		expectError bool
+	}{
+		{
+			name:     "same DGS",
+			decoded:  helpers.ServerActivePlayers{ServerName: "dgs1", Namespace: "games", PlayerCount: 5},
+			expected: helpers.ServerActivePlayers{ServerName: "dgs1", Namespace: "games", PlayerCount: 5},
+		},
+		{
+			name:     "DGS of the token",
+			decoded:  helpers.ServerHealth{Health: "Healthy"},
+			expected: helpers.ServerHealth{ServerName: "dgs1", Namespace: "games", Health: "Healthy"},
+		},
+		{
+			name:        "other DGS",
+			decoded:     helpers.ServerState{ServerName: "dgs2", Namespace: "games", State: "Running"},
+			expectError: true,
+		},
+		{
+			name:        "other namespace",
+			decoded:     helpers.ServerMarkedForDeletion{ServerName: "dgs1", Namespace: "default", MarkedForDeletion: true},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := setDecodedIdentity(tt.decoded, identity)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, decoded)
+		})
+	}
+}
+
+func TestV1Adapters(t *testing.T) {
+	a, dgsClient := newTestAPI(
+		newTestDGS("dgs1", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle),
+		newTestDGS("dgs2", dgsv1alpha1.DGSCreating, dgsv1alpha1.DGSIdle),
+	)
+
+	// the v1 routes keep their plain text responses
+	w := serve(a, "POST", "/create", "", `{"metadata":{"name":"col1"}}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Unathorized", w.Body.String())
+
+	w = serve(a, "POST", "/create", "Bearer denied", `{"metadata":{"name":"col1"}}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "Forbidden: create dedicatedgameservercollections is not allowed for denied", w.Body.String())
+
+	w = serve(a, "POST", "/create", "Bearer allowed", `{"metadata":{"name":"col1"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"col1"`)
+
+	w = serve(a, "GET", "/delete?name=col1", "Bearer allowed", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "col1 was deleted", w.Body.String())
+
+	w = serve(a, "GET", "/running", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var dgss []dgsv1alpha1.DedicatedGameServer
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgss))
+	assert.Len(t, dgss, 1)
+	assert.Equal(t, "dgs1", dgss[0].Name)
+
+	w = serve(a, "POST", "/setdgsstate", "Bearer dgs1", `{"state":"Running"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "Set values {dgs1 default Running} OK\n", w.Body.String())
+	dgs, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers("default").Get("dgs1", metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, dgsv1alpha1.DGSRunning, dgs.Status.DGSState)
+
+	w = serve(a, "POST", "/setactiveplayers", "Bearer dgs1", `{"serverName":"dgs2","playerCount":3}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,507 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	helpers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apiserver/helpers"
+	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/gorilla/mux"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// v2Prefix is the path prefix of the v2 API
+const v2Prefix = "/api/v2"
+
+// v2Handler is a handler of the v2 API, which returns the status code and the body of the response, or an error
+// The body is written as JSON, and the error as a JSON Status
+type v2Handler func(r *http.Request) (int, interface{}, error)
+
+func (h v2Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	code, body, err := h(r)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	writeJSON(w, code, body)
+}
+
+// v2Route is a route of the v2 API, the path is relative to v2Prefix
+type v2Route struct {
+	method  string
+	path    string
+	handler v2Handler
+}
+
+// v2Routes returns the routes of the v2 API. All of them are described by the OpenAPI document
+func (a *api) v2Routes() []v2Route {
+	return []v2Route{
+		{"GET", "/openapi.json", a.getOpenAPIV2},
+		{"GET", "/namespaces/{namespace}/collections", a.listCollectionsV2},
+		{"POST", "/namespaces/{namespace}/collections", a.createCollectionV2},
+		{"GET", "/namespaces/{namespace}/collections/{name}", a.getCollectionV2},
+		{"POST", "/namespaces/{namespace}/collections/{name}", a.createCollectionV2},
+		{"PUT", "/namespaces/{namespace}/collections/{name}", a.updateCollectionV2},
+		{"PATCH", "/namespaces/{namespace}/collections/{name}", a.patchCollectionV2},
+		{"DELETE", "/namespaces/{namespace}/collections/{name}", a.deleteCollectionV2},
+		{"GET", "/namespaces/{namespace}/collections/{name}/revisions", a.getCollectionRevisionsV2},
+		{"POST", "/namespaces/{namespace}/collections/{name}/rollback", a.rollbackCollectionV2},
+		{"POST", "/namespaces/{namespace}/allocations", a.allocateV2},
+		{"GET", "/dgs", a.listDGSsV2},
+		{"GET", "/namespaces/{namespace}/dgs", a.listDGSsV2},
+		{"GET", "/namespaces/{namespace}/dgs/{name}", a.getDGSV2},
+		{"PATCH", "/namespaces/{namespace}/dgs/{name}", a.patchDGSV2},
+		{"GET", "/accesscode/status", a.getAccessCodeStatusV2},
+	}
+}
+
+// v2Methods are the handlers of the methods of a path of the v2 API
+type v2Methods map[string]v2Handler
+
+func (m v2Methods) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, ok := m[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(m))
+		for method := range m {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeV2Error(w, newAPIError(http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, "method %s is not allowed", r.Method))
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// registerV2Routes registers the routes of the v2 API
+// It must be called before the registration of the file server, so that the unknown paths of the v2 API return a JSON Status
+func (a *api) registerV2Routes(router *mux.Router) {
+	v2 := router.PathPrefix(v2Prefix).Subrouter()
+
+	// the methods are dispatched per path, so that the wrong methods return 405 instead of 404
+	var paths []string
+	methods := make(map[string]v2Methods)
+	for _, route := range a.v2Routes() {
+		if methods[route.path] == nil {
+			methods[route.path] = v2Methods{}
+			paths = append(paths, route.path)
+		}
+		methods[route.path][route.method] = route.handler
+	}
+	for _, path := range paths {
+		v2.Handle(path, methods[path])
+	}
+
+	v2.PathPrefix("/").Handler(v2Handler(func(r *http.Request) (int, interface{}, error) {
+		return 0, nil, newAPIError(http.StatusNotFound, metav1.StatusReasonNotFound, "path %s was not found", r.URL.Path)
+	}))
+}
+
+// writeJSON writes the body as JSON. A nil body is written as an empty response
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	if body == nil {
+		w.WriteHeader(code)
+		return
+	}
+	response, err := json.Marshal(body)
+	if err != nil {
+		writeV2Error(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(response)
+}
+
+// writeV2Error writes the error as a JSON Status of the Kubernetes API, with the status code of the error
+func writeV2Error(w http.ResponseWriter, err error) {
+	e := toAPIError(err)
+	if e.code >= http.StatusInternalServerError {
+		log.Errorf("Error in API call: %s", e.message)
+	}
+	response, _ := json.Marshal(metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Message:  e.message,
+		Reason:   e.reason,
+		Code:     int32(e.code),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.code)
+	w.Write(response)
+}
+
+// decodeBody decodes the JSON body of the request, it returns a 400 error if the body is not valid
+func decodeBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return newBadRequestError("invalid body: %s", err.Error())
+	}
+	return nil
+}
+
+// getNamespaceV2 returns the namespace of the path, it returns a 404 error if it is not a game namespace
+func getNamespaceV2(r *http.Request) (string, error) {
+	namespace := mux.Vars(r)["namespace"]
+	if !shared.IsGameNamespace(namespace) {
+		return "", newAPIError(http.StatusNotFound, metav1.StatusReasonNotFound, "namespace %s is not a game namespace", namespace)
+	}
+	return namespace, nil
+}
+
+func (a *api) getOpenAPIV2(r *http.Request) (int, interface{}, error) {
+	return http.StatusOK, json.RawMessage(openAPIDocument), nil
+}
+
+func (a *api) listCollectionsV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := a.authenticateAndAuthorize(r, "list", "dedicatedgameservercollections", "", namespace, ""); err != nil {
+		return 0, nil, err
+	}
+	dgsCols, err := a.listCollections(namespace)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, dgsCols, nil
+}
+
+// decodeCollectionV2 returns the DedicatedGameServerCollection of the body of the request
+// The namespace and, if it is set, the name of the path are used, those of the body must be empty or the same
+func decodeCollectionV2(r *http.Request, namespace string) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	var dgsCol dgsv1alpha1.DedicatedGameServerCollection
+	if err := decodeBody(r, &dgsCol); err != nil {
+		return nil, err
+	}
+	if name := mux.Vars(r)["name"]; name != "" {
+		if dgsCol.Name != "" && dgsCol.Name != name {
+			return nil, newBadRequestError("the name of the body %s does not match the name of the path %s", dgsCol.Name, name)
+		}
+		dgsCol.Name = name
+	}
+	if dgsCol.Namespace != "" && dgsCol.Namespace != namespace {
+		return nil, newBadRequestError("the namespace of the body %s does not match the namespace of the path %s", dgsCol.Namespace, namespace)
+	}
+	if dgsCol.Name == "" {
+		return nil, newBadRequestError("the name of the DedicatedGameServerCollection is required")
+	}
+	dgsCol.Namespace = namespace
+	return &dgsCol, nil
+}
+
+// getDryRunV2 returns true if the request is a dry run, it returns a 400 error if the 'dryRun' query parameter is not valid
+func getDryRunV2(r *http.Request) (bool, error) {
+	dryRun, err := helpers.GetDryRun(r)
+	if err != nil {
+		return false, newBadRequestError("%s", err.Error())
+	}
+	return dryRun, nil
+}
+
+// createCollectionV2 creates the DedicatedGameServerCollection of the body, with its complete spec, labels and annotations
+func (a *api) createCollectionV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := a.authenticateAndAuthorize(r, "create", "dedicatedgameservercollections", "", namespace, ""); err != nil {
+		return 0, nil, err
+	}
+
+	dryRun, err := getDryRunV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	dgsCol, err := decodeCollectionV2(r, namespace)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	created, err := a.createCollection(namespace, dgsCol, dryRun)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusCreated, created, nil
+}
+
+// updateCollectionV2 replaces the spec, the labels and the annotations of the DedicatedGameServerCollection with those of the body
+func (a *api) updateCollectionV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	name := mux.Vars(r)["name"]
+	if err := a.authenticateAndAuthorize(r, "update", "dedicatedgameservercollections", "", namespace, name); err != nil {
+		return 0, nil, err
+	}
+
+	dryRun, err := getDryRunV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	dgsCol, err := decodeCollectionV2(r, namespace)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	updated, err := a.updateCollection(namespace, dgsCol, dryRun)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, updated, nil
+}
+
+func (a *api) getCollectionV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	name := mux.Vars(r)["name"]
+	if err := a.authenticateAndAuthorize(r, "get", "dedicatedgameservercollections", "", namespace, name); err != nil {
+		return 0, nil, err
+	}
+	dgsCol, err := a.getCollection(namespace, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, dgsCol, nil
+}
+
+// patchCollectionV2 applies the JSON merge patch of the body to the spec, the labels and the annotations of the DedicatedGameServerCollection
+func (a *api) patchCollectionV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	name := mux.Vars(r)["name"]
+	if err := a.authenticateAndAuthorize(r, "patch", "dedicatedgameservercollections", "", namespace, name); err != nil {
+		return 0, nil, err
+	}
+
+	dryRun, err := getDryRunV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	patch, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if !json.Valid(patch) {
+		return 0, nil, newBadRequestError("invalid body: the patch is not valid JSON")
+	}
+
+	dgsCol, err := a.patchCollection(namespace, name, patch, dryRun)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, dgsCol, nil
+}
+
+func (a *api) deleteCollectionV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	name := mux.Vars(r)["name"]
+	if err := a.authenticateAndAuthorize(r, "delete", "dedicatedgameservercollections", "", namespace, name); err != nil {
+		return 0, nil, err
+	}
+	if err := a.deleteCollection(namespace, name); err != nil {
+		return 0, nil, err
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// revisionList is the response of the revisions of a DedicatedGameServerCollection
+type revisionList struct {
+	Items []shared.DedicatedGameServerCollectionRevision `json:"items"`
+}
+
+func (a *api) getCollectionRevisionsV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	name := mux.Vars(r)["name"]
+	if err := a.authenticateAndAuthorize(r, "get", "dedicatedgameservercollections", "", namespace, name); err != nil {
+		return 0, nil, err
+	}
+	revisions, err := a.getCollectionRevisions(namespace, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, revisionList{Items: revisions}, nil
+}
+
+// rollbackRequest is the optional body of a rollback, without a revision the previous one is used
+type rollbackRequest struct {
+	Revision int64 `json:"revision"`
+}
+
+func (a *api) rollbackCollectionV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	name := mux.Vars(r)["name"]
+	if err := a.authenticateAndAuthorize(r, "update", "dedicatedgameservercollections", "", namespace, name); err != nil {
+		return 0, nil, err
+	}
+
+	var rollback rollbackRequest
+	if r.ContentLength != 0 {
+		if err := decodeBody(r, &rollback); err != nil {
+			return 0, nil, err
+		}
+	}
+	if rollback.Revision < 0 {
+		return 0, nil, newBadRequestError("wrong value for revision: %d", rollback.Revision)
+	}
+
+	dgsCol, err := a.rollbackCollection(namespace, name, rollback.Revision)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, dgsCol, nil
+}
+
+// allocateV2 allocates a DedicatedGameServer. The status code is 201 if a DedicatedGameServer was allocated,
+// and 200 with an UnAllocated state if there was no capacity
+func (a *api) allocateV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := a.authenticateAndAuthorize(r, "create", "dedicatedgameserverallocations", "", namespace, ""); err != nil {
+		return 0, nil, err
+	}
+
+	var dgsAlloc dgsv1alpha1.DedicatedGameServerAllocation
+	if err := decodeBody(r, &dgsAlloc); err != nil {
+		return 0, nil, err
+	}
+	if dgsAlloc.Namespace != "" && dgsAlloc.Namespace != namespace {
+		return 0, nil, newBadRequestError("the namespace of the body %s does not match the namespace of the path %s", dgsAlloc.Namespace, namespace)
+	}
+	dgsAlloc.Namespace = namespace
+
+	allocated, err := a.allocate(&dgsAlloc)
+	if err != nil {
+		return 0, nil, err
+	}
+	if allocated.Status.State == dgsv1alpha1.DGSAllocationUnAllocated {
+		return http.StatusOK, allocated, nil
+	}
+	return http.StatusCreated, allocated, nil
+}
+
+// listDGSsV2 returns the DedicatedGameServers of the namespace of the path, or of all the game namespaces
+// With the 'ready' query parameter set to true, only the ready DedicatedGameServers are returned
+func (a *api) listDGSsV2(r *http.Request) (int, interface{}, error) {
+	namespace := ""
+	if _, ok := mux.Vars(r)["namespace"]; ok {
+		var err error
+		namespace, err = getNamespaceV2(r)
+		if err != nil {
+			return 0, nil, err
+		}
+	}
+
+	readyOnly := false
+	if value := r.URL.Query().Get("ready"); value != "" {
+		var err error
+		readyOnly, err = strconv.ParseBool(value)
+		if err != nil {
+			return 0, nil, newBadRequestError("wrong value for ready: %s", value)
+		}
+	}
+
+	if err := a.authorizeDGSRead(r, "list", namespace, ""); err != nil {
+		return 0, nil, err
+	}
+
+	dgss, err := a.listDGSs(namespace, readyOnly)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, dgsv1alpha1.DedicatedGameServerList{Items: dgss}, nil
+}
+
+func (a *api) getDGSV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	name := mux.Vars(r)["name"]
+	if err := a.authorizeDGSRead(r, "get", namespace, name); err != nil {
+		return 0, nil, err
+	}
+	dgs, err := a.getDGS(namespace, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, dgs, nil
+}
+
+// dgsPatch is the body of a DedicatedGameServer patch, only the fields of the status that a DedicatedGameServer reports can be set
+type dgsPatch struct {
+	Status struct {
+		Health            *dgsv1alpha1.DGSHealth `json:"health"`
+		DGSState          *dgsv1alpha1.DGSState  `json:"dgsState"`
+		ActivePlayers     *int                   `json:"activePlayers"`
+		MarkedForDeletion *bool                  `json:"markedForDeletion"`
+	} `json:"status"`
+}
+
+// patchDGSV2 sets the fields of the status of the body on the DedicatedGameServer
+// The DedicatedGameServers authenticate with their token, the other callers need to be authorized to update the status
+func (a *api) patchDGSV2(r *http.Request) (int, interface{}, error) {
+	namespace, err := getNamespaceV2(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	name := mux.Vars(r)["name"]
+	if err := a.authorizeDGSStatusUpdate(r, namespace, name); err != nil {
+		return 0, nil, err
+	}
+
+	var patch dgsPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		return 0, nil, newBadRequestError("invalid body: %s", err.Error())
+	}
+
+	dgs, err := a.updateDGSStatus(namespace, name, shared.DGSStatusFields{
+		DGSHealth:         patch.Status.Health,
+		DGSState:          patch.Status.DGSState,
+		ActivePlayers:     patch.Status.ActivePlayers,
+		MarkedForDeletion: patch.Status.MarkedForDeletion,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, dgs, nil
+}
+
+// getAccessCodeStatusV2 returns the rotation status of the access codes, without the codes
+func (a *api) getAccessCodeStatusV2(r *http.Request) (int, interface{}, error) {
+	if err := a.authorizeAccessCodeStatus(r); err != nil {
+		return 0, nil, err
+	}
+	status, err := a.getAccessCodeStatus()
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, status, nil
+}
@@ -0,0 +1,294 @@
+package apiserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	helpers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apiserver/helpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+	shared "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestAPI returns an api with fake clientsets. The 'Bearer allowed' token is allowed to call every method,
+// the 'Bearer denied' token is authenticated but not authorized, and the 'Bearer dgs1' token is the token of DedicatedGameServer dgs1
+func newTestAPI(objects ...runtime.Object) (*api, *fake.Clientset) {
+	dgsClient := fake.NewSimpleClientset(objects...)
+	client := k8sfake.NewSimpleClientset()
+	a := newAPI(client, dgsClient, false, shared.NewDGSTokenKeyStore(client, shared.GameNamespace, false))
+	a.authenticateCaller = func(r *http.Request) (*helpers.APICaller, error) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer allowed":
+			return &helpers.APICaller{User: authenticationv1.UserInfo{Username: "allowed"}}, nil
+		case "Bearer denied":
+			return &helpers.APICaller{User: authenticationv1.UserInfo{Username: "denied"}}, nil
+		}
+		return nil, nil
+	}
+	a.authorizeCaller = func(caller *helpers.APICaller, attributes authorizationv1.ResourceAttributes) (bool, error) {
+		return caller.User.Username == "allowed", nil
+	}
+	a.authenticateDGS = func(r *http.Request) (*shared.DGSIdentity, error) {
+		if r.Header.Get("Authorization") == "Bearer dgs1" {
+			return &shared.DGSIdentity{Namespace: shared.GameNamespace, Name: "dgs1"}, nil
+		}
+		return nil, nil
+	}
+	return a, dgsClient
+}
+
+func newTestDGS(name string, health dgsv1alpha1.DGSHealth, state dgsv1alpha1.DGSState) *dgsv1alpha1.DedicatedGameServer {
+	dgs := shared.NewDedicatedGameServerWithNoParent(shared.GameNamespace, name, corev1.PodSpec{}, nil)
+	dgs.Status.Health = health
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.DGSState = state
+	return dgs
+}
+
+// serve serves the request with the router of the api
+func serve(a *api, method, target, authorization, body string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, target, reader)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	newRouter(a).ServeHTTP(w, r)
+	return w
+}
+
+func decodeStatus(t *testing.T, w *httptest.ResponseRecorder) metav1.Status {
+	var status metav1.Status
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	return status
+}
+
+func TestV2Collections(t *testing.T) {
+	a, _ := newTestAPI()
+
+	w := serve(a, "POST", "/api/v2/namespaces/default/collections", "Bearer allowed", `{"metadata":{"name":"col1"},"spec":{"replicas":2}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var dgsCol dgsv1alpha1.DedicatedGameServerCollection
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgsCol))
+	assert.Equal(t, "col1", dgsCol.Name)
+	assert.Equal(t, "default", dgsCol.Namespace)
+	assert.Equal(t, int32(2), dgsCol.Spec.Replicas)
+
+	// the name of the path is used if the body has none
+	w = serve(a, "POST", "/api/v2/namespaces/default/collections/col2", "Bearer allowed", `{"spec":{"replicas":1}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	w = serve(a, "POST", "/api/v2/namespaces/default/collections", "Bearer allowed", `{"metadata":{"name":"col1"}}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, metav1.StatusReasonAlreadyExists, decodeStatus(t, w).Reason)
+
+	w = serve(a, "GET", "/api/v2/namespaces/default/collections", "Bearer allowed", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var dgsCols dgsv1alpha1.DedicatedGameServerCollectionList
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgsCols))
+	assert.Len(t, dgsCols.Items, 2)
+
+	w = serve(a, "PATCH", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", `{"spec":{"replicas":5}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(a, "GET", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgsCol))
+	assert.Equal(t, int32(5), dgsCol.Spec.Replicas)
+
+	w = serve(a, "DELETE", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	w = serve(a, "GET", "/api/v2/namespaces/default/collections/col1", "Bearer allowed", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	status := decodeStatus(t, w)
+	assert.Equal(t, metav1.StatusFailure, status.Status)
+	assert.Equal(t, metav1.StatusReasonNotFound, status.Reason)
+	assert.Equal(t, int32(http.StatusNotFound), status.Code)
+}
+
+func TestV2Errors(t *testing.T) {
+	a, _ := newTestAPI()
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		authorization  string
+		body           string
+		expectedCode   int
+		expectedReason metav1.StatusReason
+	}{
+		{name: "not authenticated", method: "GET", target: "/api/v2/namespaces/default/collections", expectedCode: 401, expectedReason: metav1.StatusReasonUnauthorized},
+		{name: "not authorized", method: "GET", target: "/api/v2/namespaces/default/collections", authorization: "Bearer denied", expectedCode: 403, expectedReason: metav1.StatusReasonForbidden},
+		{name: "invalid body", method: "POST", target: "/api/v2/namespaces/default/collections", authorization: "Bearer allowed", body: "{", expectedCode: 400, expectedReason: metav1.StatusReasonBadRequest},
+		{name: "name mismatch", method: "POST", target: "/api/v2/namespaces/default/collections/col1", authorization: "Bearer allowed", body: `{"metadata":{"name":"col2"}}`, expectedCode: 400, expectedReason: metav1.StatusReasonBadRequest},
+		{name: "missing name", method: "POST", target: "/api/v2/namespaces/default/collections", authorization: "Bearer allowed", body: `{}`, expectedCode: 400, expectedReason: metav1.StatusReasonBadRequest},
+		{name: "invalid patch", method: "PATCH", target: "/api/v2/namespaces/default/collections/col1", authorization: "Bearer allowed", body: "{", expectedCode: 400, expectedReason: metav1.StatusReasonBadRequest},
+		{name: "negative revision", method: "POST", target: "/api/v2/namespaces/default/collections/col1/rollback", authorization: "Bearer allowed", body: `{"revision":-1}`, expectedCode: 400, expectedReason: metav1.StatusReasonBadRequest},
+		{name: "invalid ready", method: "GET", target: "/api/v2/dgs?ready=maybe", expectedCode: 400, expectedReason: metav1.StatusReasonBadRequest},
+		{name: "method not allowed", method: "DELETE", target: "/api/v2/namespaces/default/collections", authorization: "Bearer allowed", expectedCode: 405, expectedReason: metav1.StatusReasonMethodNotAllowed},
+		{name: "unknown path", method: "GET", target: "/api/v2/unknown", expectedCode: 404, expectedReason: metav1.StatusReasonNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(a, tt.method, tt.target, tt.authorization, tt.body)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			status := decodeStatus(t, w)
+			assert.Equal(t, tt.expectedReason, status.Reason)
+			assert.Equal(t, int32(tt.expectedCode), status.Code)
+			assert.NotEmpty(t, status.Message)
+		})
+	}
+
+	w := serve(a, "DELETE", "/api/v2/namespaces/default/collections", "Bearer allowed", "")
+	assert.Equal(t, "GET, POST", w.Header().Get("Allow"))
+}
+
+func TestV2NotGameNamespace(t *testing.T) {
+	a, _ := newTestAPI()
+	w := serve(a, "GET", "/api/v2/namespaces/kube-system/collections", "Bearer allowed", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, decodeStatus(t, w).Message, "kube-system is not a game namespace")
+}
+
+func TestV2DGS(t *testing.T) {
+	a, _ := newTestAPI(
+		newTestDGS("dgs1", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle),
+		newTestDGS("dgs2", dgsv1alpha1.DGSCreating, dgsv1alpha1.DGSIdle),
+	)
+
+	w := serve(a, "GET", "/api/v2/namespaces/default/dgs", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var dgss dgsv1alpha1.DedicatedGameServerList
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgss))
+	assert.Len(t, dgss.Items, 2)
+
+	w = serve(a, "GET", "/api/v2/dgs?ready=true", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgss))
+	assert.Len(t, dgss.Items, 1)
+	assert.Equal(t, "dgs1", dgss.Items[0].Name)
+
+	// the DGS reads require authentication with listingauth
+	a.listRunningRequiresAuth = true
+	w = serve(a, "GET", "/api/v2/namespaces/default/dgs/dgs2", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serve(a, "GET", "/api/v2/namespaces/default/dgs/dgs2", "Bearer allowed", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var dgs dgsv1alpha1.DedicatedGameServer
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgs))
+	assert.Equal(t, "dgs2", dgs.Name)
+}
+
+func TestV2PatchDGS(t *testing.T) {
+	tests := []struct {
+		name          string
+		target        string
+		authorization string
+		body          string
+		expectedCode  int
+	}{
+		{name: "token of the DGS", target: "/api/v2/namespaces/default/dgs/dgs1", authorization: "Bearer dgs1", body: `{"status":{"dgsState":"Running","activePlayers":4}}`, expectedCode: 200},
+		{name: "authorized caller", target: "/api/v2/namespaces/default/dgs/dgs1", authorization: "Bearer allowed", body: `{"status":{"dgsState":"Running","activePlayers":4}}`, expectedCode: 200},
+		{name: "token of another DGS", target: "/api/v2/namespaces/default/dgs/dgs2", authorization: "Bearer dgs1", body: `{"status":{"dgsState":"Running"}}`, expectedCode: 403},
+		{name: "not authenticated", target: "/api/v2/namespaces/default/dgs/dgs1", body: `{"status":{"dgsState":"Running"}}`, expectedCode: 401},
+		{name: "wrong state", target: "/api/v2/namespaces/default/dgs/dgs1", authorization: "Bearer dgs1", body: `{"status":{"dgsState":"Sleeping"}}`, expectedCode: 400},
+		{name: "negative active players", target: "/api/v2/namespaces/default/dgs/dgs1", authorization: "Bearer dgs1", body: `{"status":{"activePlayers":-1}}`, expectedCode: 400},
+		{name: "field that is not reported by the DGS", target: "/api/v2/namespaces/default/dgs/dgs1", authorization: "Bearer dgs1", body: `{"status":{"publicIP":"1.2.3.4"}}`, expectedCode: 400},
+		{name: "missing DGS", target: "/api/v2/namespaces/default/dgs/dgs3", authorization: "Bearer allowed", body: `{"status":{"health":"Failed"}}`, expectedCode: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, dgsClient := newTestAPI(
+				newTestDGS("dgs1", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle),
+				newTestDGS("dgs2", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle),
+			)
+			w := serve(a, "PATCH", tt.target, tt.authorization, tt.body)
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				decodeStatus(t, w)
+				return
+			}
+
+			var dgs dgsv1alpha1.DedicatedGameServer
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgs))
+			assert.Equal(t, dgsv1alpha1.DGSRunning, dgs.Status.DGSState)
+			assert.Equal(t, 4, dgs.Status.ActivePlayers)
+			// the fields that are not in the patch are not changed
+			assert.Equal(t, dgsv1alpha1.DGSHealthy, dgs.Status.Health)
+
+			stored, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers("default").Get("dgs1", metav1.GetOptions{})
+			assert.NoError(t, err)
+			assert.Equal(t, dgsv1alpha1.DGSRunning, stored.Status.DGSState)
+		})
+	}
+}
+
+func TestV2Allocate(t *testing.T) {
+	a, _ := newTestAPI(newTestDGS("dgs1", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle))
+
+	w := serve(a, "POST", "/api/v2/namespaces/default/allocations", "Bearer allowed", `{"metadata":{"name":"alloc1"}}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var dgsAlloc dgsv1alpha1.DedicatedGameServerAllocation
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgsAlloc))
+	assert.Equal(t, dgsv1alpha1.DGSAllocationAllocated, dgsAlloc.Status.State)
+	assert.Equal(t, "dgs1", dgsAlloc.Status.DedicatedGameServerName)
+
+	// the lack of capacity is not an error
+	w = serve(a, "POST", "/api/v2/namespaces/default/allocations", "Bearer allowed", `{"metadata":{"name":"alloc2"}}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dgsAlloc))
+	assert.Equal(t, dgsv1alpha1.DGSAllocationUnAllocated, dgsAlloc.Status.State)
+
+	w = serve(a, "POST", "/api/v2/namespaces/default/allocations", "Bearer allowed", `{"metadata":{"namespace":"games"}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestOpenAPIDocumentsV2Routes(t *testing.T) {
+	a, _ := newTestAPI()
+
+	w := serve(a, "GET", "/api/v2/openapi.json", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var document struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &document))
+	assert.Equal(t, "3.0.0", document.OpenAPI)
+
+	// every route is documented, and every documented operation is a route
+	documented := make(map[string]bool)
+	for path, operations := range document.Paths {
+		for method := range operations {
+			if method != "parameters" {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+	routes := make(map[string]bool)
+	for _, route := range a.v2Routes() {
+		routes[route.method+" "+route.path] = true
+	}
+	assert.Equal(t, routes, documented)
+}
@@ -0,0 +1,121 @@
+package helpers
+
+import (
+	"net/http"
+	"strings"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	log "github.com/sirupsen/logrus"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// APICaller is the authenticated caller of an API call
+type APICaller struct {
+	// AccessCode is true if the caller authenticated with the access code, which can call all the API methods
+	AccessCode bool
+	// User is the Kubernetes user of the bearer token of the caller
+	User authenticationv1.UserInfo
+}
+
+// APIAuthenticator authenticates the API calls with the Kubernetes TokenReview API, or with the access code,
+// and authorizes them with the Kubernetes SubjectAccessReview API
+type APIAuthenticator struct {
+	client kubernetes.Interface
+	// authenticateCode checks the access code of the calls without a bearer token
+	authenticateCode func(code string) (bool, error)
+}
+
+// NewAPIAuthenticator returns a new APIAuthenticator
+func NewAPIAuthenticator(client kubernetes.Interface) *APIAuthenticator {
+	return &APIAuthenticator{
+		client:           client,
+		authenticateCode: shared.AuthenticateWebServerCode,
+	}
+}
+
+// Authenticate returns the caller of the 'Authorization: Bearer' token of the API call, or of the 'code' query parameter
+// if there is no bearer token. It returns nil if the call is not authenticated
+func (a *APIAuthenticator) Authenticate(r *http.Request) (*APICaller, error) {
+	if token := getBearerToken(r); token != "" {
+		review, err := a.client.AuthenticationV1().TokenReviews().Create(&authenticationv1.TokenReview{
+			Spec: authenticationv1.TokenReviewSpec{Token: token},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !review.Status.Authenticated {
+			if review.Status.Error != "" {
+				log.Infof("Bearer token was not authenticated: %s", review.Status.Error)
+			}
+			return nil, nil
+		}
+		return &APICaller{User: review.Status.User}, nil
+	}
+
+	code := r.FormValue("code")
+	if code == "" {
+		return nil, nil
+	}
+	result, err := a.authenticateCode(code)
+	if err != nil || !result {
+		return nil, err
+	}
+	return &APICaller{AccessCode: true}, nil
+}
+
+// Authorize returns true if the caller is allowed to perform the action of the attributes
+// The access code is allowed to perform every action
+func (a *APIAuthenticator) Authorize(caller *APICaller, attributes authorizationv1.ResourceAttributes) (bool, error) {
+	if caller.AccessCode {
+		return true, nil
+	}
+
+	extra := make(map[string]authorizationv1.ExtraValue)
+	for key, value := range caller.User.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	review, err := a.client.AuthorizationV1().SubjectAccessReviews().Create(&authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attributes,
+			User:               caller.User.Username,
+			Groups:             caller.User.Groups,
+			UID:                caller.User.UID,
+			Extra:              extra,
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	if !review.Status.Allowed && review.Status.EvaluationError != "" {
+		log.Infof("Error authorizing %s: %s", caller.User.Username, review.Status.EvaluationError)
+	}
+	return review.Status.Allowed, nil
+}
+
+// NewResourceAttributes returns the attributes of the action on a resource of the azuregaming API group,
+// e.g. create on dedicatedgameservercollections
+func NewResourceAttributes(verb, resource, subresource, namespace, name string) authorizationv1.ResourceAttributes {
+	return authorizationv1.ResourceAttributes{
+		Verb:        verb,
+		Group:       dgsv1alpha1.SchemeGroupVersion.Group,
+		Version:     dgsv1alpha1.SchemeGroupVersion.Version,
+		Resource:    resource,
+		Subresource: subresource,
+		Namespace:   namespace,
+		Name:        name,
+	}
+}
+
+// getBearerToken returns the token of the 'Authorization: Bearer' header, if any
+func getBearerToken(r *http.Request) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(parts[1])
+}
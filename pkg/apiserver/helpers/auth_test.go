@@ -0,0 +1,162 @@
+package helpers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+)
+
+// newAuthFakeClientset returns a fake clientset that authenticates the validToken as a matchmaker ServiceAccount,
+// which is only allowed to create DedicatedGameServerAllocations in the default namespace
+func newAuthFakeClientset(validToken string) *k8sfake.Clientset {
+	client := k8sfake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: shared.APIAccessCodeSecretName, Namespace: shared.GameNamespace},
+		Data:       map[string][]byte{"code": []byte("code123")},
+	})
+	client.PrependReactor("create", "tokenreviews", func(action core.Action) (bool, runtime.Object, error) {
+		review := action.(core.CreateAction).GetObject().(*authenticationv1.TokenReview)
+		if review.Spec.Token == "error" {
+			return true, &authenticationv1.TokenReview{}, fmt.Errorf("TokenReview failed")
+		}
+		if review.Spec.Token == validToken {
+			review.Status = authenticationv1.TokenReviewStatus{
+				Authenticated: true,
+				User: authenticationv1.UserInfo{
+					Username: "system:serviceaccount:default:matchmaker",
+					Groups:   []string{"system:serviceaccounts"},
+				},
+			}
+		}
+		return true, review, nil
+	})
+	client.PrependReactor("create", "subjectaccessreviews", func(action core.Action) (bool, runtime.Object, error) {
+		review := action.(core.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		review.Status.Allowed = review.Spec.User == "system:serviceaccount:default:matchmaker" &&
+			attributes.Group == "azuregaming.com" && attributes.Verb == "create" &&
+			attributes.Resource == "dedicatedgameserverallocations" && attributes.Namespace == "default"
+		return true, review, nil
+	})
+	return client
+}
+
+func TestAuthenticateBearerToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        string
+		expectedUser  string
+		expectCaller  bool
+		expectError   bool
+		expectActions int
+	}{
+		{name: "valid token", header: "Bearer token123", expectedUser: "system:serviceaccount:default:matchmaker", expectCaller: true, expectActions: 1},
+		{name: "scheme is case insensitive", header: "bearer token123", expectedUser: "system:serviceaccount:default:matchmaker", expectCaller: true, expectActions: 1},
+		{name: "invalid token", header: "Bearer other", expectActions: 1},
+		{name: "TokenReview error", header: "Bearer error", expectError: true, expectActions: 1},
+		{name: "other scheme", header: "Basic dXNlcjpwYXNz", expectActions: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newAuthFakeClientset("token123")
+			authenticator := NewAPIAuthenticator(client)
+
+			r := httptest.NewRequest("POST", "/allocate", nil)
+			r.Header.Set("Authorization", tt.header)
+			caller, err := authenticator.Authenticate(r)
+
+			if tt.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if tt.expectCaller {
+				assert.NotNil(t, caller)
+				assert.False(t, caller.AccessCode)
+				assert.Equal(t, tt.expectedUser, caller.User.Username)
+			} else {
+				assert.Nil(t, caller)
+			}
+			assert.Len(t, client.Actions(), tt.expectActions)
+		})
+	}
+}
+
+func TestAuthenticateAccessCode(t *testing.T) {
+	client := newAuthFakeClientset("token123")
+	authenticator := NewAPIAuthenticator(client)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	store := shared.NewAccessCodeStore(client, shared.GameNamespace, shared.DefaultAccessCodeOverlap)
+	go store.Run(stopCh)
+	err := wait.Poll(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		return store.Status().SecretFound, nil
+	})
+	assert.NoError(t, err)
+	authenticator.authenticateCode = func(code string) (bool, error) {
+		return store.Authenticate(code), nil
+	}
+
+	// the bearer token takes precedence over the code
+	r := httptest.NewRequest("POST", "/allocate?code=code123", nil)
+	r.Header.Set("Authorization", "Bearer other")
+	caller, err := authenticator.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Nil(t, caller)
+
+	caller, err = authenticator.Authenticate(httptest.NewRequest("POST", "/allocate?code=code123", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, &APICaller{AccessCode: true}, caller)
+
+	caller, err = authenticator.Authenticate(httptest.NewRequest("POST", "/allocate?code=wrong", nil))
+	assert.NoError(t, err)
+	assert.Nil(t, caller)
+
+	caller, err = authenticator.Authenticate(httptest.NewRequest("POST", "/allocate", nil))
+	assert.NoError(t, err)
+	assert.Nil(t, caller)
+}
+
+func TestAuthorize(t *testing.T) {
+	client := newAuthFakeClientset("token123")
+	authenticator := NewAPIAuthenticator(client)
+	caller := &APICaller{User: authenticationv1.UserInfo{Username: "system:serviceaccount:default:matchmaker"}}
+
+	tests := []struct {
+		name       string
+		caller     *APICaller
+		attributes authorizationv1.ResourceAttributes
+		expected   bool
+	}{
+		{name: "allowed", caller: caller, attributes: NewResourceAttributes("create", "dedicatedgameserverallocations", "", "default", ""), expected: true},
+		{name: "other resource", caller: caller, attributes: NewResourceAttributes("create", "dedicatedgameservercollections", "", "default", ""), expected: false},
+		{name: "other namespace", caller: caller, attributes: NewResourceAttributes("create", "dedicatedgameserverallocations", "", "games", ""), expected: false},
+		{name: "access code", caller: &APICaller{AccessCode: true}, attributes: NewResourceAttributes("delete", "dedicatedgameservercollections", "", "default", "test"), expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := authenticator.Authorize(tt.caller, tt.attributes)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, allowed)
+		})
+	}
+
+	// the access code is authorized without a SubjectAccessReview
+	assert.Len(t, client.Actions(), 3)
+}
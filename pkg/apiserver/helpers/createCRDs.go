@@ -6,19 +6,20 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-func CreateDedicatedGameServerCRD(dgsName string, podSpec corev1.PodSpec, portsToExpose []int32) (finalDDGSName string, err error) {
+func CreateDedicatedGameServerCRD(namespace string, dgsName string, podSpec corev1.PodSpec, portsToExpose []intstr.IntOrString) (finalDDGSName string, err error) {
 	log.Printf("Creating DedicatedGameServer %s", dgsName)
 
-	dgs := shared.NewDedicatedGameServerWithNoParent(shared.GameNamespace, dgsName, podSpec, portsToExpose)
+	dgs := shared.NewDedicatedGameServerWithNoParent(namespace, dgsName, podSpec, portsToExpose)
 
 	_, dgsClient, err := shared.GetClientSet()
 	if err != nil {
 		return "", err
 	}
 
-	dgsInstance, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers(shared.GameNamespace).Create(dgs)
+	dgsInstance, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Create(dgs)
 
 	if err != nil {
 		return "", err
@@ -27,17 +28,17 @@ func CreateDedicatedGameServerCRD(dgsName string, podSpec corev1.PodSpec, portsT
 
 }
 
-func CreateDedicatedGameServerCollectionCRD(dgsColName string, replicas int32, podSpec corev1.PodSpec) (finalDGSColName string, err error) {
+func CreateDedicatedGameServerCollectionCRD(namespace string, dgsColName string, replicas int32, podSpec corev1.PodSpec) (finalDGSColName string, err error) {
 	log.Printf("Creating DedicatedGameServerCollection %s", dgsColName)
 
-	dgsCol := shared.NewDedicatedGameServerCollection(dgsColName, shared.GameNamespace, replicas, podSpec)
+	dgsCol := shared.NewDedicatedGameServerCollection(dgsColName, namespace, replicas, podSpec)
 
 	_, dgsClient, err := shared.GetClientSet()
 	if err != nil {
 		return "", err
 	}
 
-	dgsColInstance, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(shared.GameNamespace).Create(dgsCol)
+	dgsColInstance, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Create(dgsCol)
 
 	if err != nil {
 		return "", err
@@ -1,22 +1,46 @@
 package helpers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 )
 
-func IsAPICallAuthenticated(w http.ResponseWriter, r *http.Request) (bool, error) {
-	code := r.FormValue("code")
-
-	result, err := shared.AuthenticateWebServerCode(code)
+// GetDGSTokenIdentity returns the DedicatedGameServer of the token of the 'Authorization: Bearer' header of an API call,
+// verified with the keys of tokenKeys. It returns nil if there is no bearer token, if it is not a DedicatedGameServer token
+// (e.g. it is a Kubernetes token) or if it is not valid
+func GetDGSTokenIdentity(r *http.Request, tokenKeys *shared.DGSTokenKeyStore) (*shared.DGSIdentity, error) {
+	token := getBearerToken(r)
+	if !shared.IsDGSToken(token) {
+		return nil, nil
+	}
+	return tokenKeys.VerifyDGSToken(token)
+}
 
-	if err != nil {
-		return false, err
+// GetRequestNamespace returns the namespace of an API call, which is the value of the 'namespace' query parameter,
+// or the given namespace of the request body, or the default game namespace. The namespace must be a game namespace
+func GetRequestNamespace(r *http.Request, namespace string) (string, error) {
+	if value := r.FormValue("namespace"); value != "" {
+		namespace = value
 	}
+	if namespace == "" {
+		namespace = shared.DefaultGameNamespace()
+	}
+	if !shared.IsGameNamespace(namespace) {
+		return "", fmt.Errorf("namespace %s is not a game namespace", namespace)
+	}
+	return namespace, nil
+}
 
-	if !result {
+// GetDryRun returns true if the 'dryRun' query parameter of an API call is set to All, like in the Kubernetes API, or to true
+func GetDryRun(r *http.Request) (bool, error) {
+	switch value := r.FormValue("dryRun"); value {
+	case "":
 		return false, nil
+	case "All", "true":
+		return true, nil
+	default:
+		return false, fmt.Errorf("wrong value for dryRun: %s", value)
 	}
-	return true, nil
 }
@@ -0,0 +1,49 @@
+package helpers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestGetDGSTokenIdentity(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: shared.DGSTokenKeysSecretName, Namespace: shared.GameNamespace},
+		Data:       map[string][]byte{shared.DGSTokenCurrentKeyField: []byte("key1"), "key1": []byte("0123456789abcdef0123456789abcdef")},
+	})
+	tokenKeys := shared.NewDGSTokenKeyStore(client, shared.GameNamespace, false)
+	token, err := tokenKeys.MintDGSToken(shared.GameNamespace, "dgs1")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		expected *shared.DGSIdentity
+	}{
+		{name: "bearer token", target: "/setactiveplayers", header: "Bearer " + token, expected: &shared.DGSIdentity{Namespace: shared.GameNamespace, Name: "dgs1"}},
+		{name: "token in the query", target: "/setactiveplayers?token=" + token},
+		{name: "Kubernetes token", target: "/setactiveplayers", header: "Bearer header.payload.signature"},
+		{name: "token with a wrong signature", target: "/setactiveplayers", header: "Bearer " + token[:len(token)-2] + "AA"},
+		{name: "no token", target: "/setactiveplayers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			identity, err := GetDGSTokenIdentity(r, tokenKeys)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, identity)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package webhookserver
+
+import (
+	"reflect"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	hostnameTopologyKey = "kubernetes.io/hostname"
+	zoneTopologyKey     = "failure-domain.beta.kubernetes.io/zone"
+)
+
+// packedPodAffinityTerms make the scheduler prefer the Nodes that already run DGS Pods
+var packedPodAffinityTerms = []corev1.WeightedPodAffinityTerm{
+	{
+		Weight: 100,
+		PodAffinityTerm: corev1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: podLabels,
+			},
+			TopologyKey: hostnameTopologyKey,
+		},
+	},
+}
+
+// distributedPodAntiAffinityTerms make the scheduler prefer the Nodes and the zones that run the fewest DGS Pods
+var distributedPodAntiAffinityTerms = []corev1.WeightedPodAffinityTerm{
+	{
+		Weight: 100,
+		PodAffinityTerm: corev1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: podLabels,
+			},
+			TopologyKey: hostnameTopologyKey,
+		},
+	},
+	{
+		Weight: 50,
+		PodAffinityTerm: corev1.PodAffinityTerm{
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: podLabels,
+			},
+			TopologyKey: zoneTopologyKey,
+		},
+	},
+}
+
+// mergeAffinity returns the user supplied affinity with the terms of the scheduling strategy
+// Terms that were added for any strategy are removed first, so that the result does not depend on previous mutations
+func mergeAffinity(existing *corev1.Affinity, strategy dgsv1alpha1.DedicatedGameServerSchedulingStrategy) *corev1.Affinity {
+	affinity := &corev1.Affinity{}
+	if existing != nil {
+		affinity = existing.DeepCopy()
+	}
+
+	if affinity.PodAffinity != nil {
+		affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution = removeTerms(affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution, packedPodAffinityTerms)
+	}
+	if affinity.PodAntiAffinity != nil {
+		affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = removeTerms(affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, distributedPodAntiAffinityTerms)
+	}
+
+	switch strategy {
+	case dgsv1alpha1.NoneSchedulingStrategy:
+	case dgsv1alpha1.DistributedSchedulingStrategy:
+		if affinity.PodAntiAffinity == nil {
+			affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+		}
+		affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, distributedPodAntiAffinityTerms...)
+	default: // Packed
+		if affinity.PodAffinity == nil {
+			affinity.PodAffinity = &corev1.PodAffinity{}
+		}
+		affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution, packedPodAffinityTerms...)
+	}
+
+	if affinity.PodAffinity != nil && len(affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution) == 0 &&
+		len(affinity.PodAffinity.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+		affinity.PodAffinity = nil
+	}
+	if affinity.PodAntiAffinity != nil && len(affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) == 0 &&
+		len(affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) == 0 {
+		affinity.PodAntiAffinity = nil
+	}
+	if affinity.NodeAffinity == nil && affinity.PodAffinity == nil && affinity.PodAntiAffinity == nil {
+		return nil
+	}
+	return affinity
+}
+
+// removeTerms returns the terms that are not in toRemove
+func removeTerms(terms, toRemove []corev1.WeightedPodAffinityTerm) []corev1.WeightedPodAffinityTerm {
+	var result []corev1.WeightedPodAffinityTerm
+	for _, term := range terms {
+		found := false
+		for _, termToRemove := range toRemove {
+			if reflect.DeepEqual(term, termToRemove) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, term)
+		}
+	}
+	return result
+}
+
+// addAffinity returns the patch operations that set the affinity of the Pod template to the merged one
+func addAffinity(podSpec *corev1.PodSpec, strategy dgsv1alpha1.DedicatedGameServerSchedulingStrategy) []patchOperation {
+	affinity := mergeAffinity(podSpec.Affinity, strategy)
+
+	if affinity == nil {
+		if podSpec.Affinity == nil {
+			return nil
+		}
+		return []patchOperation{{Op: "remove", Path: "/spec/template/affinity"}}
+	}
+
+	if podSpec.Affinity == nil {
+		return []patchOperation{{Op: "add", Path: "/spec/template/affinity", Value: affinity}}
+	}
+	if reflect.DeepEqual(podSpec.Affinity, affinity) {
+		return nil
+	}
+	return []patchOperation{{Op: "replace", Path: "/spec/template/affinity", Value: affinity}}
+}
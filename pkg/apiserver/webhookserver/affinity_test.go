@@ -0,0 +1,145 @@
+package webhookserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var userNodeAffinity = &corev1.NodeAffinity{
+	RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+		NodeSelectorTerms: []corev1.NodeSelectorTerm{
+			{
+				MatchExpressions: []corev1.NodeSelectorRequirement{
+					{Key: "agentpool", Operator: corev1.NodeSelectorOpIn, Values: []string{"gameservers"}},
+				},
+			},
+		},
+	},
+}
+
+var userPodAffinityTerm = corev1.WeightedPodAffinityTerm{
+	Weight: 10,
+	PodAffinityTerm: corev1.PodAffinityTerm{
+		TopologyKey: hostnameTopologyKey,
+	},
+}
+
+func TestMergeAffinity(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *corev1.Affinity
+		strategy dgsv1alpha1.DedicatedGameServerSchedulingStrategy
+		expected *corev1.Affinity
+	}{
+		{
+			name:     "packed is the default",
+			expected: &corev1.Affinity{PodAffinity: &corev1.PodAffinity{PreferredDuringSchedulingIgnoredDuringExecution: packedPodAffinityTerms}},
+		},
+		{
+			name:     "distributed",
+			strategy: dgsv1alpha1.DistributedSchedulingStrategy,
+			expected: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{PreferredDuringSchedulingIgnoredDuringExecution: distributedPodAntiAffinityTerms}},
+		},
+		{
+			name:     "none",
+			strategy: dgsv1alpha1.NoneSchedulingStrategy,
+		},
+		{
+			name:     "none keeps user affinity",
+			existing: &corev1.Affinity{NodeAffinity: userNodeAffinity},
+			strategy: dgsv1alpha1.NoneSchedulingStrategy,
+			expected: &corev1.Affinity{NodeAffinity: userNodeAffinity},
+		},
+		{
+			name:     "packed merges with user affinity",
+			existing: &corev1.Affinity{NodeAffinity: userNodeAffinity, PodAffinity: &corev1.PodAffinity{PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{userPodAffinityTerm}}},
+			strategy: dgsv1alpha1.PackedSchedulingStrategy,
+			expected: &corev1.Affinity{NodeAffinity: userNodeAffinity, PodAffinity: &corev1.PodAffinity{PreferredDuringSchedulingIgnoredDuringExecution: append([]corev1.WeightedPodAffinityTerm{userPodAffinityTerm}, packedPodAffinityTerms...)}},
+		},
+		{
+			name:     "packed is not added twice",
+			existing: &corev1.Affinity{PodAffinity: &corev1.PodAffinity{PreferredDuringSchedulingIgnoredDuringExecution: packedPodAffinityTerms}},
+			strategy: dgsv1alpha1.PackedSchedulingStrategy,
+			expected: &corev1.Affinity{PodAffinity: &corev1.PodAffinity{PreferredDuringSchedulingIgnoredDuringExecution: packedPodAffinityTerms}},
+		},
+		{
+			name:     "changing strategy removes the previous terms",
+			existing: &corev1.Affinity{NodeAffinity: userNodeAffinity, PodAffinity: &corev1.PodAffinity{PreferredDuringSchedulingIgnoredDuringExecution: packedPodAffinityTerms}},
+			strategy: dgsv1alpha1.DistributedSchedulingStrategy,
+			expected: &corev1.Affinity{NodeAffinity: userNodeAffinity, PodAntiAffinity: &corev1.PodAntiAffinity{PreferredDuringSchedulingIgnoredDuringExecution: distributedPodAntiAffinityTerms}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, mergeAffinity(tt.existing, tt.strategy))
+		})
+	}
+}
+
+func TestAddAffinity(t *testing.T) {
+	patch := addAffinity(&corev1.PodSpec{}, dgsv1alpha1.PackedSchedulingStrategy)
+	if assert.Len(t, patch, 1) {
+		assert.Equal(t, "add", patch[0].Op)
+		assert.Equal(t, "/spec/template/affinity", patch[0].Path)
+	}
+
+	patch = addAffinity(&corev1.PodSpec{Affinity: &corev1.Affinity{NodeAffinity: userNodeAffinity}}, dgsv1alpha1.PackedSchedulingStrategy)
+	if assert.Len(t, patch, 1) {
+		assert.Equal(t, "replace", patch[0].Op)
+	}
+
+	// already mutated
+	patch = addAffinity(&corev1.PodSpec{Affinity: mergeAffinity(nil, dgsv1alpha1.PackedSchedulingStrategy)}, dgsv1alpha1.PackedSchedulingStrategy)
+	assert.Len(t, patch, 0)
+
+	patch = addAffinity(&corev1.PodSpec{Affinity: mergeAffinity(nil, dgsv1alpha1.PackedSchedulingStrategy)}, dgsv1alpha1.NoneSchedulingStrategy)
+	if assert.Len(t, patch, 1) {
+		assert.Equal(t, "remove", patch[0].Op)
+	}
+
+	patch = addAffinity(&corev1.PodSpec{}, dgsv1alpha1.NoneSchedulingStrategy)
+	assert.Len(t, patch, 0)
+}
+
+func TestMutateUsesTheObjectOfTheRequestKind(t *testing.T) {
+	dgs := dgsv1alpha1.DedicatedGameServer{
+		Spec: dgsv1alpha1.DedicatedGameServerSpec{
+			SchedulingStrategy: dgsv1alpha1.DistributedSchedulingStrategy,
+			Template: corev1.PodSpec{
+				Affinity: &corev1.Affinity{NodeAffinity: userNodeAffinity},
+			},
+		},
+	}
+	raw, err := json.Marshal(dgs)
+	assert.NoError(t, err)
+
+	ar := &v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			Kind:   metav1.GroupVersionKind{Group: "azuregaming.com", Version: "v1alpha1", Kind: "DedicatedGameServer"},
+			Object: runtime.RawExtension{Raw: raw},
+		},
+	}
+	whsvr := &WebhookServer{}
+	response := whsvr.mutate(ar)
+
+	assert.True(t, response.Allowed)
+	var patch []patchOperation
+	assert.NoError(t, json.Unmarshal(response.Patch, &patch))
+	if assert.Len(t, patch, 1) {
+		// the existing affinity of the DGS is kept
+		assert.Equal(t, "replace", patch[0].Op)
+		affinity := patch[0].Value.(map[string]interface{})
+		assert.Contains(t, affinity, "nodeAffinity")
+		assert.Contains(t, affinity, "podAntiAffinity")
+	}
+}
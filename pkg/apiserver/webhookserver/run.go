@@ -20,6 +20,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 var (
@@ -60,72 +61,96 @@ func init() {
 func (whsvr *WebhookServer) mutate(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionResponse {
 	req := ar.Request
 
-	isDGSCol := false
-	isDGS := false
-	hasExistingAffinity := false
 	var podSpec *corev1.PodSpec
+	var schedulingStrategy dgsv1alpha1.DedicatedGameServerSchedulingStrategy
+	var name string
+	var addressTemplate string
+	var portsToExpose []intstr.IntOrString
+	var portPolicyErr error
+	var err error
+
+	// both objects have a spec.template, so we use the Kind of the request to find out which one we got
+	switch req.Kind.Kind {
+	case shared.DedicatedGameServerCollectionKind:
+		var dgsCol dgsv1alpha1.DedicatedGameServerCollection
+		if err = json.Unmarshal(req.Object.Raw, &dgsCol); err == nil {
+			podSpec = &dgsCol.Spec.Template
+			schedulingStrategy = dgsCol.Spec.SchedulingStrategy
+			name = dgsCol.Name
+			addressTemplate = dgsCol.Spec.AddressTemplate
+			portsToExpose = dgsCol.Spec.PortsToExpose
+			portPolicyErr = shared.ValidatePortPolicy(dgsCol.Spec.PortPolicy, len(shared.GetPortsToExpose(podSpec, dgsCol.Spec.PortsToExpose)))
+		}
+	case shared.DedicatedGameServerKind:
+		var dgs dgsv1alpha1.DedicatedGameServer
+		if err = json.Unmarshal(req.Object.Raw, &dgs); err == nil {
+			podSpec = &dgs.Spec.Template
+			schedulingStrategy = dgs.Spec.SchedulingStrategy
+			name = dgs.Name
+			addressTemplate = dgs.Spec.AddressTemplate
+			portsToExpose = dgs.Spec.PortsToExpose
+		}
+	default:
+		err = fmt.Errorf("unexpected Kind %s", req.Kind.Kind)
+	}
 
-	var dgsCol dgsv1alpha1.DedicatedGameServerCollection
-	err := json.Unmarshal(req.Object.Raw, &dgsCol)
-	if err == nil {
-		isDGSCol = true
-		hasExistingAffinity = dgsCol.Spec.Template.Affinity != nil
-		podSpec = &dgsCol.Spec.Template
+	if err != nil {
+		log.Errorf("Could not unmarshal raw object to either DGSCol or DGS: %v", err)
+		return &v1beta1.AdmissionResponse{
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
+		}
 	}
 
-	var dgs dgsv1alpha1.DedicatedGameServer
-	err = json.Unmarshal(req.Object.Raw, &dgs)
-	if err == nil {
-		isDGS = true
-		hasExistingAffinity = dgsCol.Spec.Template.Affinity != nil
-		podSpec = &dgsCol.Spec.Template
+	if err := shared.ValidatePortsToExpose(podSpec, portsToExpose); err != nil {
+		return &v1beta1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf("Invalid portsToExpose: %s", err.Error()),
+			},
+		}
 	}
 
-	if !isDGSCol && !isDGS {
-		log.Errorf("Could not unmarshal raw object to either DGSCol or DGS: %v", err)
+	if portPolicyErr != nil {
 		return &v1beta1.AdmissionResponse{
+			Allowed: false,
 			Result: &metav1.Status{
-				Message: err.Error(),
+				Message: fmt.Sprintf("Invalid portPolicy: %s", portPolicyErr.Error()),
 			},
 		}
 	}
 
-	//check if all the containers in the PodSpec have requests and limits (CPU and RAM) set
-	for _, container := range podSpec.Containers {
-		//check for requests
-		if container.Resources.Requests.Cpu() == nil ||
-			container.Resources.Requests.Cpu().IsZero() ||
-			container.Resources.Requests.Memory() == nil ||
-			container.Resources.Requests.Memory().IsZero() {
-			return &v1beta1.AdmissionResponse{
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("Container called %s does not have Cpu and/or Memory requests defined", container.Name),
-				},
-			}
+	if _, err := shared.ParseAddressTemplate(addressTemplate); err != nil {
+		return &v1beta1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf("Invalid addressTemplate: %s", err.Error()),
+			},
 		}
+	}
 
-		//check for limits
-		if container.Resources.Limits.Cpu() == nil ||
-			container.Resources.Limits.Cpu().IsZero() ||
-			container.Resources.Limits.Memory() == nil ||
-			container.Resources.Limits.Memory().IsZero() {
-			return &v1beta1.AdmissionResponse{
-				Allowed: false,
-				Result: &metav1.Status{
-					Message: fmt.Sprintf("Container called %s does not have Cpu and/or Memory limits defined", container.Name),
-				},
-			}
+	//check if all the containers in the PodSpec have requests and limits (CPU and RAM) set
+	if err := shared.ValidatePodSpecResources(podSpec); err != nil {
+		return &v1beta1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Message: err.Error(),
+			},
 		}
 	}
 
 	if verboseLogging {
 		log.Infof("AdmissionReview for Kind=%v, Namespace=%v Name=%v (%v) UID=%v k8sOperation=%v UserInfo=%v",
-			req.Kind, req.Namespace, req.Name, dgsCol.Name, req.UID, req.Operation, req.UserInfo)
+			req.Kind, req.Namespace, req.Name, name, req.UID, req.Operation, req.UserInfo)
 	}
 
-	var patch []patchOperation
-	patch = append(patch, addAffinity(hasExistingAffinity))
+	patch := addAffinity(podSpec, schedulingStrategy)
+	if len(patch) == 0 {
+		return &v1beta1.AdmissionResponse{
+			Allowed: true,
+		}
+	}
 
 	patchBytes, err := json.Marshal(patch)
 	if err != nil {
@@ -235,34 +260,3 @@ func Run(certFile, keyFile string, port int) *WebhookServer {
 
 	return whsvr
 }
-
-func addAffinity(affinityExists bool) patchOperation {
-	affinity := corev1.Affinity{
-		PodAffinity: &corev1.PodAffinity{
-			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
-				{
-					Weight: 100,
-					PodAffinityTerm: corev1.PodAffinityTerm{
-						LabelSelector: &metav1.LabelSelector{
-							MatchLabels: podLabels,
-						},
-						TopologyKey: "kubernetes.io/hostname",
-					},
-				},
-			},
-		},
-	}
-
-	operation := "add"
-	if affinityExists {
-		operation = "replace"
-	}
-
-	patchAffinity := patchOperation{
-		Op:    operation,
-		Path:  "/spec/template/affinity",
-		Value: affinity,
-	}
-
-	return patchAffinity
-}
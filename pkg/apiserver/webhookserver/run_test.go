@@ -0,0 +1,100 @@
+package webhookserver
+
+import (
+	"encoding/json"
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+
+	"k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestMutateRejectsInvalidPortPolicy(t *testing.T) {
+	dgsCol := dgsv1alpha1.DedicatedGameServerCollection{
+		Spec: dgsv1alpha1.DedicatedGameServerCollectionSpec{
+			PortsToExpose: []intstr.IntOrString{intstr.FromInt(7777), intstr.FromInt(7778), intstr.FromInt(7779)},
+			PortPolicy:    &dgsv1alpha1.DGSPortPolicy{BlockSize: 2},
+			Template: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:  "game",
+						Ports: []corev1.ContainerPort{{ContainerPort: 7777}, {ContainerPort: 7778}, {ContainerPort: 7779}},
+					},
+				},
+			},
+		},
+	}
+	raw, err := json.Marshal(dgsCol)
+	assert.NoError(t, err)
+
+	ar := &v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			Kind:   metav1.GroupVersionKind{Group: "azuregaming.com", Version: "v1alpha1", Kind: "DedicatedGameServerCollection"},
+			Object: runtime.RawExtension{Raw: raw},
+		},
+	}
+	whsvr := &WebhookServer{}
+	response := whsvr.mutate(ar)
+
+	// 3 exposed ports cannot be split in blocks of 2
+	assert.False(t, response.Allowed)
+	assert.Contains(t, response.Result.Message, "portPolicy")
+}
+
+func TestMutateRejectsInvalidAddressTemplate(t *testing.T) {
+	dgs := dgsv1alpha1.DedicatedGameServer{
+		Spec: dgsv1alpha1.DedicatedGameServerSpec{
+			AddressTemplate: "{{.PublicIP",
+		},
+	}
+	raw, err := json.Marshal(dgs)
+	assert.NoError(t, err)
+
+	ar := &v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			Kind:   metav1.GroupVersionKind{Group: "azuregaming.com", Version: "v1alpha1", Kind: "DedicatedGameServer"},
+			Object: runtime.RawExtension{Raw: raw},
+		},
+	}
+	whsvr := &WebhookServer{}
+	response := whsvr.mutate(ar)
+
+	assert.False(t, response.Allowed)
+	assert.Contains(t, response.Result.Message, "addressTemplate")
+}
+
+func TestMutateRejectsMissingPortsToExpose(t *testing.T) {
+	dgsCol := dgsv1alpha1.DedicatedGameServerCollection{
+		Spec: dgsv1alpha1.DedicatedGameServerCollectionSpec{
+			PortsToExpose: []intstr.IntOrString{intstr.FromString("game"), intstr.FromString("sidecar/query")},
+			Template: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{
+						Name:  "game",
+						Ports: []corev1.ContainerPort{{Name: "game", ContainerPort: 7777}},
+					},
+				},
+			},
+		},
+	}
+	raw, err := json.Marshal(dgsCol)
+	assert.NoError(t, err)
+
+	ar := &v1beta1.AdmissionReview{
+		Request: &v1beta1.AdmissionRequest{
+			Kind:   metav1.GroupVersionKind{Group: "azuregaming.com", Version: "v1alpha1", Kind: "DedicatedGameServerCollection"},
+			Object: runtime.RawExtension{Raw: raw},
+		},
+	}
+	whsvr := &WebhookServer{}
+	response := whsvr.mutate(ar)
+
+	assert.False(t, response.Allowed)
+	assert.Contains(t, response.Result.Message, "sidecar/query")
+}
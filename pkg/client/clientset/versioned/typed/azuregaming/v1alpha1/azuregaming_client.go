@@ -28,6 +28,7 @@ import (
 type AzuregamingV1alpha1Interface interface {
 	RESTClient() rest.Interface
 	DedicatedGameServersGetter
+	DedicatedGameServerAllocationsGetter
 	DedicatedGameServerCollectionsGetter
 }
 
@@ -40,6 +41,10 @@ func (c *AzuregamingV1alpha1Client) DedicatedGameServers(namespace string) Dedic
 	return newDedicatedGameServers(c, namespace)
 }
 
+func (c *AzuregamingV1alpha1Client) DedicatedGameServerAllocations(namespace string) DedicatedGameServerAllocationInterface {
+	return newDedicatedGameServerAllocations(c, namespace)
+}
+
 func (c *AzuregamingV1alpha1Client) DedicatedGameServerCollections(namespace string) DedicatedGameServerCollectionInterface {
 	return newDedicatedGameServerCollections(c, namespace)
 }
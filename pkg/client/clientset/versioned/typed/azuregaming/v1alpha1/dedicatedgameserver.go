@@ -37,6 +37,7 @@ type DedicatedGameServersGetter interface {
 type DedicatedGameServerInterface interface {
 	Create(*v1alpha1.DedicatedGameServer) (*v1alpha1.DedicatedGameServer, error)
 	Update(*v1alpha1.DedicatedGameServer) (*v1alpha1.DedicatedGameServer, error)
+	UpdateStatus(*v1alpha1.DedicatedGameServer) (*v1alpha1.DedicatedGameServer, error)
 	Delete(name string, options *v1.DeleteOptions) error
 	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
 	Get(name string, options v1.GetOptions) (*v1alpha1.DedicatedGameServer, error)
@@ -120,6 +121,22 @@ func (c *dedicatedGameServers) Update(dedicatedGameServer *v1alpha1.DedicatedGam
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *dedicatedGameServers) UpdateStatus(dedicatedGameServer *v1alpha1.DedicatedGameServer) (result *v1alpha1.DedicatedGameServer, err error) {
+	result = &v1alpha1.DedicatedGameServer{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("dedicatedgameservers").
+		Name(dedicatedGameServer.Name).
+		SubResource("status").
+		Body(dedicatedGameServer).
+		Do().
+		Into(result)
+	return
+}
+
 // Delete takes name of the dedicatedGameServer and deletes it. Returns an error if one occurs.
 func (c *dedicatedGameServers) Delete(name string, options *v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -0,0 +1,157 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	scheme "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/scheme"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	rest "k8s.io/client-go/rest"
+)
+
+// DedicatedGameServerAllocationsGetter has a method to return a DedicatedGameServerAllocationInterface.
+// A group's client should implement this interface.
+type DedicatedGameServerAllocationsGetter interface {
+	DedicatedGameServerAllocations(namespace string) DedicatedGameServerAllocationInterface
+}
+
+// DedicatedGameServerAllocationInterface has methods to work with DedicatedGameServerAllocation resources.
+type DedicatedGameServerAllocationInterface interface {
+	Create(*v1alpha1.DedicatedGameServerAllocation) (*v1alpha1.DedicatedGameServerAllocation, error)
+	Update(*v1alpha1.DedicatedGameServerAllocation) (*v1alpha1.DedicatedGameServerAllocation, error)
+	Delete(name string, options *v1.DeleteOptions) error
+	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
+	Get(name string, options v1.GetOptions) (*v1alpha1.DedicatedGameServerAllocation, error)
+	List(opts v1.ListOptions) (*v1alpha1.DedicatedGameServerAllocationList, error)
+	Watch(opts v1.ListOptions) (watch.Interface, error)
+	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.DedicatedGameServerAllocation, err error)
+	DedicatedGameServerAllocationExpansion
+}
+
+// dedicatedGameServerAllocations implements DedicatedGameServerAllocationInterface
+type dedicatedGameServerAllocations struct {
+	client rest.Interface
+	ns     string
+}
+
+// newDedicatedGameServerAllocations returns a DedicatedGameServerAllocations
+func newDedicatedGameServerAllocations(c *AzuregamingV1alpha1Client, namespace string) *dedicatedGameServerAllocations {
+	return &dedicatedGameServerAllocations{
+		client: c.RESTClient(),
+		ns:     namespace,
+	}
+}
+
+// Get takes name of the dedicatedGameServerAllocation, and returns the corresponding dedicatedGameServerAllocation object, and an error if there is any.
+func (c *dedicatedGameServerAllocations) Get(name string, options v1.GetOptions) (result *v1alpha1.DedicatedGameServerAllocation, err error) {
+	result = &v1alpha1.DedicatedGameServerAllocation{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("dedicatedgameserverallocations").
+		Name(name).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// List takes label and field selectors, and returns the list of DedicatedGameServerAllocations that match those selectors.
+func (c *dedicatedGameServerAllocations) List(opts v1.ListOptions) (result *v1alpha1.DedicatedGameServerAllocationList, err error) {
+	result = &v1alpha1.DedicatedGameServerAllocationList{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("dedicatedgameserverallocations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// Watch returns a watch.Interface that watches the requested dedicatedGameServerAllocations.
+func (c *dedicatedGameServerAllocations) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	return c.client.Get().
+		Namespace(c.ns).
+		Resource("dedicatedgameserverallocations").
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+}
+
+// Create takes the representation of a dedicatedGameServerAllocation and creates it.  Returns the server's representation of the dedicatedGameServerAllocation, and an error, if there is any.
+func (c *dedicatedGameServerAllocations) Create(dedicatedGameServerAllocation *v1alpha1.DedicatedGameServerAllocation) (result *v1alpha1.DedicatedGameServerAllocation, err error) {
+	result = &v1alpha1.DedicatedGameServerAllocation{}
+	err = c.client.Post().
+		Namespace(c.ns).
+		Resource("dedicatedgameserverallocations").
+		Body(dedicatedGameServerAllocation).
+		Do().
+		Into(result)
+	return
+}
+
+// Update takes the representation of a dedicatedGameServerAllocation and updates it. Returns the server's representation of the dedicatedGameServerAllocation, and an error, if there is any.
+func (c *dedicatedGameServerAllocations) Update(dedicatedGameServerAllocation *v1alpha1.DedicatedGameServerAllocation) (result *v1alpha1.DedicatedGameServerAllocation, err error) {
+	result = &v1alpha1.DedicatedGameServerAllocation{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("dedicatedgameserverallocations").
+		Name(dedicatedGameServerAllocation.Name).
+		Body(dedicatedGameServerAllocation).
+		Do().
+		Into(result)
+	return
+}
+
+// Delete takes name of the dedicatedGameServerAllocation and deletes it. Returns an error if one occurs.
+func (c *dedicatedGameServerAllocations) Delete(name string, options *v1.DeleteOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("dedicatedgameserverallocations").
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *dedicatedGameServerAllocations) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	return c.client.Delete().
+		Namespace(c.ns).
+		Resource("dedicatedgameserverallocations").
+		VersionedParams(&listOptions, scheme.ParameterCodec).
+		Body(options).
+		Do().
+		Error()
+}
+
+// Patch applies the patch and returns the patched dedicatedGameServerAllocation.
+func (c *dedicatedGameServerAllocations) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.DedicatedGameServerAllocation, err error) {
+	result = &v1alpha1.DedicatedGameServerAllocation{}
+	err = c.client.Patch(pt).
+		Namespace(c.ns).
+		Resource("dedicatedgameserverallocations").
+		SubResource(subresources...).
+		Name(name).
+		Body(data).
+		Do().
+		Into(result)
+	return
+}
@@ -21,6 +21,7 @@ package v1alpha1
 import (
 	v1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
 	scheme "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/scheme"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
 	watch "k8s.io/apimachinery/pkg/watch"
@@ -37,12 +38,16 @@ type DedicatedGameServerCollectionsGetter interface {
 type DedicatedGameServerCollectionInterface interface {
 	Create(*v1alpha1.DedicatedGameServerCollection) (*v1alpha1.DedicatedGameServerCollection, error)
 	Update(*v1alpha1.DedicatedGameServerCollection) (*v1alpha1.DedicatedGameServerCollection, error)
+	UpdateStatus(*v1alpha1.DedicatedGameServerCollection) (*v1alpha1.DedicatedGameServerCollection, error)
 	Delete(name string, options *v1.DeleteOptions) error
 	DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error
 	Get(name string, options v1.GetOptions) (*v1alpha1.DedicatedGameServerCollection, error)
 	List(opts v1.ListOptions) (*v1alpha1.DedicatedGameServerCollectionList, error)
 	Watch(opts v1.ListOptions) (watch.Interface, error)
 	Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.DedicatedGameServerCollection, err error)
+	GetScale(dedicatedGameServerCollectionName string, options v1.GetOptions) (*autoscalingv1.Scale, error)
+	UpdateScale(dedicatedGameServerCollectionName string, scale *autoscalingv1.Scale) (*autoscalingv1.Scale, error)
+
 	DedicatedGameServerCollectionExpansion
 }
 
@@ -120,6 +125,22 @@ func (c *dedicatedGameServerCollections) Update(dedicatedGameServerCollection *v
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *dedicatedGameServerCollections) UpdateStatus(dedicatedGameServerCollection *v1alpha1.DedicatedGameServerCollection) (result *v1alpha1.DedicatedGameServerCollection, err error) {
+	result = &v1alpha1.DedicatedGameServerCollection{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("dedicatedgameservercollections").
+		Name(dedicatedGameServerCollection.Name).
+		SubResource("status").
+		Body(dedicatedGameServerCollection).
+		Do().
+		Into(result)
+	return
+}
+
 // Delete takes name of the dedicatedGameServerCollection and deletes it. Returns an error if one occurs.
 func (c *dedicatedGameServerCollections) Delete(name string, options *v1.DeleteOptions) error {
 	return c.client.Delete().
@@ -155,3 +176,31 @@ func (c *dedicatedGameServerCollections) Patch(name string, pt types.PatchType,
 		Into(result)
 	return
 }
+
+// GetScale takes name of the dedicatedGameServerCollection, and returns the corresponding autoscalingv1.Scale object, and an error if there is any.
+func (c *dedicatedGameServerCollections) GetScale(dedicatedGameServerCollectionName string, options v1.GetOptions) (result *autoscalingv1.Scale, err error) {
+	result = &autoscalingv1.Scale{}
+	err = c.client.Get().
+		Namespace(c.ns).
+		Resource("dedicatedgameservercollections").
+		Name(dedicatedGameServerCollectionName).
+		SubResource("scale").
+		VersionedParams(&options, scheme.ParameterCodec).
+		Do().
+		Into(result)
+	return
+}
+
+// UpdateScale takes the top resource name and the representation of a scale and updates it. Returns the server's representation of the scale, and an error, if there is any.
+func (c *dedicatedGameServerCollections) UpdateScale(dedicatedGameServerCollectionName string, scale *autoscalingv1.Scale) (result *autoscalingv1.Scale, err error) {
+	result = &autoscalingv1.Scale{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("dedicatedgameservercollections").
+		Name(dedicatedGameServerCollectionName).
+		SubResource("scale").
+		Body(scale).
+		Do().
+		Into(result)
+	return
+}
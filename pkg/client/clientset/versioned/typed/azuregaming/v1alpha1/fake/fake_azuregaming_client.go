@@ -32,6 +32,10 @@ func (c *FakeAzuregamingV1alpha1) DedicatedGameServers(namespace string) v1alpha
 	return &FakeDedicatedGameServers{c, namespace}
 }
 
+func (c *FakeAzuregamingV1alpha1) DedicatedGameServerAllocations(namespace string) v1alpha1.DedicatedGameServerAllocationInterface {
+	return &FakeDedicatedGameServerAllocations{c, namespace}
+}
+
 func (c *FakeAzuregamingV1alpha1) DedicatedGameServerCollections(namespace string) v1alpha1.DedicatedGameServerCollectionInterface {
 	return &FakeDedicatedGameServerCollections{c, namespace}
 }
@@ -100,6 +100,18 @@ func (c *FakeDedicatedGameServers) Update(dedicatedGameServer *v1alpha1.Dedicate
 	return obj.(*v1alpha1.DedicatedGameServer), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeDedicatedGameServers) UpdateStatus(dedicatedGameServer *v1alpha1.DedicatedGameServer) (*v1alpha1.DedicatedGameServer, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(dedicatedgameserversResource, "status", c.ns, dedicatedGameServer), &v1alpha1.DedicatedGameServer{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.DedicatedGameServer), err
+}
+
 // Delete takes name of the dedicatedGameServer and deletes it. Returns an error if one occurs.
 func (c *FakeDedicatedGameServers) Delete(name string, options *v1.DeleteOptions) error {
 	_, err := c.Fake.
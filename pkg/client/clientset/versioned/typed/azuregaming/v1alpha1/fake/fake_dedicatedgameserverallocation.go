@@ -0,0 +1,128 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by client-gen. DO NOT EDIT.
+
+package fake
+
+import (
+	v1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	labels "k8s.io/apimachinery/pkg/labels"
+	schema "k8s.io/apimachinery/pkg/runtime/schema"
+	types "k8s.io/apimachinery/pkg/types"
+	watch "k8s.io/apimachinery/pkg/watch"
+	testing "k8s.io/client-go/testing"
+)
+
+// FakeDedicatedGameServerAllocations implements DedicatedGameServerAllocationInterface
+type FakeDedicatedGameServerAllocations struct {
+	Fake *FakeAzuregamingV1alpha1
+	ns   string
+}
+
+var dedicatedgameserverallocationsResource = schema.GroupVersionResource{Group: "azuregaming.com", Version: "v1alpha1", Resource: "dedicatedgameserverallocations"}
+
+var dedicatedgameserverallocationsKind = schema.GroupVersionKind{Group: "azuregaming.com", Version: "v1alpha1", Kind: "DedicatedGameServerAllocation"}
+
+// Get takes name of the dedicatedGameServerAllocation, and returns the corresponding dedicatedGameServerAllocation object, and an error if there is any.
+func (c *FakeDedicatedGameServerAllocations) Get(name string, options v1.GetOptions) (result *v1alpha1.DedicatedGameServerAllocation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetAction(dedicatedgameserverallocationsResource, c.ns, name), &v1alpha1.DedicatedGameServerAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.DedicatedGameServerAllocation), err
+}
+
+// List takes label and field selectors, and returns the list of DedicatedGameServerAllocations that match those selectors.
+func (c *FakeDedicatedGameServerAllocations) List(opts v1.ListOptions) (result *v1alpha1.DedicatedGameServerAllocationList, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewListAction(dedicatedgameserverallocationsResource, dedicatedgameserverallocationsKind, c.ns, opts), &v1alpha1.DedicatedGameServerAllocationList{})
+
+	if obj == nil {
+		return nil, err
+	}
+
+	label, _, _ := testing.ExtractFromListOptions(opts)
+	if label == nil {
+		label = labels.Everything()
+	}
+	list := &v1alpha1.DedicatedGameServerAllocationList{ListMeta: obj.(*v1alpha1.DedicatedGameServerAllocationList).ListMeta}
+	for _, item := range obj.(*v1alpha1.DedicatedGameServerAllocationList).Items {
+		if label.Matches(labels.Set(item.Labels)) {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list, err
+}
+
+// Watch returns a watch.Interface that watches the requested dedicatedGameServerAllocations.
+func (c *FakeDedicatedGameServerAllocations) Watch(opts v1.ListOptions) (watch.Interface, error) {
+	return c.Fake.
+		InvokesWatch(testing.NewWatchAction(dedicatedgameserverallocationsResource, c.ns, opts))
+
+}
+
+// Create takes the representation of a dedicatedGameServerAllocation and creates it.  Returns the server's representation of the dedicatedGameServerAllocation, and an error, if there is any.
+func (c *FakeDedicatedGameServerAllocations) Create(dedicatedGameServerAllocation *v1alpha1.DedicatedGameServerAllocation) (result *v1alpha1.DedicatedGameServerAllocation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewCreateAction(dedicatedgameserverallocationsResource, c.ns, dedicatedGameServerAllocation), &v1alpha1.DedicatedGameServerAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.DedicatedGameServerAllocation), err
+}
+
+// Update takes the representation of a dedicatedGameServerAllocation and updates it. Returns the server's representation of the dedicatedGameServerAllocation, and an error, if there is any.
+func (c *FakeDedicatedGameServerAllocations) Update(dedicatedGameServerAllocation *v1alpha1.DedicatedGameServerAllocation) (result *v1alpha1.DedicatedGameServerAllocation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateAction(dedicatedgameserverallocationsResource, c.ns, dedicatedGameServerAllocation), &v1alpha1.DedicatedGameServerAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.DedicatedGameServerAllocation), err
+}
+
+// Delete takes name of the dedicatedGameServerAllocation and deletes it. Returns an error if one occurs.
+func (c *FakeDedicatedGameServerAllocations) Delete(name string, options *v1.DeleteOptions) error {
+	_, err := c.Fake.
+		Invokes(testing.NewDeleteAction(dedicatedgameserverallocationsResource, c.ns, name), &v1alpha1.DedicatedGameServerAllocation{})
+
+	return err
+}
+
+// DeleteCollection deletes a collection of objects.
+func (c *FakeDedicatedGameServerAllocations) DeleteCollection(options *v1.DeleteOptions, listOptions v1.ListOptions) error {
+	action := testing.NewDeleteCollectionAction(dedicatedgameserverallocationsResource, c.ns, listOptions)
+
+	_, err := c.Fake.Invokes(action, &v1alpha1.DedicatedGameServerAllocationList{})
+	return err
+}
+
+// Patch applies the patch and returns the patched dedicatedGameServerAllocation.
+func (c *FakeDedicatedGameServerAllocations) Patch(name string, pt types.PatchType, data []byte, subresources ...string) (result *v1alpha1.DedicatedGameServerAllocation, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewPatchSubresourceAction(dedicatedgameserverallocationsResource, c.ns, name, data, subresources...), &v1alpha1.DedicatedGameServerAllocation{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.DedicatedGameServerAllocation), err
+}
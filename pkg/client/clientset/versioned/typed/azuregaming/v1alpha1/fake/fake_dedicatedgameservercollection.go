@@ -20,6 +20,7 @@ package fake
 
 import (
 	v1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	labels "k8s.io/apimachinery/pkg/labels"
 	schema "k8s.io/apimachinery/pkg/runtime/schema"
@@ -100,6 +101,18 @@ func (c *FakeDedicatedGameServerCollections) Update(dedicatedGameServerCollectio
 	return obj.(*v1alpha1.DedicatedGameServerCollection), err
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+func (c *FakeDedicatedGameServerCollections) UpdateStatus(dedicatedGameServerCollection *v1alpha1.DedicatedGameServerCollection) (*v1alpha1.DedicatedGameServerCollection, error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(dedicatedgameservercollectionsResource, "status", c.ns, dedicatedGameServerCollection), &v1alpha1.DedicatedGameServerCollection{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*v1alpha1.DedicatedGameServerCollection), err
+}
+
 // Delete takes name of the dedicatedGameServerCollection and deletes it. Returns an error if one occurs.
 func (c *FakeDedicatedGameServerCollections) Delete(name string, options *v1.DeleteOptions) error {
 	_, err := c.Fake.
@@ -126,3 +139,25 @@ func (c *FakeDedicatedGameServerCollections) Patch(name string, pt types.PatchTy
 	}
 	return obj.(*v1alpha1.DedicatedGameServerCollection), err
 }
+
+// GetScale takes name of the dedicatedGameServerCollection, and returns the corresponding scale object, and an error if there is any.
+func (c *FakeDedicatedGameServerCollections) GetScale(dedicatedGameServerCollectionName string, options v1.GetOptions) (result *autoscalingv1.Scale, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewGetSubresourceAction(dedicatedgameservercollectionsResource, c.ns, "scale", dedicatedGameServerCollectionName), &autoscalingv1.Scale{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*autoscalingv1.Scale), err
+}
+
+// UpdateScale takes the representation of a scale and updates it. Returns the server's representation of the scale, and an error, if there is any.
+func (c *FakeDedicatedGameServerCollections) UpdateScale(dedicatedGameServerCollectionName string, scale *autoscalingv1.Scale) (result *autoscalingv1.Scale, err error) {
+	obj, err := c.Fake.
+		Invokes(testing.NewUpdateSubresourceAction(dedicatedgameservercollectionsResource, "scale", c.ns, scale), &autoscalingv1.Scale{})
+
+	if obj == nil {
+		return nil, err
+	}
+	return obj.(*autoscalingv1.Scale), err
+}
@@ -20,4 +20,6 @@ package v1alpha1
 
 type DedicatedGameServerExpansion interface{}
 
+type DedicatedGameServerAllocationExpansion interface{}
+
 type DedicatedGameServerCollectionExpansion interface{}
@@ -26,6 +26,8 @@ import (
 type Interface interface {
 	// DedicatedGameServers returns a DedicatedGameServerInformer.
 	DedicatedGameServers() DedicatedGameServerInformer
+	// DedicatedGameServerAllocations returns a DedicatedGameServerAllocationInformer.
+	DedicatedGameServerAllocations() DedicatedGameServerAllocationInformer
 	// DedicatedGameServerCollections returns a DedicatedGameServerCollectionInformer.
 	DedicatedGameServerCollections() DedicatedGameServerCollectionInformer
 }
@@ -46,6 +48,11 @@ func (v *version) DedicatedGameServers() DedicatedGameServerInformer {
 	return &dedicatedGameServerInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
 
+// DedicatedGameServerAllocations returns a DedicatedGameServerAllocationInformer.
+func (v *version) DedicatedGameServerAllocations() DedicatedGameServerAllocationInformer {
+	return &dedicatedGameServerAllocationInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
+
 // DedicatedGameServerCollections returns a DedicatedGameServerCollectionInformer.
 func (v *version) DedicatedGameServerCollections() DedicatedGameServerCollectionInformer {
 	return &dedicatedGameServerCollectionInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
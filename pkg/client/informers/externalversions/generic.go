@@ -55,6 +55,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=azuregaming.com, Version=v1alpha1
 	case v1alpha1.SchemeGroupVersion.WithResource("dedicatedgameservers"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Azuregaming().V1alpha1().DedicatedGameServers().Informer()}, nil
+	case v1alpha1.SchemeGroupVersion.WithResource("dedicatedgameserverallocations"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Azuregaming().V1alpha1().DedicatedGameServerAllocations().Informer()}, nil
 	case v1alpha1.SchemeGroupVersion.WithResource("dedicatedgameservercollections"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Azuregaming().V1alpha1().DedicatedGameServerCollections().Informer()}, nil
 
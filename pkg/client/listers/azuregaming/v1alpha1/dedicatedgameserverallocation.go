@@ -0,0 +1,94 @@
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by lister-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	v1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DedicatedGameServerAllocationLister helps list DedicatedGameServerAllocations.
+type DedicatedGameServerAllocationLister interface {
+	// List lists all DedicatedGameServerAllocations in the indexer.
+	List(selector labels.Selector) (ret []*v1alpha1.DedicatedGameServerAllocation, err error)
+	// DedicatedGameServerAllocations returns an object that can list and get DedicatedGameServerAllocations.
+	DedicatedGameServerAllocations(namespace string) DedicatedGameServerAllocationNamespaceLister
+	DedicatedGameServerAllocationListerExpansion
+}
+
+// dedicatedGameServerAllocationLister implements the DedicatedGameServerAllocationLister interface.
+type dedicatedGameServerAllocationLister struct {
+	indexer cache.Indexer
+}
+
+// NewDedicatedGameServerAllocationLister returns a new DedicatedGameServerAllocationLister.
+func NewDedicatedGameServerAllocationLister(indexer cache.Indexer) DedicatedGameServerAllocationLister {
+	return &dedicatedGameServerAllocationLister{indexer: indexer}
+}
+
+// List lists all DedicatedGameServerAllocations in the indexer.
+func (s *dedicatedGameServerAllocationLister) List(selector labels.Selector) (ret []*v1alpha1.DedicatedGameServerAllocation, err error) {
+	err = cache.ListAll(s.indexer, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.DedicatedGameServerAllocation))
+	})
+	return ret, err
+}
+
+// DedicatedGameServerAllocations returns an object that can list and get DedicatedGameServerAllocations.
+func (s *dedicatedGameServerAllocationLister) DedicatedGameServerAllocations(namespace string) DedicatedGameServerAllocationNamespaceLister {
+	return dedicatedGameServerAllocationNamespaceLister{indexer: s.indexer, namespace: namespace}
+}
+
+// DedicatedGameServerAllocationNamespaceLister helps list and get DedicatedGameServerAllocations.
+type DedicatedGameServerAllocationNamespaceLister interface {
+	// List lists all DedicatedGameServerAllocations in the indexer for a given namespace.
+	List(selector labels.Selector) (ret []*v1alpha1.DedicatedGameServerAllocation, err error)
+	// Get retrieves the DedicatedGameServerAllocation from the indexer for a given namespace and name.
+	Get(name string) (*v1alpha1.DedicatedGameServerAllocation, error)
+	DedicatedGameServerAllocationNamespaceListerExpansion
+}
+
+// dedicatedGameServerAllocationNamespaceLister implements the DedicatedGameServerAllocationNamespaceLister
+// interface.
+type dedicatedGameServerAllocationNamespaceLister struct {
+	indexer   cache.Indexer
+	namespace string
+}
+
+// List lists all DedicatedGameServerAllocations in the indexer for a given namespace.
+func (s dedicatedGameServerAllocationNamespaceLister) List(selector labels.Selector) (ret []*v1alpha1.DedicatedGameServerAllocation, err error) {
+	err = cache.ListAllByNamespace(s.indexer, s.namespace, selector, func(m interface{}) {
+		ret = append(ret, m.(*v1alpha1.DedicatedGameServerAllocation))
+	})
+	return ret, err
+}
+
+// Get retrieves the DedicatedGameServerAllocation from the indexer for a given namespace and name.
+func (s dedicatedGameServerAllocationNamespaceLister) Get(name string) (*v1alpha1.DedicatedGameServerAllocation, error) {
+	obj, exists, err := s.indexer.GetByKey(s.namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.NewNotFound(v1alpha1.Resource("dedicatedgameserverallocation"), name)
+	}
+	return obj.(*v1alpha1.DedicatedGameServerAllocation), nil
+}
@@ -26,6 +26,14 @@ type DedicatedGameServerListerExpansion interface{}
 // DedicatedGameServerNamespaceLister.
 type DedicatedGameServerNamespaceListerExpansion interface{}
 
+// DedicatedGameServerAllocationListerExpansion allows custom methods to be added to
+// DedicatedGameServerAllocationLister.
+type DedicatedGameServerAllocationListerExpansion interface{}
+
+// DedicatedGameServerAllocationNamespaceListerExpansion allows custom methods to be added to
+// DedicatedGameServerAllocationNamespaceLister.
+type DedicatedGameServerAllocationNamespaceListerExpansion interface{}
+
 // DedicatedGameServerCollectionListerExpansion allows custom methods to be added to
 // DedicatedGameServerCollectionLister.
 type DedicatedGameServerCollectionListerExpansion interface{}
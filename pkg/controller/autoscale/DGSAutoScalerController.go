@@ -1,6 +1,7 @@
 package autoscale
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -20,16 +21,20 @@ import (
 	errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/cache"
 	record "k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/retry"
 	"k8s.io/client-go/util/workqueue"
 )
 
 const activePlayersAutoscalerControllerAgentName = "active-players-auto-scaler-controller"
-const timeformat = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// legacyTimeFormat is the format of the deprecated LastScaleOperationDateTime string
+const legacyTimeFormat = "2006-01-02 15:04:05.999999999 -0700 MST"
 
 // ActivePlayersAutoScalerController is the struct that represents the ActivePlayersAutoScalerController
 type ActivePlayersAutoScalerController struct {
@@ -43,6 +48,10 @@ type ActivePlayersAutoScalerController struct {
 	logger *logrus.Logger
 	clock  clockwork.Clock
 
+	// evaluationInterval is the interval of the periodic evaluation of all the DedicatedGameServerCollections
+	// that have autoscaling enabled. Zero disables the periodic evaluation
+	evaluationInterval time.Duration
+
 	// recorder is an event recorder for recording Event resources to the
 	// Kubernetes API.
 	recorder record.EventRecorder
@@ -53,7 +62,7 @@ type ActivePlayersAutoScalerController struct {
 // NewActivePlayersAutoScalerController creates a new DGSAutoScalerController
 func NewActivePlayersAutoScalerController(client kubernetes.Interface, dgsclient dgsclientset.Interface,
 	dgsColInformer informerdgs.DedicatedGameServerCollectionInformer,
-	dgsInformer informerdgs.DedicatedGameServerInformer, clockImpl clockwork.Clock) *ActivePlayersAutoScalerController {
+	dgsInformer informerdgs.DedicatedGameServerInformer, clockImpl clockwork.Clock, evaluationInterval time.Duration) *ActivePlayersAutoScalerController {
 
 	c := &ActivePlayersAutoScalerController{
 		dgsColClient:       dgsclient,
@@ -63,6 +72,7 @@ func NewActivePlayersAutoScalerController(client kubernetes.Interface, dgsclient
 		dgsLister:          dgsInformer.Lister(),
 		dgsListerSynced:    dgsInformer.Informer().HasSynced,
 		clock:              clockImpl,
+		evaluationInterval: evaluationInterval,
 		logger:             shared.Logger(),
 	}
 
@@ -167,30 +177,49 @@ func (c *ActivePlayersAutoScalerController) syncHandler(key string) error {
 		return nil
 	}
 
-	loc, err := time.LoadLocation("UTC")
+	// carry over the last scale operation time of DGSCols that were created before it moved to the Status
+	dgsColTemp, err = c.migrateLastScaleOperationDateTime(dgsColTemp)
 	if err != nil {
-		c.logger.Error("Cannot load UTC time")
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "Error": err.Error()}).Error("Cannot migrate LastScaleOperationDateTime")
 		return err
 	}
 
-	// lastScaleOperationDateTime != "" => scale in/out has happened before, at least once
-	// let's see if time has passed since then is more than the cooldown threshold
-	if dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime != "" {
-		lastScaleOperation, err := time.ParseInLocation(timeformat, dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime, loc)
+	// get scaler information
+	scalerDetails := dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails
+
+	// scaling schedules override the replica limits during their windows
+	// replicas are brought within the limits right away, regardless of the cooldown
+	if len(scalerDetails.Schedules) > 0 {
+		schedule, err := getActiveScalingSchedule(scalerDetails.Schedules, c.clock.Now())
 		if err != nil {
-			c.logger.WithFields(logrus.Fields{
-				"DGSColName":                 dgsColTemp.Name,
-				"LastScaleOperationDateTime": dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime,
-				"Error":                      err.Error(),
-			}).Info("Cannot parse LastScaleOperationDateTime string. Will ignore cooldown duration")
+			c.recorder.Event(dgsColTemp, corev1.EventTypeWarning, shared.ActivePlayersAutoScalerMisconfigured, err.Error())
+			c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "Error": err.Error()}).Error("Cannot evaluate scaling schedules")
 		} else {
-			currentTime := c.clock.Now().In(loc)
-			durationSinceLastScaleOperation := currentTime.Sub(lastScaleOperation)
-			// cooldown period has not passed
-			if durationSinceLastScaleOperation.Minutes() <= float64(dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails.CoolDownInMinutes) {
-				c.logger.WithField("DGSColName", dgsColTemp.Name).Info("Not checking about ActivePlayers autoscaling because coolDownPeriod has not passed")
-				return nil
+			activeSchedule := "none"
+			if schedule != nil {
+				scalerDetails = applyScalingSchedule(scalerDetails, schedule)
+				activeSchedule = schedule.Name
 			}
+
+			if desiredReplicas := clampReplicas(dgsColTemp.Spec.Replicas, scalerDetails); desiredReplicas != dgsColTemp.Spec.Replicas {
+				reason := fmt.Sprintf(shared.MessageActivePlayersAutoScalerScheduled, dgsColTemp.Name, dgsColTemp.Spec.Replicas, desiredReplicas,
+					scalerDetails.MinimumReplicas, scalerDetails.MaximumReplicas, activeSchedule)
+				return c.scale(dgsColTemp, desiredReplicas, reason)
+			}
+		}
+	}
+
+	// LastScaleOperationTime != nil => scale in/out has happened before, at least once
+	// let's see if time has passed since then is more than the cooldown threshold
+	if autoScalerStatus := dgsColTemp.Status.ActivePlayersAutoScalerStatus; autoScalerStatus != nil && autoScalerStatus.LastScaleOperationTime != nil {
+		coolDown := time.Duration(dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails.CoolDownInMinutes) * time.Minute
+		remainingCoolDown := coolDown - c.clock.Now().Sub(autoScalerStatus.LastScaleOperationTime.Time)
+		// cooldown period has not passed
+		if remainingCoolDown > 0 {
+			c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "RemainingCoolDown": remainingCoolDown}).Info("Not checking about ActivePlayers autoscaling because coolDownPeriod has not passed")
+			// check again when the cooldown passes, since there may be no DGS or DGSCol updates until then
+			c.controllerHelper.Workqueue.AddAfter(key, remainingCoolDown)
+			return nil
 		}
 	}
 
@@ -208,63 +237,189 @@ func (c *ActivePlayersAutoScalerController) syncHandler(key string) error {
 		totalActivePlayers += dgs.Status.ActivePlayers
 	}
 
-	// get scaler information
-	scalerDetails := dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails
-
 	// measure total player capacity
 	totalPlayerCapacity := scalerDetails.MaxPlayersPerServer * len(dgsRunningList)
+	if totalPlayerCapacity == 0 {
+		c.logger.WithField("DGSColName", dgsColTemp.Name).Info("Not checking about ActivePlayers autoscaling because total player capacity is zero")
+		return nil
+	}
 
-	currentLoad := float32(totalActivePlayers) / float32(totalPlayerCapacity)
-	scaleOutThresholdPercent := float32(scalerDetails.ScaleOutThreshold) / float32(100)
-	scaleInThresholdPercent := float32(scalerDetails.ScaleInThreshold) / float32(100)
-
-	// c.logger.WithFields(c.logger.Fields{
-	// 	"DGSCol":                   dgsColTemp.Name,
-	// 	"currentLoad":              currentLoad,
-	// 	"scaleOutThresholdPercent": scaleOutThresholdPercent,
-	// 	"scaleInThresholdPercent":  scaleInThresholdPercent,
-	// 	"len(dgsRunningList)":      len(dgsRunningList),
-	// 	"minReplicas":              scalerDetails.MinimumReplicas,
-	// 	"maxReplicas":              scalerDetails.MaximumReplicas,
-	// }).Info("Scaler details")
-
-	if len(dgsRunningList) < scalerDetails.MaximumReplicas && currentLoad > scaleOutThresholdPercent {
-		//scale out
-		dgsColToUpdate := dgsColTemp.DeepCopy()
-		dgsColToUpdate.Spec.Replicas++
-		dgsColToUpdate.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime = c.clock.Now().In(loc).String()
-		dgsColToUpdate.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
+	desiredReplicas := getActivePlayersDesiredReplicas(scalerDetails, dgsColTemp.Spec.Replicas, len(dgsRunningList), totalActivePlayers)
+	if desiredReplicas == dgsColTemp.Spec.Replicas {
+		return nil
+	}
 
-		_, err := c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgsColToUpdate)
-		if err != nil {
-			c.logger.WithFields(logrus.Fields{"DedicatedGameServerCollectionName": dgsColTemp.Name, "totalActivePlayers": totalActivePlayers, "totalPlayerCapacity": totalPlayerCapacity, "Error": err.Error()}).Error("Cannot scale out based on ActivePlayers")
-			return err
+	reason := fmt.Sprintf(shared.MessageActivePlayersAutoScalerScaled, dgsColTemp.Name, dgsColTemp.Spec.Replicas, desiredReplicas,
+		totalActivePlayers, len(dgsRunningList), scalerDetails.MaxPlayersPerServer, totalActivePlayers*100/totalPlayerCapacity,
+		scalerDetails.ScaleInThreshold, scalerDetails.ScaleOutThreshold)
+	return c.scale(dgsColTemp, desiredReplicas, reason)
+}
+
+// scale scales the DGSCol to the desired replicas, recording the reason of the scaling decision as an event and in the DGSCol Status
+func (c *ActivePlayersAutoScalerController) scale(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, desiredReplicas int32, reason string) error {
+	decision := dgsv1alpha1.ActivePlayersAutoScalerScaleOut
+	if desiredReplicas < dgsCol.Spec.Replicas {
+		decision = dgsv1alpha1.ActivePlayersAutoScalerScaleIn
+	}
+
+	_, err := c.scaleDGSCol(dgsCol, desiredReplicas, decision, reason)
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{"DedicatedGameServerCollectionName": dgsCol.Name, "Reason": reason, "Error": err.Error()}).Error("Cannot scale on ActivePlayersAutoscaler")
+		return err
+	}
+
+	c.recorder.Event(dgsCol, corev1.EventTypeNormal, shared.ActivePlayersAutoScalerScaled, reason)
+	c.logger.WithFields(logrus.Fields{
+		"DedicatedGameServerCollectionName": dgsCol.Name,
+		"fromReplicas":                      dgsCol.Spec.Replicas,
+		"toReplicas":                        desiredReplicas,
+		"Reason":                            reason,
+	}).Info("Scaling occurred on ActivePlayersAutoscaler")
+
+	return nil
+}
+
+// clampReplicas returns the replicas limited to MinimumReplicas and MaximumReplicas
+func clampReplicas(replicas int32, scalerDetails *dgsv1alpha1.DGSActivePlayersAutoScalerDetails) int32 {
+	if replicas > int32(scalerDetails.MaximumReplicas) {
+		replicas = int32(scalerDetails.MaximumReplicas)
+	}
+	if replicas < int32(scalerDetails.MinimumReplicas) {
+		replicas = int32(scalerDetails.MinimumReplicas)
+	}
+	return replicas
+}
+
+// getActivePlayersDesiredReplicas returns the replicas that bring the load of the DGSCol back between ScaleInThreshold and ScaleOutThreshold.
+// The change is limited by MaxScaleOutStep/MaxScaleInStep and the result stays within MinimumReplicas and MaximumReplicas.
+// If the load is already between the thresholds, current replicas are returned
+func getActivePlayersDesiredReplicas(scalerDetails *dgsv1alpha1.DGSActivePlayersAutoScalerDetails, replicas int32, dgsCount int, totalActivePlayers int) int32 {
+	totalPlayerCapacity := scalerDetails.MaxPlayersPerServer * dgsCount
+	if totalPlayerCapacity <= 0 || scalerDetails.ScaleInThreshold <= 0 || scalerDetails.ScaleOutThreshold <= 0 {
+		return replicas
+	}
+
+	// fewest replicas whose load does not exceed ScaleOutThreshold
+	replicasForScaleOutThreshold := ceilDiv(totalActivePlayers*100, scalerDetails.MaxPlayersPerServer*scalerDetails.ScaleOutThreshold)
+
+	// load is above ScaleOutThreshold
+	if totalActivePlayers*100 > totalPlayerCapacity*scalerDetails.ScaleOutThreshold && dgsCount < scalerDetails.MaximumReplicas {
+		desiredReplicas := replicasForScaleOutThreshold
+		if scalerDetails.MaxScaleOutStep > 0 && desiredReplicas > int(replicas)+scalerDetails.MaxScaleOutStep {
+			desiredReplicas = int(replicas) + scalerDetails.MaxScaleOutStep
+		}
+		if desiredReplicas > scalerDetails.MaximumReplicas {
+			desiredReplicas = scalerDetails.MaximumReplicas
+		}
+		if desiredReplicas < int(replicas) {
+			return replicas
 		}
+		return int32(desiredReplicas)
+	}
 
-		c.logger.WithField("DedicatedGameServerCollectionName", dgsColToUpdate.Name).Info("Scale out occurred on ActivePlayersAutoscaler")
+	// load is below ScaleInThreshold
+	if totalActivePlayers*100 < totalPlayerCapacity*scalerDetails.ScaleInThreshold && dgsCount > scalerDetails.MinimumReplicas {
+		// most replicas whose load is not below ScaleInThreshold
+		desiredReplicas := totalActivePlayers * 100 / (scalerDetails.MaxPlayersPerServer * scalerDetails.ScaleInThreshold)
+		// however, load should not go above ScaleOutThreshold, so we don't scale out right after
+		if desiredReplicas < replicasForScaleOutThreshold {
+			desiredReplicas = replicasForScaleOutThreshold
+		}
+		if scalerDetails.MaxScaleInStep > 0 && desiredReplicas < int(replicas)-scalerDetails.MaxScaleInStep {
+			desiredReplicas = int(replicas) - scalerDetails.MaxScaleInStep
+		}
+		if desiredReplicas < scalerDetails.MinimumReplicas {
+			desiredReplicas = scalerDetails.MinimumReplicas
+		}
+		if desiredReplicas > int(replicas) {
+			return replicas
+		}
+		return int32(desiredReplicas)
+	}
 
-		return nil
+	return replicas
+}
+
+// ceilDiv returns the ceiling of a/b for non negative a and positive b
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
+
+// scaleDGSCol sets the requested replicas on the DGSCol spec via a merge patch, so it does not conflict with status updates.
+// It then sets the DGSCol health to Creating and records the scaling decision via a status update.
+// The status update is retried on conflict (e.g. with the DGSCol controller reacting to the new replicas),
+// since the replicas have already been changed and the cooldown should not be lost
+func (c *ActivePlayersAutoScalerController) scaleDGSCol(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, replicas int32,
+	decision dgsv1alpha1.ActivePlayersAutoScalerDecision, reason string) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if len(dgsRunningList) > scalerDetails.MinimumReplicas && currentLoad < scaleInThresholdPercent {
-		//scale in
-		dgsColToUpdate := dgsColTemp.DeepCopy()
-		dgsColToUpdate.Spec.Replicas--
-		dgsColToUpdate.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime = c.clock.Now().In(loc).String()
-		dgsColToUpdate.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
+	dgsColPatched, err := c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Patch(dgsCol.Name, types.MergePatchType, patch)
+	if err != nil {
+		return nil, err
+	}
 
-		_, err := c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgsColToUpdate)
+	now := metav1.NewTime(c.clock.Now())
+	dgsColToUpdate := dgsColPatched
+	var dgsColUpdated *dgsv1alpha1.DedicatedGameServerCollection
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if dgsColToUpdate == nil {
+			dgsColToUpdate, err = c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Get(dgsCol.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+		}
+		dgsColToUpdate.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
+		dgsColToUpdate.Status.ActivePlayersAutoScalerStatus = &dgsv1alpha1.DGSActivePlayersAutoScalerStatus{
+			LastScaleOperationTime: &now,
+			LastDecision:           decision,
+			LastDecisionReason:     reason,
+		}
+		dgsColUpdated, err = c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).UpdateStatus(dgsColToUpdate)
 		if err != nil {
-			c.logger.WithFields(logrus.Fields{"DedicatedGameServerCollectionName": dgsColTemp.Name, "totalActivePlayers": totalActivePlayers, "totalPlayerCapacity": totalPlayerCapacity, "Error": err.Error()}).Error("Cannot scale in")
-			return err
+			// get the latest version of the DGSCol before retrying
+			dgsColToUpdate = nil
 		}
+		return err
+	})
+	return dgsColUpdated, err
+}
 
-		c.logger.WithField("DedicatedGameServerCollectionName", dgsColToUpdate.Name).Info("Scale in occurred on ActivePlayersAutoscaler")
+// migrateLastScaleOperationDateTime carries over the deprecated Spec LastScaleOperationDateTime string
+// to the Status LastScaleOperationTime, if the latter has not been set yet
+// DGSCols with a LastScaleOperationDateTime that cannot be parsed are not migrated, so their cooldown is ignored
+func (c *ActivePlayersAutoScalerController) migrateLastScaleOperationDateTime(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	lastScaleOperationDateTime := dgsCol.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime
+	if lastScaleOperationDateTime == "" ||
+		(dgsCol.Status.ActivePlayersAutoScalerStatus != nil && dgsCol.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime != nil) {
+		return dgsCol, nil
+	}
 
-		return nil
+	lastScaleOperation, err := time.Parse(legacyTimeFormat, lastScaleOperationDateTime)
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{
+			"DGSColName":                 dgsCol.Name,
+			"LastScaleOperationDateTime": lastScaleOperationDateTime,
+			"Error":                      err.Error(),
+		}).Info("Cannot parse LastScaleOperationDateTime string. Will ignore cooldown duration")
+		return dgsCol, nil
 	}
 
-	return nil
+	dgsColToUpdate := dgsCol.DeepCopy()
+	lastScaleOperationTime := metav1.NewTime(lastScaleOperation)
+	if dgsColToUpdate.Status.ActivePlayersAutoScalerStatus == nil {
+		dgsColToUpdate.Status.ActivePlayersAutoScalerStatus = &dgsv1alpha1.DGSActivePlayersAutoScalerStatus{}
+	}
+	dgsColToUpdate.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime = &lastScaleOperationTime
+
+	c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "LastScaleOperationDateTime": lastScaleOperationDateTime}).Info("Migrating LastScaleOperationDateTime to Status")
+	return c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).UpdateStatus(dgsColToUpdate)
 }
 
 // enqueueDedicatedGameServer takes a DedicatedGameServer resource and converts it into a namespace/name
@@ -282,9 +437,46 @@ func (c *ActivePlayersAutoScalerController) enqueueDedicatedGameServerCollection
 
 // Run initiates the AutoScalerController
 func (c *ActivePlayersAutoScalerController) Run(controllerThreadiness int, stopCh <-chan struct{}) error {
+	if c.evaluationInterval > 0 {
+		go c.runPeriodicEvaluation(stopCh)
+	}
 	return c.controllerHelper.Run(controllerThreadiness, stopCh)
 }
 
+// runPeriodicEvaluation enqueues all the DedicatedGameServerCollections that have autoscaling enabled
+// every evaluationInterval, until stopCh is closed
+// This way, autoscaling decisions are made even if there are no DGS or DGSCol updates
+func (c *ActivePlayersAutoScalerController) runPeriodicEvaluation(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-c.clock.After(c.evaluationInterval):
+			c.enqueueAutoScaledDedicatedGameServerCollections()
+		}
+	}
+}
+
+// enqueueAutoScaledDedicatedGameServerCollections enqueues all the DedicatedGameServerCollections that have autoscaling enabled
+func (c *ActivePlayersAutoScalerController) enqueueAutoScaledDedicatedGameServerCollections() {
+	dgsCols, err := c.dgsColLister.List(labels.Everything())
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("error listing DedicatedGameServerCollections for periodic evaluation: %s", err.Error()))
+		return
+	}
+
+	for _, dgsCol := range dgsCols {
+		if dgsCol.Spec.DGSActivePlayersAutoScalerDetails != nil && dgsCol.Spec.DGSActivePlayersAutoScalerDetails.Enabled {
+			key, err := cache.MetaNamespaceKeyFunc(dgsCol)
+			if err != nil {
+				runtime.HandleError(err)
+				continue
+			}
+			c.controllerHelper.Workqueue.Add(key)
+		}
+	}
+}
+
 func (c *ActivePlayersAutoScalerController) handleDedicatedGameServerCollection(obj interface{}) {
 	var object metav1.Object
 	var ok bool
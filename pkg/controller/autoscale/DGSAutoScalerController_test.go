@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
 
 	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
@@ -13,12 +14,15 @@ import (
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 )
 
 type dgsActivePlayersAutoScalerFixture struct {
@@ -38,7 +42,8 @@ type dgsActivePlayersAutoScalerFixture struct {
 	k8sObjects []runtime.Object
 	dgsObjects []runtime.Object
 
-	clock clockwork.FakeClock
+	clock    clockwork.FakeClock
+	recorder *record.FakeRecorder
 }
 
 func newDGSAutoScalerFixture(t *testing.T) *dgsActivePlayersAutoScalerFixture {
@@ -50,6 +55,7 @@ func newDGSAutoScalerFixture(t *testing.T) *dgsActivePlayersAutoScalerFixture {
 	f.dgsObjects = []runtime.Object{}
 
 	f.clock = clockwork.NewFakeClockAt(testhelpers.FixedTime)
+	f.recorder = record.NewFakeRecorder(10)
 	return f
 }
 
@@ -62,12 +68,13 @@ func (f *dgsActivePlayersAutoScalerFixture) newActivePlayersAutoScalerController
 
 	testController := NewActivePlayersAutoScalerController(f.k8sClient, f.dgsClient,
 		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
-		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers(), f.clock)
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers(), f.clock, 0)
 
+	testController.controllerHelper.Workqueue = newClockworkDelayingQueue(testController.controllerHelper.Workqueue, f.clock)
 	testController.dgsColListerSynced = testhelpers.AlwaysReady
 	testController.dgsListerSynced = testhelpers.AlwaysReady
 
-	testController.recorder = &record.FakeRecorder{}
+	testController.recorder = f.recorder
 
 	for _, dgsCol := range f.dgsColLister {
 		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerCollections().Informer().GetIndexer().Add(dgsCol)
@@ -100,6 +107,10 @@ func (f *dgsActivePlayersAutoScalerFixture) runController(dgsName string, startI
 		f.t.Error("expected error syncing DGS, got nil")
 	}
 
+	f.verifyActions()
+}
+
+func (f *dgsActivePlayersAutoScalerFixture) verifyActions() {
 	actions := filterInformerActionsPodAutoScaler(f.dgsClient.Actions())
 
 	for i, action := range actions {
@@ -115,11 +126,53 @@ func (f *dgsActivePlayersAutoScalerFixture) runController(dgsName string, startI
 	if len(f.dgsActions) > len(actions) {
 		f.t.Errorf("%d additional expected actions:%+v", len(f.dgsActions)-len(actions), f.dgsActions[len(actions):])
 	}
+}
+
+// clockworkDelayingQueue is a RateLimitingInterface that delays the items added via AddAfter
+// according to a clockwork Clock, so tests can control when they are added
+type clockworkDelayingQueue struct {
+	workqueue.RateLimitingInterface
+	clock clockwork.Clock
+}
+
+func newClockworkDelayingQueue(queue workqueue.RateLimitingInterface, clock clockwork.Clock) *clockworkDelayingQueue {
+	return &clockworkDelayingQueue{RateLimitingInterface: queue, clock: clock}
+}
+
+func (q *clockworkDelayingQueue) AddAfter(item interface{}, duration time.Duration) {
+	ch := q.clock.After(duration)
+	go func() {
+		<-ch
+		q.Add(item)
+	}()
+}
 
+// getQueueItem returns the next item of the queue, failing the test if there is none in a reasonable time
+func getQueueItem(t *testing.T, queue workqueue.RateLimitingInterface) string {
+	itemCh := make(chan interface{})
+	go func() {
+		item, _ := queue.Get()
+		itemCh <- item
+	}()
+
+	select {
+	case item := <-itemCh:
+		queue.Done(item)
+		return item.(string)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for an item in the workqueue")
+		return ""
+	}
 }
 
-func (f *dgsActivePlayersAutoScalerFixture) expectUpdateDGSColAction(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, assertions func(runtime.Object)) {
-	action := core.NewUpdateAction(schema.GroupVersionResource{Resource: "dedicatedgameservercollections"}, dgsCol.Namespace, dgsCol)
+func (f *dgsActivePlayersAutoScalerFixture) expectPatchDGSColAction(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) {
+	action := core.NewPatchAction(schema.GroupVersionResource{Resource: "dedicatedgameservercollections"}, dgsCol.Namespace, dgsCol.Name, nil)
+	extAction := testhelpers.ExtendedAction{Action: action}
+	f.dgsActions = append(f.dgsActions, extAction)
+}
+
+func (f *dgsActivePlayersAutoScalerFixture) expectUpdateDGSColActionStatus(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, assertions func(runtime.Object)) {
+	action := core.NewUpdateSubresourceAction(schema.GroupVersionResource{Resource: "dedicatedgameservercollections"}, "status", dgsCol.Namespace, dgsCol)
 	extAction := testhelpers.ExtendedAction{Action: action, Assertions: assertions}
 	f.dgsActions = append(f.dgsActions, extAction)
 }
@@ -157,12 +210,18 @@ func TestScaleOutDGSCol(t *testing.T) {
 	f.dgsObjects = append(f.dgsObjects, dgs)
 
 	expDGSCol := dgsCol.DeepCopy()
-	expDGSCol.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime = f.clock.Now().String()
 	expDGSCol.Spec.Replicas = 2
 	expDGSCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
 
-	f.expectUpdateDGSColAction(expDGSCol, nil)
-	//f.expectUpdateDGSColActionStatus(expDGSCol)
+	f.expectPatchDGSColAction(expDGSCol)
+	f.expectUpdateDGSColActionStatus(expDGSCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, expDGSCol.Spec.Replicas, dgsCol.Spec.Replicas)
+		assert.True(t, f.clock.Now().Equal(dgsCol.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime.Time))
+		assert.Equal(t, dgsv1alpha1.DGSColCreating, dgsCol.Status.DGSCollectionHealth)
+		assert.Equal(t, dgsv1alpha1.ActivePlayersAutoScalerScaleOut, dgsCol.Status.ActivePlayersAutoScalerStatus.LastDecision)
+		assert.Contains(t, dgsCol.Status.ActivePlayersAutoScalerStatus.LastDecisionReason, "from 1 to 2 replicas")
+	})
 
 	f.run(getKeyDGSCol(dgsCol, t))
 }
@@ -189,12 +248,12 @@ func TestScaleInDGSCol(t *testing.T) {
 	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
 	dgs.Status.Health = dgsv1alpha1.DGSHealthy
 	dgs.Status.PodPhase = corev1.PodRunning
-	dgs.Status.ActivePlayers = 5
+	dgs.Status.ActivePlayers = 3
 
 	dgs2 := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
 	dgs2.Status.Health = dgsv1alpha1.DGSHealthy
 	dgs2.Status.PodPhase = corev1.PodRunning
-	dgs2.Status.ActivePlayers = 5
+	dgs2.Status.ActivePlayers = 2
 
 	f.dgsColLister = append(f.dgsColLister, dgsCol)
 	f.dgsObjects = append(f.dgsObjects, dgsCol)
@@ -206,19 +265,142 @@ func TestScaleInDGSCol(t *testing.T) {
 	f.dgsObjects = append(f.dgsObjects, dgs2)
 
 	expDGSCol := dgsCol.DeepCopy()
-	expDGSCol.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime = f.clock.Now().String()
 	expDGSCol.Spec.Replicas = 1
 	expDGSCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
 
-	f.expectUpdateDGSColAction(expDGSCol, nil)
-	//f.expectUpdateDGSColActionStatus(expDGSCol)
+	f.expectPatchDGSColAction(expDGSCol)
+	f.expectUpdateDGSColActionStatus(expDGSCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, expDGSCol.Spec.Replicas, dgsCol.Spec.Replicas)
+		assert.True(t, f.clock.Now().Equal(dgsCol.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime.Time))
+		assert.Equal(t, dgsv1alpha1.DGSColCreating, dgsCol.Status.DGSCollectionHealth)
+		assert.Equal(t, dgsv1alpha1.ActivePlayersAutoScalerScaleIn, dgsCol.Status.ActivePlayersAutoScalerStatus.LastDecision)
+	})
 
 	f.run(getKeyDGSCol(dgsCol, t))
 }
 
+func TestScaleOutRetriesConflictingStatusUpdate(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{
+		MinimumReplicas:     1,
+		MaximumReplicas:     5,
+		ScaleInThreshold:    60,
+		ScaleOutThreshold:   80,
+		Enabled:             true,
+		CoolDownInMinutes:   5,
+		MaxPlayersPerServer: 10,
+		MaxScaleOutStep:     1,
+	}
+
+	dgsCol.Spec.Replicas = 1
+	dgsCol.Status.AvailableReplicas = 1
+	dgsCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	dgsCol.Status.PodCollectionState = corev1.PodRunning
+
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Status.Health = dgsv1alpha1.DGSHealthy
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.ActivePlayers = 9
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	f.dgsLister = append(f.dgsLister, dgs)
+	f.dgsObjects = append(f.dgsObjects, dgs)
+
+	expDGSCol := dgsCol.DeepCopy()
+	expDGSCol.Spec.Replicas = 2
+
+	f.expectPatchDGSColAction(expDGSCol)
+	// the first status update conflicts with the DGSCol controller, so the DGSCol is fetched and updated again
+	f.expectUpdateDGSColActionStatus(expDGSCol, nil)
+	f.dgsActions = append(f.dgsActions, testhelpers.ExtendedAction{
+		Action: core.NewGetAction(schema.GroupVersionResource{Resource: "dedicatedgameservercollections"}, dgsCol.Namespace, dgsCol.Name),
+	})
+	f.expectUpdateDGSColActionStatus(expDGSCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, expDGSCol.Spec.Replicas, dgsCol.Spec.Replicas)
+		assert.True(t, f.clock.Now().Equal(dgsCol.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime.Time))
+		assert.Equal(t, dgsv1alpha1.ActivePlayersAutoScalerScaleOut, dgsCol.Status.ActivePlayersAutoScalerStatus.LastDecision)
+	})
+
+	testController, dgsInformers := f.newActivePlayersAutoScalerController()
+	conflicted := false
+	f.dgsClient.PrependReactor("update", "dedicatedgameservercollections", func(action core.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() == "status" && !conflicted {
+			conflicted = true
+			return true, nil, errors.NewConflict(schema.GroupResource{Resource: "dedicatedgameservercollections"}, dgsCol.Name, nil)
+		}
+		return false, nil, nil
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	dgsInformers.Start(stopCh)
+
+	err := testController.syncHandler(getKeyDGSCol(dgsCol, t))
+	assert.NoError(t, err)
+	f.verifyActions()
+
+	// the cooldown is recorded, so the next scale out step waits for it
+	dgsColUpdated, err := f.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Get(dgsCol.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), dgsColUpdated.Spec.Replicas)
+	assert.NotNil(t, dgsColUpdated.Status.ActivePlayersAutoScalerStatus)
+}
+
 func TestDoNothingBecauseOfCoolDown(t *testing.T) {
 	f := newDGSAutoScalerFixture(t)
 
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{
+		MinimumReplicas:     1,
+		MaximumReplicas:     5,
+		ScaleInThreshold:    60,
+		ScaleOutThreshold:   80,
+		Enabled:             true,
+		CoolDownInMinutes:   5,
+		MaxPlayersPerServer: 10,
+	}
+	lastScaleOperationTime := metav1.NewTime(f.clock.Now())
+	dgsCol.Status.ActivePlayersAutoScalerStatus = &dgsv1alpha1.DGSActivePlayersAutoScalerStatus{LastScaleOperationTime: &lastScaleOperationTime}
+
+	f.clock.Advance(1 * time.Minute)
+
+	dgsCol.Spec.Replicas = 1
+	dgsCol.Status.AvailableReplicas = 1
+	dgsCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	dgsCol.Status.PodCollectionState = corev1.PodRunning
+
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+
+	dgs.Status.Health = dgsv1alpha1.DGSHealthy
+
+	dgs.Status.PodPhase = corev1.PodRunning
+
+	dgs.Status.ActivePlayers = 9
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	f.dgsLister = append(f.dgsLister, dgs)
+	f.dgsObjects = append(f.dgsObjects, dgs)
+
+	expDGSCol := dgsCol.DeepCopy()
+	expDGSCol.Spec.Replicas = 2
+	expDGSCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
+
+	//expect nothing
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestMigratesLastScaleOperationDateTime(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
 	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
 	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{
 		MinimumReplicas:            1,
@@ -252,12 +434,12 @@ func TestDoNothingBecauseOfCoolDown(t *testing.T) {
 	f.dgsLister = append(f.dgsLister, dgs)
 	f.dgsObjects = append(f.dgsObjects, dgs)
 
-	expDGSCol := dgsCol.DeepCopy()
-	expDGSCol.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime = f.clock.Now().String()
-	expDGSCol.Spec.Replicas = 2
-	expDGSCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
-
-	//expect nothing
+	// the deprecated LastScaleOperationDateTime is carried over to the Status, and the cooldown still applies
+	f.expectUpdateDGSColActionStatus(dgsCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, int32(1), dgsCol.Spec.Replicas)
+		assert.True(t, testhelpers.FixedTime.Equal(dgsCol.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime.Time))
+	})
 
 	f.run(getKeyDGSCol(dgsCol, t))
 }
@@ -298,16 +480,207 @@ func TestWithMalformedLastScaleTime(t *testing.T) {
 	f.dgsObjects = append(f.dgsObjects, dgs)
 
 	expDGSCol := dgsCol.DeepCopy()
-	expDGSCol.Spec.DGSActivePlayersAutoScalerDetails.LastScaleOperationDateTime = f.clock.Now().String()
 	expDGSCol.Spec.Replicas = 2
 	expDGSCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColCreating
 
-	f.expectUpdateDGSColAction(expDGSCol, nil)
-	//f.expectUpdateDGSColActionStatus(expDGSCol)
+	f.expectPatchDGSColAction(expDGSCol)
+	f.expectUpdateDGSColActionStatus(expDGSCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, expDGSCol.Spec.Replicas, dgsCol.Spec.Replicas)
+		assert.True(t, f.clock.Now().Equal(dgsCol.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime.Time))
+		assert.Equal(t, dgsv1alpha1.DGSColCreating, dgsCol.Status.DGSCollectionHealth)
+	})
 
 	f.run(getKeyDGSCol(dgsCol, t))
 }
 
+func TestScaleOutAfterCoolDownWithoutEvents(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{
+		MinimumReplicas:     1,
+		MaximumReplicas:     5,
+		ScaleInThreshold:    60,
+		ScaleOutThreshold:   80,
+		Enabled:             true,
+		CoolDownInMinutes:   5,
+		MaxPlayersPerServer: 10,
+	}
+	lastScaleOperationTime := metav1.NewTime(f.clock.Now())
+	dgsCol.Status.ActivePlayersAutoScalerStatus = &dgsv1alpha1.DGSActivePlayersAutoScalerStatus{LastScaleOperationTime: &lastScaleOperationTime}
+
+	f.clock.Advance(1 * time.Minute)
+
+	dgsCol.Status.AvailableReplicas = 1
+	dgsCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	dgsCol.Status.PodCollectionState = corev1.PodRunning
+
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Status.Health = dgsv1alpha1.DGSHealthy
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.ActivePlayers = 9
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	f.dgsLister = append(f.dgsLister, dgs)
+	f.dgsObjects = append(f.dgsObjects, dgs)
+
+	testController, dgsInformers := f.newActivePlayersAutoScalerController()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	dgsInformers.Start(stopCh)
+
+	// cooldown has not passed, so nothing happens but the DGSCol is requeued
+	key := getKeyDGSCol(dgsCol, t)
+	err := testController.syncHandler(key)
+	assert.NoError(t, err)
+	f.verifyActions()
+	assert.Equal(t, 0, testController.controllerHelper.Workqueue.Len())
+
+	// cooldown passes, with no DGS or DGSCol updates in the meantime
+	f.clock.Advance(4 * time.Minute)
+	assert.Equal(t, key, getQueueItem(t, testController.controllerHelper.Workqueue))
+
+	expDGSCol := dgsCol.DeepCopy()
+	expDGSCol.Spec.Replicas = 2
+	f.expectPatchDGSColAction(expDGSCol)
+	f.expectUpdateDGSColActionStatus(expDGSCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, expDGSCol.Spec.Replicas, dgsCol.Spec.Replicas)
+		assert.True(t, f.clock.Now().Equal(dgsCol.Status.ActivePlayersAutoScalerStatus.LastScaleOperationTime.Time))
+	})
+
+	err = testController.syncHandler(key)
+	assert.NoError(t, err)
+	f.verifyActions()
+}
+
+func TestPeriodicEvaluation(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{Enabled: true}
+	dgsColNotAutoScaled := shared.NewDedicatedGameServerCollection("notautoscaled", shared.GameNamespace, 1, testhelpers.PodSpec)
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol, dgsColNotAutoScaled)
+	f.dgsObjects = append(f.dgsObjects, dgsCol, dgsColNotAutoScaled)
+
+	testController, _ := f.newActivePlayersAutoScalerController()
+	testController.evaluationInterval = time.Minute
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go testController.runPeriodicEvaluation(stopCh)
+
+	for i := 0; i < 2; i++ {
+		f.clock.BlockUntil(1)
+		assert.Equal(t, 0, testController.controllerHelper.Workqueue.Len())
+		f.clock.Advance(time.Minute)
+		// only the DGSCol with autoscaling enabled is enqueued
+		assert.Equal(t, getKeyDGSCol(dgsCol, t), getQueueItem(t, testController.controllerHelper.Workqueue))
+	}
+}
+
+func TestScaleOutMultipleReplicas(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 2, testhelpers.PodSpec)
+	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{
+		MinimumReplicas:     1,
+		MaximumReplicas:     20,
+		ScaleInThreshold:    40,
+		ScaleOutThreshold:   80,
+		Enabled:             true,
+		CoolDownInMinutes:   5,
+		MaxPlayersPerServer: 10,
+	}
+
+	dgsCol.Status.AvailableReplicas = 2
+	dgsCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	dgsCol.Status.PodCollectionState = corev1.PodRunning
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	// servers are full and 20 more players are waiting, i.e. 40 players need 5 servers at 80% load
+	for _, activePlayers := range []int{20, 20} {
+		dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+		dgs.Status.Health = dgsv1alpha1.DGSHealthy
+		dgs.Status.PodPhase = corev1.PodRunning
+		dgs.Status.ActivePlayers = activePlayers
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+	}
+
+	expDGSCol := dgsCol.DeepCopy()
+	expDGSCol.Spec.Replicas = 5
+	f.expectPatchDGSColAction(expDGSCol)
+	f.expectUpdateDGSColActionStatus(expDGSCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, expDGSCol.Spec.Replicas, dgsCol.Spec.Replicas)
+	})
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	select {
+	case event := <-f.recorder.Events:
+		assert.Contains(t, event, shared.ActivePlayersAutoScalerScaled)
+		assert.Contains(t, event, "from 2 to 5 replicas, 40 ActivePlayers on 2 DedicatedGameServers with 10 MaxPlayersPerServer (load 200%")
+	default:
+		t.Error("Expected an event for the scaling decision")
+	}
+}
+
+func TestGetActivePlayersDesiredReplicas(t *testing.T) {
+	tests := []struct {
+		name               string
+		replicas           int32
+		totalActivePlayers int
+		maxScaleOutStep    int
+		maxScaleInStep     int
+		minReplicas        int
+		maxReplicas        int
+		desiredReplicas    int32
+	}{
+		{name: "within thresholds", replicas: 10, totalActivePlayers: 70, desiredReplicas: 10},
+		{name: "on scale out threshold", replicas: 10, totalActivePlayers: 80, desiredReplicas: 10},
+		{name: "on scale in threshold", replicas: 10, totalActivePlayers: 40, desiredReplicas: 10},
+		{name: "scale out by one", replicas: 10, totalActivePlayers: 81, desiredReplicas: 11},
+		{name: "scale out proportionally", replicas: 10, totalActivePlayers: 100, desiredReplicas: 13},
+		{name: "scale out limited by step", replicas: 10, totalActivePlayers: 100, maxScaleOutStep: 2, desiredReplicas: 12},
+		{name: "scale out limited by maximum", replicas: 10, totalActivePlayers: 200, maxReplicas: 15, desiredReplicas: 15},
+		{name: "scale in proportionally", replicas: 10, totalActivePlayers: 20, desiredReplicas: 5},
+		{name: "scale in limited by step", replicas: 10, totalActivePlayers: 20, maxScaleInStep: 3, desiredReplicas: 7},
+		{name: "scale in limited by minimum", replicas: 10, totalActivePlayers: 0, minReplicas: 2, desiredReplicas: 2},
+		{name: "scale in keeps load above scale in threshold", replicas: 10, totalActivePlayers: 39, desiredReplicas: 9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scalerDetails := &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{
+				Enabled:             true,
+				MinimumReplicas:     1,
+				MaximumReplicas:     100,
+				ScaleInThreshold:    40,
+				ScaleOutThreshold:   80,
+				MaxPlayersPerServer: 10,
+				MaxScaleOutStep:     tt.maxScaleOutStep,
+				MaxScaleInStep:      tt.maxScaleInStep,
+			}
+			if tt.minReplicas > 0 {
+				scalerDetails.MinimumReplicas = tt.minReplicas
+			}
+			if tt.maxReplicas > 0 {
+				scalerDetails.MaximumReplicas = tt.maxReplicas
+			}
+			desiredReplicas := getActivePlayersDesiredReplicas(scalerDetails, tt.replicas, int(tt.replicas), tt.totalActivePlayers)
+			assert.Equal(t, tt.desiredReplicas, desiredReplicas)
+		})
+	}
+}
+
 // filterInformerActionsDGS filters list and watch actions for testing resources.
 // Since list and watch don't change resource state we can filter it to lower
 // noise level in our tests.
@@ -0,0 +1,350 @@
+package autoscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	dgsclientset "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
+	dgsscheme "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/scheme"
+	informerdgs "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions/azuregaming/v1alpha1"
+	listerdgs "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/listers/azuregaming/v1alpha1"
+	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	logrus "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	record "k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const bufferAutoscalerControllerAgentName = "buffer-auto-scaler-controller"
+
+// BufferAutoScalerController is the struct that represents the BufferAutoScalerController
+// It scales DedicatedGameServerCollections so that they always have a buffer of Idle DedicatedGameServers
+type BufferAutoScalerController struct {
+	dgsColClient       dgsclientset.Interface
+	dgsColLister       listerdgs.DedicatedGameServerCollectionLister
+	dgsLister          listerdgs.DedicatedGameServerLister
+	dgsColListerSynced cache.InformerSynced
+	dgsListerSynced    cache.InformerSynced
+
+	logger *logrus.Logger
+
+	// recorder is an event recorder for recording Event resources to the
+	// Kubernetes API.
+	recorder record.EventRecorder
+
+	controllerHelper *controllers.ControllerHelper
+}
+
+// NewBufferAutoScalerController creates a new BufferAutoScalerController
+func NewBufferAutoScalerController(client kubernetes.Interface, dgsclient dgsclientset.Interface,
+	dgsColInformer informerdgs.DedicatedGameServerCollectionInformer,
+	dgsInformer informerdgs.DedicatedGameServerInformer) *BufferAutoScalerController {
+
+	c := &BufferAutoScalerController{
+		dgsColClient:       dgsclient,
+		dgsColLister:       dgsColInformer.Lister(),
+		dgsColListerSynced: dgsColInformer.Informer().HasSynced,
+		dgsLister:          dgsInformer.Lister(),
+		dgsListerSynced:    dgsInformer.Informer().HasSynced,
+		logger:             shared.Logger(),
+	}
+
+	c.controllerHelper = controllers.NewControllerHelper(
+		workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "BufferAutoScalerSync"),
+		c.logger,
+		c.syncHandler,
+		"BufferAutoScalerController",
+		[]cache.InformerSynced{c.dgsColListerSynced, c.dgsListerSynced},
+	)
+
+	dgsscheme.AddToScheme(dgsscheme.Scheme)
+	c.logger.Info("Creating event broadcaster for BufferAutoScaler controller")
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(c.logger.Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	c.recorder = eventBroadcaster.NewRecorder(dgsscheme.Scheme, corev1.EventSource{Component: bufferAutoscalerControllerAgentName})
+
+	c.logger.Info("Setting up event handlers for BufferAutoScaler controller")
+
+	dgsColInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.logger.Print("BufferAutoScaler controller - add DedicatedGameServerCollection")
+				c.handleDedicatedGameServerCollection(obj)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				c.logger.Print("BufferAutoScaler controller - update DedicatedGameServerCollection")
+				oldDGSCol := oldObj.(*dgsv1alpha1.DedicatedGameServerCollection)
+				newDGSCol := newObj.(*dgsv1alpha1.DedicatedGameServerCollection)
+				if oldDGSCol.ResourceVersion == newDGSCol.ResourceVersion {
+					return
+				}
+				c.handleDedicatedGameServerCollection(newObj)
+			},
+		},
+	)
+
+	// every change in the DGSs may affect the buffer, e.g. an allocation sets a DGS state to Assigned
+	dgsInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.logger.Print("BufferAutoScaler controller - add DedicatedGameServer")
+				c.handleDedicatedGameServer(obj)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				c.logger.Print("BufferAutoScaler controller - update DedicatedGameServer")
+				oldDGS := oldObj.(*dgsv1alpha1.DedicatedGameServer)
+				newDGS := newObj.(*dgsv1alpha1.DedicatedGameServer)
+				if oldDGS.ResourceVersion == newDGS.ResourceVersion {
+					return
+				}
+				c.handleDedicatedGameServer(newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				c.logger.Print("BufferAutoScaler controller - delete DedicatedGameServer")
+				c.handleDedicatedGameServer(obj)
+			},
+		},
+	)
+	return c
+}
+
+// syncHandler calculates the replicas the DedicatedGameServerCollection needs in order to have the requested buffer
+// of Idle DedicatedGameServers and scales it, if needed
+func (c *BufferAutoScalerController) syncHandler(key string) error {
+	// Convert the namespace/name string into a distinct namespace and name
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+
+	dgsColTemp, err := c.dgsColLister.DedicatedGameServerCollections(namespace).Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			runtime.HandleError(fmt.Errorf("DedicatedGameServerCollection '%s' in work queue no longer exists", key))
+			return nil
+		}
+		c.logger.WithField("DGSColName", name).Errorf("Error listing DGSCol: %s", err.Error())
+		return err
+	}
+
+	// DGSCol is being terminated
+	if !dgsColTemp.DeletionTimestamp.IsZero() {
+		c.logger.WithField("DGSColName", dgsColTemp.Name).Info("DGSCol is being terminated")
+		return nil
+	}
+
+	// check if it has buffer autoscaling enabled
+	scalerDetails := dgsColTemp.Spec.DGSBufferAutoScalerDetails
+	if scalerDetails == nil || !scalerDetails.Enabled {
+		return nil
+	}
+
+	// the two autoscalers would fight over the replicas
+	if dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails != nil && dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails.Enabled {
+		c.logger.WithField("DGSColName", dgsColTemp.Name).Info("Not checking about buffer autoscaling because ActivePlayers autoscaling is enabled as well")
+		return nil
+	}
+
+	// DGSCol needs intervention, so no DGSs will be created
+	if dgsColTemp.Status.DGSCollectionHealth == dgsv1alpha1.DGSColNeedsIntervention {
+		c.logger.WithField("DGSColName", dgsColTemp.Name).Info("Not checking about buffer autoscaling because DGSCol is in NeedsIntervention state")
+		return nil
+	}
+
+	set := labels.Set{
+		shared.LabelDedicatedGameServerCollectionName: dgsColTemp.Name,
+	}
+	dgss, err := c.dgsLister.DedicatedGameServers(dgsColTemp.Namespace).List(labels.SelectorFromSet(set))
+	if err != nil {
+		return err
+	}
+
+	busyCount, idleHealthyCount := countBusyAndIdleDGSs(dgss)
+
+	desiredReplicas, err := getBufferDesiredReplicas(scalerDetails, busyCount)
+	if err != nil {
+		c.recorder.Event(dgsColTemp, corev1.EventTypeWarning, shared.BufferAutoScalerMisconfigured, err.Error())
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "Error": err.Error()}).Error("Cannot calculate buffer autoscaling replicas")
+		// requeuing will not help, the DGSCol needs to be fixed
+		return nil
+	}
+
+	if desiredReplicas == dgsColTemp.Spec.Replicas {
+		return nil
+	}
+
+	err = c.scaleDGSCol(dgsColTemp, desiredReplicas)
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "DesiredReplicas": desiredReplicas, "Error": err.Error()}).Error("Cannot scale based on buffer")
+		return err
+	}
+
+	c.recorder.Event(dgsColTemp, corev1.EventTypeNormal, shared.BufferAutoScalerScaled,
+		fmt.Sprintf(shared.MessageBufferAutoScalerScaled, dgsColTemp.Name, dgsColTemp.Spec.Replicas, desiredReplicas, busyCount, idleHealthyCount))
+	c.logger.WithFields(logrus.Fields{
+		"DGSColName":       dgsColTemp.Name,
+		"Replicas":         dgsColTemp.Spec.Replicas,
+		"DesiredReplicas":  desiredReplicas,
+		"BusyCount":        busyCount,
+		"IdleHealthyCount": idleHealthyCount,
+	}).Info("Scale occurred on BufferAutoScaler")
+
+	return nil
+}
+
+// countBusyAndIdleDGSs returns the number of DGSs that are in a match and the number of the Idle and Healthy ones
+// Failed DGSs and the ones MarkedForDeletion are not counted
+func countBusyAndIdleDGSs(dgss []*dgsv1alpha1.DedicatedGameServer) (int32, int32) {
+	var busyCount, idleHealthyCount int32
+	for _, dgs := range dgss {
+		if dgs.Status.Health == dgsv1alpha1.DGSFailed || dgs.Status.MarkedForDeletion {
+			continue
+		}
+		if dgs.Status.DGSState == "" || dgs.Status.DGSState == dgsv1alpha1.DGSIdle {
+			if dgs.Status.Health == dgsv1alpha1.DGSHealthy {
+				idleHealthyCount++
+			}
+		} else {
+			busyCount++
+		}
+	}
+	return busyCount, idleHealthyCount
+}
+
+// getBufferDesiredReplicas returns the replicas that are needed so that there are BufferSize DGSs on top of the busy ones
+// DGSs that are still being created count towards the buffer, since they will be Idle once they are Healthy
+// If BufferSize is a percentage, it refers to the desired replicas, e.g. 20% with 8 busy DGSs means 10 replicas
+func getBufferDesiredReplicas(scalerDetails *dgsv1alpha1.DGSBufferAutoScalerDetails, busyCount int32) (int32, error) {
+	if scalerDetails.MinimumReplicas > scalerDetails.MaximumReplicas {
+		return 0, fmt.Errorf("MinimumReplicas %d is greater than MaximumReplicas %d", scalerDetails.MinimumReplicas, scalerDetails.MaximumReplicas)
+	}
+
+	var desiredReplicas int32
+	if scalerDetails.BufferSize.Type == intstr.String {
+		// we use 100 as the total, so we get the percentage value
+		percent, err := intstr.GetValueFromIntOrPercent(&scalerDetails.BufferSize, 100, true)
+		if err != nil {
+			return 0, err
+		}
+		if percent < 0 || percent >= 100 {
+			return 0, fmt.Errorf("BufferSize %s should be between 0%% and 99%%", scalerDetails.BufferSize.String())
+		}
+		desiredReplicas = int32(math.Ceil(float64(busyCount) * 100 / float64(100-percent)))
+	} else {
+		if scalerDetails.BufferSize.IntVal < 0 {
+			return 0, fmt.Errorf("BufferSize %d should not be negative", scalerDetails.BufferSize.IntVal)
+		}
+		desiredReplicas = busyCount + scalerDetails.BufferSize.IntVal
+	}
+
+	if desiredReplicas < int32(scalerDetails.MinimumReplicas) {
+		desiredReplicas = int32(scalerDetails.MinimumReplicas)
+	}
+	if desiredReplicas > int32(scalerDetails.MaximumReplicas) {
+		desiredReplicas = int32(scalerDetails.MaximumReplicas)
+	}
+	return desiredReplicas, nil
+}
+
+// scaleDGSCol sets the requested replicas on the DGSCol spec via a merge patch, so it does not conflict with status updates
+func (c *BufferAutoScalerController) scaleDGSCol(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, replicas int32) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Patch(dgsCol.Name, types.MergePatchType, patch)
+	return err
+}
+
+// enqueueDedicatedGameServerCollection takes a DedicatedGameServerCollection resource and converts it into a namespace/name
+// string which is then put onto the work queue. This method should *not* be
+// passed resources of any type other than DedicatedGameServerCollection.
+// The key is added without rate limiting, since we want to react immediately when the buffer is drained
+func (c *BufferAutoScalerController) enqueueDedicatedGameServerCollection(obj interface{}) {
+	var key string
+	var err error
+	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.controllerHelper.Workqueue.Add(key)
+}
+
+// Run initiates the BufferAutoScalerController
+func (c *BufferAutoScalerController) Run(controllerThreadiness int, stopCh <-chan struct{}) error {
+	return c.controllerHelper.Run(controllerThreadiness, stopCh)
+}
+
+func (c *BufferAutoScalerController) handleDedicatedGameServerCollection(obj interface{}) {
+	var object metav1.Object
+	var ok bool
+	if object, ok = obj.(metav1.Object); !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			runtime.HandleError(fmt.Errorf("error decoding DedicatedGameServerCollection object, invalid type"))
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			runtime.HandleError(fmt.Errorf("error decoding DedicatedGameServerCollection object tombstone, invalid type"))
+			return
+		}
+		c.logger.Infof("Recovered deleted DedicatedGameServerCollection object '%s' from tombstone", object.GetName())
+	}
+	c.enqueueDedicatedGameServerCollection(object)
+}
+
+func (c *BufferAutoScalerController) handleDedicatedGameServer(obj interface{}) {
+	var object metav1.Object
+	var ok bool
+	if object, ok = obj.(metav1.Object); !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			runtime.HandleError(fmt.Errorf("error decoding DedicatedGameServer object, invalid type"))
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			runtime.HandleError(fmt.Errorf("error decoding DedicatedGameServer object tombstone, invalid type"))
+			return
+		}
+		c.logger.Infof("Recovered deleted DedicatedGameServer object '%s' from tombstone", object.GetName())
+	}
+
+	// a DGS that was marked for deletion has left the collection, but the collection may need a new one
+	dgsColName, ok := object.GetLabels()[shared.LabelDedicatedGameServerCollectionName]
+	if !ok {
+		dgsColName, ok = object.GetLabels()[shared.LabelOriginalDedicatedGameServerCollectionName]
+	}
+	if !ok {
+		return
+	}
+
+	dgsCol, err := c.dgsColLister.DedicatedGameServerCollections(object.GetNamespace()).Get(dgsColName)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("error getting a DedicatedGameServerCollection from the DedicatedGameServer with Name %s", object.GetName()))
+		return
+	}
+	c.enqueueDedicatedGameServerCollection(dgsCol)
+}
@@ -0,0 +1,276 @@
+package autoscale
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+	dgsinformers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+type dgsBufferAutoScalerFixture struct {
+	t *testing.T
+
+	k8sClient *k8sfake.Clientset
+	dgsClient *fake.Clientset
+
+	// Objects to put in the store.
+	dgsColLister []*dgsv1alpha1.DedicatedGameServerCollection
+	dgsLister    []*dgsv1alpha1.DedicatedGameServer
+
+	// Actions expected to happen on the client.
+	dgsActions []testhelpers.ExtendedAction
+
+	// Objects from here preloaded into NewSimpleFake.
+	k8sObjects []runtime.Object
+	dgsObjects []runtime.Object
+}
+
+func newDGSBufferAutoScalerFixture(t *testing.T) *dgsBufferAutoScalerFixture {
+	f := &dgsBufferAutoScalerFixture{}
+	f.t = t
+
+	f.k8sObjects = []runtime.Object{}
+	f.dgsObjects = []runtime.Object{}
+	return f
+}
+
+func (f *dgsBufferAutoScalerFixture) newBufferAutoScalerController() (*BufferAutoScalerController, dgsinformers.SharedInformerFactory) {
+	f.k8sClient = k8sfake.NewSimpleClientset(f.k8sObjects...)
+	f.dgsClient = fake.NewSimpleClientset(f.dgsObjects...)
+
+	dgsInformers := dgsinformers.NewSharedInformerFactory(f.dgsClient, testhelpers.NoResyncPeriodFunc())
+
+	testController := NewBufferAutoScalerController(f.k8sClient, f.dgsClient,
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers())
+
+	testController.dgsColListerSynced = testhelpers.AlwaysReady
+	testController.dgsListerSynced = testhelpers.AlwaysReady
+
+	testController.recorder = &record.FakeRecorder{}
+
+	for _, dgsCol := range f.dgsColLister {
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerCollections().Informer().GetIndexer().Add(dgsCol)
+	}
+
+	for _, dgs := range f.dgsLister {
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers().Informer().GetIndexer().Add(dgs)
+	}
+
+	return testController, dgsInformers
+}
+
+func (f *dgsBufferAutoScalerFixture) run(dgsColName string) {
+	testController, dgsInformers := f.newBufferAutoScalerController()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	dgsInformers.Start(stopCh)
+
+	err := testController.syncHandler(dgsColName)
+	if err != nil {
+		f.t.Errorf("error syncing DGSCol: %v", err)
+	}
+
+	actions := filterInformerActionsPodAutoScaler(f.dgsClient.Actions())
+
+	for i, action := range actions {
+		if len(f.dgsActions) < i+1 {
+			f.t.Errorf("%d unexpected actions: %+v", len(actions)-len(f.dgsActions), actions[i:])
+			break
+		}
+
+		expectedAction := f.dgsActions[i]
+		testhelpers.CheckAction(expectedAction, action, f.t)
+	}
+
+	if len(f.dgsActions) > len(actions) {
+		f.t.Errorf("%d additional expected actions:%+v", len(f.dgsActions)-len(actions), f.dgsActions[len(actions):])
+	}
+}
+
+func (f *dgsBufferAutoScalerFixture) expectPatchDGSColAction(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) {
+	action := core.NewPatchAction(schema.GroupVersionResource{Resource: "dedicatedgameservercollections"}, dgsCol.Namespace, dgsCol.Name, nil)
+	extAction := testhelpers.ExtendedAction{Action: action}
+	f.dgsActions = append(f.dgsActions, extAction)
+}
+
+// getReplicas returns the replicas of the DGSCol in the fake clientset, after the controller has run
+func (f *dgsBufferAutoScalerFixture) getReplicas(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) int32 {
+	dgsColUpdated, err := f.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Get(dgsCol.Name, metav1.GetOptions{})
+	assert.NoError(f.t, err)
+	return dgsColUpdated.Spec.Replicas
+}
+
+func newBufferDGSCol(replicas int32, bufferSize intstr.IntOrString) *dgsv1alpha1.DedicatedGameServerCollection {
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, replicas, testhelpers.PodSpec)
+	dgsCol.Spec.DGSBufferAutoScalerDetails = &dgsv1alpha1.DGSBufferAutoScalerDetails{
+		Enabled:         true,
+		BufferSize:      bufferSize,
+		MinimumReplicas: 1,
+		MaximumReplicas: 10,
+	}
+	dgsCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	dgsCol.Status.PodCollectionState = corev1.PodRunning
+	return dgsCol
+}
+
+// addDGSs adds a DGS for each of the states to the DGSCol
+func (f *dgsBufferAutoScalerFixture) addDGSs(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, states ...dgsv1alpha1.DGSState) {
+	for _, state := range states {
+		dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+		dgs.Status.Health = dgsv1alpha1.DGSHealthy
+		dgs.Status.PodPhase = corev1.PodRunning
+		dgs.Status.DGSState = state
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+	}
+}
+
+func TestBufferScaleOutWhenBufferIsDrained(t *testing.T) {
+	f := newDGSBufferAutoScalerFixture(t)
+
+	dgsCol := newBufferDGSCol(4, intstr.FromInt(2))
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	// three DGSs were allocated, so only one is Idle
+	f.addDGSs(dgsCol, dgsv1alpha1.DGSAssigned, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSIdle)
+
+	f.expectPatchDGSColAction(dgsCol)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, int32(5), f.getReplicas(dgsCol))
+}
+
+func TestBufferScaleInWhenBufferIsTooLarge(t *testing.T) {
+	f := newDGSBufferAutoScalerFixture(t)
+
+	dgsCol := newBufferDGSCol(6, intstr.FromInt(2))
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	f.addDGSs(dgsCol, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSPostMatch, dgsv1alpha1.DGSIdle, dgsv1alpha1.DGSIdle, dgsv1alpha1.DGSIdle, dgsv1alpha1.DGSIdle)
+
+	f.expectPatchDGSColAction(dgsCol)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, int32(4), f.getReplicas(dgsCol))
+}
+
+func TestBufferDoNothingWhenBufferIsSatisfied(t *testing.T) {
+	f := newDGSBufferAutoScalerFixture(t)
+
+	dgsCol := newBufferDGSCol(3, intstr.FromInt(2))
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	// the creating DGS counts towards the buffer
+	f.addDGSs(dgsCol, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSIdle, dgsv1alpha1.DGSIdle)
+	f.dgsLister[2].Status.Health = dgsv1alpha1.DGSCreating
+
+	//expect nothing
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestBufferRespectsMaximumReplicas(t *testing.T) {
+	f := newDGSBufferAutoScalerFixture(t)
+
+	dgsCol := newBufferDGSCol(9, intstr.FromInt(3))
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	f.addDGSs(dgsCol, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSRunning,
+		dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSIdle)
+
+	f.expectPatchDGSColAction(dgsCol)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, int32(10), f.getReplicas(dgsCol))
+}
+
+func TestBufferDoNothingWithActivePlayersAutoScaler(t *testing.T) {
+	f := newDGSBufferAutoScalerFixture(t)
+
+	dgsCol := newBufferDGSCol(1, intstr.FromInt(2))
+	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{Enabled: true}
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	f.addDGSs(dgsCol, dgsv1alpha1.DGSRunning)
+
+	//expect nothing
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestBufferEnqueuesImmediatelyOnAllocation(t *testing.T) {
+	f := newDGSBufferAutoScalerFixture(t)
+
+	dgsCol := newBufferDGSCol(1, intstr.FromInt(1))
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	testController, _ := f.newBufferAutoScalerController()
+
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Status.DGSState = dgsv1alpha1.DGSAssigned
+	testController.handleDedicatedGameServer(dgs)
+
+	assert.Equal(t, 1, testController.controllerHelper.Workqueue.Len())
+}
+
+func TestGetBufferDesiredReplicas(t *testing.T) {
+	tests := []struct {
+		name            string
+		bufferSize      intstr.IntOrString
+		minReplicas     int
+		maxReplicas     int
+		busyCount       int32
+		desiredReplicas int32
+		expectError     bool
+	}{
+		{name: "absolute", bufferSize: intstr.FromInt(3), minReplicas: 1, maxReplicas: 20, busyCount: 5, desiredReplicas: 8},
+		{name: "percentage", bufferSize: intstr.FromString("20%"), minReplicas: 1, maxReplicas: 20, busyCount: 8, desiredReplicas: 10},
+		{name: "percentage rounds up", bufferSize: intstr.FromString("25%"), minReplicas: 1, maxReplicas: 20, busyCount: 4, desiredReplicas: 6},
+		{name: "minimum", bufferSize: intstr.FromInt(1), minReplicas: 3, maxReplicas: 20, busyCount: 0, desiredReplicas: 3},
+		{name: "maximum", bufferSize: intstr.FromInt(5), minReplicas: 1, maxReplicas: 10, busyCount: 8, desiredReplicas: 10},
+		{name: "percentage too large", bufferSize: intstr.FromString("100%"), minReplicas: 1, maxReplicas: 10, expectError: true},
+		{name: "negative", bufferSize: intstr.FromInt(-1), minReplicas: 1, maxReplicas: 10, expectError: true},
+		{name: "minimum greater than maximum", bufferSize: intstr.FromInt(1), minReplicas: 5, maxReplicas: 2, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desiredReplicas, err := getBufferDesiredReplicas(&dgsv1alpha1.DGSBufferAutoScalerDetails{
+				Enabled:         true,
+				BufferSize:      tt.bufferSize,
+				MinimumReplicas: tt.minReplicas,
+				MaximumReplicas: tt.maxReplicas,
+			}, tt.busyCount)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.desiredReplicas, desiredReplicas)
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package autoscale
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	dgsclientset "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
+	dgsscheme "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/scheme"
+	informerdgs "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions/azuregaming/v1alpha1"
+	listerdgs "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/listers/azuregaming/v1alpha1"
+	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	logrus "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	record "k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const webhookAutoscalerControllerAgentName = "webhook-auto-scaler-controller"
+
+// WebhookAutoScalerController is the struct that represents the WebhookAutoScalerController
+// It scales DedicatedGameServerCollections to the replicas that an external HTTP endpoint returns
+type WebhookAutoScalerController struct {
+	dgsColClient       dgsclientset.Interface
+	dgsColLister       listerdgs.DedicatedGameServerCollectionLister
+	dgsLister          listerdgs.DedicatedGameServerLister
+	dgsColListerSynced cache.InformerSynced
+	dgsListerSynced    cache.InformerSynced
+
+	logger *logrus.Logger
+	clock  clockwork.Clock
+
+	// evaluationInterval is the interval of the periodic evaluation of all the DedicatedGameServerCollections
+	// that have webhook autoscaling enabled. Zero disables the periodic evaluation
+	evaluationInterval time.Duration
+
+	// recorder is an event recorder for recording Event resources to the
+	// Kubernetes API.
+	recorder record.EventRecorder
+
+	controllerHelper *controllers.ControllerHelper
+}
+
+// NewWebhookAutoScalerController creates a new WebhookAutoScalerController
+func NewWebhookAutoScalerController(client kubernetes.Interface, dgsclient dgsclientset.Interface,
+	dgsColInformer informerdgs.DedicatedGameServerCollectionInformer,
+	dgsInformer informerdgs.DedicatedGameServerInformer, clockImpl clockwork.Clock, evaluationInterval time.Duration) *WebhookAutoScalerController {
+
+	c := &WebhookAutoScalerController{
+		dgsColClient:       dgsclient,
+		dgsColLister:       dgsColInformer.Lister(),
+		dgsColListerSynced: dgsColInformer.Informer().HasSynced,
+		dgsLister:          dgsInformer.Lister(),
+		dgsListerSynced:    dgsInformer.Informer().HasSynced,
+		clock:              clockImpl,
+		evaluationInterval: evaluationInterval,
+		logger:             shared.Logger(),
+	}
+
+	c.controllerHelper = controllers.NewControllerHelper(
+		workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "WebhookAutoScalerSync"),
+		c.logger,
+		c.syncHandler,
+		"WebhookAutoScalerController",
+		[]cache.InformerSynced{c.dgsColListerSynced, c.dgsListerSynced},
+	)
+
+	dgsscheme.AddToScheme(dgsscheme.Scheme)
+	c.logger.Info("Creating event broadcaster for WebhookAutoScaler controller")
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(c.logger.Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	c.recorder = eventBroadcaster.NewRecorder(dgsscheme.Scheme, corev1.EventSource{Component: webhookAutoscalerControllerAgentName})
+
+	c.logger.Info("Setting up event handlers for WebhookAutoScaler controller")
+
+	// DGS changes do not trigger an evaluation, since they happen too often to call the webhook on each one of them
+	// the webhook is called periodically instead
+	dgsColInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.logger.Print("WebhookAutoScaler controller - add DedicatedGameServerCollection")
+				c.handleDedicatedGameServerCollection(obj)
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				c.logger.Print("WebhookAutoScaler controller - update DedicatedGameServerCollection")
+				oldDGSCol := oldObj.(*dgsv1alpha1.DedicatedGameServerCollection)
+				newDGSCol := newObj.(*dgsv1alpha1.DedicatedGameServerCollection)
+				// status updates happen all the time, only spec changes trigger an evaluation
+				if oldDGSCol.Generation == newDGSCol.Generation {
+					return
+				}
+				c.handleDedicatedGameServerCollection(newObj)
+			},
+		},
+	)
+	return c
+}
+
+// syncHandler calls the webhook of the DedicatedGameServerCollection and scales it to the replicas it returns, if needed
+func (c *WebhookAutoScalerController) syncHandler(key string) error {
+	// Convert the namespace/name string into a distinct namespace and name
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+
+	dgsColTemp, err := c.dgsColLister.DedicatedGameServerCollections(namespace).Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			runtime.HandleError(fmt.Errorf("DedicatedGameServerCollection '%s' in work queue no longer exists", key))
+			return nil
+		}
+		c.logger.WithField("DGSColName", name).Errorf("Error listing DGSCol: %s", err.Error())
+		return err
+	}
+
+	// DGSCol is being terminated
+	if !dgsColTemp.DeletionTimestamp.IsZero() {
+		c.logger.WithField("DGSColName", dgsColTemp.Name).Info("DGSCol is being terminated")
+		return nil
+	}
+
+	// check if it has webhook autoscaling enabled
+	scalerDetails := dgsColTemp.Spec.DGSWebhookAutoScalerDetails
+	if scalerDetails == nil || !scalerDetails.Enabled {
+		return nil
+	}
+
+	// the autoscalers would fight over the replicas
+	if (dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails != nil && dgsColTemp.Spec.DGSActivePlayersAutoScalerDetails.Enabled) ||
+		(dgsColTemp.Spec.DGSBufferAutoScalerDetails != nil && dgsColTemp.Spec.DGSBufferAutoScalerDetails.Enabled) {
+		c.logger.WithField("DGSColName", dgsColTemp.Name).Info("Not checking about webhook autoscaling because another autoscaler is enabled as well")
+		return nil
+	}
+
+	// DGSCol needs intervention, so no DGSs will be created
+	if dgsColTemp.Status.DGSCollectionHealth == dgsv1alpha1.DGSColNeedsIntervention {
+		c.logger.WithField("DGSColName", dgsColTemp.Name).Info("Not checking about webhook autoscaling because DGSCol is in NeedsIntervention state")
+		return nil
+	}
+
+	set := labels.Set{
+		shared.LabelDedicatedGameServerCollectionName: dgsColTemp.Name,
+	}
+	dgss, err := c.dgsLister.DedicatedGameServers(dgsColTemp.Namespace).List(labels.SelectorFromSet(set))
+	if err != nil {
+		return err
+	}
+
+	var desiredReplicas int32
+	var message string
+	webhookReplicas, err := c.getWebhookReplicas(dgsColTemp, dgss)
+	if err != nil {
+		c.recorder.Event(dgsColTemp, corev1.EventTypeWarning, shared.WebhookAutoScalerFailed,
+			fmt.Sprintf(shared.MessageWebhookAutoScalerFailed, scalerDetails.URL, err.Error()))
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "URL": scalerDetails.URL, "Error": err.Error()}).Error("Webhook autoscaler call failed")
+
+		// the webhook will be called again on the next periodic evaluation
+		if scalerDetails.FallbackReplicas == nil {
+			return nil
+		}
+		desiredReplicas = clampWebhookReplicas(*scalerDetails.FallbackReplicas, scalerDetails)
+		message = fmt.Sprintf(shared.MessageWebhookAutoScalerFallback, dgsColTemp.Name, dgsColTemp.Spec.Replicas, desiredReplicas)
+	} else {
+		desiredReplicas = clampWebhookReplicas(webhookReplicas, scalerDetails)
+		message = fmt.Sprintf(shared.MessageWebhookAutoScalerScaled, dgsColTemp.Name, dgsColTemp.Spec.Replicas, desiredReplicas, webhookReplicas)
+	}
+
+	if desiredReplicas == dgsColTemp.Spec.Replicas {
+		return nil
+	}
+
+	err = c.scaleDGSCol(dgsColTemp, desiredReplicas)
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "DesiredReplicas": desiredReplicas, "Error": err.Error()}).Error("Cannot scale based on webhook")
+		return err
+	}
+
+	c.recorder.Event(dgsColTemp, corev1.EventTypeNormal, shared.WebhookAutoScalerScaled, message)
+	c.logger.WithFields(logrus.Fields{
+		"DGSColName":      dgsColTemp.Name,
+		"Replicas":        dgsColTemp.Spec.Replicas,
+		"DesiredReplicas": desiredReplicas,
+	}).Info("Scale occurred on WebhookAutoScaler")
+
+	return nil
+}
+
+// getWebhookReplicas calls the webhook of the DGSCol and returns the replicas it responded with
+func (c *WebhookAutoScalerController) getWebhookReplicas(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgss []*dgsv1alpha1.DedicatedGameServer) (int32, error) {
+	client, err := newWebhookHTTPClient(dgsCol.Spec.DGSWebhookAutoScalerDetails)
+	if err != nil {
+		return 0, err
+	}
+	return callWebhook(client, dgsCol.Spec.DGSWebhookAutoScalerDetails.URL, newWebhookRequest(dgsCol, dgss))
+}
+
+// clampWebhookReplicas returns the replicas limited to MinimumReplicas and MaximumReplicas
+func clampWebhookReplicas(replicas int32, scalerDetails *dgsv1alpha1.DGSWebhookAutoScalerDetails) int32 {
+	if replicas > int32(scalerDetails.MaximumReplicas) {
+		replicas = int32(scalerDetails.MaximumReplicas)
+	}
+	if replicas < int32(scalerDetails.MinimumReplicas) {
+		replicas = int32(scalerDetails.MinimumReplicas)
+	}
+	return replicas
+}
+
+// scaleDGSCol sets the requested replicas on the DGSCol spec via a merge patch, so it does not conflict with status updates
+func (c *WebhookAutoScalerController) scaleDGSCol(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, replicas int32) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"replicas": replicas,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Patch(dgsCol.Name, types.MergePatchType, patch)
+	return err
+}
+
+// enqueueDedicatedGameServerCollection takes a DedicatedGameServerCollection resource and converts it into a namespace/name
+// string which is then put onto the work queue. This method should *not* be
+// passed resources of any type other than DedicatedGameServerCollection.
+func (c *WebhookAutoScalerController) enqueueDedicatedGameServerCollection(obj interface{}) {
+	var key string
+	var err error
+	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.controllerHelper.Workqueue.AddRateLimited(key)
+}
+
+// Run initiates the WebhookAutoScalerController
+func (c *WebhookAutoScalerController) Run(controllerThreadiness int, stopCh <-chan struct{}) error {
+	if c.evaluationInterval > 0 {
+		go c.runPeriodicEvaluation(stopCh)
+	}
+	return c.controllerHelper.Run(controllerThreadiness, stopCh)
+}
+
+// runPeriodicEvaluation enqueues all the DedicatedGameServerCollections that have webhook autoscaling enabled
+// every evaluationInterval, until stopCh is closed
+func (c *WebhookAutoScalerController) runPeriodicEvaluation(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-c.clock.After(c.evaluationInterval):
+			c.enqueueWebhookAutoScaledDedicatedGameServerCollections()
+		}
+	}
+}
+
+// enqueueWebhookAutoScaledDedicatedGameServerCollections enqueues all the DedicatedGameServerCollections that have webhook autoscaling enabled
+func (c *WebhookAutoScalerController) enqueueWebhookAutoScaledDedicatedGameServerCollections() {
+	dgsCols, err := c.dgsColLister.List(labels.Everything())
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("error listing DedicatedGameServerCollections for periodic evaluation: %s", err.Error()))
+		return
+	}
+
+	for _, dgsCol := range dgsCols {
+		if dgsCol.Spec.DGSWebhookAutoScalerDetails != nil && dgsCol.Spec.DGSWebhookAutoScalerDetails.Enabled {
+			key, err := cache.MetaNamespaceKeyFunc(dgsCol)
+			if err != nil {
+				runtime.HandleError(err)
+				continue
+			}
+			c.controllerHelper.Workqueue.Add(key)
+		}
+	}
+}
+
+func (c *WebhookAutoScalerController) handleDedicatedGameServerCollection(obj interface{}) {
+	var object metav1.Object
+	var ok bool
+	if object, ok = obj.(metav1.Object); !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			runtime.HandleError(fmt.Errorf("error decoding DedicatedGameServerCollection object, invalid type"))
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			runtime.HandleError(fmt.Errorf("error decoding DedicatedGameServerCollection object tombstone, invalid type"))
+			return
+		}
+		c.logger.Infof("Recovered deleted DedicatedGameServerCollection object '%s' from tombstone", object.GetName())
+	}
+	c.enqueueDedicatedGameServerCollection(object)
+}
@@ -0,0 +1,391 @@
+package autoscale
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+	dgsinformers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+type dgsWebhookAutoScalerFixture struct {
+	t *testing.T
+
+	k8sClient *k8sfake.Clientset
+	dgsClient *fake.Clientset
+
+	// Objects to put in the store.
+	dgsColLister []*dgsv1alpha1.DedicatedGameServerCollection
+	dgsLister    []*dgsv1alpha1.DedicatedGameServer
+
+	// Actions expected to happen on the client.
+	dgsActions []testhelpers.ExtendedAction
+
+	// Objects from here preloaded into NewSimpleFake.
+	k8sObjects []runtime.Object
+	dgsObjects []runtime.Object
+
+	clock    clockwork.FakeClock
+	recorder *record.FakeRecorder
+}
+
+func newDGSWebhookAutoScalerFixture(t *testing.T) *dgsWebhookAutoScalerFixture {
+	f := &dgsWebhookAutoScalerFixture{}
+	f.t = t
+
+	f.k8sObjects = []runtime.Object{}
+	f.dgsObjects = []runtime.Object{}
+
+	f.clock = clockwork.NewFakeClockAt(testhelpers.FixedTime)
+	f.recorder = record.NewFakeRecorder(10)
+	return f
+}
+
+func (f *dgsWebhookAutoScalerFixture) newWebhookAutoScalerController() (*WebhookAutoScalerController, dgsinformers.SharedInformerFactory) {
+	f.k8sClient = k8sfake.NewSimpleClientset(f.k8sObjects...)
+	f.dgsClient = fake.NewSimpleClientset(f.dgsObjects...)
+
+	dgsInformers := dgsinformers.NewSharedInformerFactory(f.dgsClient, testhelpers.NoResyncPeriodFunc())
+
+	testController := NewWebhookAutoScalerController(f.k8sClient, f.dgsClient,
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers(), f.clock, 0)
+
+	testController.dgsColListerSynced = testhelpers.AlwaysReady
+	testController.dgsListerSynced = testhelpers.AlwaysReady
+
+	testController.recorder = f.recorder
+
+	for _, dgsCol := range f.dgsColLister {
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerCollections().Informer().GetIndexer().Add(dgsCol)
+	}
+
+	for _, dgs := range f.dgsLister {
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers().Informer().GetIndexer().Add(dgs)
+	}
+
+	return testController, dgsInformers
+}
+
+func (f *dgsWebhookAutoScalerFixture) run(dgsColName string) {
+	testController, dgsInformers := f.newWebhookAutoScalerController()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	dgsInformers.Start(stopCh)
+
+	err := testController.syncHandler(dgsColName)
+	if err != nil {
+		f.t.Errorf("error syncing DGSCol: %v", err)
+	}
+
+	actions := filterInformerActionsPodAutoScaler(f.dgsClient.Actions())
+
+	for i, action := range actions {
+		if len(f.dgsActions) < i+1 {
+			f.t.Errorf("%d unexpected actions: %+v", len(actions)-len(f.dgsActions), actions[i:])
+			break
+		}
+
+		expectedAction := f.dgsActions[i]
+		testhelpers.CheckAction(expectedAction, action, f.t)
+	}
+
+	if len(f.dgsActions) > len(actions) {
+		f.t.Errorf("%d additional expected actions:%+v", len(f.dgsActions)-len(actions), f.dgsActions[len(actions):])
+	}
+}
+
+func (f *dgsWebhookAutoScalerFixture) expectPatchDGSColAction(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) {
+	action := core.NewPatchAction(schema.GroupVersionResource{Resource: "dedicatedgameservercollections"}, dgsCol.Namespace, dgsCol.Name, nil)
+	extAction := testhelpers.ExtendedAction{Action: action}
+	f.dgsActions = append(f.dgsActions, extAction)
+}
+
+// getReplicas returns the replicas of the DGSCol in the fake clientset, after the controller has run
+func (f *dgsWebhookAutoScalerFixture) getReplicas(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) int32 {
+	dgsColUpdated, err := f.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Get(dgsCol.Name, metav1.GetOptions{})
+	assert.NoError(f.t, err)
+	return dgsColUpdated.Spec.Replicas
+}
+
+// expectEvent checks that the next recorded event contains message
+func (f *dgsWebhookAutoScalerFixture) expectEvent(message string) {
+	select {
+	case event := <-f.recorder.Events:
+		assert.Contains(f.t, event, message)
+	default:
+		f.t.Errorf("Expected an event containing %q", message)
+	}
+}
+
+// newWebhookDGSCol returns a DGSCol with webhook autoscaling to url and a DGS with activePlayers for each of the players
+func (f *dgsWebhookAutoScalerFixture) newWebhookDGSCol(url string, players ...int) *dgsv1alpha1.DedicatedGameServerCollection {
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, int32(len(players)), testhelpers.PodSpec)
+	dgsCol.Spec.DGSWebhookAutoScalerDetails = &dgsv1alpha1.DGSWebhookAutoScalerDetails{
+		Enabled:         true,
+		URL:             url,
+		MinimumReplicas: 1,
+		MaximumReplicas: 10,
+		TimeoutSeconds:  1,
+	}
+	dgsCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	dgsCol.Status.PodCollectionState = corev1.PodRunning
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	for _, activePlayers := range players {
+		dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+		dgs.Status.Health = dgsv1alpha1.DGSHealthy
+		dgs.Status.PodPhase = corev1.PodRunning
+		dgs.Status.DGSState = dgsv1alpha1.DGSRunning
+		dgs.Status.ActivePlayers = activePlayers
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+	}
+
+	return dgsCol
+}
+
+// newReplicasHandler returns an HTTP handler that responds with the replicas and sends the requests it gets to requests, if not nil
+func newReplicasHandler(replicas int32, requests chan<- WebhookAutoScalerRequest) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var request WebhookAutoScalerRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if requests != nil {
+			requests <- request
+		}
+		fmt.Fprintf(w, `{"replicas": %d}`, replicas)
+	}
+}
+
+func TestWebhookScalesToReturnedReplicas(t *testing.T) {
+	requests := make(chan WebhookAutoScalerRequest, 1)
+	server := httptest.NewServer(newReplicasHandler(4, requests))
+	defer server.Close()
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(server.URL, 8, 3)
+
+	f.expectPatchDGSColAction(dgsCol)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, int32(4), f.getReplicas(dgsCol))
+	f.expectEvent("was scaled from 2 to 4 replicas, webhook returned 4 replicas")
+
+	request := <-requests
+	assert.Equal(t, dgsCol.Name, request.Name)
+	assert.Equal(t, dgsCol.Namespace, request.Namespace)
+	assert.Equal(t, int32(2), request.Replicas)
+	assert.Equal(t, dgsv1alpha1.DGSColHealthy, request.Status.DGSCollectionHealth)
+	totalActivePlayers := 0
+	for _, dgs := range request.DedicatedGameServers {
+		totalActivePlayers += dgs.ActivePlayers
+		assert.Equal(t, dgsv1alpha1.DGSRunning, dgs.DGSState)
+	}
+	assert.Len(t, request.DedicatedGameServers, 2)
+	assert.Equal(t, 11, totalActivePlayers)
+}
+
+func TestWebhookReplicasAreClamped(t *testing.T) {
+	server := httptest.NewServer(newReplicasHandler(50, nil))
+	defer server.Close()
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(server.URL, 5)
+
+	f.expectPatchDGSColAction(dgsCol)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, int32(10), f.getReplicas(dgsCol))
+}
+
+func TestWebhookSameReplicas(t *testing.T) {
+	server := httptest.NewServer(newReplicasHandler(1, nil))
+	defer server.Close()
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(server.URL, 5)
+
+	// expect nothing
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestWebhookTLSWithCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(newReplicasHandler(3, nil))
+	defer server.Close()
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(server.URL, 5)
+	dgsCol.Spec.DGSWebhookAutoScalerDetails.CABundle = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	f.expectPatchDGSColAction(dgsCol)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, int32(3), f.getReplicas(dgsCol))
+}
+
+func TestWebhookTLSInsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(newReplicasHandler(3, nil))
+	defer server.Close()
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(server.URL, 5)
+	dgsCol.Spec.DGSWebhookAutoScalerDetails.InsecureSkipVerify = true
+
+	f.expectPatchDGSColAction(dgsCol)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, int32(3), f.getReplicas(dgsCol))
+}
+
+func TestWebhookTLSUnknownAuthorityKeepsReplicas(t *testing.T) {
+	server := httptest.NewTLSServer(newReplicasHandler(3, nil))
+	defer server.Close()
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(server.URL, 5)
+
+	// expect nothing but a warning event, since the certificate of the server cannot be verified
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	f.expectEvent(shared.WebhookAutoScalerFailed)
+}
+
+func TestWebhookTimeoutUsesFallbackReplicas(t *testing.T) {
+	// the handler responds after the client has given up
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(server.URL, 5, 5)
+	dgsCol.Spec.DGSWebhookAutoScalerDetails.FallbackReplicas = int32Ptr(6)
+
+	f.expectPatchDGSColAction(dgsCol)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, int32(6), f.getReplicas(dgsCol))
+	f.expectEvent(shared.WebhookAutoScalerFailed)
+	f.expectEvent("was scaled from 2 to 6 fallback replicas")
+}
+
+func TestWebhookUnavailableKeepsReplicas(t *testing.T) {
+	server := httptest.NewServer(newReplicasHandler(3, nil))
+	url := server.URL
+	// nothing listens on the URL any more
+	server.Close()
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(url, 5)
+
+	// expect nothing but a warning event
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	f.expectEvent(shared.WebhookAutoScalerFailed)
+}
+
+func TestWebhookNotCalledWithOtherAutoScaler(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		fmt.Fprint(w, `{"replicas": 3}`)
+	}))
+	defer server.Close()
+
+	f := newDGSWebhookAutoScalerFixture(t)
+	dgsCol := f.newWebhookDGSCol(server.URL, 5)
+	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{Enabled: true}
+
+	// expect nothing
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.False(t, called)
+}
+
+func TestCallWebhookInvalidResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+	}{
+		{name: "error status code", statusCode: http.StatusInternalServerError, body: `{"replicas": 3}`},
+		{name: "invalid JSON", statusCode: http.StatusOK, body: `replicas: 3`},
+		{name: "missing replicas", statusCode: http.StatusOK, body: `{"count": 3}`},
+		{name: "negative replicas", statusCode: http.StatusOK, body: `{"replicas": -1}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statusCode)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			_, err := callWebhook(server.Client(), server.URL, &WebhookAutoScalerRequest{})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNewWebhookHTTPClientInvalidCABundle(t *testing.T) {
+	_, err := newWebhookHTTPClient(&dgsv1alpha1.DGSWebhookAutoScalerDetails{CABundle: []byte("not a certificate")})
+	assert.Error(t, err)
+}
+
+func TestWebhookPeriodicEvaluation(t *testing.T) {
+	f := newDGSWebhookAutoScalerFixture(t)
+
+	dgsCol := f.newWebhookDGSCol("http://localhost", 1)
+	dgsColNotAutoScaled := shared.NewDedicatedGameServerCollection("notautoscaled", shared.GameNamespace, 1, testhelpers.PodSpec)
+	f.dgsColLister = append(f.dgsColLister, dgsColNotAutoScaled)
+	f.dgsObjects = append(f.dgsObjects, dgsColNotAutoScaled)
+
+	testController, _ := f.newWebhookAutoScalerController()
+	testController.evaluationInterval = 30 * time.Second
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go testController.runPeriodicEvaluation(stopCh)
+
+	f.clock.BlockUntil(1)
+	assert.Equal(t, 0, testController.controllerHelper.Workqueue.Len())
+	f.clock.Advance(30 * time.Second)
+	// only the DGSCol with webhook autoscaling enabled is enqueued
+	assert.Equal(t, getKeyDGSCol(dgsCol, t), getQueueItem(t, testController.controllerHelper.Workqueue))
+}
@@ -0,0 +1,187 @@
+package autoscale
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed cron expression with the standard five fields:
+// minute, hour, day of month, month and day of week
+// Each field is a bitset of the values it matches
+type cronSchedule struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+	// dayOfMonthAny and dayOfWeekAny are true when the respective field starts with '*' (e.g. '*' or '*/2')
+	// As in standard cron, when both fields are restricted a day matches if it matches either of them
+	dayOfMonthAny bool
+	dayOfWeekAny  bool
+}
+
+// cronField describes the bounds and the (optional) value names of a cron field
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronMinute     = cronField{name: "minute", min: 0, max: 59}
+	cronHour       = cronField{name: "hour", min: 0, max: 23}
+	cronDayOfMonth = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth      = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday as well, it's folded to 0 after parsing
+	cronDayOfWeek = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// parseCronSchedule parses a cron expression like "30 18 * * mon-fri"
+// Every field supports '*', single values, ranges ("1-5"), steps ("*/15", "0-30/10") and lists ("1,15,30")
+// Months and days of week can also be set by their three letter names
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q should have 5 fields, it has %d", spec, len(fields))
+	}
+
+	schedule := &cronSchedule{
+		dayOfMonthAny: strings.HasPrefix(fields[2], "*"),
+		dayOfWeekAny:  strings.HasPrefix(fields[4], "*"),
+	}
+
+	var err error
+	if schedule.minute, err = parseCronField(fields[0], cronMinute); err != nil {
+		return nil, err
+	}
+	if schedule.hour, err = parseCronField(fields[1], cronHour); err != nil {
+		return nil, err
+	}
+	if schedule.dayOfMonth, err = parseCronField(fields[2], cronDayOfMonth); err != nil {
+		return nil, err
+	}
+	if schedule.month, err = parseCronField(fields[3], cronMonth); err != nil {
+		return nil, err
+	}
+	if schedule.dayOfWeek, err = parseCronField(fields[4], cronDayOfWeek); err != nil {
+		return nil, err
+	}
+	// Sunday can be either 0 or 7
+	if schedule.dayOfWeek&(1<<7) != 0 {
+		schedule.dayOfWeek |= 1
+	}
+
+	return schedule, nil
+}
+
+// parseCronField returns the bitset of the values that a comma separated cron field matches
+func parseCronField(value string, field cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(value, ",") {
+		partBits, err := parseCronFieldPart(part, field)
+		if err != nil {
+			return 0, err
+		}
+		bits |= partBits
+	}
+	return bits, nil
+}
+
+// parseCronFieldPart parses a single value, range or step of a cron field
+func parseCronFieldPart(part string, field cronField) (uint64, error) {
+	rangePart := part
+	step := 1
+	if i := strings.Index(part, "/"); i >= 0 {
+		var err error
+		step, err = strconv.Atoi(part[i+1:])
+		if err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step %q in %s field", part[i+1:], field.name)
+		}
+		rangePart = part[:i]
+	}
+
+	var start, end int
+	switch {
+	case rangePart == "*":
+		start, end = field.min, field.max
+	case strings.Contains(rangePart, "-"):
+		bounds := strings.SplitN(rangePart, "-", 2)
+		var err error
+		if start, err = parseCronValue(bounds[0], field); err != nil {
+			return 0, err
+		}
+		if end, err = parseCronValue(bounds[1], field); err != nil {
+			return 0, err
+		}
+	default:
+		var err error
+		if start, err = parseCronValue(rangePart, field); err != nil {
+			return 0, err
+		}
+		end = start
+		// "5/15" means every 15 starting from 5
+		if step > 1 {
+			end = field.max
+		}
+	}
+
+	if start > end {
+		return 0, fmt.Errorf("invalid range %q in %s field", rangePart, field.name)
+	}
+
+	var bits uint64
+	for i := start; i <= end; i += step {
+		bits |= 1 << uint(i)
+	}
+	return bits, nil
+}
+
+// parseCronValue parses a number or a name of a cron field, checking its bounds
+func parseCronValue(value string, field cronField) (int, error) {
+	if number, ok := field.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q in %s field", value, field.name)
+	}
+	if number < field.min || number > field.max {
+		return 0, fmt.Errorf("value %d of %s field is out of range %d-%d", number, field.name, field.min, field.max)
+	}
+	return number, nil
+}
+
+// matches returns true if the minute of t matches the schedule, in the location of t
+func (s *cronSchedule) matches(t time.Time) bool {
+	if s.minute&(1<<uint(t.Minute())) == 0 ||
+		s.hour&(1<<uint(t.Hour())) == 0 ||
+		s.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dayOfMonthMatches := s.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeekMatches := s.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.dayOfMonthAny || s.dayOfWeekAny {
+		return dayOfMonthMatches && dayOfWeekMatches
+	}
+	return dayOfMonthMatches || dayOfWeekMatches
+}
+
+// isActive returns true if a window that starts on the schedule and lasts for duration contains now
+// The window start is searched minute by minute, so duration should be reasonably bounded
+func (s *cronSchedule) isActive(now time.Time, duration time.Duration) bool {
+	for start := now.Truncate(time.Minute); now.Sub(start) < duration; start = start.Add(-time.Minute) {
+		if s.matches(start) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package autoscale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+	}
+
+	for _, spec := range tests {
+		t.Run(spec, func(t *testing.T) {
+			_, err := parseCronSchedule(spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	// 2018-01-05 was a Friday
+	friday := time.Date(2018, 1, 5, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec    string
+		t       time.Time
+		matches bool
+	}{
+		{spec: "* * * * *", t: friday, matches: true},
+		{spec: "30 18 * * *", t: friday, matches: true},
+		{spec: "31 18 * * *", t: friday, matches: false},
+		{spec: "*/15 * * * *", t: friday, matches: true},
+		{spec: "*/20 * * * *", t: friday, matches: false},
+		{spec: "10/20 * * * *", t: friday, matches: true},
+		{spec: "0-30/10 17-19 * * *", t: friday, matches: true},
+		{spec: "0,15,30,45 * * * *", t: friday, matches: true},
+		{spec: "30 18 * * fri", t: friday, matches: true},
+		{spec: "30 18 * * mon-thu", t: friday, matches: false},
+		{spec: "30 18 * * 5", t: friday, matches: true},
+		{spec: "30 18 * jan *", t: friday, matches: true},
+		{spec: "30 18 * feb-dec *", t: friday, matches: false},
+		{spec: "30 18 5 * *", t: friday, matches: true},
+		{spec: "30 18 6 * *", t: friday, matches: false},
+		// both day of month and day of week are restricted, either matches
+		{spec: "30 18 6 * fri", t: friday, matches: true},
+		{spec: "30 18 5 * sat", t: friday, matches: true},
+		{spec: "30 18 6 * sat", t: friday, matches: false},
+		// a stepped '*' field counts as unrestricted, so both fields have to match
+		{spec: "30 18 */2 * mon", t: friday, matches: false},
+		{spec: "30 18 */2 * mon", t: friday.AddDate(0, 0, 3), matches: false},
+		{spec: "30 18 */2 * mon", t: friday.AddDate(0, 0, 10), matches: true},
+		{spec: "30 18 * * */2", t: friday, matches: false},
+		// Sunday is both 0 and 7
+		{spec: "30 18 * * 7", t: friday.AddDate(0, 0, 2), matches: true},
+		{spec: "30 18 * * 0", t: friday.AddDate(0, 0, 2), matches: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tt.spec)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.matches, schedule.matches(tt.t))
+		})
+	}
+}
+
+func TestCronScheduleIsActive(t *testing.T) {
+	schedule, err := parseCronSchedule("0 18 * * fri")
+	assert.NoError(t, err)
+
+	start := time.Date(2018, 1, 5, 18, 0, 0, 0, time.UTC)
+	assert.False(t, schedule.isActive(start.Add(-time.Minute), 4*time.Hour))
+	assert.True(t, schedule.isActive(start, 4*time.Hour))
+	assert.True(t, schedule.isActive(start.Add(4*time.Hour-time.Second), 4*time.Hour))
+	assert.False(t, schedule.isActive(start.Add(4*time.Hour), 4*time.Hour))
+
+	// the schedule is evaluated in the location of the time
+	athens, err := time.LoadLocation("Europe/Athens")
+	assert.NoError(t, err)
+	assert.False(t, schedule.isActive(start.In(athens), time.Hour))
+	assert.True(t, schedule.isActive(start.Add(-2*time.Hour).In(athens), time.Hour))
+}
@@ -0,0 +1,59 @@
+package autoscale
+
+import (
+	"fmt"
+	"time"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+)
+
+// maxScheduleDuration is the maximum duration of a scaling schedule window
+const maxScheduleDuration = 7 * 24 * time.Hour
+
+// getActiveScalingSchedule returns the first of the schedules that is active at now, or nil if none is
+// It returns an error if any of the schedules up to the active one is invalid
+func getActiveScalingSchedule(schedules []dgsv1alpha1.DGSScalingSchedule, now time.Time) (*dgsv1alpha1.DGSScalingSchedule, error) {
+	for i := range schedules {
+		schedule := &schedules[i]
+
+		if schedule.Duration.Duration <= 0 || schedule.Duration.Duration > maxScheduleDuration {
+			return nil, fmt.Errorf("duration %s of schedule %s should be positive and at most %s", schedule.Duration.Duration, schedule.Name, maxScheduleDuration)
+		}
+
+		location := time.UTC
+		if schedule.TimeZone != "" {
+			var err error
+			location, err = time.LoadLocation(schedule.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time zone of schedule %s: %s", schedule.Name, err.Error())
+			}
+		}
+
+		cron, err := parseCronSchedule(schedule.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %s: %s", schedule.Name, err.Error())
+		}
+
+		if cron.isActive(now.In(location), schedule.Duration.Duration) {
+			return schedule, nil
+		}
+	}
+	return nil, nil
+}
+
+// applyScalingSchedule returns a copy of the scaler details with the replica limits of the schedule
+func applyScalingSchedule(scalerDetails *dgsv1alpha1.DGSActivePlayersAutoScalerDetails, schedule *dgsv1alpha1.DGSScalingSchedule) *dgsv1alpha1.DGSActivePlayersAutoScalerDetails {
+	scheduledDetails := scalerDetails.DeepCopy()
+	if schedule.Replicas != nil {
+		scheduledDetails.MinimumReplicas = int(*schedule.Replicas)
+		scheduledDetails.MaximumReplicas = int(*schedule.Replicas)
+		return scheduledDetails
+	}
+	if schedule.MinimumReplicas != nil {
+		scheduledDetails.MinimumReplicas = *schedule.MinimumReplicas
+	}
+	if schedule.MaximumReplicas != nil {
+		scheduledDetails.MaximumReplicas = *schedule.MaximumReplicas
+	}
+	return scheduledDetails
+}
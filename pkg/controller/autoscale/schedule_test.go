@@ -0,0 +1,215 @@
+package autoscale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func TestGetActiveScalingSchedule(t *testing.T) {
+	// testhelpers.FixedTime is Monday 2018-01-01 00:00 UTC, i.e. 02:00 in Athens
+	primeTime := dgsv1alpha1.DGSScalingSchedule{Name: "primetime", Schedule: "0 22 * * sun", Duration: metav1.Duration{Duration: 4 * time.Hour}}
+	athensNight := dgsv1alpha1.DGSScalingSchedule{Name: "athensnight", Schedule: "0 1 * * mon", Duration: metav1.Duration{Duration: 2 * time.Hour}, TimeZone: "Europe/Athens"}
+	weekend := dgsv1alpha1.DGSScalingSchedule{Name: "weekend", Schedule: "0 0 * * sat", Duration: metav1.Duration{Duration: 48 * time.Hour}}
+
+	tests := []struct {
+		name           string
+		schedules      []dgsv1alpha1.DGSScalingSchedule
+		activeSchedule string
+		expectError    bool
+	}{
+		{name: "no schedules"},
+		{name: "inactive", schedules: []dgsv1alpha1.DGSScalingSchedule{weekend}},
+		{name: "active", schedules: []dgsv1alpha1.DGSScalingSchedule{weekend, primeTime}, activeSchedule: "primetime"},
+		{name: "active in time zone", schedules: []dgsv1alpha1.DGSScalingSchedule{athensNight}, activeSchedule: "athensnight"},
+		{name: "first active wins", schedules: []dgsv1alpha1.DGSScalingSchedule{athensNight, primeTime}, activeSchedule: "athensnight"},
+		{name: "invalid cron", schedules: []dgsv1alpha1.DGSScalingSchedule{{Name: "bad", Schedule: "0 25 * * *", Duration: metav1.Duration{Duration: time.Hour}}}, expectError: true},
+		{name: "invalid time zone", schedules: []dgsv1alpha1.DGSScalingSchedule{{Name: "bad", Schedule: "0 1 * * *", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Nowhere/Land"}}, expectError: true},
+		{name: "zero duration", schedules: []dgsv1alpha1.DGSScalingSchedule{{Name: "bad", Schedule: "0 1 * * *"}}, expectError: true},
+		{name: "too long duration", schedules: []dgsv1alpha1.DGSScalingSchedule{{Name: "bad", Schedule: "0 1 * * *", Duration: metav1.Duration{Duration: 8 * 24 * time.Hour}}}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := getActiveScalingSchedule(tt.schedules, testhelpers.FixedTime)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.activeSchedule == "" {
+				assert.Nil(t, schedule)
+				return
+			}
+			if assert.NotNil(t, schedule) {
+				assert.Equal(t, tt.activeSchedule, schedule.Name)
+			}
+		})
+	}
+}
+
+func TestApplyScalingSchedule(t *testing.T) {
+	scalerDetails := &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{MinimumReplicas: 2, MaximumReplicas: 10}
+
+	scheduled := applyScalingSchedule(scalerDetails, &dgsv1alpha1.DGSScalingSchedule{MinimumReplicas: intPtr(5)})
+	assert.Equal(t, 5, scheduled.MinimumReplicas)
+	assert.Equal(t, 10, scheduled.MaximumReplicas)
+
+	scheduled = applyScalingSchedule(scalerDetails, &dgsv1alpha1.DGSScalingSchedule{MinimumReplicas: intPtr(5), Replicas: int32Ptr(8)})
+	assert.Equal(t, 8, scheduled.MinimumReplicas)
+	assert.Equal(t, 8, scheduled.MaximumReplicas)
+
+	// the DGSCol scaler details are not modified
+	assert.Equal(t, 2, scalerDetails.MinimumReplicas)
+}
+
+// newScheduledDGSCol returns a Healthy DGSCol with one DGS that has a load between the autoscaler thresholds
+func (f *dgsActivePlayersAutoScalerFixture) newScheduledDGSCol(replicas int32, schedules ...dgsv1alpha1.DGSScalingSchedule) *dgsv1alpha1.DedicatedGameServerCollection {
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, replicas, testhelpers.PodSpec)
+	dgsCol.Spec.DGSActivePlayersAutoScalerDetails = &dgsv1alpha1.DGSActivePlayersAutoScalerDetails{
+		MinimumReplicas:     1,
+		MaximumReplicas:     5,
+		ScaleInThreshold:    60,
+		ScaleOutThreshold:   80,
+		Enabled:             true,
+		CoolDownInMinutes:   5,
+		MaxPlayersPerServer: 10,
+		Schedules:           schedules,
+	}
+	// a scale operation just happened, so load based scaling is in cooldown
+	lastScaleOperationTime := metav1.NewTime(f.clock.Now())
+	dgsCol.Status.ActivePlayersAutoScalerStatus = &dgsv1alpha1.DGSActivePlayersAutoScalerStatus{LastScaleOperationTime: &lastScaleOperationTime}
+	dgsCol.Status.DGSCollectionHealth = dgsv1alpha1.DGSColHealthy
+	dgsCol.Status.PodCollectionState = corev1.PodRunning
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	for i := int32(0); i < replicas; i++ {
+		dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+		dgs.Status.Health = dgsv1alpha1.DGSHealthy
+		dgs.Status.PodPhase = corev1.PodRunning
+		dgs.Status.ActivePlayers = 7
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+	}
+
+	return dgsCol
+}
+
+func (f *dgsActivePlayersAutoScalerFixture) expectScheduledScale(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, replicas int32, decision dgsv1alpha1.ActivePlayersAutoScalerDecision) {
+	expDGSCol := dgsCol.DeepCopy()
+	expDGSCol.Spec.Replicas = replicas
+	f.expectPatchDGSColAction(expDGSCol)
+	f.expectUpdateDGSColActionStatus(expDGSCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(f.t, replicas, dgsCol.Spec.Replicas)
+		assert.Equal(f.t, decision, dgsCol.Status.ActivePlayersAutoScalerStatus.LastDecision)
+	})
+}
+
+func TestScheduleSetsFixedReplicasDuringCoolDown(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	dgsCol := f.newScheduledDGSCol(1, dgsv1alpha1.DGSScalingSchedule{
+		Name:     "launch",
+		Schedule: "0 0 1 1 *",
+		Duration: metav1.Duration{Duration: time.Hour},
+		Replicas: int32Ptr(8),
+	})
+
+	// fixed replicas override the autoscaler MaximumReplicas
+	f.expectScheduledScale(dgsCol, 8, dgsv1alpha1.ActivePlayersAutoScalerScaleOut)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	select {
+	case event := <-f.recorder.Events:
+		assert.Contains(t, event, "from 1 to 8 replicas to be within the replica limits 8-8 (active scaling schedule: launch)")
+	default:
+		t.Error("Expected an event for the scaling decision")
+	}
+}
+
+func TestScheduleRaisesMinimumReplicas(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	dgsCol := f.newScheduledDGSCol(2, dgsv1alpha1.DGSScalingSchedule{
+		Name:            "prewarm",
+		Schedule:        "30 23 * * sun",
+		Duration:        metav1.Duration{Duration: time.Hour},
+		MinimumReplicas: intPtr(4),
+	})
+
+	f.expectScheduledScale(dgsCol, 4, dgsv1alpha1.ActivePlayersAutoScalerScaleOut)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestReplicasReturnToLimitsAfterSchedule(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	// the window has ended, so replicas above MaximumReplicas are removed
+	dgsCol := f.newScheduledDGSCol(8, dgsv1alpha1.DGSScalingSchedule{
+		Name:     "launch",
+		Schedule: "0 20 31 12 *",
+		Duration: metav1.Duration{Duration: time.Hour},
+		Replicas: int32Ptr(8),
+	})
+
+	f.expectScheduledScale(dgsCol, 5, dgsv1alpha1.ActivePlayersAutoScalerScaleIn)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestScheduleWithinLimitsRespectsCoolDown(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	dgsCol := f.newScheduledDGSCol(3, dgsv1alpha1.DGSScalingSchedule{
+		Name:            "prewarm",
+		Schedule:        "* * * * *",
+		Duration:        metav1.Duration{Duration: time.Hour},
+		MinimumReplicas: intPtr(2),
+	})
+
+	// expect nothing, replicas are within the scheduled limits and load based scaling is in cooldown
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestInvalidScheduleIsIgnored(t *testing.T) {
+	f := newDGSAutoScalerFixture(t)
+
+	dgsCol := f.newScheduledDGSCol(1, dgsv1alpha1.DGSScalingSchedule{
+		Name:     "invalid",
+		Schedule: "every day",
+		Duration: metav1.Duration{Duration: time.Hour},
+		Replicas: int32Ptr(8),
+	})
+
+	// expect nothing but a warning event
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	select {
+	case event := <-f.recorder.Events:
+		assert.Contains(t, event, shared.ActivePlayersAutoScalerMisconfigured)
+	default:
+		t.Error("Expected an event for the invalid schedule")
+	}
+}
@@ -0,0 +1,131 @@
+package autoscale
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+)
+
+const (
+	// defaultWebhookTimeout is the timeout of the webhook requests, if the DGSCol does not set one
+	defaultWebhookTimeout = 5 * time.Second
+	// maxWebhookResponseSize is the maximum size of a webhook response body that is read
+	maxWebhookResponseSize = 1 << 20
+)
+
+// WebhookAutoScalerRequest is the JSON body that the webhook autoscaler POSTs to the endpoint
+type WebhookAutoScalerRequest struct {
+	Name                 string                                          `json:"name"`
+	Namespace            string                                          `json:"namespace"`
+	Replicas             int32                                           `json:"replicas"`
+	MinimumReplicas      int                                             `json:"minimumReplicas"`
+	MaximumReplicas      int                                             `json:"maximumReplicas"`
+	Status               dgsv1alpha1.DedicatedGameServerCollectionStatus `json:"status"`
+	DedicatedGameServers []WebhookAutoScalerDedicatedGameServer          `json:"dedicatedGameServers"`
+}
+
+// WebhookAutoScalerDedicatedGameServer contains the state and the players of a DGS of the collection
+type WebhookAutoScalerDedicatedGameServer struct {
+	Name              string                `json:"name"`
+	ActivePlayers     int                   `json:"activePlayers"`
+	DGSState          dgsv1alpha1.DGSState  `json:"dgsState"`
+	Health            dgsv1alpha1.DGSHealth `json:"health"`
+	MarkedForDeletion bool                  `json:"markedForDeletion"`
+}
+
+// WebhookAutoScalerResponse is the JSON body that the endpoint should return, with status code 200
+type WebhookAutoScalerResponse struct {
+	Replicas *int32 `json:"replicas"`
+}
+
+// newWebhookRequest returns the request for the DGSCol and its DGSs
+func newWebhookRequest(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgss []*dgsv1alpha1.DedicatedGameServer) *WebhookAutoScalerRequest {
+	request := &WebhookAutoScalerRequest{
+		Name:                 dgsCol.Name,
+		Namespace:            dgsCol.Namespace,
+		Replicas:             dgsCol.Spec.Replicas,
+		MinimumReplicas:      dgsCol.Spec.DGSWebhookAutoScalerDetails.MinimumReplicas,
+		MaximumReplicas:      dgsCol.Spec.DGSWebhookAutoScalerDetails.MaximumReplicas,
+		Status:               dgsCol.Status,
+		DedicatedGameServers: make([]WebhookAutoScalerDedicatedGameServer, 0, len(dgss)),
+	}
+	for _, dgs := range dgss {
+		request.DedicatedGameServers = append(request.DedicatedGameServers, WebhookAutoScalerDedicatedGameServer{
+			Name:              dgs.Name,
+			ActivePlayers:     dgs.Status.ActivePlayers,
+			DGSState:          dgs.Status.DGSState,
+			Health:            dgs.Status.Health,
+			MarkedForDeletion: dgs.Status.MarkedForDeletion,
+		})
+	}
+	return request
+}
+
+// newWebhookHTTPClient returns an HTTP client with the timeout and the TLS options of the scaler details
+func newWebhookHTTPClient(scalerDetails *dgsv1alpha1.DGSWebhookAutoScalerDetails) (*http.Client, error) {
+	timeout := defaultWebhookTimeout
+	if scalerDetails.TimeoutSeconds > 0 {
+		timeout = time.Duration(scalerDetails.TimeoutSeconds) * time.Second
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: scalerDetails.InsecureSkipVerify}
+	if len(scalerDetails.CABundle) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(scalerDetails.CABundle) {
+			return nil, fmt.Errorf("CABundle does not contain any PEM encoded certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+			// calls are periodic, so there is no point in keeping connections open
+			DisableKeepAlives: true,
+		},
+	}, nil
+}
+
+// callWebhook POSTs the request to url and returns the replicas of the response
+func callWebhook(client *http.Client, url string, request *WebhookAutoScalerRequest) (int32, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookResponseSize))
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("webhook returned status code %d", resp.StatusCode)
+	}
+
+	var response WebhookAutoScalerResponse
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return 0, fmt.Errorf("cannot decode webhook response: %s", err.Error())
+	}
+	if response.Replicas == nil {
+		return 0, fmt.Errorf("webhook response does not contain replicas")
+	}
+	if *response.Replicas < 0 {
+		return 0, fmt.Errorf("webhook returned negative replicas %d", *response.Replicas)
+	}
+	return *response.Replicas, nil
+}
@@ -2,6 +2,7 @@ package dgs
 
 import (
 	"fmt"
+	"time"
 
 	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
 	dgsclientset "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
@@ -46,6 +47,10 @@ type Controller struct {
 	logger *logrus.Logger
 
 	portRegistry *controllers.PortRegistry
+	// portReconciliationInterval is the interval of the comparison of the PortRegistry with the DGSs and their Pods, 0 disables it
+	portReconciliationInterval time.Duration
+	// tokenKeys mint the tokens that the DGS Pods use to call the API Server
+	tokenKeys *shared.DGSTokenKeyStore
 	// recorder is an event recorder for recording Event resources to the
 	// Kubernetes API.
 	recorder record.EventRecorder
@@ -56,7 +61,8 @@ type Controller struct {
 // NewDedicatedGameServerController creates a new DedicatedGameServerController
 func NewDedicatedGameServerController(client kubernetes.Interface, dgsclient dgsclientset.Interface,
 	dgsInformer informerdgs.DedicatedGameServerInformer,
-	podInformer informercorev1.PodInformer, nodeInformer informercorev1.NodeInformer, portRegistry *controllers.PortRegistry) *Controller {
+	podInformer informercorev1.PodInformer, nodeInformer informercorev1.NodeInformer, portRegistry *controllers.PortRegistry,
+	portReconciliationInterval time.Duration) *Controller {
 
 	c := &Controller{
 		dgsClient:        dgsclient,
@@ -69,7 +75,10 @@ func NewDedicatedGameServerController(client kubernetes.Interface, dgsclient dgs
 		podListerSynced:  podInformer.Informer().HasSynced,
 		nodeListerSynced: nodeInformer.Informer().HasSynced,
 		portRegistry:     portRegistry,
+		tokenKeys:        shared.NewDGSTokenKeyStore(client, shared.DefaultGameNamespace(), true),
 		logger:           shared.Logger(),
+
+		portReconciliationInterval: portReconciliationInterval,
 	}
 
 	c.controllerHelper = controllers.NewControllerHelper(
@@ -194,11 +203,14 @@ func (c *Controller) handleDedicatedGameServer(obj interface{}) {
 }
 
 func (c *Controller) handleDedicatedGameServerDelete(obj interface{}) {
-	dgs, ok := obj.(*dgsv1alpha1.DedicatedGameServer)
-	if ok {
-		//make sure all ports are deleted from the registry
-		c.portRegistry.DeregisterServerPorts(dgs.Spec.PortsToExpose)
+	// DeletionHandlingMetaNamespaceKeyFunc handles tombstones as well
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
 	}
+	//make sure all ports of the DGS are deleted from the registry
+	c.portRegistry.Release(key)
 }
 
 // syncHandler compares the actual state with the desired, and attempts to
@@ -227,7 +239,7 @@ func (c *Controller) syncHandler(key string) error {
 	// DGS is being terminated
 	if !dgsTemp.DeletionTimestamp.IsZero() {
 		c.logger.WithField("DedicatedGameServerName", dgsTemp.Name).Info("DedicatedGameServer is being terminated")
-		return nil
+		return c.handleTerminatingDGS(dgsTemp)
 	}
 
 	//check if DGS is markedForDeletion and has zero players connected to it
@@ -256,6 +268,12 @@ func (c *Controller) syncHandler(key string) error {
 
 	// pod found
 
+	err = c.syncPodCollectionLabel(dgsTemp, pod)
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{"Name": dgsTemp.Name, "Error": err.Error()}).Error("Error in updating the DedicatedGameServerCollection label of the Pod")
+		return err
+	}
+
 	// try to update DGS with Node's Public IP
 	// get the Node/Public IP for this Pod
 	var ip string
@@ -287,7 +305,26 @@ func (c *Controller) syncHandler(key string) error {
 	dgsToUpdate.Status.PublicIP = ip
 	dgsToUpdate.Status.NodeName = pod.Spec.NodeName
 
-	_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Update(dgsToUpdate)
+	// clients connect to the exposed ports of the DGS via its Address
+	dgsToUpdate.Status.Ports = shared.GetDGSPorts(dgsToUpdate)
+	address, err := shared.RenderDGSAddress(dgsToUpdate, ip, dgsToUpdate.Status.Ports)
+	if err != nil {
+		// the DGS can still be updated, without an Address
+		c.logger.WithFields(logrus.Fields{"Name": dgsName, "Error": err.Error()}).Error("Error in rendering the Address of the DedicatedGameServer")
+		c.recorder.Event(dgsTemp, corev1.EventTypeWarning, shared.AddressTemplateInvalid, err.Error())
+	}
+	dgsToUpdate.Status.Address = address
+
+	// status is not persisted on DGS creation, since it's a subresource
+	// so we set the initial values here
+	if dgsToUpdate.Status.Health == "" {
+		dgsToUpdate.Status.Health = dgsv1alpha1.DGSCreating
+	}
+	if dgsToUpdate.Status.DGSState == "" {
+		dgsToUpdate.Status.DGSState = dgsv1alpha1.DGSIdle
+	}
+
+	_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).UpdateStatus(dgsToUpdate)
 
 	if err != nil {
 		c.logger.WithFields(logrus.Fields{
@@ -350,14 +387,15 @@ func (c *Controller) enqueueDedicatedGameServer(obj interface{}) {
 }
 
 func (c *Controller) createNewPod(dgs *dgsv1alpha1.DedicatedGameServer) error {
-	accesscode, err := shared.GetAccessCode(c.podClient)
+	// the token is bound to the DGS, so that its Pod cannot update the status of other DGSs
+	token, err := c.tokenKeys.MintDGSToken(dgs.Namespace, dgs.Name)
 	if err != nil {
-		return fmt.Errorf("Cannot get API Server Access Code because of: %s", err.Error())
+		return fmt.Errorf("Cannot mint the API Server token because of: %s", err.Error())
 	}
 	pod := shared.NewPod(dgs,
 		shared.APIDetails{
 			APIServerURL: shared.APIServerURL,
-			Code:         accesscode,
+			Token:        token,
 		})
 	_, err = c.podClient.CoreV1().Pods(dgs.Namespace).Create(pod)
 	if err != nil {
@@ -368,5 +406,8 @@ func (c *Controller) createNewPod(dgs *dgsv1alpha1.DedicatedGameServer) error {
 
 // Run initiates the DedicatedGameServer controller
 func (c *Controller) Run(controllerThreadiness int, stopCh <-chan struct{}) error {
+	if c.portRegistry != nil && c.portReconciliationInterval > 0 {
+		go c.runPortReconciliation(stopCh)
+	}
 	return c.controllerHelper.Run(controllerThreadiness, stopCh)
 }
@@ -30,6 +30,11 @@ func (c *Controller) hasDGSChanged(oldDGS, newDGS *dgsv1alpha1.DedicatedGameServ
 		}
 	}
 
+	// the DGS is being terminated, so its Pod and HostPorts need to be released
+	if oldDGS.DeletionTimestamp.IsZero() != newDGS.DeletionTimestamp.IsZero() {
+		return true
+	}
+
 	// we check if all of the following fields are the same
 	if oldDGS.Status.Health != newDGS.Status.Health ||
 		oldDGS.Status.PodPhase != newDGS.Status.PodPhase ||
@@ -83,3 +88,27 @@ func (c *Controller) isDGSMarkedForDeletionWithZeroPlayers(dgs *dgsv1alpha1.Dedi
 	//check its state and active players
 	return dgs.Status.ActivePlayers == 0 && dgs.Status.MarkedForDeletion
 }
+
+// syncPodCollectionLabel sets the DGSCol name label of the Pod to the one of its DGS
+// so that the Selector of the DGSCol scale subresource matches only the Pods of the DGSs that belong to the DGSCol
+// This also labels the Pods that were created before the Pods got the label
+func (c *Controller) syncPodCollectionLabel(dgs *dgsv1alpha1.DedicatedGameServer, pod *corev1.Pod) error {
+	dgsColName, dgsHasLabel := dgs.Labels[shared.LabelDedicatedGameServerCollectionName]
+	podColName, podHasLabel := pod.Labels[shared.LabelDedicatedGameServerCollectionName]
+	if dgsHasLabel == podHasLabel && dgsColName == podColName {
+		return nil
+	}
+
+	podToUpdate := pod.DeepCopy()
+	if podToUpdate.Labels == nil {
+		podToUpdate.Labels = make(map[string]string)
+	}
+	if dgsHasLabel {
+		podToUpdate.Labels[shared.LabelDedicatedGameServerCollectionName] = dgsColName
+	} else {
+		// the DGS was removed from its DGSCol
+		delete(podToUpdate.Labels, shared.LabelDedicatedGameServerCollectionName)
+	}
+	_, err := c.podClient.CoreV1().Pods(pod.Namespace).Update(podToUpdate)
+	return err
+}
@@ -0,0 +1,163 @@
+package dgs
+
+import (
+	"fmt"
+	"time"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	logrus "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+// portReconciliationMinAge is the minimum age of an allocation that has no DGS or Pod, before it is released by the reconciliation
+// The DGSs of newer allocations may not have reached the informer cache yet
+const portReconciliationMinAge = time.Minute
+
+// handleTerminatingDGS deletes the Pod of a terminating DGS and, once the Pod is gone, releases the HostPorts
+// of the DGS and removes its finalizer, so that the DGS can be deleted
+func (c *Controller) handleTerminatingDGS(dgs *dgsv1alpha1.DedicatedGameServer) error {
+	if !hasHostPortsFinalizer(dgs) {
+		return nil
+	}
+
+	set := labels.Set{
+		shared.LabelDedicatedGameServerName: dgs.Name,
+	}
+	pods, err := c.podLister.Pods(dgs.Namespace).List(labels.SelectorFromSet(set))
+	if err != nil {
+		return err
+	}
+
+	// the Pod is not removed by the garbage collector, since its owner DGS still exists because of the finalizer
+	if len(pods) > 0 {
+		for _, pod := range pods {
+			if !pod.DeletionTimestamp.IsZero() {
+				continue
+			}
+			err := c.podClient.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		}
+		// HostPorts are in use until the Pod is gone, its deletion will enqueue the DGS again
+		return nil
+	}
+
+	key, err := cache.MetaNamespaceKeyFunc(dgs)
+	if err != nil {
+		return err
+	}
+	if c.portRegistry != nil {
+		c.portRegistry.Release(key)
+	}
+
+	dgsToUpdate := dgs.DeepCopy()
+	dgsToUpdate.ObjectMeta.Finalizers = removeString(dgsToUpdate.ObjectMeta.Finalizers, shared.HostPortsFinalizer)
+	_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgs.Namespace).Update(dgsToUpdate)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	c.logger.WithField("DedicatedGameServerName", dgs.Name).Info("Released the HostPorts of the terminated DedicatedGameServer")
+	c.recorder.Event(dgs, corev1.EventTypeNormal, shared.HostPortsReleased, fmt.Sprintf(shared.MessageHostPortsReleased, dgs.Name))
+	return nil
+}
+
+// runPortReconciliation periodically compares the PortRegistry with the DGSs and their Pods, until stopCh is closed
+func (c *Controller) runPortReconciliation(stopCh <-chan struct{}) {
+	// an empty cache would release all the HostPorts
+	if !cache.WaitForCacheSync(stopCh, c.dgsListerSynced, c.podListerSynced) {
+		return
+	}
+	wait.Until(c.reconcilePorts, c.portReconciliationInterval, stopCh)
+}
+
+// reconcilePorts repairs any drift between the PortRegistry and the HostPorts of the DGSs and their Pods
+func (c *Controller) reconcilePorts() {
+	expected, err := c.getExpectedPortAllocations()
+	if err != nil {
+		c.logger.WithField("Error", err.Error()).Error("Cannot get the HostPorts of the DedicatedGameServers")
+		return
+	}
+
+	registered, released := c.portRegistry.Reconcile(expected, portReconciliationMinAge)
+	if len(registered) > 0 || len(released) > 0 {
+		c.logger.WithFields(logrus.Fields{
+			"Registered": registered,
+			"Released":   released,
+		}).Warn("Repaired drift between the Port Registry and the DedicatedGameServers")
+	}
+}
+
+// getExpectedPortAllocations returns the HostPorts that are used by the DGSs and by the Pods of deleted DGSs, keyed by DGS
+func (c *Controller) getExpectedPortAllocations() (map[string]dgsv1alpha1.DGSPortAllocation, error) {
+	expected := make(map[string]dgsv1alpha1.DGSPortAllocation)
+
+	dgss, err := c.dgsLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, dgs := range dgss {
+		allocation := c.portRegistry.AllocationFor(dgs)
+		if allocation == nil {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(dgs)
+		if err != nil {
+			return nil, err
+		}
+		expected[key] = *allocation
+	}
+
+	// Pods keep their HostPorts until they are gone, even if their DGS has been deleted
+	pods, err := c.podLister.List(labels.SelectorFromSet(labels.Set{shared.LabelIsDedicatedGameServer: "true"}))
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		key := fmt.Sprintf("%s/%s", pod.Namespace, pod.Labels[shared.LabelDedicatedGameServerName])
+		if _, ok := expected[key]; ok {
+			continue
+		}
+		var hostPorts []int32
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.HostPort != 0 && !shared.SliceContains(hostPorts, port.HostPort) {
+					hostPorts = append(hostPorts, port.HostPort)
+				}
+			}
+		}
+		if len(hostPorts) > 0 {
+			expected[key] = dgsv1alpha1.DGSPortAllocation{NodePool: c.portRegistry.NodePoolFor(&pod.Spec), HostPorts: hostPorts}
+		}
+	}
+
+	return expected, nil
+}
+
+func hasHostPortsFinalizer(dgs *dgsv1alpha1.DedicatedGameServer) bool {
+	for _, finalizer := range dgs.ObjectMeta.Finalizers {
+		if finalizer == shared.HostPortsFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(slice []string, s string) []string {
+	var result []string
+	for _, item := range slice {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
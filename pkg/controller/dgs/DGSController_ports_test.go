@@ -0,0 +1,108 @@
+package dgs
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newTerminatingDGS() *dgsv1alpha1.DedicatedGameServer {
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	now := metav1.Now()
+	dgs.DeletionTimestamp = &now
+	dgs.Finalizers = []string{shared.HostPortsFinalizer}
+	dgs.Spec.PortAllocation = &dgsv1alpha1.DGSPortAllocation{HostPorts: []int32{20000}}
+	return dgs
+}
+
+func newTestPortRegistry(t *testing.T) *controllers.PortRegistry {
+	portRegistry, err := controllers.NewPortRegistry(fake.NewSimpleClientset(), 20000, 20010, metav1.NamespaceAll, controllers.PortRegistryOptions{})
+	if err != nil {
+		t.Fatalf("Cannot initialize PortRegistry due to: %s", err.Error())
+	}
+	return portRegistry
+}
+
+func TestTerminatingDGSDeletesPod(t *testing.T) {
+	f := newDGSFixture(t)
+	f.portRegistry = newTestPortRegistry(t)
+
+	dgs := newTerminatingDGS()
+	pod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Token: ""})
+
+	f.podLister = append(f.podLister, pod)
+	f.k8sObjects = append(f.k8sObjects, pod)
+
+	f.dgsLister = append(f.dgsLister, dgs)
+	f.dgsObjects = append(f.dgsObjects, dgs)
+
+	_, err := f.portRegistry.Allocate(controllers.PortAllocationRequest{Owner: getKeyDGS(dgs, t), Count: 1})
+	assert.NoError(t, err)
+
+	// the finalizer is kept while the Pod exists
+	f.expectDeletePodAction(pod, nil)
+
+	f.run(getKeyDGS(dgs, t))
+
+	_, ok := f.portRegistry.Snapshot().Owners[getKeyDGS(dgs, t)]
+	assert.True(t, ok)
+}
+
+func TestTerminatingDGSWithoutPodReleasesHostPorts(t *testing.T) {
+	f := newDGSFixture(t)
+	f.portRegistry = newTestPortRegistry(t)
+
+	dgs := newTerminatingDGS()
+
+	f.dgsLister = append(f.dgsLister, dgs)
+	f.dgsObjects = append(f.dgsObjects, dgs)
+
+	_, err := f.portRegistry.Allocate(controllers.PortAllocationRequest{Owner: getKeyDGS(dgs, t), Count: 1})
+	assert.NoError(t, err)
+
+	f.expectUpdateDGSAction(dgs, func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		assert.NotContains(t, dgs.Finalizers, shared.HostPortsFinalizer)
+	})
+
+	f.run(getKeyDGS(dgs, t))
+
+	_, ok := f.portRegistry.Snapshot().Owners[getKeyDGS(dgs, t)]
+	assert.False(t, ok)
+}
+
+func TestGetExpectedPortAllocations(t *testing.T) {
+	f := newDGSFixture(t)
+	f.portRegistry = newTestPortRegistry(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Spec.PortAllocation = &dgsv1alpha1.DGSPortAllocation{HostPorts: []int32{20001}}
+	f.dgsLister = append(f.dgsLister, dgs)
+
+	// the Pod of a deleted DGS still uses its HostPorts
+	deletedDGS := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	pod := shared.NewPod(deletedDGS, shared.APIDetails{APIServerURL: "", Token: ""})
+	pod.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 20002}}
+	f.podLister = append(f.podLister, pod)
+
+	c, _, _ := f.newDedicatedGameServerController()
+	expected, err := c.getExpectedPortAllocations()
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]dgsv1alpha1.DGSPortAllocation{
+		getKeyDGS(dgs, t):        {HostPorts: []int32{20001}},
+		getKeyDGS(deletedDGS, t): {HostPorts: []int32{20002}},
+	}, expected)
+}
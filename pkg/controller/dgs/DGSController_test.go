@@ -6,13 +6,18 @@ import (
 	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
 	dgsinformers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions"
+	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 
+	"github.com/stretchr/testify/assert"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	kubeinformers "k8s.io/client-go/informers"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
@@ -27,14 +32,17 @@ type dgsFixture struct {
 	dgsClient *fake.Clientset
 	// Objects to put in the store.
 
-	dgsLister []*dgsv1alpha1.DedicatedGameServer
-	podLister []*corev1.Pod
+	dgsLister  []*dgsv1alpha1.DedicatedGameServer
+	podLister  []*corev1.Pod
+	nodeLister []*corev1.Node
 	// Actions expected to happen on the client.
 	k8sActions []testhelpers.ExtendedAction
 	dgsActions []testhelpers.ExtendedAction
 	// Objects from here preloaded into NewSimpleFake.
 	k8sObjects []runtime.Object
 	dgsObjects []runtime.Object
+
+	portRegistry *controllers.PortRegistry
 }
 
 func newDGSFixture(t *testing.T) *dgsFixture {
@@ -61,7 +69,7 @@ func (f *dgsFixture) newDedicatedGameServerController() (*Controller,
 		f.dgsClient,
 		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers(),
 		k8sInformers.Core().V1().Pods(),
-		k8sInformers.Core().V1().Nodes(), nil)
+		k8sInformers.Core().V1().Nodes(), f.portRegistry, 0)
 
 	testController.dgsListerSynced = testhelpers.AlwaysReady
 	testController.podListerSynced = testhelpers.AlwaysReady
@@ -76,6 +84,10 @@ func (f *dgsFixture) newDedicatedGameServerController() (*Controller,
 		k8sInformers.Core().V1().Pods().Informer().GetIndexer().Add(pod)
 	}
 
+	for _, node := range f.nodeLister {
+		k8sInformers.Core().V1().Nodes().Informer().GetIndexer().Add(node)
+	}
+
 	return testController, dgsInformers, k8sInformers
 }
 
@@ -138,18 +150,36 @@ func (f *dgsFixture) expectCreatePodAction(p *corev1.Pod, assertions func(runtim
 	f.k8sActions = append(f.k8sActions, extAction)
 }
 
+func (f *dgsFixture) expectUpdatePodAction(p *corev1.Pod, assertions func(runtime.Object)) {
+	action := core.NewUpdateAction(schema.GroupVersionResource{Resource: "pods"}, p.Namespace, p)
+	extAction := testhelpers.ExtendedAction{Action: action, Assertions: assertions}
+	f.k8sActions = append(f.k8sActions, extAction)
+}
+
 func (f *dgsFixture) expectDeleteDGSAction(dgs *dgsv1alpha1.DedicatedGameServer, assertions func(runtime.Object)) {
 	action := core.NewDeleteAction(schema.GroupVersionResource{Group: "azuregaming.com", Resource: "dedicatedgameservers", Version: "v1alpha1"}, dgs.Namespace, dgs.Name)
 	extAction := testhelpers.ExtendedAction{Action: action, Assertions: assertions}
 	f.dgsActions = append(f.dgsActions, extAction)
 }
 
+func (f *dgsFixture) expectDeletePodAction(p *corev1.Pod, assertions func(runtime.Object)) {
+	action := core.NewDeleteAction(schema.GroupVersionResource{Resource: "pods"}, p.Namespace, p.Name)
+	extAction := testhelpers.ExtendedAction{Action: action, Assertions: assertions}
+	f.k8sActions = append(f.k8sActions, extAction)
+}
+
 func (f *dgsFixture) expectUpdateDGSAction(dgs *dgsv1alpha1.DedicatedGameServer, assertions func(runtime.Object)) {
 	action := core.NewUpdateAction(schema.GroupVersionResource{Group: "azuregaming.com", Resource: "dedicatedgameservers", Version: "v1alpha1"}, dgs.Namespace, dgs)
 	extAction := testhelpers.ExtendedAction{Action: action, Assertions: assertions}
 	f.dgsActions = append(f.dgsActions, extAction)
 }
 
+func (f *dgsFixture) expectUpdateDGSStatusAction(dgs *dgsv1alpha1.DedicatedGameServer, assertions func(runtime.Object)) {
+	action := core.NewUpdateSubresourceAction(schema.GroupVersionResource{Group: "azuregaming.com", Resource: "dedicatedgameservers", Version: "v1alpha1"}, "status", dgs.Namespace, dgs)
+	extAction := testhelpers.ExtendedAction{Action: action, Assertions: assertions}
+	f.dgsActions = append(f.dgsActions, extAction)
+}
+
 func getKeyDGS(dgs *dgsv1alpha1.DedicatedGameServer, t *testing.T) string {
 	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(dgs)
 	if err != nil {
@@ -169,21 +199,84 @@ func TestCreatesPod(t *testing.T) {
 	f.dgsLister = append(f.dgsLister, dgs)
 	f.dgsObjects = append(f.dgsObjects, dgs)
 
+	key := []byte("0123456789abcdef0123456789abcdef")
 	f.k8sObjects = append(f.k8sObjects, &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "apiaccesscode",
+			Name:      shared.DGSTokenKeysSecretName,
 			Namespace: shared.GameNamespace,
 		},
-		StringData: map[string]string{"code": ""},
+		Data: map[string][]byte{shared.DGSTokenCurrentKeyField: []byte("key1"), "key1": key},
 	})
 
-	expPod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Code: ""})
+	expPod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Token: ""})
 
-	f.expectCreatePodAction(expPod, nil)
+	f.expectCreatePodAction(expPod, func(obj runtime.Object) {
+		pod := obj.(*corev1.Pod)
+		var token string
+		for _, env := range pod.Spec.Containers[0].Env {
+			if env.Name == "API_SERVER_TOKEN" {
+				token = env.Value
+			}
+		}
+		// the token of the Pod is bound to its DGS
+		keys := &shared.DGSTokenKeys{CurrentKeyID: "key1", Keys: map[string][]byte{"key1": key}}
+		assert.Equal(t, &shared.DGSIdentity{Namespace: dgs.Namespace, Name: dgs.Name}, keys.Verify(token))
+		// the Pod matches the Selector of the scale subresource of its DGSCol
+		selector := labels.SelectorFromSet(labels.Set{shared.LabelDedicatedGameServerCollectionName: dgsCol.Name})
+		assert.True(t, selector.Matches(labels.Set(pod.Labels)))
+	})
 
 	f.run(getKeyDGS(dgs, t))
 }
 
+func TestPodCollectionLabelFollowsDGS(t *testing.T) {
+	tests := []struct {
+		name           string
+		dgsInCol       bool
+		podInCol       bool
+		expectedInCol  bool
+		expectedUpdate bool
+	}{
+		{name: "Pod created before the label", dgsInCol: true, podInCol: false, expectedInCol: true, expectedUpdate: true},
+		{name: "DGS removed from the DGSCol", dgsInCol: false, podInCol: true, expectedInCol: false, expectedUpdate: true},
+		{name: "labels are the same", dgsInCol: true, podInCol: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newDGSFixture(t)
+
+			dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+			dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+			pod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Token: ""})
+			if !tt.dgsInCol {
+				delete(dgs.Labels, shared.LabelDedicatedGameServerCollectionName)
+			}
+			if !tt.podInCol {
+				delete(pod.Labels, shared.LabelDedicatedGameServerCollectionName)
+			}
+
+			f.podLister = append(f.podLister, pod)
+			f.k8sObjects = append(f.k8sObjects, pod)
+
+			f.dgsLister = append(f.dgsLister, dgs)
+			f.dgsObjects = append(f.dgsObjects, dgs)
+
+			if tt.expectedUpdate {
+				f.expectUpdatePodAction(pod, func(actual runtime.Object) {
+					pod := actual.(*corev1.Pod)
+					_, ok := pod.Labels[shared.LabelDedicatedGameServerCollectionName]
+					assert.Equal(t, tt.expectedInCol, ok)
+					assert.Equal(t, dgs.Name, pod.Labels[shared.LabelDedicatedGameServerName])
+				})
+			}
+			f.expectUpdateDGSStatusAction(dgs, nil)
+
+			f.run(getKeyDGS(dgs, t))
+		})
+	}
+}
+
 func TestDeleteDGSWithZeroActivePlayers(t *testing.T) {
 	f := newDGSFixture(t)
 
@@ -194,7 +287,7 @@ func TestDeleteDGSWithZeroActivePlayers(t *testing.T) {
 	dgs.Status.Health = dgsv1alpha1.DGSHealthy
 	dgs.Status.MarkedForDeletion = true
 
-	delPod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Code: ""})
+	delPod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Token: ""})
 
 	f.podLister = append(f.podLister, delPod)
 	f.k8sObjects = append(f.k8sObjects, delPod)
@@ -215,7 +308,7 @@ func TestDGSStatusIsUpdated(t *testing.T) {
 
 	//dgs.Status.ActivePlayers = 0
 
-	pod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Code: ""})
+	pod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Token: ""})
 
 	f.podLister = append(f.podLister, pod)
 	f.k8sObjects = append(f.k8sObjects, pod)
@@ -223,7 +316,81 @@ func TestDGSStatusIsUpdated(t *testing.T) {
 	f.dgsLister = append(f.dgsLister, dgs)
 	f.dgsObjects = append(f.dgsObjects, dgs)
 
-	f.expectUpdateDGSAction(dgs, nil)
+	f.expectUpdateDGSStatusAction(dgs, nil)
+
+	f.run(getKeyDGS(dgs, t))
+}
+
+func TestDGSStatusIsInitialized(t *testing.T) {
+	f := newDGSFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+
+	// status is a subresource, so it's empty when the DGS is created
+	dgs.Status = dgsv1alpha1.DedicatedGameServerStatus{}
+
+	pod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Token: ""})
+
+	f.podLister = append(f.podLister, pod)
+	f.k8sObjects = append(f.k8sObjects, pod)
+
+	f.dgsLister = append(f.dgsLister, dgs)
+	f.dgsObjects = append(f.dgsObjects, dgs)
+
+	f.expectUpdateDGSStatusAction(dgs, func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		if dgs.Status.Health != dgsv1alpha1.DGSCreating {
+			t.Errorf("Health is %s instead of %s", dgs.Status.Health, dgsv1alpha1.DGSCreating)
+		}
+		if dgs.Status.DGSState != dgsv1alpha1.DGSIdle {
+			t.Errorf("DGSState is %s instead of %s", dgs.Status.DGSState, dgsv1alpha1.DGSIdle)
+		}
+	})
+
+	f.run(getKeyDGS(dgs, t))
+}
+
+func TestDGSStatusContainsPortsAndAddress(t *testing.T) {
+	f := newDGSFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgsCol.Spec.PortsToExpose = []intstr.IntOrString{intstr.FromInt(7777)}
+	dgsCol.Spec.AddressTemplate = `{{.PublicIP}}:{{index .Ports "game"}}`
+	dgs := shared.NewDedicatedGameServer(dgsCol, corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name: "test",
+				Ports: []corev1.ContainerPort{
+					{Name: "game", ContainerPort: 7777, HostPort: 20001, Protocol: corev1.ProtocolUDP},
+					{Name: "metrics", ContainerPort: 9090},
+				},
+			},
+		},
+	})
+
+	pod := shared.NewPod(dgs, shared.APIDetails{APIServerURL: "", Token: ""})
+	pod.Spec.NodeName = "node1"
+
+	f.podLister = append(f.podLister, pod)
+	f.k8sObjects = append(f.k8sObjects, pod)
+	f.nodeLister = append(f.nodeLister, &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node1"},
+		Status: corev1.NodeStatus{
+			Addresses: []corev1.NodeAddress{{Type: corev1.NodeExternalIP, Address: "1.2.3.4"}},
+		},
+	})
+
+	f.dgsLister = append(f.dgsLister, dgs)
+	f.dgsObjects = append(f.dgsObjects, dgs)
+
+	f.expectUpdateDGSStatusAction(dgs, func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		assert.Equal(t, []dgsv1alpha1.DGSPort{
+			{Name: "game", ContainerPort: 7777, HostPort: 20001, Protocol: corev1.ProtocolUDP},
+		}, dgs.Status.Ports)
+		assert.Equal(t, "1.2.3.4:20001", dgs.Status.Address)
+	})
 
 	f.run(getKeyDGS(dgs, t))
 }
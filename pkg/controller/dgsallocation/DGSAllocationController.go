@@ -0,0 +1,175 @@
+package dgsallocation
+
+import (
+	"fmt"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	dgsclientset "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
+	dgsscheme "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/scheme"
+	informerdgs "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions/azuregaming/v1alpha1"
+	listerdgs "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/listers/azuregaming/v1alpha1"
+	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	logrus "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	record "k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const dgsAllocationControllerAgentName = "dedicated-game-server-allocation-controller"
+
+// DGSAllocationController is the struct that represents the DGSAllocationController
+type DGSAllocationController struct {
+	dgsClient            dgsclientset.Interface
+	dgsAllocLister       listerdgs.DedicatedGameServerAllocationLister
+	dgsAllocListerSynced cache.InformerSynced
+
+	logger *logrus.Logger
+
+	// recorder is an event recorder for recording Event resources to the
+	// Kubernetes API.
+	recorder record.EventRecorder
+
+	controllerHelper *controllers.ControllerHelper
+}
+
+// NewDGSAllocationController creates a new DGSAllocationController
+func NewDGSAllocationController(client kubernetes.Interface, dgsclient dgsclientset.Interface,
+	dgsAllocInformer informerdgs.DedicatedGameServerAllocationInformer) *DGSAllocationController {
+
+	c := &DGSAllocationController{
+		dgsClient:            dgsclient,
+		dgsAllocLister:       dgsAllocInformer.Lister(),
+		dgsAllocListerSynced: dgsAllocInformer.Informer().HasSynced,
+		logger:               shared.Logger(),
+	}
+
+	c.controllerHelper = controllers.NewControllerHelper(
+		workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "DedicatedGameServerAllocationSync"),
+		c.logger,
+		c.syncHandler,
+		"DGSAllocationController",
+		[]cache.InformerSynced{c.dgsAllocListerSynced},
+	)
+
+	// Create event broadcaster
+	// Add DGSAllocationController types to the default Kubernetes Scheme so Events can be
+	// logged for DGSAllocationController types.
+	dgsscheme.AddToScheme(dgsscheme.Scheme)
+	c.logger.Info("Creating event broadcaster for DGSAllocation controller")
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartLogging(c.logger.Infof)
+	eventBroadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: client.CoreV1().Events("")})
+	c.recorder = eventBroadcaster.NewRecorder(dgsscheme.Scheme, corev1.EventSource{Component: dgsAllocationControllerAgentName})
+
+	c.logger.Info("Setting up event handlers for DGSAllocation controller")
+
+	dgsAllocInformer.Informer().AddEventHandler(
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				c.logger.Print("DGSAllocation controller - add DedicatedGameServerAllocation")
+				c.handleDedicatedGameServerAllocation(obj)
+			},
+			// allocations are fulfilled only once, so there is nothing to do on update
+		},
+	)
+
+	return c
+}
+
+// syncHandler tries to fulfill a pending DedicatedGameServerAllocation by assigning an Idle DedicatedGameServer to it.
+// It then updates the Status block of the DedicatedGameServerAllocation resource with the outcome
+func (c *DGSAllocationController) syncHandler(key string) error {
+	// Convert the namespace/name string into a distinct namespace and name
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+
+	dgsAllocTemp, err := c.dgsAllocLister.DedicatedGameServerAllocations(namespace).Get(name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			runtime.HandleError(fmt.Errorf("DedicatedGameServerAllocation '%s' in work queue no longer exists", key))
+			return nil
+		}
+		c.logger.WithField("DGSAllocationName", name).Errorf("Error getting DGSAllocation: %s", err.Error())
+		return err
+	}
+
+	// allocation has already been processed
+	if dgsAllocTemp.Status.State != "" {
+		return nil
+	}
+
+	dgsAllocToUpdate := dgsAllocTemp.DeepCopy()
+
+	dgs, err := shared.AllocateDedicatedGameServer(c.dgsClient, dgsAllocToUpdate)
+	if err == shared.ErrNoCapacity {
+		dgsAllocToUpdate.Status = dgsv1alpha1.DedicatedGameServerAllocationStatus{State: dgsv1alpha1.DGSAllocationUnAllocated}
+	} else if err != nil {
+		c.logger.WithFields(logrus.Fields{"DGSAllocationName": name, "Error": err.Error()}).Error("Cannot allocate DedicatedGameServer")
+		return err
+	} else {
+		dgsAllocToUpdate.Status = shared.NewAllocatedStatus(dgs)
+	}
+
+	_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServerAllocations(namespace).Update(dgsAllocToUpdate)
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{"DGSAllocationName": name, "Error": err.Error()}).Error("Cannot update DedicatedGameServerAllocation")
+		return err
+	}
+
+	if dgsAllocToUpdate.Status.State == dgsv1alpha1.DGSAllocationAllocated {
+		c.recorder.Event(dgsAllocToUpdate, corev1.EventTypeNormal, shared.DedicatedGameServerAllocated, fmt.Sprintf(shared.MessageDedicatedGameServerAllocated, dgs.Name, dgsAllocToUpdate.Name))
+	} else {
+		c.recorder.Event(dgsAllocToUpdate, corev1.EventTypeWarning, shared.DedicatedGameServerUnAllocated, fmt.Sprintf(shared.MessageDedicatedGameServerUnAllocated, dgsAllocToUpdate.Name))
+	}
+
+	return nil
+}
+
+// enqueueDedicatedGameServerAllocation takes a DedicatedGameServerAllocation resource and converts it into a namespace/name
+// string which is then put onto the work queue. This method should *not* be
+// passed resources of any type other than DedicatedGameServerAllocation.
+func (c *DGSAllocationController) enqueueDedicatedGameServerAllocation(obj interface{}) {
+	var key string
+	var err error
+	if key, err = cache.MetaNamespaceKeyFunc(obj); err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.controllerHelper.Workqueue.AddRateLimited(key)
+}
+
+// Run initiates the DGSAllocationController
+func (c *DGSAllocationController) Run(controllerThreadiness int, stopCh <-chan struct{}) error {
+	return c.controllerHelper.Run(controllerThreadiness, stopCh)
+}
+
+func (c *DGSAllocationController) handleDedicatedGameServerAllocation(obj interface{}) {
+	var object metav1.Object
+	var ok bool
+	if object, ok = obj.(metav1.Object); !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			runtime.HandleError(fmt.Errorf("error decoding DedicatedGameServerAllocation object, invalid type"))
+			return
+		}
+		object, ok = tombstone.Obj.(metav1.Object)
+		if !ok {
+			runtime.HandleError(fmt.Errorf("error decoding DedicatedGameServerAllocation object tombstone, invalid type"))
+			return
+		}
+		c.logger.Infof("Recovered deleted DedicatedGameServerAllocation object '%s' from tombstone", object.GetName())
+	}
+	c.enqueueDedicatedGameServerAllocation(object)
+}
@@ -0,0 +1,295 @@
+package dgsallocation
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+	dgsinformers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	core "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+type dgsAllocationFixture struct {
+	t *testing.T
+
+	k8sClient *k8sfake.Clientset
+	dgsClient *fake.Clientset
+
+	// Objects to put in the store.
+	dgsAllocLister []*dgsv1alpha1.DedicatedGameServerAllocation
+
+	// Actions expected to happen on the client.
+	dgsActions []testhelpers.ExtendedAction
+
+	// Objects from here preloaded into NewSimpleFake.
+	k8sObjects []runtime.Object
+	dgsObjects []runtime.Object
+}
+
+func newDGSAllocationFixture(t *testing.T) *dgsAllocationFixture {
+	f := &dgsAllocationFixture{}
+	f.t = t
+
+	f.k8sObjects = []runtime.Object{}
+	f.dgsObjects = []runtime.Object{}
+	return f
+}
+
+func (f *dgsAllocationFixture) newDGSAllocationController() (*DGSAllocationController, dgsinformers.SharedInformerFactory) {
+
+	f.k8sClient = k8sfake.NewSimpleClientset(f.k8sObjects...)
+	f.dgsClient = fake.NewSimpleClientset(f.dgsObjects...)
+
+	dgsInformers := dgsinformers.NewSharedInformerFactory(f.dgsClient, testhelpers.NoResyncPeriodFunc())
+
+	testController := NewDGSAllocationController(f.k8sClient, f.dgsClient,
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerAllocations())
+
+	testController.dgsAllocListerSynced = testhelpers.AlwaysReady
+
+	testController.recorder = &record.FakeRecorder{}
+
+	for _, dgsAlloc := range f.dgsAllocLister {
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerAllocations().Informer().GetIndexer().Add(dgsAlloc)
+	}
+
+	return testController, dgsInformers
+}
+
+func (f *dgsAllocationFixture) run(dgsAllocName string) {
+	f.runController(dgsAllocName, true, false)
+}
+
+func (f *dgsAllocationFixture) runController(dgsAllocName string, startInformers bool, expectError bool) {
+
+	testController, dgsInformers := f.newDGSAllocationController()
+	if startInformers {
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		dgsInformers.Start(stopCh)
+	}
+
+	err := testController.syncHandler(dgsAllocName)
+	if !expectError && err != nil {
+		f.t.Errorf("error syncing DGSAllocation: %v", err)
+	} else if expectError && err == nil {
+		f.t.Error("expected error syncing DGSAllocation, got nil")
+	}
+
+	actions := filterInformerActionsDGSAllocation(f.dgsClient.Actions())
+
+	for i, action := range actions {
+		if len(f.dgsActions) < i+1 {
+			f.t.Errorf("%d unexpected actions: %+v", len(actions)-len(f.dgsActions), actions[i:])
+			break
+		}
+
+		expectedAction := f.dgsActions[i]
+		testhelpers.CheckAction(expectedAction, action, f.t)
+	}
+
+	if len(f.dgsActions) > len(actions) {
+		f.t.Errorf("%d additional expected actions:%+v", len(f.dgsActions)-len(actions), f.dgsActions[len(actions):])
+	}
+}
+
+func (f *dgsAllocationFixture) expectListDGSAction(namespace string) {
+	action := core.NewListAction(schema.GroupVersionResource{Resource: "dedicatedgameservers"}, schema.GroupVersionKind{Kind: "DedicatedGameServer"}, namespace, metav1.ListOptions{})
+	f.dgsActions = append(f.dgsActions, testhelpers.ExtendedAction{Action: action})
+}
+
+func (f *dgsAllocationFixture) expectUpdateDGSStatusAction(dgs *dgsv1alpha1.DedicatedGameServer, assertions func(runtime.Object)) {
+	action := core.NewUpdateSubresourceAction(schema.GroupVersionResource{Resource: "dedicatedgameservers"}, "status", dgs.Namespace, dgs)
+	f.dgsActions = append(f.dgsActions, testhelpers.ExtendedAction{Action: action, Assertions: assertions})
+}
+
+func (f *dgsAllocationFixture) expectUpdateDGSAllocationAction(dgsAlloc *dgsv1alpha1.DedicatedGameServerAllocation, assertions func(runtime.Object)) {
+	action := core.NewUpdateAction(schema.GroupVersionResource{Resource: "dedicatedgameserverallocations"}, dgsAlloc.Namespace, dgsAlloc)
+	f.dgsActions = append(f.dgsActions, testhelpers.ExtendedAction{Action: action, Assertions: assertions})
+}
+
+func newDGSAllocation(name string, dgsColName string) *dgsv1alpha1.DedicatedGameServerAllocation {
+	return &dgsv1alpha1.DedicatedGameServerAllocation{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: shared.GameNamespace},
+		Spec:       dgsv1alpha1.DedicatedGameServerAllocationSpec{DedicatedGameServerCollectionName: dgsColName},
+	}
+}
+
+func TestAllocateDGS(t *testing.T) {
+	f := newDGSAllocationFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Status.Health = dgsv1alpha1.DGSHealthy
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.PublicIP = "1.2.3.4"
+
+	dgsAlloc := newDGSAllocation("alloc", dgsCol.Name)
+	dgsAlloc.UID = "uid1"
+
+	f.dgsAllocLister = append(f.dgsAllocLister, dgsAlloc)
+	f.dgsObjects = append(f.dgsObjects, dgs, dgsAlloc)
+
+	f.expectListDGSAction(shared.GameNamespace)
+	f.expectUpdateDGSStatusAction(dgs, func(obj runtime.Object) {
+		dgs := obj.(*dgsv1alpha1.DedicatedGameServer)
+		if dgs.Status.DGSState != dgsv1alpha1.DGSAssigned {
+			t.Errorf("DGSState is %s instead of %s", dgs.Status.DGSState, dgsv1alpha1.DGSAssigned)
+		}
+		if dgs.Status.AllocationUID != dgsAlloc.UID {
+			t.Errorf("AllocationUID is %s instead of %s", dgs.Status.AllocationUID, dgsAlloc.UID)
+		}
+	})
+	f.expectUpdateDGSAllocationAction(dgsAlloc, func(obj runtime.Object) {
+		dgsAlloc := obj.(*dgsv1alpha1.DedicatedGameServerAllocation)
+		if dgsAlloc.Status.State != dgsv1alpha1.DGSAllocationAllocated {
+			t.Errorf("State is %s instead of %s", dgsAlloc.Status.State, dgsv1alpha1.DGSAllocationAllocated)
+		}
+		if dgsAlloc.Status.DedicatedGameServerName != dgs.Name {
+			t.Errorf("DedicatedGameServerName is %s instead of %s", dgsAlloc.Status.DedicatedGameServerName, dgs.Name)
+		}
+		if dgsAlloc.Status.PublicIP != "1.2.3.4" {
+			t.Errorf("PublicIP is %s instead of %s", dgsAlloc.Status.PublicIP, "1.2.3.4")
+		}
+	})
+
+	f.run(getKeyDGSAllocation(dgsAlloc, t))
+}
+
+func TestAllocateDGSRetryGetsSameDGS(t *testing.T) {
+	f := newDGSAllocationFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 2, testhelpers.PodSpec)
+	dgsAlloc := newDGSAllocation("alloc", dgsCol.Name)
+	dgsAlloc.UID = "uid1"
+
+	// the previous sync assigned dgs to the allocation, but could not update the allocation
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Status.Health = dgsv1alpha1.DGSHealthy
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.DGSState = dgsv1alpha1.DGSAssigned
+	dgs.Status.AllocationUID = dgsAlloc.UID
+	dgsIdle := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgsIdle.Status.Health = dgsv1alpha1.DGSHealthy
+	dgsIdle.Status.PodPhase = corev1.PodRunning
+
+	f.dgsAllocLister = append(f.dgsAllocLister, dgsAlloc)
+	f.dgsObjects = append(f.dgsObjects, dgs, dgsIdle, dgsAlloc)
+
+	// no other DGS is assigned
+	f.expectListDGSAction(shared.GameNamespace)
+	f.expectUpdateDGSAllocationAction(dgsAlloc, func(obj runtime.Object) {
+		dgsAlloc := obj.(*dgsv1alpha1.DedicatedGameServerAllocation)
+		if dgsAlloc.Status.DedicatedGameServerName != dgs.Name {
+			t.Errorf("DedicatedGameServerName is %s instead of %s", dgsAlloc.Status.DedicatedGameServerName, dgs.Name)
+		}
+	})
+
+	f.run(getKeyDGSAllocation(dgsAlloc, t))
+}
+
+func TestAllocateDGSRetryGetsSameRunningDGS(t *testing.T) {
+	f := newDGSAllocationFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 2, testhelpers.PodSpec)
+	dgsAlloc := newDGSAllocation("alloc", dgsCol.Name)
+	dgsAlloc.UID = "uid1"
+
+	// the previous sync assigned dgs to the allocation, but could not update the allocation
+	// in the meantime the game server reported that it is Running and its health flapped
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Status.Health = dgsv1alpha1.DGSFailed
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.DGSState = dgsv1alpha1.DGSRunning
+	dgs.Status.AllocationUID = dgsAlloc.UID
+	dgsIdle := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgsIdle.Status.Health = dgsv1alpha1.DGSHealthy
+	dgsIdle.Status.PodPhase = corev1.PodRunning
+
+	f.dgsAllocLister = append(f.dgsAllocLister, dgsAlloc)
+	f.dgsObjects = append(f.dgsObjects, dgs, dgsIdle, dgsAlloc)
+
+	// dgsIdle is not assigned
+	f.expectListDGSAction(shared.GameNamespace)
+	f.expectUpdateDGSAllocationAction(dgsAlloc, func(obj runtime.Object) {
+		dgsAlloc := obj.(*dgsv1alpha1.DedicatedGameServerAllocation)
+		if dgsAlloc.Status.DedicatedGameServerName != dgs.Name {
+			t.Errorf("DedicatedGameServerName is %s instead of %s", dgsAlloc.Status.DedicatedGameServerName, dgs.Name)
+		}
+	})
+
+	f.run(getKeyDGSAllocation(dgsAlloc, t))
+}
+
+func TestAllocateDGSNoCapacity(t *testing.T) {
+	f := newDGSAllocationFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Status.Health = dgsv1alpha1.DGSHealthy
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.DGSState = dgsv1alpha1.DGSAssigned
+
+	dgsAlloc := newDGSAllocation("alloc", dgsCol.Name)
+
+	f.dgsAllocLister = append(f.dgsAllocLister, dgsAlloc)
+	f.dgsObjects = append(f.dgsObjects, dgs, dgsAlloc)
+
+	f.expectListDGSAction(shared.GameNamespace)
+	f.expectUpdateDGSAllocationAction(dgsAlloc, func(obj runtime.Object) {
+		dgsAlloc := obj.(*dgsv1alpha1.DedicatedGameServerAllocation)
+		if dgsAlloc.Status.State != dgsv1alpha1.DGSAllocationUnAllocated {
+			t.Errorf("State is %s instead of %s", dgsAlloc.Status.State, dgsv1alpha1.DGSAllocationUnAllocated)
+		}
+	})
+
+	f.run(getKeyDGSAllocation(dgsAlloc, t))
+}
+
+func TestAllocationAlreadyProcessed(t *testing.T) {
+	f := newDGSAllocationFixture(t)
+
+	dgsAlloc := newDGSAllocation("alloc", "test")
+	dgsAlloc.Status.State = dgsv1alpha1.DGSAllocationAllocated
+
+	f.dgsAllocLister = append(f.dgsAllocLister, dgsAlloc)
+	f.dgsObjects = append(f.dgsObjects, dgsAlloc)
+
+	f.run(getKeyDGSAllocation(dgsAlloc, t))
+}
+
+// filterInformerActionsDGSAllocation filters list and watch actions for testing resources.
+// Since list and watch don't change resource state we can filter it to lower
+// noise level in our tests.
+func filterInformerActionsDGSAllocation(actions []core.Action) []core.Action {
+	ret := []core.Action{}
+	for _, action := range actions {
+		if len(action.GetNamespace()) == 0 &&
+			(action.Matches("list", "dedicatedgameserverallocations") ||
+				action.Matches("watch", "dedicatedgameserverallocations")) {
+			continue
+		}
+		ret = append(ret, action)
+	}
+
+	return ret
+}
+
+func getKeyDGSAllocation(dgsAlloc *dgsv1alpha1.DedicatedGameServerAllocation, t *testing.T) string {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(dgsAlloc)
+	if err != nil {
+		t.Errorf("Unexpected error getting key for DGSAllocation %v: %v", dgsAlloc.Name, err)
+		return ""
+	}
+	return key
+}
@@ -17,8 +17,10 @@ import (
 	errors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
+	appsinformers "k8s.io/client-go/informers/apps/v1"
 	kubernetes "k8s.io/client-go/kubernetes"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
 	cache "k8s.io/client-go/tools/cache"
 	record "k8s.io/client-go/tools/record"
 	workqueue "k8s.io/client-go/util/workqueue"
@@ -30,33 +32,40 @@ const (
 
 // Controller represents the Dedicated Game Server Collection controller
 type Controller struct {
-	dgsColClient       dgsclientset.Interface
-	dgsClient          dgsclientset.Interface
-	dgsColLister       listerdgs.DedicatedGameServerCollectionLister
-	dgsLister          listerdgs.DedicatedGameServerLister
-	dgsColListerSynced cache.InformerSynced
-	dgsListerSynced    cache.InformerSynced
-	logger             *logrus.Logger
-	portRegistry       *controllers.PortRegistry
-	recorder           record.EventRecorder
-	controllerHelper   *controllers.ControllerHelper
+	client                         kubernetes.Interface
+	dgsColClient                   dgsclientset.Interface
+	dgsClient                      dgsclientset.Interface
+	dgsColLister                   listerdgs.DedicatedGameServerCollectionLister
+	dgsLister                      listerdgs.DedicatedGameServerLister
+	controllerRevisionLister       appslisters.ControllerRevisionLister
+	dgsColListerSynced             cache.InformerSynced
+	dgsListerSynced                cache.InformerSynced
+	controllerRevisionListerSynced cache.InformerSynced
+	logger                         *logrus.Logger
+	portRegistry                   *controllers.PortRegistry
+	recorder                       record.EventRecorder
+	controllerHelper               *controllers.ControllerHelper
 }
 
 // NewDedicatedGameServerCollectionController initializes and returns a new DedicatedGameServerCollectionController instance
 func NewDedicatedGameServerCollectionController(client kubernetes.Interface, dgsclient dgsclientset.Interface,
 	dgsColInformer informerdgs.DedicatedGameServerCollectionInformer, dgsInformer informerdgs.DedicatedGameServerInformer,
+	controllerRevisionInformer appsinformers.ControllerRevisionInformer,
 	portRegistry *controllers.PortRegistry) (*Controller, error) {
 	dgsscheme.AddToScheme(dgsscheme.Scheme)
 
 	c := &Controller{
-		dgsColClient:       dgsclient,
-		dgsClient:          dgsclient,
-		dgsColLister:       dgsColInformer.Lister(),
-		dgsLister:          dgsInformer.Lister(),
-		dgsColListerSynced: dgsColInformer.Informer().HasSynced,
-		dgsListerSynced:    dgsInformer.Informer().HasSynced,
-		portRegistry:       portRegistry,
-		logger:             shared.Logger(),
+		client:                         client,
+		dgsColClient:                   dgsclient,
+		dgsClient:                      dgsclient,
+		dgsColLister:                   dgsColInformer.Lister(),
+		dgsLister:                      dgsInformer.Lister(),
+		controllerRevisionLister:       controllerRevisionInformer.Lister(),
+		dgsColListerSynced:             dgsColInformer.Informer().HasSynced,
+		dgsListerSynced:                dgsInformer.Informer().HasSynced,
+		controllerRevisionListerSynced: controllerRevisionInformer.Informer().HasSynced,
+		portRegistry:                   portRegistry,
+		logger:                         shared.Logger(),
 	}
 
 	c.controllerHelper = controllers.NewControllerHelper(
@@ -64,7 +73,7 @@ func NewDedicatedGameServerCollectionController(client kubernetes.Interface, dgs
 		c.logger,
 		c.syncHandler,
 		"DedicatedGameServerCollectionController",
-		[]cache.InformerSynced{c.dgsColListerSynced, c.dgsListerSynced},
+		[]cache.InformerSynced{c.dgsColListerSynced, c.dgsListerSynced, c.controllerRevisionListerSynced},
 	)
 
 	eventBroadcaster := record.NewBroadcaster()
@@ -148,6 +157,12 @@ func (c *Controller) syncHandler(key string) error {
 		return nil
 	}
 
+	err = c.reconcileRevisions(dgsCol)
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{"DedicatedGameServerCollection": dgsCol.Name, "Error": err.Error()}).Errorf("Failed to reconcile revisions of the DGSCol because of %s", err.Error())
+		return err
+	}
+
 	err = c.reconcileStatuses(dgsCol)
 	if err != nil {
 		c.logger.WithFields(logrus.Fields{"DedicatedGameServerCollection": dgsCol.Name, "Error": err.Error()}).Errorf("Failed to reconcile statuses of the DGSCol because of %s", err.Error())
@@ -184,10 +199,35 @@ func (c *Controller) syncHandler(key string) error {
 		return err
 	}
 
+	err = c.setMissingTemplateHashes(dgsCol, dgsExisting)
+	if err != nil {
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "Error": err.Error()}).Error("Cannot set the template hash of DedicatedGameServers")
+		return err
+	}
+
+	// the template has changed, so we need to replace the DGSs that run the old one
+	dgsUpdated, dgsOld := splitDGSByTemplate(dgsCol, dgsExisting)
+	if len(dgsOld) > 0 {
+		err = c.rolloutDGSCol(dgsCol, dgsUpdated, dgsOld)
+		if err != nil {
+			c.recorder.Event(dgsCol, corev1.EventTypeWarning, "Cannot replace dedicated game servers", err.Error())
+			c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "Error": err.Error()}).Error("Cannot replace dedicated game servers")
+			return err
+		}
+		return nil //exiting sync handler, further DGS updates will propagate here as well via another item in the workqueue
+	}
+
 	dgsExistingCount := len(dgsExisting)
 
 	// if there are less DedicatedGameServers than the ones we requested
 	if dgsExistingCount < int(dgsCol.Spec.Replicas) {
+		err = c.validatePortPolicy(dgsCol)
+		if err != nil {
+			c.recorder.Event(dgsCol, corev1.EventTypeWarning, shared.PortPolicyInvalid, err.Error())
+			c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "Error": err.Error()}).Error("Invalid port policy")
+			// requeuing will not help, the DGSCol needs to be fixed
+			return nil
+		}
 		err = c.addDGSColReplicas(dgsCol, dgsExistingCount)
 		if err != nil {
 			c.recorder.Event(dgsCol, corev1.EventTypeWarning, "Cannot increase dedicated game servers", err.Error())
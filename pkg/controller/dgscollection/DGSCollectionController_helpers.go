@@ -1,6 +1,8 @@
 package dgscollection
 
 import (
+	"reflect"
+
 	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 
@@ -9,11 +11,15 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/retry"
 )
 
 func (c *Controller) hasSpecChanged(oldDGSCol, newDGSCol *dgsv1alpha1.DedicatedGameServerCollection) bool {
-	return oldDGSCol.Spec.Replicas != newDGSCol.Spec.Replicas
+	return oldDGSCol.Spec.Replicas != newDGSCol.Spec.Replicas ||
+		shared.GetTemplateHash(oldDGSCol.Spec.Template) != shared.GetTemplateHash(newDGSCol.Spec.Template) ||
+		!reflect.DeepEqual(oldDGSCol.Spec.UpdateStrategy, newDGSCol.Spec.UpdateStrategy) ||
+		!reflect.DeepEqual(oldDGSCol.Spec.PortPolicy, newDGSCol.Spec.PortPolicy)
 }
 
 func (c *Controller) setPodCollectionState(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
@@ -97,32 +103,39 @@ func (c *Controller) setAvailableReplicasStatus(dgsCol *dgsv1alpha1.DedicatedGam
 	return nil
 }
 
+func (c *Controller) setUpdatedReplicasStatus(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
+	set := labels.Set{
+		shared.LabelDedicatedGameServerCollectionName: dgsCol.Name,
+	}
+	// we search via Labels, each DGS will have the DGSCol name as a Label
+	selector := labels.SelectorFromSet(set)
+	dgsInstances, err := c.dgsLister.DedicatedGameServers(dgsCol.Namespace).List(selector)
+
+	if err != nil {
+		return err
+	}
+
+	dgsUpdated, dgsOld := splitDGSByTemplate(dgsCol, dgsInstances)
+	dgsCol.Status.UpdatedReplicas = int32(len(dgsUpdated))
+	dgsCol.Status.OldReplicas = int32(len(dgsOld))
+
+	return nil
+}
+
+func (c *Controller) setSelectorStatus(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) {
+	set := labels.Set{
+		shared.LabelDedicatedGameServerCollectionName: dgsCol.Name,
+	}
+	dgsCol.Status.Selector = labels.SelectorFromSet(set).String()
+}
+
 func (c *Controller) addDGSColReplicas(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgsExistingCount int) error {
 	//create them
 	increaseCount := int(dgsCol.Spec.Replicas) - dgsExistingCount
 	c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "IncreaseCount": increaseCount}).Printf("Scaling out")
 
 	for i := 0; i < increaseCount; i++ {
-		dgs := shared.NewDedicatedGameServer(dgsCol, dgsCol.Spec.Template)
-		// if we want to expose ports for this DGS
-		if dgsCol.Spec.PortsToExpose != nil {
-			// for each container on the pod
-			for k := 0; k < len(dgs.Spec.Template.Containers); k++ {
-				// assign random port for each port request
-				for j := 0; j < len(dgs.Spec.Template.Containers[k].Ports); j++ {
-					// if we want to expose this specific ContainerPort
-					if shared.SliceContains(dgsCol.Spec.PortsToExpose, dgs.Spec.Template.Containers[k].Ports[j].ContainerPort) {
-						hostport, errPort := c.portRegistry.GetNewPort() //get a random port
-						if errPort != nil {
-							return errPort
-						}
-						dgs.Spec.Template.Containers[k].Ports[j].HostPort = hostport
-					}
-				}
-			}
-		}
-
-		_, err := c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgsCol.Namespace).Create(dgs)
+		err := c.createDGSForDGSCol(dgsCol)
 		if err != nil {
 			return err
 		}
@@ -130,39 +143,90 @@ func (c *Controller) addDGSColReplicas(dgsCol *dgsv1alpha1.DedicatedGameServerCo
 	return nil
 }
 
+// createDGSForDGSCol creates a new DGS with the current template of the DGSCol
+func (c *Controller) createDGSForDGSCol(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
+	dgs := shared.NewDedicatedGameServer(dgsCol, dgsCol.Spec.Template)
+	key, err := cache.MetaNamespaceKeyFunc(dgs)
+	if err != nil {
+		return err
+	}
+
+	allocatedPorts, err := c.assignHostPorts(dgsCol, dgs, key)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgsCol.Namespace).Create(dgs)
+	if err != nil && allocatedPorts {
+		// the DGS was not created, so nobody will use its ports
+		c.portRegistry.Release(key)
+	}
+	return err
+}
+
 func (c *Controller) removeDGSColReplicas(dgsColTemp *dgsv1alpha1.DedicatedGameServerCollection, dgsExisting []*dgsv1alpha1.DedicatedGameServer) error {
 	dgsExistingCount := len(dgsExisting)
 	// we need to decrease our DGS for this collection
 	// to accomplish this, we'll first find the number of DGS we need to decrease
 	decreaseCount := dgsExistingCount - int(dgsColTemp.Spec.Replicas)
-	// we'll remove random instances of DGS from our DGSCol
-	indexesToDecrease := shared.GetRandomIndexes(dgsExistingCount, decreaseCount)
-
-	c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "DecreaseCount": decreaseCount}).Printf("Scaling in")
+	// we'll select the DGSs to remove according to the DGSCol ScaleInPolicy
+	dgsToRemove, err := c.selectDGSsForScaleIn(dgsColTemp, dgsExisting, decreaseCount)
+	if err != nil {
+		return err
+	}
 
-	for i := 0; i < len(indexesToDecrease); i++ {
-		dgsToMarkForDeletionTemp, err := c.dgsLister.DedicatedGameServers(dgsColTemp.Namespace).Get(dgsExisting[indexesToDecrease[i]].Name)
+	c.logger.WithFields(logrus.Fields{"DGSColName": dgsColTemp.Name, "DecreaseCount": decreaseCount, "ScaleInPolicy": dgsColTemp.Spec.ScaleInPolicy}).Printf("Scaling in")
 
+	for _, dgs := range dgsToRemove {
+		err := c.markDGSForDeletion(dgsColTemp, dgs)
 		if err != nil {
 			return err
 		}
-		dgsToMarkForDeletionToUpdate := dgsToMarkForDeletionTemp.DeepCopy()
-		// update the DGS so it has no owners
-		dgsToMarkForDeletionToUpdate.ObjectMeta.OwnerReferences = nil
-		//remove the DGSCol name from the DGS labels
-		delete(dgsToMarkForDeletionToUpdate.ObjectMeta.Labels, shared.LabelDedicatedGameServerCollectionName)
-		//set its state as marked for deletion
-		dgsToMarkForDeletionToUpdate.Status.MarkedForDeletion = true
-		//set its previous Collection owner
-		dgsToMarkForDeletionToUpdate.ObjectMeta.Labels[shared.LabelOriginalDedicatedGameServerCollectionName] = dgsColTemp.Name
-		//update the DGS CRD
-		_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgsColTemp.Namespace).Update(dgsToMarkForDeletionToUpdate)
+	}
+
+	return nil
+}
+
+// markDGSForDeletion sets the DGS as MarkedForDeletion and then removes it from the DGSCol
+// The DGS will be deleted by the DGS controller when it has zero ActivePlayers
+// The status is written first so that a DGS that has left the DGSCol is always marked for deletion
+func (c *Controller) markDGSForDeletion(dgsColTemp *dgsv1alpha1.DedicatedGameServerCollection, dgs *dgsv1alpha1.DedicatedGameServer) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		dgsToMarkForDeletion, err := c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgsColTemp.Namespace).Get(dgs.Name, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
+		if dgsToMarkForDeletion.Status.MarkedForDeletion {
+			return nil
+		}
+		//set its state as marked for deletion
+		dgsToMarkForDeletion.Status.MarkedForDeletion = true
+		//update the DGS status
+		_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgsColTemp.Namespace).UpdateStatus(dgsToMarkForDeletion)
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		dgsToMarkForDeletion, err := c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgsColTemp.Namespace).Get(dgs.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		// update the DGS so it has no owners
+		dgsToMarkForDeletion.ObjectMeta.OwnerReferences = nil
+		if dgsToMarkForDeletion.ObjectMeta.Labels == nil {
+			dgsToMarkForDeletion.ObjectMeta.Labels = make(map[string]string)
+		}
+		//remove the DGSCol name from the DGS labels
+		delete(dgsToMarkForDeletion.ObjectMeta.Labels, shared.LabelDedicatedGameServerCollectionName)
+		//set its previous Collection owner
+		dgsToMarkForDeletion.ObjectMeta.Labels[shared.LabelOriginalDedicatedGameServerCollectionName] = dgsColTemp.Name
+		//update the DGS CRD
+		_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgsColTemp.Namespace).Update(dgsToMarkForDeletion)
+		return err
+	})
 }
 
 func (c *Controller) increaseTimesFailed(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, count int) {
@@ -173,7 +237,7 @@ func (c *Controller) increaseTimesFailed(dgsCol *dgsv1alpha1.DedicatedGameServer
 		}
 		dgsColToUpdate.Status.DGSTimesFailed += int32(count)
 
-		_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Update(dgsColToUpdate)
+		_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).UpdateStatus(dgsColToUpdate)
 		if err == nil {
 			c.logger.WithFields(logrus.Fields{"DedicatedGameServerCollection": dgsCol.Name}).Infof("Increased DGSTimesFailed field of the DGSCol with value %d, new value is %d", count, dgsColToUpdate.Status.DGSTimesFailed)
 		}
@@ -231,7 +295,7 @@ func (c *Controller) setDGSColToNeedsIntervention(dgsCol *dgsv1alpha1.DedicatedG
 			return err
 		}
 		dgsColToUpdate.Status.DGSCollectionHealth = dgsv1alpha1.DGSColNeedsIntervention
-		_, err = c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Update(dgsColToUpdate)
+		_, err = c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).UpdateStatus(dgsColToUpdate)
 
 		if err != nil {
 			return err
@@ -265,7 +329,16 @@ func (c *Controller) reconcileStatuses(dgsCol *dgsv1alpha1.DedicatedGameServerCo
 			return err
 		}
 
-		_, err = c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).Update(dgsColToUpdate)
+		//assign DGSCol.Status.UpdatedReplicas and DGSCol.Status.OldReplicas
+		err = c.setUpdatedReplicasStatus(dgsColToUpdate)
+		if err != nil {
+			return err
+		}
+
+		//assign DGSCol.Status.Selector, used by the scale subresource
+		c.setSelectorStatus(dgsColToUpdate)
+
+		_, err = c.dgsColClient.AzuregamingV1alpha1().DedicatedGameServerCollections(dgsCol.Namespace).UpdateStatus(dgsColToUpdate)
 
 		if err != nil {
 			return err
@@ -278,6 +351,7 @@ func (c *Controller) reconcileStatuses(dgsCol *dgsv1alpha1.DedicatedGameServerCo
 func (c *Controller) hasDGSStatusChanged(oldDGS, newDGS *dgsv1alpha1.DedicatedGameServer) bool {
 	if oldDGS.Status.Health != newDGS.Status.Health ||
 		oldDGS.Status.PodPhase != newDGS.Status.PodPhase ||
+		oldDGS.Status.DGSState != newDGS.Status.DGSState ||
 		len(oldDGS.GetOwnerReferences()) != len(newDGS.GetOwnerReferences()) {
 		return true
 	}
@@ -0,0 +1,126 @@
+package dgscollection
+
+import (
+	"fmt"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// assignHostPorts allocates the HostPorts of the exposed ports of the DGS according to the PortPolicy of the DGSCol
+// and records the allocation on the DGS. It returns true if any HostPorts were allocated
+func (c *Controller) assignHostPorts(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgs *dgsv1alpha1.DedicatedGameServer, key string) (bool, error) {
+	portsToAssign := shared.GetPortsToExpose(&dgs.Spec.Template, dgsCol.Spec.PortsToExpose)
+	if len(portsToAssign) == 0 {
+		return false, nil
+	}
+
+	err := shared.ValidatePortPolicy(dgsCol.Spec.PortPolicy, len(portsToAssign))
+	if err != nil {
+		return false, err
+	}
+
+	request := c.newPortAllocationRequest(dgsCol.Spec.PortPolicy, &dgs.Spec.Template, portsToAssign)
+	request.Owner = key
+	hostports, err := c.portRegistry.Allocate(request)
+	if err != nil {
+		return false, err
+	}
+	for i, port := range portsToAssign {
+		port.HostPort = hostports[i]
+	}
+
+	// record the allocation on the DGS, the finalizer releases it when the DGS Pod is gone
+	dgs.Spec.PortAllocation = &dgsv1alpha1.DGSPortAllocation{NodePool: request.NodePool, HostPorts: hostports}
+	dgs.ObjectMeta.Finalizers = append(dgs.ObjectMeta.Finalizers, shared.HostPortsFinalizer)
+
+	if dgsCol.Spec.PortPolicy != nil {
+		setExposedPortsProtocol(&dgs.Spec.Template, dgsCol.Spec.PortsToExpose, dgsCol.Spec.PortPolicy.Protocol)
+	}
+	return true, nil
+}
+
+// newPortAllocationRequest returns the PortAllocationRequest for the exposed ports of a Pod Template, according to the PortPolicy
+func (c *Controller) newPortAllocationRequest(policy *dgsv1alpha1.DGSPortPolicy, podSpec *corev1.PodSpec, ports []*corev1.ContainerPort) controllers.PortAllocationRequest {
+	request := controllers.PortAllocationRequest{
+		Count:    len(ports),
+		NodePool: c.portRegistry.NodePoolFor(podSpec),
+	}
+	if policy == nil {
+		return request
+	}
+
+	if policy.Mode == dgsv1alpha1.PassthroughPortPolicyMode {
+		for _, port := range ports {
+			request.Ports = append(request.Ports, port.ContainerPort)
+		}
+		return request
+	}
+	request.Min = policy.MinPort
+	request.Max = policy.MaxPort
+	request.BlockSize = int(policy.BlockSize)
+	return request
+}
+
+// validatePortPolicy checks that the PortPolicy of the DGSCol is valid and that its port range can satisfy the Replicas
+func (c *Controller) validatePortPolicy(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
+	template := dgsCol.Spec.Template.DeepCopy()
+	ports := shared.GetPortsToExpose(template, dgsCol.Spec.PortsToExpose)
+
+	err := shared.ValidatePortPolicy(dgsCol.Spec.PortPolicy, len(ports))
+	if err != nil {
+		return err
+	}
+	if len(ports) == 0 || c.portRegistry == nil {
+		return nil
+	}
+
+	maxReplicas, err := c.portRegistry.MaxAllocations(c.newPortAllocationRequest(dgsCol.Spec.PortPolicy, template, ports))
+	if err != nil {
+		return err
+	}
+	if int(dgsCol.Spec.Replicas) > maxReplicas {
+		return fmt.Errorf("the port policy can satisfy at most %d replicas, %d were requested", maxReplicas, dgsCol.Spec.Replicas)
+	}
+	return nil
+}
+
+// setExposedPortsProtocol sets the protocol of the exposed ports of the Pod Template
+// TCPAndUDP exposes every port over TCP and adds the same port, with the same HostPort, over UDP
+func setExposedPortsProtocol(podSpec *corev1.PodSpec, portsToExpose []intstr.IntOrString, protocol dgsv1alpha1.DGSPortProtocol) {
+	if protocol == "" {
+		return
+	}
+	for k := range podSpec.Containers {
+		var ports []corev1.ContainerPort
+		for _, port := range podSpec.Containers[k].Ports {
+			if !shared.IsPortExposed(&podSpec.Containers[k], port.ContainerPort, portsToExpose) {
+				ports = append(ports, port)
+				continue
+			}
+			switch protocol {
+			case dgsv1alpha1.TCPPortProtocol:
+				port.Protocol = corev1.ProtocolTCP
+				ports = append(ports, port)
+			case dgsv1alpha1.UDPPortProtocol:
+				port.Protocol = corev1.ProtocolUDP
+				ports = append(ports, port)
+			case dgsv1alpha1.TCPAndUDPPortProtocol:
+				port.Protocol = corev1.ProtocolTCP
+				udpPort := port
+				udpPort.Protocol = corev1.ProtocolUDP
+				// port names must be unique in the Pod and up to 15 characters long
+				udpPort.Name = ""
+				if port.Name != "" && len(port.Name) <= 11 {
+					udpPort.Name = port.Name + "-udp"
+				}
+				ports = append(ports, port, udpPort)
+			}
+		}
+		podSpec.Containers[k].Ports = ports
+	}
+}
@@ -0,0 +1,103 @@
+package dgscollection
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newPortPolicyDGSCol(t *testing.T, f *dgsColFixture, replicas int32, policy *dgsv1alpha1.DGSPortPolicy, ports ...corev1.ContainerPort) *dgsv1alpha1.DedicatedGameServerCollection {
+	portRegistry, err := controllers.NewPortRegistry(fake.NewSimpleClientset(), 20000, 20010, metav1.NamespaceAll, controllers.PortRegistryOptions{})
+	assert.NoError(t, err)
+	f.portRegistry = portRegistry
+
+	podSpec := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:  "test",
+				Ports: ports,
+			},
+		},
+	}
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, replicas, podSpec)
+	for _, port := range ports {
+		dgsCol.Spec.PortsToExpose = append(dgsCol.Spec.PortsToExpose, intstr.FromInt(int(port.ContainerPort)))
+	}
+	dgsCol.Spec.PortPolicy = policy
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+	return dgsCol
+}
+
+func TestPassthroughPortPolicyOverTCPAndUDP(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := newPortPolicyDGSCol(t, f, 1, &dgsv1alpha1.DGSPortPolicy{
+		Mode:     dgsv1alpha1.PassthroughPortPolicyMode,
+		Protocol: dgsv1alpha1.TCPAndUDPPortProtocol,
+	}, corev1.ContainerPort{Name: "game", ContainerPort: 7777})
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+	f.expectCreateDedicatedGameServerAction(shared.NewDedicatedGameServer(dgsCol, dgsCol.Spec.Template), func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		assert.Equal(t, []corev1.ContainerPort{
+			{Name: "game", ContainerPort: 7777, HostPort: 7777, Protocol: corev1.ProtocolTCP},
+			{Name: "game-udp", ContainerPort: 7777, HostPort: 7777, Protocol: corev1.ProtocolUDP},
+		}, dgs.Spec.Template.Containers[0].Ports)
+		assert.Equal(t, []int32{7777}, dgs.Spec.PortAllocation.HostPorts)
+	})
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestBlockPortPolicy(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := newPortPolicyDGSCol(t, f, 1, &dgsv1alpha1.DGSPortPolicy{
+		MinPort:   7000,
+		MaxPort:   7009,
+		BlockSize: 2,
+		Protocol:  dgsv1alpha1.UDPPortProtocol,
+	}, corev1.ContainerPort{Name: "game", ContainerPort: 7777}, corev1.ContainerPort{Name: "query", ContainerPort: 7778})
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+	f.expectCreateDedicatedGameServerAction(shared.NewDedicatedGameServer(dgsCol, dgsCol.Spec.Template), func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		ports := dgs.Spec.Template.Containers[0].Ports
+		if assert.Len(t, ports, 2) {
+			assert.True(t, ports[0].HostPort >= 7000 && ports[0].HostPort < 7009)
+			assert.Equal(t, ports[0].HostPort+1, ports[1].HostPort)
+			assert.Equal(t, corev1.ProtocolUDP, ports[0].Protocol)
+			assert.Equal(t, corev1.ProtocolUDP, ports[1].Protocol)
+		}
+	})
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestPortPolicyThatCannotSatisfyReplicas(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	// a passthrough HostPort can be used once in the Cluster scope
+	dgsCol := newPortPolicyDGSCol(t, f, 2, &dgsv1alpha1.DGSPortPolicy{
+		Mode: dgsv1alpha1.PassthroughPortPolicyMode,
+	}, corev1.ContainerPort{ContainerPort: 7777})
+
+	// no DGSs are created
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Len(t, f.portRegistry.Snapshot().Owners, 0)
+}
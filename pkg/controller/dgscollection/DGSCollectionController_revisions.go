@@ -0,0 +1,85 @@
+package dgscollection
+
+import (
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	logrus "github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reconcileRevisions makes sure that the current Template of the DGSCol is stored as the latest ControllerRevision
+// and that at most RevisionHistoryLimit old ControllerRevisions are kept
+func (c *Controller) reconcileRevisions(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
+	controllerRevisions, err := c.controllerRevisionLister.ControllerRevisions(dgsCol.Namespace).List(shared.GetControllerRevisionSelector(dgsCol.Name))
+	if err != nil {
+		return err
+	}
+	shared.SortControllerRevisions(controllerRevisions)
+
+	templateHash := shared.GetTemplateHash(dgsCol.Spec.Template)
+	var current *appsv1.ControllerRevision
+	var maxRevision int64
+	for _, controllerRevision := range controllerRevisions {
+		if controllerRevision.Labels[shared.LabelDedicatedGameServerTemplateHash] == templateHash {
+			current = controllerRevision
+		}
+		if controllerRevision.Revision > maxRevision {
+			maxRevision = controllerRevision.Revision
+		}
+	}
+
+	if current == nil {
+		// Template is new, so we store it as the latest revision
+		controllerRevision, err := shared.NewControllerRevision(dgsCol, maxRevision+1)
+		if err != nil {
+			return err
+		}
+		current, err = c.client.AppsV1().ControllerRevisions(dgsCol.Namespace).Create(controllerRevision)
+		if err != nil {
+			return err
+		}
+		controllerRevisions = append(controllerRevisions, current)
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "Revision": current.Revision}).Info("Created ControllerRevision for DGSCol Template")
+	} else if current.Revision < maxRevision {
+		// DGSCol was rolled back to an old Template, so this becomes the latest revision
+		controllerRevisionToUpdate := current.DeepCopy()
+		controllerRevisionToUpdate.Revision = maxRevision + 1
+		_, err := c.client.AppsV1().ControllerRevisions(dgsCol.Namespace).Update(controllerRevisionToUpdate)
+		if err != nil {
+			return err
+		}
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "Revision": controllerRevisionToUpdate.Revision}).Info("Updated ControllerRevision for DGSCol Template")
+	}
+
+	return c.truncateRevisionHistory(dgsCol, controllerRevisions, current)
+}
+
+// truncateRevisionHistory deletes the oldest ControllerRevisions of the DGSCol so that at most RevisionHistoryLimit
+// of them are kept, apart from the current one
+func (c *Controller) truncateRevisionHistory(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, controllerRevisions []*appsv1.ControllerRevision, current *appsv1.ControllerRevision) error {
+	historyLimit := shared.DefaultRevisionHistoryLimit
+	if dgsCol.Spec.RevisionHistoryLimit != nil {
+		historyLimit = *dgsCol.Spec.RevisionHistoryLimit
+	}
+
+	// controllerRevisions are sorted by ascending revision number, so the oldest are at the start
+	oldRevisions := make([]*appsv1.ControllerRevision, 0)
+	for _, controllerRevision := range controllerRevisions {
+		if controllerRevision.Name != current.Name {
+			oldRevisions = append(oldRevisions, controllerRevision)
+		}
+	}
+
+	for i := 0; i < len(oldRevisions)-int(historyLimit); i++ {
+		err := c.client.AppsV1().ControllerRevisions(dgsCol.Namespace).Delete(oldRevisions[i].Name, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "Revision": oldRevisions[i].Revision}).Info("Deleted old ControllerRevision for DGSCol Template")
+	}
+	return nil
+}
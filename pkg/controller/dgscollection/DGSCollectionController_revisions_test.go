@@ -0,0 +1,138 @@
+package dgscollection
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// newTemplate returns a PodSpec that differs from testhelpers.PodSpec by its image
+func newTemplate(image string) corev1.PodSpec {
+	template := testhelpers.PodSpec.DeepCopy()
+	template.Containers[0].Image = image
+	return *template
+}
+
+// newControllerRevision returns a ControllerRevision of the DGSCol for the specified Template
+func newControllerRevision(t *testing.T, dgsCol *dgsv1alpha1.DedicatedGameServerCollection, template corev1.PodSpec, revision int64) *appsv1.ControllerRevision {
+	dgsColWithTemplate := dgsCol.DeepCopy()
+	dgsColWithTemplate.Spec.Template = template
+	controllerRevision, err := shared.NewControllerRevision(dgsColWithTemplate, revision)
+	if err != nil {
+		t.Fatalf("Error creating ControllerRevision: %s", err.Error())
+	}
+	return controllerRevision
+}
+
+func (f *dgsColFixture) addControllerRevision(controllerRevision *appsv1.ControllerRevision) {
+	f.controllerRevisionLister = append(f.controllerRevisionLister, controllerRevision)
+	f.k8sObjects = append(f.k8sObjects, controllerRevision)
+}
+
+func (f *dgsColFixture) listControllerRevisions(namespace string) map[string]int64 {
+	list, err := f.k8sClient.AppsV1().ControllerRevisions(namespace).List(metav1.ListOptions{})
+	assert.NoError(f.t, err)
+	revisions := make(map[string]int64)
+	for _, controllerRevision := range list.Items {
+		revisions[controllerRevision.Name] = controllerRevision.Revision
+	}
+	return revisions
+}
+
+func TestCreatesFirstControllerRevision(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, newTemplate("game:1"))
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	expDGS := shared.NewDedicatedGameServer(dgsCol, dgsCol.Spec.Template)
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+	f.expectCreateDedicatedGameServerAction(expDGS, nil)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	name := shared.GetControllerRevisionName(dgsCol.Name, shared.GetTemplateHash(dgsCol.Spec.Template))
+	assert.Equal(t, map[string]int64{name: 1}, f.listControllerRevisions(dgsCol.Namespace))
+
+	controllerRevision, err := f.k8sClient.AppsV1().ControllerRevisions(dgsCol.Namespace).Get(name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	template, err := shared.GetControllerRevisionTemplate(controllerRevision)
+	assert.NoError(t, err)
+	assert.Equal(t, "game:1", template.Containers[0].Image)
+	assert.Equal(t, dgsCol.Name, controllerRevision.Labels[shared.LabelDedicatedGameServerCollectionName])
+}
+
+func TestCreatesControllerRevisionOnTemplateUpdate(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 0, newTemplate("game:2"))
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	oldRevision := newControllerRevision(t, dgsCol, newTemplate("game:1"), 1)
+	f.addControllerRevision(oldRevision)
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	name := shared.GetControllerRevisionName(dgsCol.Name, shared.GetTemplateHash(dgsCol.Spec.Template))
+	assert.Equal(t, map[string]int64{oldRevision.Name: 1, name: 2}, f.listControllerRevisions(dgsCol.Namespace))
+}
+
+func TestRollbackBumpsControllerRevision(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	// DGSCol was rolled back to the Template of revision 1
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 0, newTemplate("game:1"))
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	firstRevision := newControllerRevision(t, dgsCol, newTemplate("game:1"), 1)
+	secondRevision := newControllerRevision(t, dgsCol, newTemplate("game:2"), 2)
+	f.addControllerRevision(firstRevision)
+	f.addControllerRevision(secondRevision)
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	assert.Equal(t, map[string]int64{firstRevision.Name: 3, secondRevision.Name: 2}, f.listControllerRevisions(dgsCol.Namespace))
+}
+
+func TestTruncatesRevisionHistory(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 0, newTemplate("game:4"))
+	historyLimit := int32(1)
+	dgsCol.Spec.RevisionHistoryLimit = &historyLimit
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	for i, image := range []string{"game:1", "game:2", "game:3"} {
+		f.addControllerRevision(newControllerRevision(t, dgsCol, newTemplate(image), int64(i+1)))
+	}
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+
+	// only the current and the latest old revision are kept
+	assert.Equal(t, map[string]int64{
+		shared.GetControllerRevisionName(dgsCol.Name, shared.GetTemplateHash(newTemplate("game:3"))): 3,
+		shared.GetControllerRevisionName(dgsCol.Name, shared.GetTemplateHash(newTemplate("game:4"))): 4,
+	}, f.listControllerRevisions(dgsCol.Namespace))
+}
@@ -0,0 +1,218 @@
+package dgscollection
+
+import (
+	"fmt"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	logrus "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+)
+
+var (
+	defaultMaxSurge       = intstr.FromString("25%")
+	defaultMaxUnavailable = intstr.FromString("25%")
+)
+
+// rolloutDGSCol replaces the DGSs that run an old template with new ones, according to the DGSCol update strategy
+// Only the old DGSs that are not in a match (i.e. Idle or PostMatch) are replaced, the rest are left to finish their match
+// and will be replaced in a subsequent loop
+func (c *Controller) rolloutDGSCol(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgsUpdated, dgsOld []*dgsv1alpha1.DedicatedGameServer) error {
+	replicas := int(dgsCol.Spec.Replicas)
+	dgsOldReplaceable := getReplaceableDGSs(dgsOld)
+
+	var createCount int
+	dgsToRemove := make([]*dgsv1alpha1.DedicatedGameServer, 0)
+
+	if dgsCol.Spec.UpdateStrategy.Type == dgsv1alpha1.RecreateDGSColStrategyType {
+		dgsToRemove = dgsOldReplaceable
+		// old DGSs that are still in a match count towards the requested replicas
+		createCount = replicas - len(dgsUpdated) - (len(dgsOld) - len(dgsOldReplaceable))
+	} else {
+		maxSurge, maxUnavailable, err := getRollingUpdateParameters(dgsCol)
+		if err != nil {
+			return err
+		}
+
+		// we can create new DGSs up to Replicas + MaxSurge in total
+		createCount = minInt(replicas-len(dgsUpdated), replicas+maxSurge-len(dgsUpdated)-len(dgsOld))
+
+		// we can remove as many available DGSs as we have above the minimum available ones
+		canRemoveCount := countAvailableDGSs(dgsUpdated) + countAvailableDGSs(dgsOld) - (replicas - maxUnavailable)
+		for _, dgs := range dgsOldReplaceable {
+			if !isDGSAvailable(dgs) {
+				// removing an unavailable DGS does not affect availability
+				dgsToRemove = append(dgsToRemove, dgs)
+			} else if canRemoveCount > 0 {
+				dgsToRemove = append(dgsToRemove, dgs)
+				canRemoveCount--
+			}
+		}
+	}
+
+	c.logger.WithFields(logrus.Fields{
+		"DGSColName":     dgsCol.Name,
+		"UpdateStrategy": dgsCol.Spec.UpdateStrategy.Type,
+		"UpdatedCount":   len(dgsUpdated),
+		"OldCount":       len(dgsOld),
+		"CreateCount":    createCount,
+		"RemoveCount":    len(dgsToRemove),
+	}).Info("Replacing DedicatedGameServers that run an old template")
+
+	for i := 0; i < createCount; i++ {
+		err := c.createDGSForDGSCol(dgsCol)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, dgs := range dgsToRemove {
+		err := c.markDGSForDeletion(dgsCol, dgs)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(dgsToRemove) > 0 {
+		c.recorder.Event(dgsCol, corev1.EventTypeNormal, shared.DedicatedGameServerCollectionTemplateUpdated, fmt.Sprintf(shared.MessageOldTemplateDedicatedGameServersReplaced, "DedicatedGameServerCollection", dgsCol.Name, len(dgsToRemove)))
+	}
+
+	// Replicas may have been decreased during the update
+	if len(dgsUpdated) > replicas {
+		return c.removeDGSColReplicas(dgsCol, dgsUpdated)
+	}
+
+	return nil
+}
+
+// getRollingUpdateParameters returns the absolute MaxSurge and MaxUnavailable values for the DGSCol
+func getRollingUpdateParameters(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) (int, int, error) {
+	maxSurgeValue := defaultMaxSurge
+	maxUnavailableValue := defaultMaxUnavailable
+	if rollingUpdate := dgsCol.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil {
+		if rollingUpdate.MaxSurge != nil {
+			maxSurgeValue = *rollingUpdate.MaxSurge
+		}
+		if rollingUpdate.MaxUnavailable != nil {
+			maxUnavailableValue = *rollingUpdate.MaxUnavailable
+		}
+	}
+
+	maxSurge, err := intstr.GetValueFromIntOrPercent(&maxSurgeValue, int(dgsCol.Spec.Replicas), true)
+	if err != nil {
+		return 0, 0, err
+	}
+	maxUnavailable, err := intstr.GetValueFromIntOrPercent(&maxUnavailableValue, int(dgsCol.Spec.Replicas), false)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// if both are zero, the update would never make progress
+	if maxSurge == 0 && maxUnavailable == 0 {
+		maxUnavailable = 1
+	}
+
+	return maxSurge, maxUnavailable, nil
+}
+
+// splitDGSByTemplate returns the DGSs that run the current template of the DGSCol and the ones that run an old one
+func splitDGSByTemplate(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgss []*dgsv1alpha1.DedicatedGameServer) ([]*dgsv1alpha1.DedicatedGameServer, []*dgsv1alpha1.DedicatedGameServer) {
+	templateHash := shared.GetTemplateHash(dgsCol.Spec.Template)
+
+	dgsUpdated := make([]*dgsv1alpha1.DedicatedGameServer, 0)
+	dgsOld := make([]*dgsv1alpha1.DedicatedGameServer, 0)
+	for _, dgs := range dgss {
+		if isDGSTemplateUpdated(dgsCol, dgs, templateHash) {
+			dgsUpdated = append(dgsUpdated, dgs)
+		} else {
+			dgsOld = append(dgsOld, dgs)
+		}
+	}
+	return dgsUpdated, dgsOld
+}
+
+// isDGSTemplateUpdated returns true if the DGS runs the template of the DGSCol with the given hash
+// DGSs without the template hash label were created before template hashes existed, so their template is compared instead
+func isDGSTemplateUpdated(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgs *dgsv1alpha1.DedicatedGameServer, templateHash string) bool {
+	if dgsTemplateHash, ok := dgs.Labels[shared.LabelDedicatedGameServerTemplateHash]; ok {
+		return dgsTemplateHash == templateHash
+	}
+	return getTemplateHashWithoutHostPorts(dgs.Spec.Template, dgsCol.Spec.PortsToExpose) ==
+		getTemplateHashWithoutHostPorts(dgsCol.Spec.Template, dgsCol.Spec.PortsToExpose)
+}
+
+// getTemplateHashWithoutHostPorts returns the hash of the template without the HostPorts of the exposed ports,
+// since these are assigned to each DGS when it is created
+func getTemplateHashWithoutHostPorts(template corev1.PodSpec, portsToExpose []intstr.IntOrString) string {
+	templateCopy := template.DeepCopy()
+	for _, port := range shared.GetPortsToExpose(templateCopy, portsToExpose) {
+		port.HostPort = 0
+	}
+	return shared.GetTemplateHash(*templateCopy)
+}
+
+// setMissingTemplateHashes labels the DGSs that run the current template of the DGSCol but were created before template hashes existed
+func (c *Controller) setMissingTemplateHashes(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgss []*dgsv1alpha1.DedicatedGameServer) error {
+	templateHash := shared.GetTemplateHash(dgsCol.Spec.Template)
+	for _, dgs := range dgss {
+		if _, ok := dgs.Labels[shared.LabelDedicatedGameServerTemplateHash]; ok || !isDGSTemplateUpdated(dgsCol, dgs, templateHash) {
+			continue
+		}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			dgsToUpdate, err := c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgs.Namespace).Get(dgs.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if dgsToUpdate.Labels == nil {
+				dgsToUpdate.Labels = make(map[string]string)
+			}
+			dgsToUpdate.Labels[shared.LabelDedicatedGameServerTemplateHash] = templateHash
+			_, err = c.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(dgs.Namespace).Update(dgsToUpdate)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		c.logger.WithFields(logrus.Fields{"DGSColName": dgsCol.Name, "DGSName": dgs.Name}).Info("Set the missing template hash label of the DedicatedGameServer")
+	}
+	return nil
+}
+
+// getReplaceableDGSs returns the DGSs that are not in a match, so they can be replaced
+func getReplaceableDGSs(dgss []*dgsv1alpha1.DedicatedGameServer) []*dgsv1alpha1.DedicatedGameServer {
+	dgsToReturn := make([]*dgsv1alpha1.DedicatedGameServer, 0)
+	for _, dgs := range dgss {
+		if dgs.Status.DGSState == "" ||
+			dgs.Status.DGSState == dgsv1alpha1.DGSIdle ||
+			dgs.Status.DGSState == dgsv1alpha1.DGSPostMatch {
+			dgsToReturn = append(dgsToReturn, dgs)
+		}
+	}
+	return dgsToReturn
+}
+
+func isDGSAvailable(dgs *dgsv1alpha1.DedicatedGameServer) bool {
+	return dgs.Status.Health == dgsv1alpha1.DGSHealthy && dgs.Status.PodPhase == corev1.PodRunning
+}
+
+func countAvailableDGSs(dgss []*dgsv1alpha1.DedicatedGameServer) int {
+	count := 0
+	for _, dgs := range dgss {
+		if isDGSAvailable(dgs) {
+			count++
+		}
+	}
+	return count
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,200 @@
+package dgscollection
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// newOldTemplateDGS returns an available DGS of the DGSCol that runs a previous template
+func newOldTemplateDGS(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, state dgsv1alpha1.DGSState) *dgsv1alpha1.DedicatedGameServer {
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	dgs.Labels[shared.LabelDedicatedGameServerTemplateHash] = "oldhash"
+	dgs.Status.Health = dgsv1alpha1.DGSHealthy
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.DGSState = state
+	return dgs
+}
+
+func TestRollingUpdateDedicatedGameServerCollection(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 4, testhelpers.PodSpec)
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	for i := 0; i < 4; i++ {
+		dgs := newOldTemplateDGS(dgsCol, dgsv1alpha1.DGSIdle)
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+	}
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, int32(0), dgsCol.Status.UpdatedReplicas)
+		assert.Equal(t, int32(4), dgsCol.Status.OldReplicas)
+	})
+
+	// default maxSurge is 25%, so one new DGS is created
+	expDGS := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	f.expectCreateDedicatedGameServerAction(expDGS, func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		assert.Equal(t, shared.GetTemplateHash(dgsCol.Spec.Template), dgs.Labels[shared.LabelDedicatedGameServerTemplateHash])
+	})
+
+	// default maxUnavailable is 25%, so one old DGS is removed
+	f.expectUpdateDedicatedGameServerStatusAction(expDGS, func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		assert.Equal(t, true, dgs.Status.MarkedForDeletion)
+		assert.Equal(t, "oldhash", dgs.Labels[shared.LabelDedicatedGameServerTemplateHash])
+	})
+	f.expectUpdateDedicatedGameServerAction(expDGS, nil)
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestRollingUpdateDoesNotReplaceDGSInMatch(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 4, testhelpers.PodSpec)
+	maxSurge := intstr.FromInt(0)
+	maxUnavailable := intstr.FromInt(4)
+	dgsCol.Spec.UpdateStrategy = dgsv1alpha1.DedicatedGameServerCollectionUpdateStrategy{
+		Type: dgsv1alpha1.RollingUpdateDGSColStrategyType,
+		RollingUpdate: &dgsv1alpha1.RollingUpdateDedicatedGameServerCollection{
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	for _, state := range []dgsv1alpha1.DGSState{dgsv1alpha1.DGSIdle, dgsv1alpha1.DGSAssigned, dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSPostMatch} {
+		dgs := newOldTemplateDGS(dgsCol, state)
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+	}
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+
+	// only the Idle and the PostMatch DGSs are replaced
+	expDGS := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	for i := 0; i < 2; i++ {
+		f.expectUpdateDedicatedGameServerStatusAction(expDGS, func(actual runtime.Object) {
+			dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+			assert.Equal(t, true, dgs.Status.MarkedForDeletion)
+			assert.NotEqual(t, dgsv1alpha1.DGSAssigned, dgs.Status.DGSState)
+			assert.NotEqual(t, dgsv1alpha1.DGSRunning, dgs.Status.DGSState)
+		})
+		f.expectUpdateDedicatedGameServerAction(expDGS, nil)
+	}
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestRecreateDedicatedGameServerCollection(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 3, testhelpers.PodSpec)
+	dgsCol.Spec.UpdateStrategy.Type = dgsv1alpha1.RecreateDGSColStrategyType
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	for _, state := range []dgsv1alpha1.DGSState{dgsv1alpha1.DGSRunning, dgsv1alpha1.DGSIdle, dgsv1alpha1.DGSIdle} {
+		dgs := newOldTemplateDGS(dgsCol, state)
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+	}
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, int32(3), dgsCol.Status.OldReplicas)
+	})
+
+	// the Running DGS counts towards the replicas until its match is over
+	expDGS := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	for i := 0; i < 2; i++ {
+		f.expectCreateDedicatedGameServerAction(expDGS, nil)
+	}
+	for i := 0; i < 2; i++ {
+		f.expectUpdateDedicatedGameServerStatusAction(expDGS, func(actual runtime.Object) {
+			dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+			assert.Equal(t, dgsv1alpha1.DGSIdle, dgs.Status.DGSState)
+		})
+		f.expectUpdateDedicatedGameServerAction(expDGS, nil)
+	}
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
+func TestSplitDGSByTemplateWithoutTemplateHash(t *testing.T) {
+	template := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:  "game",
+				Image: "game:1.0",
+				Ports: []corev1.ContainerPort{{Name: "game", ContainerPort: 7777}},
+			},
+		},
+	}
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 2, template)
+	dgsCol.Spec.PortsToExpose = []intstr.IntOrString{intstr.FromInt(7777)}
+
+	// DGSs that were created before template hashes existed, with a HostPort assigned to the exposed port
+	dgsCurrent := shared.NewDedicatedGameServer(dgsCol, template)
+	dgsCurrent.Spec.Template.Containers[0].Ports[0].HostPort = 20000
+	delete(dgsCurrent.Labels, shared.LabelDedicatedGameServerTemplateHash)
+
+	dgsPrevious := shared.NewDedicatedGameServer(dgsCol, template)
+	dgsPrevious.Spec.Template.Containers[0].Image = "game:0.9"
+	delete(dgsPrevious.Labels, shared.LabelDedicatedGameServerTemplateHash)
+
+	dgsUpdated, dgsOld := splitDGSByTemplate(dgsCol, []*dgsv1alpha1.DedicatedGameServer{dgsCurrent, dgsPrevious})
+	assert.Equal(t, []*dgsv1alpha1.DedicatedGameServer{dgsCurrent}, dgsUpdated)
+	assert.Equal(t, []*dgsv1alpha1.DedicatedGameServer{dgsPrevious}, dgsOld)
+}
+
+func TestMissingTemplateHashIsSet(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 2, testhelpers.PodSpec)
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	for i := 0; i < 2; i++ {
+		dgs := newOldTemplateDGS(dgsCol, dgsv1alpha1.DGSRunning)
+		delete(dgs.Labels, shared.LabelDedicatedGameServerTemplateHash)
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+	}
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, int32(2), dgsCol.Status.UpdatedReplicas)
+		assert.Equal(t, int32(0), dgsCol.Status.OldReplicas)
+	})
+
+	// the DGSs run the current template, so they are labeled instead of replaced
+	expDGS := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	for i := 0; i < 2; i++ {
+		f.expectUpdateDedicatedGameServerAction(expDGS, func(actual runtime.Object) {
+			dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+			assert.Equal(t, shared.GetTemplateHash(dgsCol.Spec.Template), dgs.Labels[shared.LabelDedicatedGameServerTemplateHash])
+			assert.Equal(t, false, dgs.Status.MarkedForDeletion)
+		})
+	}
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
@@ -0,0 +1,91 @@
+package dgscollection
+
+import (
+	"sort"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// selectDGSsForScaleIn returns count DGSs of the DGSCol that should be removed, according to the DGSCol ScaleInPolicy
+func (c *Controller) selectDGSsForScaleIn(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, dgss []*dgsv1alpha1.DedicatedGameServer, count int) ([]*dgsv1alpha1.DedicatedGameServer, error) {
+	if count > len(dgss) {
+		count = len(dgss)
+	}
+
+	if dgsCol.Spec.ScaleInPolicy == dgsv1alpha1.RandomScaleInPolicy {
+		dgsToRemove := make([]*dgsv1alpha1.DedicatedGameServer, 0, count)
+		for _, idx := range shared.GetRandomIndexes(len(dgss), count) {
+			dgsToRemove = append(dgsToRemove, dgss[idx])
+		}
+		return dgsToRemove, nil
+	}
+
+	dgssPerNode, err := c.getDGSCountPerNode()
+	if err != nil {
+		return nil, err
+	}
+	return rankDGSsForScaleIn(dgss, dgssPerNode)[:count], nil
+}
+
+// getDGSCountPerNode returns the number of DGSs (of all DGSCols) that run on each Node
+func (c *Controller) getDGSCountPerNode() (map[string]int, error) {
+	dgss, err := c.dgsLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	dgssPerNode := make(map[string]int)
+	for _, dgs := range dgss {
+		if dgs.Status.NodeName != "" {
+			dgssPerNode[dgs.Status.NodeName]++
+		}
+	}
+	return dgssPerNode, nil
+}
+
+// scaleInRank returns the rank of the DGS for removal, lower ranks are removed first
+func scaleInRank(dgs *dgsv1alpha1.DedicatedGameServer) int {
+	if dgs.Status.Health == dgsv1alpha1.DGSFailed {
+		return 0
+	}
+	if dgs.Status.DGSState == dgsv1alpha1.DGSIdle && dgs.Status.ActivePlayers == 0 {
+		return 1
+	}
+	return 2
+}
+
+// isDGSInMatch returns true if the DGS has been allocated to a match, so it may have players that have not connected yet
+func isDGSInMatch(dgs *dgsv1alpha1.DedicatedGameServer) bool {
+	return dgs.Status.DGSState == dgsv1alpha1.DGSAssigned || dgs.Status.DGSState == dgsv1alpha1.DGSRunning
+}
+
+// rankDGSsForScaleIn returns a copy of dgss, sorted in the order they should be removed:
+// Failed first, then Idle with zero ActivePlayers, then the ones with the fewest ActivePlayers.
+// Between DGSs with the same ActivePlayers, the ones that are not in a match go first.
+// Between equals, DGSs on the Node with the fewest DGSs (according to dgssPerNode) go first, so that Nodes can drain
+func rankDGSsForScaleIn(dgss []*dgsv1alpha1.DedicatedGameServer, dgssPerNode map[string]int) []*dgsv1alpha1.DedicatedGameServer {
+	ranked := make([]*dgsv1alpha1.DedicatedGameServer, len(dgss))
+	copy(ranked, dgss)
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if rankA, rankB := scaleInRank(a), scaleInRank(b); rankA != rankB {
+			return rankA < rankB
+		}
+		if a.Status.ActivePlayers != b.Status.ActivePlayers {
+			return a.Status.ActivePlayers < b.Status.ActivePlayers
+		}
+		if inMatchA, inMatchB := isDGSInMatch(a), isDGSInMatch(b); inMatchA != inMatchB {
+			return inMatchB
+		}
+		if nodeA, nodeB := dgssPerNode[a.Status.NodeName], dgssPerNode[b.Status.NodeName]; nodeA != nodeB {
+			return nodeA < nodeB
+		}
+		return a.Name < b.Name
+	})
+
+	return ranked
+}
@@ -0,0 +1,169 @@
+package dgscollection
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func newScaleInDGS(name string, health dgsv1alpha1.DGSHealth, state dgsv1alpha1.DGSState, activePlayers int, nodeName string) *dgsv1alpha1.DedicatedGameServer {
+	return &dgsv1alpha1.DedicatedGameServer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: shared.GameNamespace},
+		Status: dgsv1alpha1.DedicatedGameServerStatus{
+			Health:        health,
+			DGSState:      state,
+			ActivePlayers: activePlayers,
+			NodeName:      nodeName,
+		},
+	}
+}
+
+func TestRankDGSsForScaleIn(t *testing.T) {
+	dgssPerNode := map[string]int{"busynode": 5, "quietnode": 1}
+
+	tests := []struct {
+		name     string
+		dgss     []*dgsv1alpha1.DedicatedGameServer
+		expected []string
+	}{
+		{
+			name: "failed first",
+			dgss: []*dgsv1alpha1.DedicatedGameServer{
+				newScaleInDGS("idle", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle, 0, "busynode"),
+				newScaleInDGS("failed", dgsv1alpha1.DGSFailed, dgsv1alpha1.DGSRunning, 3, "busynode"),
+			},
+			expected: []string{"failed", "idle"},
+		},
+		{
+			name: "idle with zero players before other states with zero players",
+			dgss: []*dgsv1alpha1.DedicatedGameServer{
+				newScaleInDGS("assigned", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSAssigned, 0, "quietnode"),
+				newScaleInDGS("idle", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle, 0, "busynode"),
+			},
+			expected: []string{"idle", "assigned"},
+		},
+		{
+			name: "idle with players is ranked by players",
+			dgss: []*dgsv1alpha1.DedicatedGameServer{
+				newScaleInDGS("idlewithplayers", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle, 4, "busynode"),
+				newScaleInDGS("running", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 2, "busynode"),
+			},
+			expected: []string{"running", "idlewithplayers"},
+		},
+		{
+			name: "assigned and running after other states with the same players",
+			dgss: []*dgsv1alpha1.DedicatedGameServer{
+				newScaleInDGS("assigned", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSAssigned, 0, "quietnode"),
+				newScaleInDGS("running", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 0, "quietnode"),
+				newScaleInDGS("creating", dgsv1alpha1.DGSCreating, "", 0, "busynode"),
+			},
+			expected: []string{"creating", "assigned", "running"},
+		},
+		{
+			name: "fewest active players",
+			dgss: []*dgsv1alpha1.DedicatedGameServer{
+				newScaleInDGS("ten", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 10, "quietnode"),
+				newScaleInDGS("one", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 1, "busynode"),
+				newScaleInDGS("five", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 5, "busynode"),
+			},
+			expected: []string{"one", "five", "ten"},
+		},
+		{
+			name: "least packed node",
+			dgss: []*dgsv1alpha1.DedicatedGameServer{
+				newScaleInDGS("onbusynode", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 2, "busynode"),
+				newScaleInDGS("onquietnode", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 2, "quietnode"),
+				newScaleInDGS("notscheduled", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 2, ""),
+			},
+			expected: []string{"notscheduled", "onquietnode", "onbusynode"},
+		},
+		{
+			name: "name breaks ties",
+			dgss: []*dgsv1alpha1.DedicatedGameServer{
+				newScaleInDGS("b", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle, 0, "busynode"),
+				newScaleInDGS("a", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSIdle, 0, "busynode"),
+			},
+			expected: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := rankDGSsForScaleIn(tt.dgss, dgssPerNode)
+			names := make([]string, 0, len(ranked))
+			for _, dgs := range ranked {
+				names = append(names, dgs.Name)
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestRankDGSsForScaleInDoesNotModifyInput(t *testing.T) {
+	dgss := []*dgsv1alpha1.DedicatedGameServer{
+		newScaleInDGS("running", dgsv1alpha1.DGSHealthy, dgsv1alpha1.DGSRunning, 5, ""),
+		newScaleInDGS("failed", dgsv1alpha1.DGSFailed, dgsv1alpha1.DGSRunning, 0, ""),
+	}
+
+	rankDGSsForScaleIn(dgss, nil)
+
+	assert.Equal(t, "running", dgss[0].Name)
+	assert.Equal(t, "failed", dgss[1].Name)
+}
+
+func TestDecreaseReplicasRemovesRankedDedicatedGameServers(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 3, testhelpers.PodSpec)
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	// DGSs of another collection make node1 more packed than node2
+	otherDGSCol := shared.NewDedicatedGameServerCollection("other", shared.GameNamespace, 2, testhelpers.PodSpec)
+	for i := 0; i < 2; i++ {
+		otherDGS := shared.NewDedicatedGameServer(otherDGSCol, testhelpers.PodSpec)
+		otherDGS.Status.NodeName = "node1"
+		f.dgsLister = append(f.dgsLister, otherDGS)
+		f.dgsObjects = append(f.dgsObjects, otherDGS)
+	}
+
+	players := []int{5, 0, 0}
+	nodes := []string{"node2", "node1", "node2"}
+	dgss := make([]*dgsv1alpha1.DedicatedGameServer, 0, 3)
+	for i := 0; i < 3; i++ {
+		dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+		dgs.Status.Health = dgsv1alpha1.DGSHealthy
+		dgs.Status.DGSState = dgsv1alpha1.DGSRunning
+		dgs.Status.ActivePlayers = players[i]
+		dgs.Status.NodeName = nodes[i]
+		f.dgsLister = append(f.dgsLister, dgs)
+		f.dgsObjects = append(f.dgsObjects, dgs)
+		dgss = append(dgss, dgs)
+	}
+
+	//Update replicas
+	dgsCol.Spec.Replicas = 2
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+
+	// the DGS without players on the least packed node is removed
+	f.expectUpdateDedicatedGameServerStatusAction(dgss[2], func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		assert.Equal(t, dgss[2].Name, dgs.Name)
+		assert.Equal(t, true, dgs.Status.MarkedForDeletion)
+	})
+	f.expectUpdateDedicatedGameServerAction(dgss[2], func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		assert.Equal(t, dgss[2].Name, dgs.Name)
+		assert.Equal(t, 0, len(dgs.OwnerReferences))
+	})
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
@@ -1,11 +1,13 @@
 package dgscollection
 
 import (
+	"errors"
 	"testing"
 
 	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
 	dgsinformers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions"
+	controllers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 
@@ -13,10 +15,13 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubeinformers "k8s.io/client-go/informers"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
 	core "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/cache"
@@ -31,6 +36,8 @@ type dgsColFixture struct {
 	// Objects to put in the store.
 	dgsColLister []*dgsv1alpha1.DedicatedGameServerCollection
 	dgsLister    []*dgsv1alpha1.DedicatedGameServer
+
+	controllerRevisionLister []*appsv1.ControllerRevision
 	// Actions expected to happen on the client.
 	dgsActions []testhelpers.ExtendedAction
 	// Objects from here preloaded into NewSimpleFake.
@@ -38,6 +45,8 @@ type dgsColFixture struct {
 	dgsObjects []runtime.Object
 
 	clock clockwork.FakeClock
+
+	portRegistry *controllers.PortRegistry
 }
 
 func newDGSColFixture(t *testing.T) *dgsColFixture {
@@ -49,15 +58,17 @@ func newDGSColFixture(t *testing.T) *dgsColFixture {
 	return f
 }
 
-func (f *dgsColFixture) newDedicatedGameServerCollectionController() (*Controller, dgsinformers.SharedInformerFactory) {
+func (f *dgsColFixture) newDedicatedGameServerCollectionController() (*Controller, dgsinformers.SharedInformerFactory, kubeinformers.SharedInformerFactory) {
 	f.k8sClient = k8sfake.NewSimpleClientset(f.k8sObjects...)
 	f.dgsClient = fake.NewSimpleClientset(f.dgsObjects...)
 
+	k8sInformers := kubeinformers.NewSharedInformerFactory(f.k8sClient, testhelpers.NoResyncPeriodFunc())
 	dgsInformers := dgsinformers.NewSharedInformerFactory(f.dgsClient, testhelpers.NoResyncPeriodFunc())
 
 	testController, err := NewDedicatedGameServerCollectionController(f.k8sClient, f.dgsClient,
 		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServerCollections(),
-		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers(), nil)
+		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers(),
+		k8sInformers.Apps().V1().ControllerRevisions(), f.portRegistry)
 
 	if err != nil {
 		f.t.Fatalf("Error in initializing DGSCol: %s", err.Error())
@@ -65,6 +76,7 @@ func (f *dgsColFixture) newDedicatedGameServerCollectionController() (*Controlle
 
 	testController.dgsColListerSynced = testhelpers.AlwaysReady
 	testController.dgsListerSynced = testhelpers.AlwaysReady
+	testController.controllerRevisionListerSynced = testhelpers.AlwaysReady
 	testController.recorder = &record.FakeRecorder{}
 
 	for _, dgsCol := range f.dgsColLister {
@@ -75,7 +87,11 @@ func (f *dgsColFixture) newDedicatedGameServerCollectionController() (*Controlle
 		dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers().Informer().GetIndexer().Add(dgs)
 	}
 
-	return testController, dgsInformers
+	for _, controllerRevision := range f.controllerRevisionLister {
+		k8sInformers.Apps().V1().ControllerRevisions().Informer().GetIndexer().Add(controllerRevision)
+	}
+
+	return testController, dgsInformers, k8sInformers
 }
 
 func (f *dgsColFixture) run(dgsColName string) {
@@ -83,11 +99,12 @@ func (f *dgsColFixture) run(dgsColName string) {
 }
 
 func (f *dgsColFixture) runController(dgsColName string, startInformers bool, expectError bool) {
-	testController, dgsInformers := f.newDedicatedGameServerCollectionController()
+	testController, dgsInformers, k8sInformers := f.newDedicatedGameServerCollectionController()
 	if startInformers {
 		stopCh := make(chan struct{})
 		defer close(stopCh)
 		dgsInformers.Start(stopCh)
+		k8sInformers.Start(stopCh)
 	}
 
 	err := testController.syncHandler(dgsColName)
@@ -134,8 +151,14 @@ func (f *dgsColFixture) expectDeleteDedicatedGameServerAction(d *dgsv1alpha1.Ded
 	f.dgsActions = append(f.dgsActions, extAction)
 }
 
-func (f *dgsColFixture) expectUpdateDedicatedGameServerCollectionAction(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, assertions func(runtime.Object)) {
-	action := core.NewUpdateAction(schema.GroupVersionResource{Resource: "dedicatedgameservercollections"}, dgsCol.Namespace, dgsCol)
+func (f *dgsColFixture) expectUpdateDedicatedGameServerStatusAction(d *dgsv1alpha1.DedicatedGameServer, assertions func(runtime.Object)) {
+	action := core.NewUpdateSubresourceAction(schema.GroupVersionResource{Resource: "dedicatedgameservers"}, "status", d.Namespace, d)
+	extAction := testhelpers.ExtendedAction{Action: action, Assertions: assertions}
+	f.dgsActions = append(f.dgsActions, extAction)
+}
+
+func (f *dgsColFixture) expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, assertions func(runtime.Object)) {
+	action := core.NewUpdateSubresourceAction(schema.GroupVersionResource{Resource: "dedicatedgameservercollections"}, "status", dgsCol.Namespace, dgsCol)
 	extAction := testhelpers.ExtendedAction{Action: action, Assertions: assertions}
 	f.dgsActions = append(f.dgsActions, extAction)
 }
@@ -159,7 +182,10 @@ func TestCreatesDedicatedGameServerCollection(t *testing.T) {
 
 	expDGS := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
 
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, nil)
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, func(actual runtime.Object) {
+		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
+		assert.Equal(t, shared.LabelDedicatedGameServerCollectionName+"=test", dgsCol.Status.Selector)
+	})
 	for i := 0; i < 5; i++ {
 		f.expectCreateDedicatedGameServerAction(expDGS, nil)
 	}
@@ -170,6 +196,45 @@ func TestCreatesDedicatedGameServerCollection(t *testing.T) {
 	assertDGSList(t, dgss.Items, 5)
 }
 
+func TestCreatedDedicatedGameServerRecordsHostPorts(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	portRegistry, err := controllers.NewPortRegistry(fake.NewSimpleClientset(), 20000, 20010, metav1.NamespaceAll, controllers.PortRegistryOptions{})
+	assert.NoError(t, err)
+	f.portRegistry = portRegistry
+
+	podSpec := corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:  "test",
+				Ports: []corev1.ContainerPort{{ContainerPort: 80}, {ContainerPort: 81}},
+			},
+		},
+	}
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, podSpec)
+	dgsCol.Spec.PortsToExpose = []intstr.IntOrString{intstr.FromInt(80)}
+
+	f.dgsColLister = append(f.dgsColLister, dgsCol)
+	f.dgsObjects = append(f.dgsObjects, dgsCol)
+
+	expDGS := shared.NewDedicatedGameServer(dgsCol, podSpec)
+
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
+	f.expectCreateDedicatedGameServerAction(expDGS, func(actual runtime.Object) {
+		dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+		hostPort := dgs.Spec.Template.Containers[0].Ports[0].HostPort
+		assert.Equal(t, &dgsv1alpha1.DGSPortAllocation{HostPorts: []int32{hostPort}}, dgs.Spec.PortAllocation)
+		assert.Equal(t, int32(0), dgs.Spec.Template.Containers[0].Ports[1].HostPort)
+		assert.Contains(t, dgs.Finalizers, shared.HostPortsFinalizer)
+
+		key, err := cache.MetaNamespaceKeyFunc(dgs)
+		assert.NoError(t, err)
+		assert.Equal(t, []int32{hostPort}, portRegistry.Snapshot().Owners[key])
+	})
+
+	f.run(getKeyDGSCol(dgsCol, t))
+}
+
 func TestIncreaseReplicasOnDedicatedGameServerCollection(t *testing.T) {
 	f := newDGSColFixture(t)
 
@@ -186,7 +251,7 @@ func TestIncreaseReplicasOnDedicatedGameServerCollection(t *testing.T) {
 
 	//Update replicas
 	dgsCol.Spec.Replicas = 10
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, nil)
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
 
 	for i := 0; i < 5; i++ {
 		dgsExpected := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
@@ -216,14 +281,18 @@ func TestDecreaseReplicasOnDedicatedGameServerCollection(t *testing.T) {
 
 	//Update replicas
 	dgsCol.Spec.Replicas = 3
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, nil)
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
 
 	for i := 0; i < 2; i++ {
 		dgsExpected := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
-		f.expectUpdateDedicatedGameServerAction(dgsExpected, func(actual runtime.Object) {
+		f.expectUpdateDedicatedGameServerStatusAction(dgsExpected, func(actual runtime.Object) {
 			dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
 			assert.Equal(t, true, dgs.Status.MarkedForDeletion)
 		})
+		f.expectUpdateDedicatedGameServerAction(dgsExpected, func(actual runtime.Object) {
+			dgs := actual.(*dgsv1alpha1.DedicatedGameServer)
+			assert.Equal(t, 0, len(dgs.OwnerReferences))
+		})
 	}
 
 	f.run(getKeyDGSCol(dgsCol, t))
@@ -248,6 +317,32 @@ func TestDecreaseReplicasOnDedicatedGameServerCollection(t *testing.T) {
 	assert.Equal(t, 2, countNotInCollection)
 }
 
+func TestMarkDGSForDeletionKeepsDGSInCollectionIfRemovalFails(t *testing.T) {
+	f := newDGSColFixture(t)
+
+	dgsCol := shared.NewDedicatedGameServerCollection("test", shared.GameNamespace, 1, testhelpers.PodSpec)
+	dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
+	f.dgsObjects = append(f.dgsObjects, dgsCol, dgs)
+
+	testController, _, _ := f.newDedicatedGameServerCollectionController()
+	f.dgsClient.PrependReactor("update", "dedicatedgameservers", func(action core.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() == "status" {
+			return false, nil, nil
+		}
+		return true, nil, errors.New("update failed")
+	})
+
+	err := testController.markDGSForDeletion(dgsCol, dgs)
+	assert.Error(t, err)
+
+	// the DGS is marked for deletion and still belongs to the DGSCol, so a requeue can find it
+	dgsUpdated, err := f.dgsClient.AzuregamingV1alpha1().DedicatedGameServers(shared.GameNamespace).Get(dgs.Name, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, true, dgsUpdated.Status.MarkedForDeletion)
+	assert.Equal(t, dgsCol.Name, dgsUpdated.Labels[shared.LabelDedicatedGameServerCollectionName])
+	assert.Equal(t, 1, len(dgsUpdated.OwnerReferences))
+}
+
 func TestFailDedicatedGameServerCollectionForFirstTimeRemove(t *testing.T) {
 	f := newDGSColFixture(t)
 
@@ -260,7 +355,7 @@ func TestFailDedicatedGameServerCollectionForFirstTimeRemove(t *testing.T) {
 	f.dgsColLister = append(f.dgsColLister, dgsCol)
 	f.dgsObjects = append(f.dgsObjects, dgsCol)
 
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, nil)
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
 	var failedDGS *dgsv1alpha1.DedicatedGameServer
 	for i := 0; i < 5; i++ {
 		dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
@@ -278,7 +373,7 @@ func TestFailDedicatedGameServerCollectionForFirstTimeRemove(t *testing.T) {
 	}
 
 	f.expectUpdateDedicatedGameServerAction(failedDGS, nil) //DGS that's removed from the collection
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, func(actual runtime.Object) {
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, func(actual runtime.Object) {
 		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
 		assert.Equal(t, dgsv1alpha1.DGSColFailed, dgsCol.Status.DGSCollectionHealth)
 		assert.Equal(t, int32(1), dgsCol.Status.DGSTimesFailed)
@@ -315,7 +410,7 @@ func TestFailDedicatedGameServerCollectionForFirstTimeDelete(t *testing.T) {
 	f.dgsColLister = append(f.dgsColLister, dgsCol)
 	f.dgsObjects = append(f.dgsObjects, dgsCol)
 
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, nil)
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
 	var failedDGS *dgsv1alpha1.DedicatedGameServer
 	for i := 0; i < 5; i++ {
 		dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
@@ -333,7 +428,7 @@ func TestFailDedicatedGameServerCollectionForFirstTimeDelete(t *testing.T) {
 	}
 
 	f.expectDeleteDedicatedGameServerAction(failedDGS, nil) //DGS that's removed from the collection
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, func(actual runtime.Object) {
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, func(actual runtime.Object) {
 		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
 		assert.Equal(t, dgsv1alpha1.DGSColFailed, dgsCol.Status.DGSCollectionHealth)
 		assert.Equal(t, int32(1), dgsCol.Status.DGSTimesFailed)
@@ -371,7 +466,7 @@ func testFailSurpassThreshold(t *testing.T, fb dgsv1alpha1.DedicatedGameServerFa
 	f.dgsColLister = append(f.dgsColLister, dgsCol)
 	f.dgsObjects = append(f.dgsObjects, dgsCol)
 
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, nil)
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, nil)
 
 	for i := 0; i < 5; i++ {
 		dgs := shared.NewDedicatedGameServer(dgsCol, testhelpers.PodSpec)
@@ -387,7 +482,7 @@ func testFailSurpassThreshold(t *testing.T, fb dgsv1alpha1.DedicatedGameServerFa
 		f.dgsObjects = append(f.dgsObjects, dgs)
 	}
 
-	f.expectUpdateDedicatedGameServerCollectionAction(dgsCol, func(actual runtime.Object) {
+	f.expectUpdateDedicatedGameServerCollectionStatusAction(dgsCol, func(actual runtime.Object) {
 		dgsCol := actual.(*dgsv1alpha1.DedicatedGameServerCollection)
 		assert.Equal(t, dgsv1alpha1.DGSColNeedsIntervention, dgsCol.Status.DGSCollectionHealth)
 		assert.Equal(t, int32(2), dgsCol.Status.DGSTimesFailed)
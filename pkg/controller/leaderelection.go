@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LeaderElectionRecordAnnotation is the annotation of the lock ConfigMap that contains the LeaderElectionRecord
+// It is the same annotation that the Kubernetes components use for their ConfigMap locks
+const LeaderElectionRecordAnnotation = "control-plane.alpha.kubernetes.io/leader"
+
+// LeaderElectionRecord describes the current leader. It is stored in an annotation of the lock ConfigMap
+type LeaderElectionRecord struct {
+	HolderIdentity       string      `json:"holderIdentity"`
+	LeaseDurationSeconds int         `json:"leaseDurationSeconds"`
+	AcquireTime          metav1.Time `json:"acquireTime"`
+	RenewTime            metav1.Time `json:"renewTime"`
+	LeaderTransitions    int         `json:"leaderTransitions"`
+}
+
+// LeaderElectionConfig configures a LeaderElector
+type LeaderElectionConfig struct {
+	Client kubernetes.Interface
+	// Namespace and Name of the lock ConfigMap, which is created if it does not exist
+	Namespace string
+	Name      string
+	// Identity is the unique identity of the candidate, e.g. the Pod name
+	Identity string
+	// LeaseDuration is the time that the other candidates wait since the last renewal of the leader, before they take over
+	LeaseDuration time.Duration
+	// RenewDeadline is the time that the leader keeps retrying to renew the lease, before it gives up leading
+	RenewDeadline time.Duration
+	// RetryPeriod is the interval between the attempts to acquire or renew the lease
+	RetryPeriod time.Duration
+	// OnStartedLeading is called in a new goroutine when the candidate becomes the leader
+	// The stop channel is closed when the candidate stops leading
+	OnStartedLeading func(stopCh <-chan struct{})
+	// OnStoppedLeading is called when the candidate stops leading, either because it lost the lease or because it was stopped
+	OnStoppedLeading func()
+}
+
+// LeaderElector elects a leader among many candidates, via the LeaderElectionRecord of a lock ConfigMap
+// The leader keeps renewing the record, the other candidates take over if the record is not renewed for the LeaseDuration
+type LeaderElector struct {
+	config LeaderElectionConfig
+	clock  clockwork.Clock
+
+	mu sync.Mutex
+	// observedRecord is the last record that was read or written, observedTime is the local time that it changed
+	observedRecord LeaderElectionRecord
+	observedRaw    string
+	observedTime   time.Time
+}
+
+// NewLeaderElector returns a new LeaderElector
+func NewLeaderElector(config LeaderElectionConfig) (*LeaderElector, error) {
+	if config.LeaseDuration <= config.RenewDeadline {
+		return nil, fmt.Errorf("leaseDuration must be greater than renewDeadline")
+	}
+	if config.RenewDeadline <= config.RetryPeriod {
+		return nil, fmt.Errorf("renewDeadline must be greater than retryPeriod")
+	}
+	if config.RetryPeriod < time.Millisecond {
+		return nil, fmt.Errorf("retryPeriod must be at least 1ms")
+	}
+	if config.Identity == "" {
+		return nil, fmt.Errorf("identity must not be empty")
+	}
+	if config.Name == "" || config.Namespace == "" {
+		return nil, fmt.Errorf("the namespace and the name of the lock ConfigMap must not be empty")
+	}
+	if config.OnStartedLeading == nil || config.OnStoppedLeading == nil {
+		return nil, fmt.Errorf("OnStartedLeading and OnStoppedLeading are required")
+	}
+	return &LeaderElector{
+		config: config,
+		clock:  clockwork.NewRealClock(),
+	}, nil
+}
+
+// Run waits until the candidate becomes the leader, calls OnStartedLeading and keeps renewing the lease
+// It returns when stopCh is closed or when the lease cannot be renewed within the RenewDeadline
+func (le *LeaderElector) Run(stopCh <-chan struct{}) {
+	if !le.acquire(stopCh) {
+		return
+	}
+
+	leaderStopCh := make(chan struct{})
+	go le.config.OnStartedLeading(leaderStopCh)
+
+	le.renew(stopCh)
+
+	close(leaderStopCh)
+	le.config.OnStoppedLeading()
+}
+
+// IsLeader returns true if the last observed record has the identity of the candidate
+func (le *LeaderElector) IsLeader() bool {
+	return le.GetLeader() == le.config.Identity
+}
+
+// GetLeader returns the identity of the last observed leader
+func (le *LeaderElector) GetLeader() string {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	return le.observedRecord.HolderIdentity
+}
+
+// acquire retries to acquire the lease every RetryPeriod. It returns false if stopCh is closed first
+func (le *LeaderElector) acquire(stopCh <-chan struct{}) bool {
+	log.Infof("Attempting to acquire the leader lease %s/%s", le.config.Namespace, le.config.Name)
+	err := wait.PollImmediateUntil(le.config.RetryPeriod, func() (bool, error) {
+		return le.tryAcquireOrRenew(), nil
+	}, stopCh)
+	if err != nil {
+		return false
+	}
+	log.Infof("Acquired the leader lease %s/%s as %s", le.config.Namespace, le.config.Name, le.config.Identity)
+	return true
+}
+
+// renew renews the lease every RetryPeriod, until stopCh is closed or a renewal does not succeed within the RenewDeadline
+// When stopCh is closed, the lease is released, so another candidate can take over without waiting for the LeaseDuration
+func (le *LeaderElector) renew(stopCh <-chan struct{}) {
+	for {
+		deadlineCh := make(chan struct{})
+		timer := time.AfterFunc(le.config.RenewDeadline, func() { close(deadlineCh) })
+		err := wait.PollImmediateUntil(le.config.RetryPeriod, func() (bool, error) {
+			select {
+			case <-stopCh:
+				return false, wait.ErrWaitTimeout
+			default:
+			}
+			return le.tryAcquireOrRenew(), nil
+		}, deadlineCh)
+		timer.Stop()
+
+		select {
+		case <-stopCh:
+			le.release()
+			return
+		default:
+		}
+		if err != nil {
+			log.Errorf("Failed to renew the leader lease %s/%s within %s", le.config.Namespace, le.config.Name, le.config.RenewDeadline)
+			return
+		}
+
+		select {
+		case <-stopCh:
+			le.release()
+			return
+		case <-time.After(le.config.RetryPeriod):
+		}
+	}
+}
+
+// tryAcquireOrRenew acquires the lease if it is free or expired, or renews it if the candidate is the leader
+// It returns true if the candidate is the leader after the call
+func (le *LeaderElector) tryAcquireOrRenew() bool {
+	now := metav1.NewTime(le.clock.Now())
+	record := LeaderElectionRecord{
+		HolderIdentity:       le.config.Identity,
+		LeaseDurationSeconds: int(le.config.LeaseDuration / time.Second),
+		AcquireTime:          now,
+		RenewTime:            now,
+	}
+
+	cm, err := le.config.Client.CoreV1().ConfigMaps(le.config.Namespace).Get(le.config.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: le.config.Namespace,
+				Name:      le.config.Name,
+			},
+		}
+		raw, err := setLeaderElectionRecord(cm, record)
+		if err != nil {
+			log.Errorf("Error encoding the leader election record: %v", err)
+			return false
+		}
+		_, err = le.config.Client.CoreV1().ConfigMaps(le.config.Namespace).Create(cm)
+		if err != nil {
+			log.Errorf("Error creating the leader lease %s/%s: %v", le.config.Namespace, le.config.Name, err)
+			return false
+		}
+		le.observe(record, raw)
+		return true
+	} else if err != nil {
+		log.Errorf("Error getting the leader lease %s/%s: %v", le.config.Namespace, le.config.Name, err)
+		return false
+	}
+
+	raw := cm.Annotations[LeaderElectionRecordAnnotation]
+	var oldRecord LeaderElectionRecord
+	if raw != "" {
+		err = json.Unmarshal([]byte(raw), &oldRecord)
+		if err != nil {
+			// a corrupted record is overwritten
+			log.Errorf("Error decoding the leader election record of %s/%s: %v", le.config.Namespace, le.config.Name, err)
+		}
+	}
+	le.observe(oldRecord, raw)
+
+	// the lease of another candidate is valid for LeaseDuration since we observed its last change
+	// the local time is used, since the clocks of the candidates may be skewed
+	le.mu.Lock()
+	observedTime := le.observedTime
+	le.mu.Unlock()
+	if oldRecord.HolderIdentity != "" && oldRecord.HolderIdentity != le.config.Identity &&
+		observedTime.Add(time.Duration(oldRecord.LeaseDurationSeconds)*time.Second).After(le.clock.Now()) {
+		return false
+	}
+
+	if oldRecord.HolderIdentity == le.config.Identity {
+		record.AcquireTime = oldRecord.AcquireTime
+		record.LeaderTransitions = oldRecord.LeaderTransitions
+	} else {
+		record.LeaderTransitions = oldRecord.LeaderTransitions + 1
+	}
+
+	cmToUpdate := cm.DeepCopy()
+	raw, err = setLeaderElectionRecord(cmToUpdate, record)
+	if err != nil {
+		log.Errorf("Error encoding the leader election record: %v", err)
+		return false
+	}
+	// the update is guarded by the resourceVersion, so only one candidate can take over an expired lease
+	_, err = le.config.Client.CoreV1().ConfigMaps(le.config.Namespace).Update(cmToUpdate)
+	if err != nil {
+		log.Errorf("Error updating the leader lease %s/%s: %v", le.config.Namespace, le.config.Name, err)
+		return false
+	}
+	le.observe(record, raw)
+	return true
+}
+
+// release clears the holder of the lease, if the candidate is the leader
+func (le *LeaderElector) release() {
+	cm, err := le.config.Client.CoreV1().ConfigMaps(le.config.Namespace).Get(le.config.Name, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("Error getting the leader lease %s/%s: %v", le.config.Namespace, le.config.Name, err)
+		return
+	}
+
+	var record LeaderElectionRecord
+	err = json.Unmarshal([]byte(cm.Annotations[LeaderElectionRecordAnnotation]), &record)
+	if err != nil || record.HolderIdentity != le.config.Identity {
+		return
+	}
+	record.HolderIdentity = ""
+
+	cmToUpdate := cm.DeepCopy()
+	raw, err := setLeaderElectionRecord(cmToUpdate, record)
+	if err != nil {
+		log.Errorf("Error encoding the leader election record: %v", err)
+		return
+	}
+	_, err = le.config.Client.CoreV1().ConfigMaps(le.config.Namespace).Update(cmToUpdate)
+	if err != nil {
+		log.Errorf("Error releasing the leader lease %s/%s: %v", le.config.Namespace, le.config.Name, err)
+		return
+	}
+	le.observe(record, raw)
+	log.Infof("Released the leader lease %s/%s", le.config.Namespace, le.config.Name)
+}
+
+// observe records the last read or written record, and the local time if it changed
+func (le *LeaderElector) observe(record LeaderElectionRecord, raw string) {
+	le.mu.Lock()
+	defer le.mu.Unlock()
+	if raw != le.observedRaw || le.observedTime.IsZero() {
+		le.observedRecord = record
+		le.observedRaw = raw
+		le.observedTime = le.clock.Now()
+	}
+}
+
+// setLeaderElectionRecord sets the record in the annotation of the ConfigMap and returns the encoded record
+func setLeaderElectionRecord(cm *corev1.ConfigMap, record LeaderElectionRecord) (string, error) {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = make(map[string]string)
+	}
+	cm.Annotations[LeaderElectionRecordAnnotation] = string(recordBytes)
+	return string(recordBytes), nil
+}
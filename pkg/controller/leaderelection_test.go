@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestLeaderElector(t *testing.T, client kubernetes.Interface, identity string, leaseDuration time.Duration) *LeaderElector {
+	le, err := NewLeaderElector(LeaderElectionConfig{
+		Client:           client,
+		Namespace:        "dgs-system",
+		Name:             "controller",
+		Identity:         identity,
+		LeaseDuration:    leaseDuration,
+		RenewDeadline:    leaseDuration * 2 / 3,
+		RetryPeriod:      leaseDuration / 6,
+		OnStartedLeading: func(stopCh <-chan struct{}) {},
+		OnStoppedLeading: func() {},
+	})
+	if err != nil {
+		t.Fatalf("Cannot initialize LeaderElector due to: %s", err.Error())
+	}
+	return le
+}
+
+func getLeaderElectionRecord(t *testing.T, client kubernetes.Interface) LeaderElectionRecord {
+	cm, err := client.CoreV1().ConfigMaps("dgs-system").Get("controller", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Cannot get the lock ConfigMap due to: %s", err.Error())
+	}
+	var record LeaderElectionRecord
+	err = json.Unmarshal([]byte(cm.Annotations[LeaderElectionRecordAnnotation]), &record)
+	if err != nil {
+		t.Fatalf("Cannot decode the leader election record due to: %s", err.Error())
+	}
+	return record
+}
+
+func TestNewLeaderElectorValidatesConfig(t *testing.T) {
+	_, err := NewLeaderElector(LeaderElectionConfig{
+		Client:        k8sfake.NewSimpleClientset(),
+		Namespace:     "dgs-system",
+		Name:          "controller",
+		Identity:      "a",
+		LeaseDuration: 10 * time.Second,
+		RenewDeadline: 15 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	})
+	assert.Error(t, err)
+}
+
+func TestLeaderElectionAcquiresMissingLock(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	le := newTestLeaderElector(t, client, "a", 15*time.Second)
+
+	assert.True(t, le.tryAcquireOrRenew())
+	assert.True(t, le.IsLeader())
+
+	record := getLeaderElectionRecord(t, client)
+	assert.Equal(t, "a", record.HolderIdentity)
+	assert.Equal(t, 15, record.LeaseDurationSeconds)
+	assert.Equal(t, 0, record.LeaderTransitions)
+}
+
+func TestLeaderElectionRenewKeepsAcquireTime(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	le := newTestLeaderElector(t, client, "a", 15*time.Second)
+	clock := clockwork.NewFakeClockAt(time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC))
+	le.clock = clock
+
+	assert.True(t, le.tryAcquireOrRenew())
+	clock.Advance(5 * time.Second)
+	assert.True(t, le.tryAcquireOrRenew())
+
+	record := getLeaderElectionRecord(t, client)
+	assert.Equal(t, time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC), record.AcquireTime.UTC())
+	assert.Equal(t, time.Date(2018, 10, 1, 0, 0, 5, 0, time.UTC), record.RenewTime.UTC())
+	assert.Equal(t, 0, record.LeaderTransitions)
+}
+
+func TestLeaderElectionTakesOverExpiredLease(t *testing.T) {
+	raw, _ := json.Marshal(LeaderElectionRecord{HolderIdentity: "b", LeaseDurationSeconds: 15})
+	client := k8sfake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "dgs-system",
+			Name:        "controller",
+			Annotations: map[string]string{LeaderElectionRecordAnnotation: string(raw)},
+		},
+	})
+	le := newTestLeaderElector(t, client, "a", 15*time.Second)
+	clock := clockwork.NewFakeClock()
+	le.clock = clock
+
+	// the lease of b is valid for 15 seconds since it was first observed
+	assert.False(t, le.tryAcquireOrRenew())
+	assert.Equal(t, "b", le.GetLeader())
+	clock.Advance(10 * time.Second)
+	assert.False(t, le.tryAcquireOrRenew())
+
+	clock.Advance(6 * time.Second)
+	assert.True(t, le.tryAcquireOrRenew())
+	assert.True(t, le.IsLeader())
+
+	record := getLeaderElectionRecord(t, client)
+	assert.Equal(t, "a", record.HolderIdentity)
+	assert.Equal(t, 1, record.LeaderTransitions)
+}
+
+func TestLeaderElectorRunHandsOverOnStop(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+
+	startedA := make(chan struct{})
+	stoppedA := make(chan struct{})
+	leaderA := newTestLeaderElector(t, client, "a", 3*time.Second)
+	leaderA.config.OnStartedLeading = func(stopCh <-chan struct{}) { close(startedA) }
+	leaderA.config.OnStoppedLeading = func() { close(stoppedA) }
+
+	startedB := make(chan struct{})
+	leaderB := newTestLeaderElector(t, client, "b", 3*time.Second)
+	leaderB.config.OnStartedLeading = func(stopCh <-chan struct{}) { close(startedB) }
+
+	stopA := make(chan struct{})
+	go leaderA.Run(stopA)
+	select {
+	case <-startedA:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a did not start leading")
+	}
+
+	stopB := make(chan struct{})
+	defer close(stopB)
+	go leaderB.Run(stopB)
+	select {
+	case <-startedB:
+		t.Fatal("b started leading while a holds the lease")
+	case <-time.After(time.Second):
+	}
+
+	// a releases the lease when it is stopped, so b takes over before the lease expires
+	close(stopA)
+	select {
+	case <-stoppedA:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a did not stop leading")
+	}
+	select {
+	case <-startedB:
+	case <-time.After(2 * time.Second):
+		t.Fatal("b did not take over the released lease")
+	}
+	assert.Equal(t, "b", getLeaderElectionRecord(t, client).HolderIdentity)
+}
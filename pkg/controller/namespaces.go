@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"time"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	dgsclientset "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
+	dgsinformers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	informers "k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NewSharedInformerFactories returns the informer factories of the controllers, whose informers only contain the objects of the game namespaces
+// Nodes are not namespaced, so their informer always contains all the Nodes of the cluster
+func NewSharedInformerFactories(client kubernetes.Interface, dgsClient dgsclientset.Interface, defaultResync time.Duration) (informers.SharedInformerFactory, dgsinformers.SharedInformerFactory) {
+	namespace := shared.InformerNamespace()
+	factory := informers.NewSharedInformerFactoryWithOptions(client, defaultResync, informers.WithNamespace(namespace))
+	dgsFactory := dgsinformers.NewSharedInformerFactoryWithOptions(dgsClient, defaultResync, dgsinformers.WithNamespace(namespace))
+	if namespace != metav1.NamespaceAll || shared.GetGameNamespaces() == nil {
+		return factory, dgsFactory
+	}
+
+	// a list of game namespaces cannot be expressed in a ListOptions, so these informers watch all the namespaces
+	// and drop the objects of the other ones. They are registered before the controllers ask the factories for their informers
+	factory.InformerFor(&corev1.Pod{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newGameNamespacesInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Pods(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Pods(metav1.NamespaceAll).Watch(options)
+			},
+		}, &corev1.Pod{}, resync)
+	})
+	factory.InformerFor(&appsv1.ControllerRevision{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newGameNamespacesInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1().ControllerRevisions(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1().ControllerRevisions(metav1.NamespaceAll).Watch(options)
+			},
+		}, &appsv1.ControllerRevision{}, resync)
+	})
+	dgsFactory.InformerFor(&dgsv1alpha1.DedicatedGameServer{}, func(client dgsclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newGameNamespacesInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.AzuregamingV1alpha1().DedicatedGameServers(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.AzuregamingV1alpha1().DedicatedGameServers(metav1.NamespaceAll).Watch(options)
+			},
+		}, &dgsv1alpha1.DedicatedGameServer{}, resync)
+	})
+	dgsFactory.InformerFor(&dgsv1alpha1.DedicatedGameServerCollection{}, func(client dgsclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newGameNamespacesInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.AzuregamingV1alpha1().DedicatedGameServerCollections(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.AzuregamingV1alpha1().DedicatedGameServerCollections(metav1.NamespaceAll).Watch(options)
+			},
+		}, &dgsv1alpha1.DedicatedGameServerCollection{}, resync)
+	})
+	dgsFactory.InformerFor(&dgsv1alpha1.DedicatedGameServerAllocation{}, func(client dgsclientset.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newGameNamespacesInformer(&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.AzuregamingV1alpha1().DedicatedGameServerAllocations(metav1.NamespaceAll).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.AzuregamingV1alpha1().DedicatedGameServerAllocations(metav1.NamespaceAll).Watch(options)
+			},
+		}, &dgsv1alpha1.DedicatedGameServerAllocation{}, resync)
+	})
+
+	return factory, dgsFactory
+}
+
+// newGameNamespacesInformer returns an informer that only contains the objects of the ListWatch that are in a game namespace
+func newGameNamespacesInformer(lw *cache.ListWatch, objType runtime.Object, resync time.Duration) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list, err := lw.List(options)
+			if err != nil {
+				return nil, err
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				return nil, err
+			}
+			var gameItems []runtime.Object
+			for _, item := range items {
+				if isInGameNamespace(item) {
+					gameItems = append(gameItems, item)
+				}
+			}
+			// the resourceVersion of the list stays the same, so the watch starts after all the listed objects
+			err = meta.SetList(list, gameItems)
+			if err != nil {
+				return nil, err
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			w, err := lw.Watch(options)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+				if event.Type == watch.Error {
+					return event, true
+				}
+				return event, isInGameNamespace(event.Object)
+			}), nil
+		},
+	}, objType, resync, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+func isInGameNamespace(obj runtime.Object) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	return shared.IsGameNamespace(accessor.GetNamespace())
+}
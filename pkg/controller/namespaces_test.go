@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newNamespacedDGS(namespace, name string) *dgsv1alpha1.DedicatedGameServer {
+	return shared.NewDedicatedGameServerWithNoParent(namespace, name, corev1.PodSpec{}, nil)
+}
+
+func TestSharedInformerFactoriesKeepGameNamespaces(t *testing.T) {
+	defer shared.SetGameNamespaces(shared.GameNamespace)
+	assert.NoError(t, shared.SetGameNamespaces("games,tournaments"))
+
+	dgsClient := fake.NewSimpleClientset(newNamespacedDGS("games", "dgs1"), newNamespacedDGS("other", "dgs2"))
+	client := k8sfake.NewSimpleClientset(
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "tournaments", Name: "pod1"}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "pod2"}},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}},
+	)
+
+	factory, dgsFactory := NewSharedInformerFactories(client, dgsClient, 0)
+	dgsInformer := dgsFactory.Azuregaming().V1alpha1().DedicatedGameServers()
+	podInformer := factory.Core().V1().Pods()
+	nodeInformer := factory.Core().V1().Nodes()
+	cacheSyncs := []cache.InformerSynced{dgsInformer.Informer().HasSynced, podInformer.Informer().HasSynced, nodeInformer.Informer().HasSynced}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	factory.Start(stopCh)
+	dgsFactory.Start(stopCh)
+	assert.True(t, cache.WaitForCacheSync(stopCh, cacheSyncs...))
+
+	// objects of the other namespaces are dropped, both from the initial list and from the watch
+	// the watch events arrive in order, so the event of dgs4 has been handled once dgs3 is in the cache
+	_, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers("other").Create(newNamespacedDGS("other", "dgs4"))
+	assert.NoError(t, err)
+	_, err = dgsClient.AzuregamingV1alpha1().DedicatedGameServers("tournaments").Create(newNamespacedDGS("tournaments", "dgs3"))
+	assert.NoError(t, err)
+
+	err = wait.Poll(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		dgss, err := dgsInformer.Lister().List(labels.Everything())
+		return len(dgss) >= 2, err
+	})
+	assert.NoError(t, err)
+
+	dgss, err := dgsInformer.Lister().List(labels.Everything())
+	assert.NoError(t, err)
+	var dgsNames []string
+	for _, dgs := range dgss {
+		dgsNames = append(dgsNames, dgs.Name)
+	}
+	assert.ElementsMatch(t, []string{"dgs1", "dgs3"}, dgsNames)
+
+	pods, err := podInformer.Lister().List(labels.Everything())
+	assert.NoError(t, err)
+	if assert.Len(t, pods, 1) {
+		assert.Equal(t, "pod1", pods[0].Name)
+	}
+
+	// Nodes are not namespaced
+	nodes, err := nodeInformer.Lister().List(labels.Everything())
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 1)
+}
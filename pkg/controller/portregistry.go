@@ -1,39 +1,137 @@
 package controllers
 
 import (
-	"errors"
 	"fmt"
 	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
 
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
 	dgsclientset "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 
+	"github.com/jonboulle/clockwork"
+
 	log "github.com/sirupsen/logrus"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	listercorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// PortRegistryScope represents the set of Nodes in which a HostPort must be unique
+type PortRegistryScope string
+
+const (
+	// ClusterPortRegistryScope allocates every HostPort once in the cluster
+	ClusterPortRegistryScope PortRegistryScope = "Cluster"
+	// NodePortRegistryScope allocates every HostPort once per Node, since the Kubernetes scheduler
+	// never places two Pods with the same HostPort on the same Node
+	NodePortRegistryScope PortRegistryScope = "Node"
+	// NodePoolPortRegistryScope allocates every HostPort once per Node of the node pool that the DGS Pod Template selects
+	NodePoolPortRegistryScope PortRegistryScope = "NodePool"
 )
 
-// PortRegistry implements a custom map for the port registry
+// PortRegistryOptions configure the way the PortRegistry tracks the HostPorts
+type PortRegistryOptions struct {
+	// Scope is the set of Nodes in which a HostPort must be unique. Default is Cluster
+	Scope PortRegistryScope
+	// NodeLister is used to count the Nodes in the Node and NodePool scopes
+	NodeLister listercorev1.NodeLister
+	// NodePoolLabel is the Node label that groups Nodes into node pools, in the NodePool scope
+	NodePoolLabel string
+}
+
+// PortAllocationRequest describes the HostPorts that a DGS needs
+type PortAllocationRequest struct {
+	// Owner is the namespace/name key of the DGS
+	Owner string
+	// Count is the number of HostPorts
+	Count int
+	// NodePool is the node pool that the DGS Pod will run on, used in the NodePool scope
+	NodePool string
+	// Min and Max override the port range of the PortRegistry, if they are set
+	Min int32
+	Max int32
+	// BlockSize is the number of adjacent HostPorts in every block. The Count HostPorts are allocated in blocks. Default is 1
+	BlockSize int
+	// Ports are specific HostPorts that are allocated instead of Count HostPorts, e.g. when HostPorts are the same as ContainerPorts
+	Ports []int32
+}
+
+// PortRegistrySnapshot is a copy of the state of the PortRegistry
+type PortRegistrySnapshot struct {
+	Scope PortRegistryScope
+	Min   int32
+	Max   int32
+	// Pools contains the allocated HostPorts of each node pool. In the Cluster and Node scopes there is a single pool with an empty name
+	Pools map[string]PortPoolSnapshot
+	// Owners contains the allocated HostPorts of each DGS
+	Owners map[string][]int32
+}
+
+// PortPoolSnapshot is a copy of the state of a node pool of the PortRegistry
+type PortPoolSnapshot struct {
+	// Capacity is the number of DGSs that can use each HostPort
+	Capacity int
+	// Ports contains the number of DGSs that use each allocated HostPort
+	Ports map[int32]int
+}
+
+// PortRegistry keeps track of the HostPorts that are used by the DGSs. It is safe for concurrent use
 type PortRegistry struct {
-	Ports             map[int32]bool
-	Indexes           []int32
-	NextFreePortIndex int32
-	Min               int32         // Minimum Port
-	Max               int32         // Maximum Port
-	portRequests      chan struct{} // buffered channel to store port requests
-	portResponses     chan int32    // buffered channel to store port responses (system returns the HostPorts)
+	mu      sync.Mutex
+	min     int32
+	max     int32
+	options PortRegistryOptions
+	pools   map[string]*portPool
+	owners  map[string]portAllocation
+	clock   clockwork.Clock
 }
 
-// NewPortRegistry initializes the IndexedDictionary that holds the port registry.
-func NewPortRegistry(dgsclientset dgsclientset.Interface, min, max int32, namespace string) (*PortRegistry, error) {
+// portPool contains the HostPorts of a node pool
+type portPool struct {
+	usage map[int32]int
+	// order is a random permutation of the HostPorts, next is the index of the next HostPort to try
+	order []int32
+	next  int
+}
+
+// portAllocation contains the HostPorts of a DGS
+type portAllocation struct {
+	pool        string
+	ports       []int32
+	allocatedAt time.Time
+}
+
+// NewPortRegistry initializes the PortRegistry with the HostPorts of the existing DGSs
+func NewPortRegistry(dgsclientset dgsclientset.Interface, min, max int32, namespace string, options PortRegistryOptions) (*PortRegistry, error) {
+	if min > max {
+		return nil, fmt.Errorf("invalid port range %d-%d", min, max)
+	}
+	if options.Scope == "" {
+		options.Scope = ClusterPortRegistryScope
+	}
+	switch options.Scope {
+	case ClusterPortRegistryScope, NodePortRegistryScope, NodePoolPortRegistryScope:
+	default:
+		return nil, fmt.Errorf("unknown port registry scope %s", options.Scope)
+	}
+	if options.Scope != ClusterPortRegistryScope && options.NodeLister == nil {
+		return nil, fmt.Errorf("a NodeLister is required for the %s scope", options.Scope)
+	}
 
 	pr := &PortRegistry{
-		Ports:         make(map[int32]bool, max-min+1),
-		Indexes:       make([]int32, max-min+1),
-		Min:           min,
-		Max:           max,
-		portRequests:  make(chan struct{}, 100),
-		portResponses: make(chan int32, 100),
+		min:     min,
+		max:     max,
+		options: options,
+		pools:   make(map[string]*portPool),
+		owners:  make(map[string]portAllocation),
+		clock:   clockwork.NewRealClock(),
 	}
 
 	dgsList, err := dgsclientset.AzuregamingV1alpha1().DedicatedGameServers(namespace).List(metav1.ListOptions{})
@@ -43,142 +141,341 @@ func NewPortRegistry(dgsclientset dgsclientset.Interface, min, max int32, namesp
 	}
 
 	// gather ports for existing DGS
-	if len(dgsList.Items) > 0 {
-		for _, dgs := range dgsList.Items {
-			if len(dgs.Spec.Template.Containers) == 0 {
-				log.Errorf("DGS with name %s has no containers in its Pod Template: %#v", dgs.Name, dgs)
+	for i := range dgsList.Items {
+		dgs := &dgsList.Items[i]
+		if !shared.IsGameNamespace(dgs.Namespace) {
+			continue
+		}
+		allocation := pr.AllocationFor(dgs)
+		if allocation == nil {
+			continue //no ports exported for this DGS
+		}
+		key, err := cache.MetaNamespaceKeyFunc(dgs)
+		if err != nil {
+			return nil, err
+		}
+		pr.register(key, allocation.NodePool, allocation.HostPorts)
+	}
+
+	return pr, nil
+}
+
+// GetExposedHostPorts returns the HostPorts of the exposed ports of the DGS
+func GetExposedHostPorts(dgs *dgsv1alpha1.DedicatedGameServer) []int32 {
+	var ports []int32
+	for k, container := range dgs.Spec.Template.Containers {
+		for _, portInfo := range container.Ports {
+			// if this port is to be exposed
+			if !shared.IsPortExposed(&dgs.Spec.Template.Containers[k], portInfo.ContainerPort, dgs.Spec.PortsToExpose) {
 				continue
 			}
-			if len(dgs.Spec.PortsToExpose) == 0 {
-				continue //no ports exported for this DGS
+			if portInfo.HostPort == 0 {
+				log.Errorf("HostPort for DGS %s and ContainerPort %d is zero, ignoring", dgs.Name, portInfo.ContainerPort)
+				continue
 			}
-
-			portsExposed := make([]int32, len(dgs.Spec.PortsToExpose))
-			portsExposedIndex := 0
-			for j := 0; j < len(dgs.Spec.Template.Containers); j++ {
-				for _, portInfo := range dgs.Spec.Template.Containers[j].Ports {
-					if portInfo.HostPort == 0 {
-						log.Errorf("HostPort for DGS %s and ContainerPort %d is zero, ignoring", dgs.Name, portInfo.ContainerPort)
-						continue
-					}
-					// if this port is to be exposed
-					if shared.SliceContains(dgs.Spec.PortsToExpose, portInfo.ContainerPort) {
-						portsExposed[portsExposedIndex] = portInfo.HostPort
-						portsExposedIndex++
-					}
-				}
+			// ports that are exposed over both TCP and UDP share the HostPort
+			if !shared.SliceContains(ports, portInfo.HostPort) {
+				ports = append(ports, portInfo.HostPort)
 			}
-			pr.assignRegisteredPorts(portsExposed)
 		}
 	}
+	return ports
+}
+
+// AllocationFor returns the HostPorts allocation of the DGS, or nil if the DGS has no HostPorts
+// DGSs that were created before allocations were recorded get their HostPorts from their Pod Template
+func (pr *PortRegistry) AllocationFor(dgs *dgsv1alpha1.DedicatedGameServer) *dgsv1alpha1.DGSPortAllocation {
+	if dgs.Spec.PortAllocation != nil {
+		return dgs.Spec.PortAllocation
+	}
+	ports := GetExposedHostPorts(dgs)
+	if len(ports) == 0 {
+		return nil
+	}
+	return &dgsv1alpha1.DGSPortAllocation{NodePool: pr.NodePoolFor(&dgs.Spec.Template), HostPorts: ports}
+}
+
+// NodePoolFor returns the node pool that the Pod Template selects, if the PortRegistry tracks HostPorts per node pool
+func (pr *PortRegistry) NodePoolFor(podSpec *corev1.PodSpec) string {
+	if pr.options.Scope != NodePoolPortRegistryScope {
+		return ""
+	}
+	return podSpec.NodeSelector[pr.options.NodePoolLabel]
+}
 
-	pr.assignUnregisteredPorts()
+// Allocate registers and returns request.Count HostPorts, or request.Ports, for request.Owner
+func (pr *PortRegistry) Allocate(request PortAllocationRequest) ([]int32, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
 
-	go pr.portProducer()
+	if _, ok := pr.owners[request.Owner]; ok {
+		return nil, fmt.Errorf("ports are already allocated for %s", request.Owner)
+	}
 
-	return pr, nil
+	capacity, err := pr.capacity(request.NodePool)
+	if err != nil {
+		return nil, err
+	}
 
-}
+	pool := pr.getPool(request.NodePool)
+	var ports []int32
+	if len(request.Ports) > 0 {
+		ports, err = pr.pickRequestedPorts(pool, capacity, request.Ports)
+	} else {
+		ports, err = pr.pickPorts(pool, capacity, request)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-func (pr *PortRegistry) displayRegistry() {
-	fmt.Printf("-------------------------------------\n")
-	fmt.Printf("Ports: %v\n", pr.Ports)
-	fmt.Printf("Indexes: %v\n", pr.Indexes)
-	fmt.Printf("NextIndex: %d\n", pr.NextFreePortIndex)
-	fmt.Printf("-------------------------------------\n")
+	pr.register(request.Owner, request.NodePool, ports)
+	return ports, nil
 }
 
-// GetNewPort returns and registers a new port for the designated game server. Locks a mutex
-func (pr *PortRegistry) GetNewPort() (int32, error) {
-	pr.portRequests <- struct{}{}
+// MaxAllocations returns the number of DGSs that the request can be allocated for, if no other DGS used any HostPorts
+func (pr *PortRegistry) MaxAllocations(request PortAllocationRequest) (int, error) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
 
-	port := <-pr.portResponses
+	capacity, err := pr.capacity(request.NodePool)
+	if err != nil {
+		return 0, err
+	}
 
-	if port == -1 {
-		return -1, errors.New("Cannot register a new port. No available ports")
+	// requested HostPorts can be used once per Node
+	if len(request.Ports) > 0 {
+		return capacity, nil
 	}
 
-	return port, nil
+	min, max, blockSize, err := pr.validateRequest(request)
+	if err != nil {
+		return 0, err
+	}
+	blocks := int(max-min+1) / blockSize
+	return blocks / (request.Count / blockSize) * capacity, nil
 }
 
-func (pr *PortRegistry) portProducer() {
-	for range pr.portRequests { //wait till a new request comes
+// validateRequest checks the Count, range and BlockSize of the request and returns its range and BlockSize
+func (pr *PortRegistry) validateRequest(request PortAllocationRequest) (int32, int32, int, error) {
+	min, max := pr.min, pr.max
+	if request.Min != 0 || request.Max != 0 {
+		min, max = request.Min, request.Max
+	}
+	if min < 1 || max > 65535 || min > max {
+		return 0, 0, 0, fmt.Errorf("invalid port range %d-%d", min, max)
+	}
 
-		initialIndex := pr.NextFreePortIndex
-		for {
-			if !pr.Ports[pr.Indexes[pr.NextFreePortIndex]] {
-				//we found a port
-				port := pr.Indexes[pr.NextFreePortIndex]
-				pr.Ports[port] = true
+	blockSize := request.BlockSize
+	if blockSize < 1 {
+		blockSize = 1
+	}
+	if request.Count < 1 || request.Count%blockSize != 0 {
+		return 0, 0, 0, fmt.Errorf("cannot allocate %d ports in blocks of %d ports", request.Count, blockSize)
+	}
+	if blockSize > int(max-min+1) {
+		return 0, 0, 0, fmt.Errorf("a block of %d ports does not fit in the port range %d-%d", blockSize, min, max)
+	}
+	return min, max, blockSize, nil
+}
 
-				pr.increaseNextFreePortIndex()
+// pickPorts returns request.Count free HostPorts of the pool, without registering them
+func (pr *PortRegistry) pickPorts(pool *portPool, capacity int, request PortAllocationRequest) ([]int32, error) {
+	min, max, blockSize, err := pr.validateRequest(request)
+	if err != nil {
+		return nil, err
+	}
 
-				//port is set
-				pr.portResponses <- port
-				break
+	ports := make([]int32, 0, request.Count)
+	if min == pr.min && max == pr.max && blockSize == 1 {
+		// the random permutation of the pool spreads the HostPorts over the whole range
+		for tried := 0; len(ports) < request.Count && tried < len(pool.order); tried++ {
+			port := pool.order[pool.next]
+			pool.next = (pool.next + 1) % len(pool.order)
+			if pool.usage[port] < capacity && !shared.SliceContains(ports, port) {
+				ports = append(ports, port)
 			}
-
-			pr.increaseNextFreePortIndex()
-
-			if initialIndex == pr.NextFreePortIndex {
-				//we did a full loop - no empty ports
-				pr.portResponses <- -1
-				break
+		}
+	} else {
+		// blocks start at every port of the range, beginning from a random one
+		starts := int(max-min+1) - blockSize + 1
+		offset := rand.Intn(starts)
+		for tried := 0; len(ports) < request.Count && tried < starts; tried++ {
+			start := min + int32((offset+tried)%starts)
+			if isBlockFree(pool, capacity, start, blockSize, ports) {
+				for port := start; port < start+int32(blockSize); port++ {
+					ports = append(ports, port)
+				}
 			}
 		}
 	}
+
+	if len(ports) < request.Count {
+		return nil, fmt.Errorf("Cannot register %d new ports. No available ports", request.Count)
+	}
+	return ports, nil
 }
 
-// Stop stops port registry mechanism by closing requests and responses channels
-func (pr *PortRegistry) Stop() {
-	close(pr.portRequests)
-	close(pr.portResponses)
+// pickRequestedPorts checks that the requested HostPorts are free in the pool and returns a copy of them, without registering them
+func (pr *PortRegistry) pickRequestedPorts(pool *portPool, capacity int, requested []int32) ([]int32, error) {
+	ports := make([]int32, 0, len(requested))
+	for _, port := range requested {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+		if shared.SliceContains(ports, port) {
+			return nil, fmt.Errorf("port %d is requested more than once", port)
+		}
+		if pool.usage[port] >= capacity {
+			return nil, fmt.Errorf("Cannot register port %d. It is not available", port)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
 }
 
-// DeregisterServerPorts deregisters all ports
-func (pr *PortRegistry) DeregisterServerPorts(ports []int32) {
-	for i := 0; i < len(ports); i++ {
-		pr.Ports[ports[i]] = false
+// isBlockFree returns true if the blockSize HostPorts that begin at start are free in the pool and not in picked
+func isBlockFree(pool *portPool, capacity int, start int32, blockSize int, picked []int32) bool {
+	for port := start; port < start+int32(blockSize); port++ {
+		if pool.usage[port] >= capacity || shared.SliceContains(picked, port) {
+			return false
+		}
 	}
+	return true
 }
 
-func (pr *PortRegistry) assignRegisteredPorts(ports []int32) {
-	for i := 0; i < len(ports); i++ {
-		pr.Ports[ports[i]] = true
-		pr.Indexes[i] = ports[i]
-		pr.increaseNextFreePortIndex()
+// Release deregisters the HostPorts of owner. Releasing an owner without HostPorts is a no-op
+func (pr *PortRegistry) Release(owner string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.release(owner)
+}
+
+// Snapshot returns a copy of the state of the PortRegistry
+func (pr *PortRegistry) Snapshot() PortRegistrySnapshot {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	snapshot := PortRegistrySnapshot{
+		Scope:  pr.options.Scope,
+		Min:    pr.min,
+		Max:    pr.max,
+		Pools:  make(map[string]PortPoolSnapshot, len(pr.pools)),
+		Owners: make(map[string][]int32, len(pr.owners)),
+	}
+	for name, pool := range pr.pools {
+		capacity, err := pr.capacity(name)
+		if err != nil {
+			capacity = 0
+		}
+		ports := make(map[int32]int, len(pool.usage))
+		for port, usage := range pool.usage {
+			ports[port] = usage
+		}
+		snapshot.Pools[name] = PortPoolSnapshot{Capacity: capacity, Ports: ports}
+	}
+	for owner, allocation := range pr.owners {
+		ports := make([]int32, len(allocation.ports))
+		copy(ports, allocation.ports)
+		snapshot.Owners[owner] = ports
 	}
+	return snapshot
 }
 
-func (pr *PortRegistry) assignUnregisteredPorts() {
-	i := pr.NextFreePortIndex
-	for _, port := range pr.getPermutatedPorts() {
-		if _, ok := pr.Ports[port]; !ok {
-			pr.Ports[port] = false
-			pr.Indexes[i] = port
-			i++
+// Reconcile makes the PortRegistry contain exactly the expected allocations, keyed by owner, and returns
+// the owners that were registered or updated and the owners that were released
+// Owners that are not expected are released only if they were allocated more than minAge ago,
+// since the DGSs of recent allocations may not be known to the caller yet
+func (pr *PortRegistry) Reconcile(expected map[string]dgsv1alpha1.DGSPortAllocation, minAge time.Duration) (registered, released []string) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	now := pr.clock.Now()
+	for owner, allocation := range pr.owners {
+		if _, ok := expected[owner]; !ok && now.Sub(allocation.allocatedAt) > minAge {
+			pr.release(owner)
+			released = append(released, owner)
 		}
 	}
+
+	for owner, expectedAllocation := range expected {
+		allocation, ok := pr.owners[owner]
+		if ok && allocation.pool == expectedAllocation.NodePool && reflect.DeepEqual(allocation.ports, expectedAllocation.HostPorts) {
+			continue
+		}
+		if ok {
+			pr.release(owner)
+		}
+		ports := make([]int32, len(expectedAllocation.HostPorts))
+		copy(ports, expectedAllocation.HostPorts)
+		pr.register(owner, expectedAllocation.NodePool, ports)
+		registered = append(registered, owner)
+	}
+
+	sort.Strings(registered)
+	sort.Strings(released)
+	return registered, released
+}
+
+// register marks the ports as used by owner. The ports may be over capacity, since they are already in use
+func (pr *PortRegistry) register(owner, poolName string, ports []int32) {
+	pool := pr.getPool(poolName)
+	for _, port := range ports {
+		pool.usage[port]++
+	}
+	pr.owners[owner] = portAllocation{pool: poolName, ports: ports, allocatedAt: pr.clock.Now()}
 }
 
-func (pr *PortRegistry) increaseNextFreePortIndex() {
-	pr.NextFreePortIndex++
-	//reset the index if needed
-	if pr.NextFreePortIndex == pr.Max-pr.Min+1 {
-		pr.NextFreePortIndex = 0
+// release deregisters the HostPorts of owner
+func (pr *PortRegistry) release(owner string) {
+	allocation, ok := pr.owners[owner]
+	if !ok {
+		return
+	}
+	pool := pr.pools[allocation.pool]
+	for _, port := range allocation.ports {
+		pool.usage[port]--
+		if pool.usage[port] <= 0 {
+			delete(pool.usage, port)
+		}
 	}
+	delete(pr.owners, owner)
+}
 
+// getPool returns the node pool with the given name, creating it if needed
+func (pr *PortRegistry) getPool(name string) *portPool {
+	pool, ok := pr.pools[name]
+	if !ok {
+		pool = &portPool{
+			usage: make(map[int32]int),
+			order: make([]int32, pr.max-pr.min+1),
+		}
+		// ports are handed out in random order
+		for i, offset := range rand.Perm(len(pool.order)) {
+			pool.order[i] = pr.min + int32(offset)
+		}
+		pr.pools[name] = pool
+	}
+	return pool
 }
 
-func (pr *PortRegistry) getPermutatedPorts() []int32 {
-	ports := make([]int32, pr.Max-pr.Min+1)
-	for i := 0; i < len(ports); i++ {
-		ports[i] = int32(pr.Min)
+// capacity returns the number of DGSs that can use each HostPort of the node pool, i.e. the number of its Nodes
+func (pr *PortRegistry) capacity(poolName string) (int, error) {
+	if pr.options.Scope == ClusterPortRegistryScope {
+		return 1, nil
 	}
-	perm := rand.Perm(int((pr.Max - pr.Min + 1)))
 
-	for i := 0; i < len(ports); i++ {
-		ports[i] += int32(perm[i])
+	selector := labels.Everything()
+	if pr.options.Scope == NodePoolPortRegistryScope && poolName != "" {
+		selector = labels.SelectorFromSet(labels.Set{pr.options.NodePoolLabel: poolName})
 	}
-	return ports
+	nodes, err := pr.options.NodeLister.List(selector)
+	if err != nil {
+		return 0, err
+	}
+	if len(nodes) == 0 {
+		// Nodes may not be known yet, so we fall back to the Cluster scope
+		return 1, nil
+	}
+	return len(nodes), nil
 }
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// legacyPortRegistry is the channel based PortRegistry that was used before the current one, kept for comparison
+// Its DeregisterServerPorts writes the ports map without synchronization, so the benchmarks that use it report races under -race
+type legacyPortRegistry struct {
+	Ports             map[int32]bool
+	Indexes           []int32
+	NextFreePortIndex int32
+	Min               int32
+	Max               int32
+	portRequests      chan struct{}
+	portResponses     chan int32
+}
+
+func newLegacyPortRegistry(min, max int32) *legacyPortRegistry {
+	pr := &legacyPortRegistry{
+		Ports:         make(map[int32]bool, max-min+1),
+		Indexes:       make([]int32, max-min+1),
+		Min:           min,
+		Max:           max,
+		portRequests:  make(chan struct{}, 100),
+		portResponses: make(chan int32, 100),
+	}
+	for i, offset := range rand.Perm(int(max - min + 1)) {
+		port := min + int32(offset)
+		pr.Ports[port] = false
+		pr.Indexes[i] = port
+	}
+	go pr.portProducer()
+	return pr
+}
+
+func (pr *legacyPortRegistry) GetNewPort() (int32, error) {
+	pr.portRequests <- struct{}{}
+	port := <-pr.portResponses
+	if port == -1 {
+		return -1, errors.New("Cannot register a new port. No available ports")
+	}
+	return port, nil
+}
+
+func (pr *legacyPortRegistry) portProducer() {
+	for range pr.portRequests {
+		initialIndex := pr.NextFreePortIndex
+		for {
+			if !pr.Ports[pr.Indexes[pr.NextFreePortIndex]] {
+				port := pr.Indexes[pr.NextFreePortIndex]
+				pr.Ports[port] = true
+				pr.increaseNextFreePortIndex()
+				pr.portResponses <- port
+				break
+			}
+			pr.increaseNextFreePortIndex()
+			if initialIndex == pr.NextFreePortIndex {
+				pr.portResponses <- -1
+				break
+			}
+		}
+	}
+}
+
+func (pr *legacyPortRegistry) increaseNextFreePortIndex() {
+	pr.NextFreePortIndex++
+	if pr.NextFreePortIndex == pr.Max-pr.Min+1 {
+		pr.NextFreePortIndex = 0
+	}
+}
+
+func (pr *legacyPortRegistry) DeregisterServerPorts(ports []int32) {
+	for i := 0; i < len(ports); i++ {
+		pr.Ports[ports[i]] = false
+	}
+}
+
+func (pr *legacyPortRegistry) Stop() {
+	close(pr.portRequests)
+}
+
+func BenchmarkPortRegistryAllocateRelease(b *testing.B) {
+	pr, err := NewPortRegistry(fake.NewSimpleClientset(), 20000, 30000, metav1.NamespaceAll, PortRegistryOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		owner := fmt.Sprintf("default/server%d", i)
+		if _, err := pr.Allocate(PortAllocationRequest{Owner: owner, Count: 1}); err != nil {
+			b.Fatal(err)
+		}
+		pr.Release(owner)
+	}
+}
+
+func BenchmarkLegacyPortRegistryAllocateRelease(b *testing.B) {
+	pr := newLegacyPortRegistry(20000, 30000)
+	defer pr.Stop()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		port, err := pr.GetNewPort()
+		if err != nil {
+			b.Fatal(err)
+		}
+		pr.DeregisterServerPorts([]int32{port})
+	}
+}
+
+func BenchmarkPortRegistryAllocateReleaseParallel(b *testing.B) {
+	pr, err := NewPortRegistry(fake.NewSimpleClientset(), 20000, 30000, metav1.NamespaceAll, PortRegistryOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	var counter int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			owner := fmt.Sprintf("default/server%d", atomic.AddInt64(&counter, 1))
+			if _, err := pr.Allocate(PortAllocationRequest{Owner: owner, Count: 1}); err != nil {
+				b.Fatal(err)
+			}
+			pr.Release(owner)
+		}
+	})
+}
+
+func BenchmarkLegacyPortRegistryAllocateReleaseParallel(b *testing.B) {
+	pr := newLegacyPortRegistry(20000, 30000)
+	defer pr.Stop()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			port, err := pr.GetNewPort()
+			if err != nil {
+				b.Fatal(err)
+			}
+			pr.DeregisterServerPorts([]int32{port})
+		}
+	})
+}
+
+// BenchmarkPortRegistryAllocateAlmostFull measures allocations when 90% of the ports are in use
+func BenchmarkPortRegistryAllocateAlmostFull(b *testing.B) {
+	pr, err := NewPortRegistry(fake.NewSimpleClientset(), 20000, 30000, metav1.NamespaceAll, PortRegistryOptions{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < 9000; i++ {
+		if _, err := pr.Allocate(PortAllocationRequest{Owner: fmt.Sprintf("default/existing%d", i), Count: 1}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		owner := fmt.Sprintf("default/server%d", i)
+		if _, err := pr.Allocate(PortAllocationRequest{Owner: owner, Count: 1}); err != nil {
+			b.Fatal(err)
+		}
+		pr.Release(owner)
+	}
+}
+
+func BenchmarkLegacyPortRegistryAllocateAlmostFull(b *testing.B) {
+	pr := newLegacyPortRegistry(20000, 30000)
+	defer pr.Stop()
+	for i := 0; i < 9000; i++ {
+		if _, err := pr.GetNewPort(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		port, err := pr.GetNewPort()
+		if err != nil {
+			b.Fatal(err)
+		}
+		pr.DeregisterServerPorts([]int32{port})
+	}
+}
@@ -1,23 +1,49 @@
 package controllers
 
 import (
-	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
-	dgsinformers "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/informers/externalversions"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/controller/testhelpers"
 	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/shared"
 
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubeinformers "k8s.io/client-go/informers"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	listercorev1 "k8s.io/client-go/listers/core/v1"
 )
 
-const displayVariablesDuringTesting = false
+func newPortRegistryWithDGSs(t *testing.T, min, max int32, options PortRegistryOptions, dgsObjects ...runtime.Object) *PortRegistry {
+	dgsClient := fake.NewSimpleClientset(dgsObjects...)
+	portRegistry, err := NewPortRegistry(dgsClient, min, max, metav1.NamespaceAll, options)
+	if err != nil {
+		t.Fatalf("Cannot initialize PortRegistry due to: %s", err.Error())
+	}
+	return portRegistry
+}
+
+// newNodeLister returns a NodeLister with nodes named after the keys of nodePools, labeled with agentpool=value
+func newNodeLister(nodePools map[string]string) listercorev1.NodeLister {
+	k8sInformers := kubeinformers.NewSharedInformerFactory(k8sfake.NewSimpleClientset(), testhelpers.NoResyncPeriodFunc())
+	for name, pool := range nodePools {
+		k8sInformers.Core().V1().Nodes().Informer().GetIndexer().Add(&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"agentpool": pool}},
+		})
+	}
+	return k8sInformers.Core().V1().Nodes().Lister()
+}
 
 func TestPortRegistry(t *testing.T) {
-
 	server1 := shared.NewDedicatedGameServerWithNoParent(shared.GameNamespace, "default1", corev1.PodSpec{
 		Containers: []corev1.Container{
 			{
@@ -42,7 +68,7 @@ func TestPortRegistry(t *testing.T) {
 				},
 			},
 		},
-	}, []int32{20002, 20004, 20006, 20008})
+	}, []intstr.IntOrString{intstr.FromInt(20002), intstr.FromInt(20004), intstr.FromInt(20006), intstr.FromInt(20008)})
 
 	server2 := shared.NewDedicatedGameServerWithNoParent(shared.GameNamespace, "default2", corev1.PodSpec{
 		Containers: []corev1.Container{
@@ -52,218 +78,360 @@ func TestPortRegistry(t *testing.T) {
 		},
 	}, nil)
 
-	dgsObjects := []runtime.Object{}
-	dgsObjects = append(dgsObjects, server1)
-	dgsObjects = append(dgsObjects, server2)
-	dgsClient := fake.NewSimpleClientset(dgsObjects...)
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20010, PortRegistryOptions{}, server1, server2)
+
+	// the ports of the existing DGSs are registered
+	snapshot := portRegistry.Snapshot()
+	assert.Equal(t, ClusterPortRegistryScope, snapshot.Scope)
+	assert.Equal(t, []int32{20002, 20004, 20006, 20008}, snapshot.Owners["default/default1"])
+	assert.Equal(t, map[int32]int{20002: 1, 20004: 1, 20006: 1, 20008: 1}, snapshot.Pools[""].Ports)
+	assert.Equal(t, 1, snapshot.Pools[""].Capacity)
+
+	// 7 ports are left
+	ports, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/server3", Count: 4})
+	assert.NoError(t, err)
+	assert.Len(t, ports, 4)
+	ports2, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/server4", Count: 3})
+	assert.NoError(t, err)
+	assert.Len(t, ports2, 3)
+
+	allPorts := append(append([]int32{20002, 20004, 20006, 20008}, ports...), ports2...)
+	for port := int32(20000); port <= 20010; port++ {
+		assert.Contains(t, allPorts, port)
+	}
 
-	dgsInformers := dgsinformers.NewSharedInformerFactory(dgsClient, testhelpers.NoResyncPeriodFunc())
-	dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers().Informer().GetIndexer().Add(server1)
-	dgsInformers.Azuregaming().V1alpha1().DedicatedGameServers().Informer().GetIndexer().Add(server2)
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server5", Count: 1})
+	assert.Error(t, err)
 
-	portRegistry, err := NewPortRegistry(dgsClient, 20000, 20010, metav1.NamespaceAll)
+	// a failed allocation does not register any ports
+	_, ok := portRegistry.Snapshot().Owners["default/server5"]
+	assert.False(t, ok)
 
-	if err != nil {
-		t.Fatalf("Cannot initialize PortRegistry due to: %s", err.Error())
-	}
+	portRegistry.Release("default/default1")
+	snapshot = portRegistry.Snapshot()
+	_, ok = snapshot.Owners["default/default1"]
+	assert.False(t, ok)
+	assert.Len(t, snapshot.Pools[""].Ports, 7)
 
-	registeredPortsServer1 := []int32{20002, 20004, 20006, 20008}
-	registeredPortsServer2 := []int32{}
+	ports, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server5", Count: 4})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int32{20002, 20004, 20006, 20008}, ports)
 
-	if displayVariablesDuringTesting {
-		portRegistry.displayRegistry()
-	}
+	// releasing twice or releasing an unknown owner is a no-op
+	portRegistry.Release("default/default1")
+	portRegistry.Release("default/unknown")
+	assert.Len(t, portRegistry.Snapshot().Pools[""].Ports, 11)
+}
 
-	verifyGameServerPortsExist(portRegistry, "server1", registeredPortsServer1, t)
+func TestPortRegistryAllocateTwice(t *testing.T) {
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20010, PortRegistryOptions{})
 
-	verifyUnregisteredPorts(portRegistry, t)
+	_, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/server1", Count: 1})
+	assert.NoError(t, err)
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server1", Count: 1})
+	assert.Error(t, err)
+}
 
-	if displayVariablesDuringTesting {
-		portRegistry.displayRegistry()
-	}
+func TestPortRegistryInvalidOptions(t *testing.T) {
+	dgsClient := fake.NewSimpleClientset()
 
-	go portRegistry.portProducer()
-	// end of initialization
+	_, err := NewPortRegistry(dgsClient, 20010, 20000, metav1.NamespaceAll, PortRegistryOptions{})
+	assert.Error(t, err)
+	_, err = NewPortRegistry(dgsClient, 20000, 20010, metav1.NamespaceAll, PortRegistryOptions{Scope: "Galaxy"})
+	assert.Error(t, err)
+	_, err = NewPortRegistry(dgsClient, 20000, 20010, metav1.NamespaceAll, PortRegistryOptions{Scope: NodePortRegistryScope})
+	assert.Error(t, err)
+}
 
-	peekPort, err := peekNextPort(portRegistry)
-	actualPort, _ := portRegistry.GetNewPort()
-	registeredPortsServer1 = append(registeredPortsServer1, actualPort)
-	if actualPort != peekPort {
-		t.Errorf("Wrong port returned, peekPort:%d,actualPort:%d", peekPort, actualPort)
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
-		t.Errorf("Error:%s", err.Error())
+func TestPortRegistryNodeScope(t *testing.T) {
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20001, PortRegistryOptions{
+		Scope:      NodePortRegistryScope,
+		NodeLister: newNodeLister(map[string]string{"node1": "a", "node2": "a", "node3": "b"}),
+	})
+
+	// every port can be used once on each of the 3 Nodes
+	for i := 0; i < 3; i++ {
+		ports, err := portRegistry.Allocate(PortAllocationRequest{Owner: fmt.Sprintf("default/server%d", i), Count: 2})
+		assert.NoError(t, err)
+		// the ports of a DGS are distinct
+		assert.ElementsMatch(t, []int32{20000, 20001}, ports)
 	}
 
-	peekPort, err = peekNextPort(portRegistry)
-	actualPort, _ = portRegistry.GetNewPort()
-	registeredPortsServer1 = append(registeredPortsServer1, actualPort)
-	if actualPort != peekPort {
-		t.Errorf("Wrong port returned, peekPort:%d,actualPort:%d", peekPort, actualPort)
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
-		t.Errorf("Error:%s", err.Error())
-	}
+	_, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/server3", Count: 1})
+	assert.Error(t, err)
 
-	peekPort, err = peekNextPort(portRegistry)
-	actualPort, _ = portRegistry.GetNewPort()
-	registeredPortsServer1 = append(registeredPortsServer1, actualPort)
-	if actualPort != peekPort {
-		t.Errorf("Wrong port returned, peekPort:%d,actualPort:%d", peekPort, actualPort)
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
-		t.Errorf("Error:%s", err.Error())
-	}
+	snapshot := portRegistry.Snapshot()
+	assert.Equal(t, 3, snapshot.Pools[""].Capacity)
+	assert.Equal(t, map[int32]int{20000: 3, 20001: 3}, snapshot.Pools[""].Ports)
 
-	peekPort, err = peekNextPort(portRegistry)
-	actualPort, _ = portRegistry.GetNewPort()
-	registeredPortsServer1 = append(registeredPortsServer1, actualPort)
-	if actualPort != peekPort {
-		t.Errorf("Wrong port returned, peekPort:%d,actualPort:%d", peekPort, actualPort)
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
-		t.Errorf("Error:%s", err.Error())
-	}
+	portRegistry.Release("default/server1")
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server3", Count: 2})
+	assert.NoError(t, err)
+}
 
-	peekPort, err = peekNextPort(portRegistry)
-	actualPort, _ = portRegistry.GetNewPort()
-	registeredPortsServer2 = append(registeredPortsServer2, actualPort)
-	if actualPort != peekPort {
-		t.Errorf("Wrong port returned, peekPort:%d,actualPort:%d", peekPort, actualPort)
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
-		t.Errorf("Error:%s", err.Error())
-	}
+func TestPortRegistryNodePoolScope(t *testing.T) {
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20000, PortRegistryOptions{
+		Scope:         NodePoolPortRegistryScope,
+		NodeLister:    newNodeLister(map[string]string{"node1": "a", "node2": "a", "node3": "b"}),
+		NodePoolLabel: "agentpool",
+	})
 
-	peekPort, err = peekNextPort(portRegistry)
-	actualPort, _ = portRegistry.GetNewPort()
-	registeredPortsServer2 = append(registeredPortsServer2, actualPort)
-	if actualPort != peekPort {
-		t.Errorf("Wrong port returned, peekPort:%d,actualPort:%d", peekPort, actualPort)
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
-		t.Errorf("Error:%s", err.Error())
-	}
+	assert.Equal(t, "a", portRegistry.NodePoolFor(&corev1.PodSpec{NodeSelector: map[string]string{"agentpool": "a"}}))
+	assert.Equal(t, "", portRegistry.NodePoolFor(&corev1.PodSpec{}))
 
-	peekPort, err = peekNextPort(portRegistry)
-	actualPort, _ = portRegistry.GetNewPort() //for server2
-	registeredPortsServer2 = append(registeredPortsServer2, actualPort)
-	if actualPort != peekPort {
-		t.Errorf("Wrong port returned, peekPort:%d,actualPort:%d", peekPort, actualPort)
-	}
-	if err != nil {
-		t.Errorf("Error should be nil")
-		t.Errorf("Error:%s", err.Error())
+	// pool a has two Nodes, pool b has one
+	for i := 0; i < 2; i++ {
+		_, err := portRegistry.Allocate(PortAllocationRequest{Owner: fmt.Sprintf("default/a%d", i), Count: 1, NodePool: "a"})
+		assert.NoError(t, err)
 	}
+	_, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/a2", Count: 1, NodePool: "a"})
+	assert.Error(t, err)
 
-	verifyGameServerPortsExist(portRegistry, "server1", registeredPortsServer1, t)
-	verifyGameServerPortsExist(portRegistry, "server2", registeredPortsServer2, t)
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/b0", Count: 1, NodePool: "b"})
+	assert.NoError(t, err)
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/b1", Count: 1, NodePool: "b"})
+	assert.Error(t, err)
 
-	if displayVariablesDuringTesting {
-		portRegistry.displayRegistry()
-	}
+	snapshot := portRegistry.Snapshot()
+	assert.Equal(t, 2, snapshot.Pools["a"].Capacity)
+	assert.Equal(t, 1, snapshot.Pools["b"].Capacity)
+}
 
-	_, err = peekNextPort(portRegistry)
-	if err == nil {
-		t.Errorf("Error should not be nil")
-		t.Errorf("Error:%s", err.Error())
-	}
+func TestPortRegistryPortRange(t *testing.T) {
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20010, PortRegistryOptions{})
 
-	_, err = portRegistry.GetNewPort()
-	if err == nil {
-		t.Error("Should return an error")
-	}
+	// the range may be outside the range of the PortRegistry
+	ports, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/server1", Count: 3, Min: 7000, Max: 7002})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []int32{7000, 7001, 7002}, ports)
+
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server2", Count: 1, Min: 7000, Max: 7002})
+	assert.Error(t, err)
+
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server2", Count: 1, Min: 7002, Max: 7000})
+	assert.Error(t, err)
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server2", Count: 1, Min: 65535, Max: 65536})
+	assert.Error(t, err)
+}
+
+func TestPortRegistryBlocks(t *testing.T) {
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20010, PortRegistryOptions{})
+
+	// the free ports of 7000-7005 are 7001, 7002, 7004 and 7005
+	_, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/taken", Ports: []int32{7000, 7003}})
+	assert.NoError(t, err)
 
-	if displayVariablesDuringTesting {
-		portRegistry.displayRegistry()
+	var blocks [][]int32
+	for i := 0; i < 2; i++ {
+		ports, err := portRegistry.Allocate(PortAllocationRequest{Owner: fmt.Sprintf("default/server%d", i), Count: 2, BlockSize: 2, Min: 7000, Max: 7005})
+		assert.NoError(t, err)
+		blocks = append(blocks, ports)
+	}
+	assert.ElementsMatch(t, [][]int32{{7001, 7002}, {7004, 7005}}, blocks)
+
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server2", Count: 2, BlockSize: 2, Min: 7000, Max: 7005})
+	assert.Error(t, err)
+
+	// two blocks of two adjacent ports
+	for i := 0; i < 20; i++ {
+		owner := fmt.Sprintf("default/blocks%d", i)
+		ports, err := portRegistry.Allocate(PortAllocationRequest{Owner: owner, Count: 4, BlockSize: 2})
+		assert.NoError(t, err)
+		if assert.Len(t, ports, 4) {
+			assert.Equal(t, ports[0]+1, ports[1])
+			assert.Equal(t, ports[2]+1, ports[3])
+		}
+		portRegistry.Release(owner)
 	}
 
-	portRegistry.DeregisterServerPorts(registeredPortsServer2)
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server3", Count: 3, BlockSize: 2})
+	assert.Error(t, err)
+}
 
-	verifyGameServerPortsDoNotExist(portRegistry, "server2", registeredPortsServer2, t)
+func TestPortRegistryRequestedPorts(t *testing.T) {
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20010, PortRegistryOptions{
+		Scope:      NodePortRegistryScope,
+		NodeLister: newNodeLister(map[string]string{"node1": "a", "node2": "a"}),
+	})
 
-	if displayVariablesDuringTesting {
-		portRegistry.displayRegistry()
+	for i := 0; i < 2; i++ {
+		ports, err := portRegistry.Allocate(PortAllocationRequest{Owner: fmt.Sprintf("default/server%d", i), Ports: []int32{7777, 7778}})
+		assert.NoError(t, err)
+		assert.Equal(t, []int32{7777, 7778}, ports)
 	}
 
-	peekPort, err = peekNextPort(portRegistry)
-	actualPort, _ = portRegistry.GetNewPort()
-	if actualPort != peekPort {
-		t.Errorf("Wrong port returned, peekPort:%d,actualPort:%d", peekPort, actualPort)
+	// every port can be used once on each of the 2 Nodes
+	_, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/server2", Ports: []int32{7778}})
+	assert.Error(t, err)
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/server2", Ports: []int32{7779, 7779}})
+	assert.Error(t, err)
+	_, ok := portRegistry.Snapshot().Owners["default/server2"]
+	assert.False(t, ok)
+}
+
+func TestPortRegistryMaxAllocations(t *testing.T) {
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20009, PortRegistryOptions{})
+
+	tests := []struct {
+		name     string
+		request  PortAllocationRequest
+		expected int
+	}{
+		{name: "default range", request: PortAllocationRequest{Count: 3}, expected: 3},
+		{name: "custom range", request: PortAllocationRequest{Count: 1, Min: 7000, Max: 7099}, expected: 100},
+		{name: "blocks", request: PortAllocationRequest{Count: 4, BlockSize: 2, Min: 7000, Max: 7010}, expected: 2},
+		{name: "requested ports", request: PortAllocationRequest{Ports: []int32{7777}}, expected: 1},
 	}
-	if err != nil {
-		t.Errorf("Error should be nil")
-		t.Errorf("Error:%s", err.Error())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			max, err := portRegistry.MaxAllocations(tt.request)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, max)
+		})
 	}
 
-	registeredPortsServer1 = append(registeredPortsServer1, actualPort)
-	verifyGameServerPortsExist(portRegistry, "server1", registeredPortsServer1, t)
+	_, err := portRegistry.MaxAllocations(PortAllocationRequest{Count: 3, BlockSize: 2})
+	assert.Error(t, err)
+
+	nodePortRegistry := newPortRegistryWithDGSs(t, 20000, 20009, PortRegistryOptions{
+		Scope:      NodePortRegistryScope,
+		NodeLister: newNodeLister(map[string]string{"node1": "a", "node2": "a", "node3": "a"}),
+	})
+	max, err := nodePortRegistry.MaxAllocations(PortAllocationRequest{Count: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 15, max)
+	max, err = nodePortRegistry.MaxAllocations(PortAllocationRequest{Ports: []int32{7777}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, max)
+}
 
-	if displayVariablesDuringTesting {
-		portRegistry.displayRegistry()
-	}
+func TestPortRegistryRecordedAllocation(t *testing.T) {
+	// the recorded allocation is preferred over the HostPorts of the Pod Template
+	server := shared.NewDedicatedGameServerWithNoParent(shared.GameNamespace, "recorded", corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:  "test",
+				Ports: []corev1.ContainerPort{{ContainerPort: 80, HostPort: 20001}},
+			},
+		},
+	}, []intstr.IntOrString{intstr.FromInt(80)})
+	server.Spec.PortAllocation = &dgsv1alpha1.DGSPortAllocation{HostPorts: []int32{20005}}
 
-	portRegistry.Stop()
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20010, PortRegistryOptions{}, server)
 
+	assert.Equal(t, []int32{20005}, portRegistry.Snapshot().Owners["default/recorded"])
 }
 
-func verifyGameServerPortsExist(portRegistry *PortRegistry, serverName string, ports []int32, t *testing.T) {
-	for _, port := range ports {
-		valB, ok := portRegistry.Ports[port]
+func TestPortRegistryReconcile(t *testing.T) {
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20010, PortRegistryOptions{})
+	clock := clockwork.NewFakeClock()
+	portRegistry.clock = clock
 
-		if !ok {
-			t.Errorf("There should be an entry about port %d", port)
-		}
+	_, err := portRegistry.Allocate(PortAllocationRequest{Owner: "default/stale", Count: 2})
+	assert.NoError(t, err)
 
-		if !valB {
-			t.Errorf("Port %d should be registered", port)
-		}
+	clock.Advance(2 * time.Minute)
+
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/recent", Count: 1})
+	assert.NoError(t, err)
+	_, err = portRegistry.Allocate(PortAllocationRequest{Owner: "default/changed", Count: 1})
+	assert.NoError(t, err)
 
+	// the expected HostPorts are outside the range of the PortRegistry, so they always differ from the allocated ones
+	expected := map[string]dgsv1alpha1.DGSPortAllocation{
+		"default/changed": {HostPorts: []int32{7001}},
+		"default/missing": {HostPorts: []int32{7002}},
 	}
 
+	registered, released := portRegistry.Reconcile(expected, time.Minute)
+	assert.Equal(t, []string{"default/changed", "default/missing"}, registered)
+	// the recent allocation is kept, since its DGS may not be known yet
+	assert.Equal(t, []string{"default/stale"}, released)
+
+	snapshot := portRegistry.Snapshot()
+	_, ok := snapshot.Owners["default/stale"]
+	assert.False(t, ok)
+	assert.Len(t, snapshot.Owners["default/recent"], 1)
+	assert.Equal(t, []int32{7001}, snapshot.Owners["default/changed"])
+	assert.Equal(t, []int32{7002}, snapshot.Owners["default/missing"])
+
+	// only the allocations that are no longer expected change in a second run
+	clock.Advance(2 * time.Minute)
+	delete(expected, "default/missing")
+	expected["default/recent"] = dgsv1alpha1.DGSPortAllocation{HostPorts: snapshot.Owners["default/recent"]}
+	registered, released = portRegistry.Reconcile(expected, time.Minute)
+	assert.Empty(t, registered)
+	assert.Equal(t, []string{"default/missing"}, released)
 }
 
-func verifyUnregisteredPorts(portRegistry *PortRegistry, t *testing.T) {
-	if len(portRegistry.Ports) != int(portRegistry.Max-portRegistry.Min+1) {
-		t.Errorf("Wrong length on portregistry ports")
+// TestPortRegistryConcurrentUse should be run with -race
+func TestPortRegistryConcurrentUse(t *testing.T) {
+	const workers = 8
+	const iterations = 200
+
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20000+workers*4-1, PortRegistryOptions{})
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				owner := fmt.Sprintf("default/server-%d-%d", w, i)
+				if _, err := portRegistry.Allocate(PortAllocationRequest{Owner: owner, Count: 4}); err != nil {
+					errCh <- err
+					return
+				}
+				portRegistry.Snapshot()
+				portRegistry.Release(owner)
+			}
+		}(w)
 	}
-}
-
-func verifyGameServerPortsDoNotExist(portRegistry *PortRegistry, serverName string, ports []int32, t *testing.T) {
-	for _, port := range ports {
-		valB, ok := portRegistry.Ports[port]
-		if !ok {
-			t.Errorf("There should be an entry about port %d", port)
-		}
+	wg.Wait()
+	close(errCh)
 
-		if valB {
-			t.Errorf("Port %d should not be registered", port)
-		}
+	// every worker holds at most 4 ports at a time, so there are always enough ports
+	for err := range errCh {
+		t.Error(err)
 	}
-}
 
-func peekNextPort(pr *PortRegistry) (int32, error) {
-	tempPointer := pr.NextFreePortIndex
-	tempPointerCopy := tempPointer
-	for {
-		if !pr.Ports[pr.Indexes[tempPointer]] { //port not taken
-			return pr.Indexes[tempPointer], nil
-		}
+	snapshot := portRegistry.Snapshot()
+	assert.Len(t, snapshot.Owners, 0)
+	assert.Len(t, snapshot.Pools[""].Ports, 0)
+}
 
-		tempPointer++
-		if tempPointer == (pr.Max - pr.Min + 1) {
-			tempPointer = 0
-		}
+// TestPortRegistryConcurrentAllocationsAreUnique should be run with -race
+func TestPortRegistryConcurrentAllocationsAreUnique(t *testing.T) {
+	const workers = 10
+	const allocations = 100
+
+	portRegistry := newPortRegistryWithDGSs(t, 20000, 20000+workers*allocations-1, PortRegistryOptions{})
+
+	var wg sync.WaitGroup
+	results := make([][]int32, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < allocations; i++ {
+				ports, err := portRegistry.Allocate(PortAllocationRequest{Owner: fmt.Sprintf("default/server-%d-%d", w, i), Count: 1})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				results[w] = append(results[w], ports...)
+			}
+		}(w)
+	}
+	wg.Wait()
 
-		if tempPointer == tempPointerCopy {
-			//we've done a full circle, no ports available
-			return 0, errors.New("No ports available")
+	seen := make(map[int32]bool)
+	for _, ports := range results {
+		for _, port := range ports {
+			assert.False(t, seen[port], "port %d was allocated twice", port)
+			seen[port] = true
 		}
 	}
+	assert.Len(t, seen, workers*allocations)
 }
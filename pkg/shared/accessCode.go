@@ -1,39 +1,208 @@
 package shared
 
 import (
+	"crypto/subtle"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
 	log "github.com/sirupsen/logrus"
-	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
 )
 
-// AuthenticateWebServerCode authenticates the user request by comparing the given code with the actual
-func AuthenticateWebServerCode(code string) (bool, error) {
-	if accesscode == "" {
-		client, _, err := GetClientSet()
-		if err != nil {
-			return false, err
+// DefaultAccessCodeOverlap is the default time that the previous access code is accepted after a rotation
+const DefaultAccessCodeOverlap = 5 * time.Minute
+
+// AccessCodeStatus describes the access codes that the API Server accepts. It never contains the codes
+type AccessCodeStatus struct {
+	// SecretFound is true if the apiaccesscode Secret exists
+	SecretFound bool `json:"secretFound"`
+	// LastRotationTime is the last time that the code of the Secret changed
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// ValidCodes is the number of accepted codes: the current one and the previous ones within the overlap window
+	ValidCodes int `json:"validCodes"`
+	// OverlapEndTime is the time that the last previous code stops being accepted, if any
+	OverlapEndTime *metav1.Time `json:"overlapEndTime,omitempty"`
+}
+
+// previousAccessCode is a rotated access code, which is accepted until validUntil
+type previousAccessCode struct {
+	code       string
+	validUntil time.Time
+}
+
+// AccessCodeStore watches the apiaccesscode Secret and keeps the access codes that are currently valid
+// When the code of the Secret changes, the previous code is still accepted for the overlap window,
+// so that the callers can switch to the new code without downtime
+type AccessCodeStore struct {
+	client    kubernetes.Interface
+	namespace string
+	overlap   time.Duration
+	clock     clockwork.Clock
+
+	mu           sync.RWMutex
+	secretFound  bool
+	current      string
+	previous     []previousAccessCode
+	lastRotation time.Time
+}
+
+// NewAccessCodeStore returns an AccessCodeStore for the apiaccesscode Secret of the namespace
+func NewAccessCodeStore(client kubernetes.Interface, namespace string, overlap time.Duration) *AccessCodeStore {
+	return &AccessCodeStore{
+		client:    client,
+		namespace: namespace,
+		overlap:   overlap,
+		clock:     clockwork.NewRealClock(),
+	}
+}
+
+// Run watches the Secret until stopCh is closed
+// A missing Secret is not an error, no code is accepted until it is created
+func (s *AccessCodeStore) Run(stopCh <-chan struct{}) {
+	selector := fields.OneTermEqualSelector("metadata.name", APIAccessCodeSecretName).String()
+	_, controller := cache.NewInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return s.client.CoreV1().Secrets(s.namespace).List(options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return s.client.CoreV1().Secrets(s.namespace).Watch(options)
+		},
+	}, &corev1.Secret{}, 0, cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			s.setSecret(obj)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			s.setSecret(newObj)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if secret, ok := obj.(*corev1.Secret); ok && secret.Name == APIAccessCodeSecretName {
+				log.Warnf("Secret %s/%s was deleted, the access code is not accepted after the overlap window", s.namespace, APIAccessCodeSecretName)
+				s.setCode(false, "")
+			}
+		},
+	})
+
+	go func() {
+		if !cache.WaitForCacheSync(stopCh, controller.HasSynced) {
+			return
 		}
-		_, err = GetAccessCode(client)
-		if err != nil {
-			return false, err
+		if !s.Status().SecretFound {
+			log.Warnf("Secret %s/%s does not exist, API calls with an access code are rejected until it is created", s.namespace, APIAccessCodeSecretName)
 		}
+	}()
+
+	controller.Run(stopCh)
+}
+
+// Authenticate returns true if the code is the current access code, or a previous one within the overlap window
+func (s *AccessCodeStore) Authenticate(code string) bool {
+	if code == "" {
+		return false
 	}
-	return code == accesscode, nil
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// all the codes are compared, so that the time of the comparison does not reveal which one matched
+	valid := subtle.ConstantTimeCompare([]byte(code), []byte(s.current)) == 1
+	now := s.clock.Now()
+	for _, previous := range s.previous {
+		if now.Before(previous.validUntil) && subtle.ConstantTimeCompare([]byte(code), []byte(previous.code)) == 1 {
+			valid = true
+		}
+	}
+	return valid
 }
 
-var accesscode string
+// Status returns the rotation status of the access codes
+func (s *AccessCodeStore) Status() AccessCodeStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-func GetAccessCode(client kubernetes.Interface) (string, error) {
-	if accesscode == "" { //if we haven't accessed the code
-		// client, _, err := GetClientSet()
-		// if err != nil {
-		// 	return "", err
-		// }
-		secret, err := client.Core().Secrets(GameNamespace).Get(APIAccessCodeSecretName, meta_v1.GetOptions{})
-		if err != nil {
-			log.Fatalf("Cannot get API Server access code due to %s", err.Error())
+	status := AccessCodeStatus{SecretFound: s.secretFound}
+	if s.current != "" {
+		status.ValidCodes++
+	}
+	if !s.lastRotation.IsZero() {
+		status.LastRotationTime = &metav1.Time{Time: s.lastRotation}
+	}
+	now := s.clock.Now()
+	for _, previous := range s.previous {
+		if !now.Before(previous.validUntil) {
+			continue
+		}
+		status.ValidCodes++
+		if status.OverlapEndTime == nil || previous.validUntil.After(status.OverlapEndTime.Time) {
+			status.OverlapEndTime = &metav1.Time{Time: previous.validUntil}
+		}
+	}
+	return status
+}
+
+func (s *AccessCodeStore) setSecret(obj interface{}) {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || secret.Name != APIAccessCodeSecretName {
+		return
+	}
+	s.setCode(true, string(secret.Data["code"]))
+}
+
+// setCode sets the current code. If it changed, the previous code is accepted for the overlap window
+func (s *AccessCodeStore) setCode(secretFound bool, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.secretFound = secretFound
+	if code == s.current {
+		return
+	}
+
+	now := s.clock.Now()
+	var previous []previousAccessCode
+	for _, p := range s.previous {
+		if now.Before(p.validUntil) && p.code != code {
+			previous = append(previous, p)
 		}
-		accesscode = string(secret.Data["code"])
 	}
-	return accesscode, nil
+	if s.current != "" {
+		previous = append(previous, previousAccessCode{code: s.current, validUntil: now.Add(s.overlap)})
+		s.lastRotation = now
+		log.Infof("The access code was rotated, the previous one is accepted until %s", now.Add(s.overlap).Format(time.RFC3339))
+	}
+	s.previous = previous
+	s.current = code
+}
+
+var accessCodeStore *AccessCodeStore
+
+// SetAccessCodeStore sets the AccessCodeStore that authenticates the access codes of the API calls
+func SetAccessCodeStore(store *AccessCodeStore) {
+	accessCodeStore = store
+}
+
+// GetAccessCodeStore returns the AccessCodeStore that authenticates the access codes of the API calls, if it has been set
+func GetAccessCodeStore() *AccessCodeStore {
+	return accessCodeStore
+}
+
+// AuthenticateWebServerCode authenticates the user request by comparing the given code with the currently valid ones
+func AuthenticateWebServerCode(code string) (bool, error) {
+	if accessCodeStore == nil {
+		return false, fmt.Errorf("the access code store has not been set")
+	}
+	return accessCodeStore.Authenticate(code), nil
 }
@@ -1,19 +1,123 @@
 package shared
 
-import "testing"
+import (
+	"testing"
+	"time"
 
-func TestGetAccessCode(t *testing.T) {
-	accesscode = "code123!"
-	code, _ := GetAccessCode(nil)
-	if code != accesscode {
-		t.Error("Codes should be the same")
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newAccessCodeSecret(code string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: APIAccessCodeSecretName, Namespace: GameNamespace},
+		Data:       map[string][]byte{"code": []byte(code)},
 	}
 }
 
+func TestAccessCodeRotation(t *testing.T) {
+	clock := clockwork.NewFakeClock()
+	store := NewAccessCodeStore(nil, GameNamespace, time.Minute)
+	store.clock = clock
+
+	store.setSecret(newAccessCodeSecret("code1"))
+	assert.True(t, store.Authenticate("code1"))
+	assert.False(t, store.Authenticate("code2"))
+	assert.False(t, store.Authenticate(""))
+	assert.Equal(t, AccessCodeStatus{SecretFound: true, ValidCodes: 1}, store.Status())
+
+	// both codes are accepted during the overlap window
+	clock.Advance(time.Second)
+	rotationTime := clock.Now()
+	store.setSecret(newAccessCodeSecret("code2"))
+	assert.True(t, store.Authenticate("code1"))
+	assert.True(t, store.Authenticate("code2"))
+	assert.Equal(t, AccessCodeStatus{
+		SecretFound:      true,
+		LastRotationTime: &metav1.Time{Time: rotationTime},
+		ValidCodes:       2,
+		OverlapEndTime:   &metav1.Time{Time: rotationTime.Add(time.Minute)},
+	}, store.Status())
+
+	// an update that does not change the code does not extend the overlap window
+	clock.Advance(30 * time.Second)
+	store.setSecret(newAccessCodeSecret("code2"))
+	clock.Advance(30 * time.Second)
+	assert.False(t, store.Authenticate("code1"))
+	assert.True(t, store.Authenticate("code2"))
+	assert.Equal(t, AccessCodeStatus{
+		SecretFound:      true,
+		LastRotationTime: &metav1.Time{Time: rotationTime},
+		ValidCodes:       1,
+	}, store.Status())
+}
+
+func TestAccessCodeRotationWithoutOverlap(t *testing.T) {
+	store := NewAccessCodeStore(nil, GameNamespace, 0)
+
+	store.setSecret(newAccessCodeSecret("code1"))
+	store.setSecret(newAccessCodeSecret("code2"))
+	assert.False(t, store.Authenticate("code1"))
+	assert.True(t, store.Authenticate("code2"))
+}
+
+func TestAccessCodeStoreWatchesSecret(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	store := NewAccessCodeStore(client, GameNamespace, time.Minute)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go store.Run(stopCh)
+
+	// a missing Secret is not fatal, no code is accepted until it is created
+	assert.False(t, store.Authenticate("code1"))
+	assert.False(t, store.Status().SecretFound)
+
+	_, err := client.CoreV1().Secrets(GameNamespace).Create(newAccessCodeSecret("code1"))
+	assert.NoError(t, err)
+	err = wait.Poll(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		return store.Authenticate("code1"), nil
+	})
+	assert.NoError(t, err)
+
+	// Secrets with other names are ignored
+	other := newAccessCodeSecret("other")
+	other.Name = "other"
+	_, err = client.CoreV1().Secrets(GameNamespace).Create(other)
+	assert.NoError(t, err)
+
+	_, err = client.CoreV1().Secrets(GameNamespace).Update(newAccessCodeSecret("code2"))
+	assert.NoError(t, err)
+	err = wait.Poll(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		return store.Authenticate("code2"), nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, store.Authenticate("code1"))
+	assert.False(t, store.Authenticate("other"))
+
+	err = client.CoreV1().Secrets(GameNamespace).Delete(APIAccessCodeSecretName, &metav1.DeleteOptions{})
+	assert.NoError(t, err)
+	err = wait.Poll(10*time.Millisecond, wait.ForeverTestTimeout, func() (bool, error) {
+		return !store.Status().SecretFound, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, store.Status().ValidCodes)
+}
+
 func TestAuthenticateWebServerCode(t *testing.T) {
-	accesscode = "code123!"
-	result, _ := AuthenticateWebServerCode("code123!")
-	if !result {
-		t.Error("Should be true")
-	}
+	defer SetAccessCodeStore(nil)
+
+	_, err := AuthenticateWebServerCode("code123!")
+	assert.Error(t, err)
+
+	store := NewAccessCodeStore(nil, GameNamespace, time.Minute)
+	store.setSecret(newAccessCodeSecret("code123!"))
+	SetAccessCodeStore(store)
+	result, err := AuthenticateWebServerCode("code123!")
+	assert.NoError(t, err)
+	assert.True(t, result)
 }
@@ -0,0 +1,132 @@
+package shared
+
+import (
+	"errors"
+	"math/rand"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	dgsclientsetversioned "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
+
+	log "github.com/sirupsen/logrus"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// ErrNoCapacity is returned when there is no Idle DedicatedGameServer that can fulfill an allocation
+var ErrNoCapacity = errors.New("no Idle DedicatedGameServer is available for allocation")
+
+// AllocateDedicatedGameServer picks one of the ready and Idle DedicatedGameServers that match the allocation
+// and transitions it to the Assigned state. The update is guarded by the resourceVersion of the DGS, so two concurrent
+// allocations can never get the same DGS. Returns ErrNoCapacity if there is no DGS that can be allocated
+// The DGS records the UID of the allocation, so if the allocation has a UID and it has already assigned a DGS
+// (e.g. its status could not be updated afterwards), the same DGS is returned instead of a new one
+func AllocateDedicatedGameServer(dgsClient dgsclientsetversioned.Interface, dgsAlloc *dgsv1alpha1.DedicatedGameServerAllocation) (*dgsv1alpha1.DedicatedGameServer, error) {
+	namespace := dgsAlloc.Namespace
+	if namespace == "" {
+		namespace = DefaultGameNamespace()
+	}
+
+	selector, err := getAllocationSelector(dgsAlloc)
+	if err != nil {
+		return nil, err
+	}
+
+	// list all the DGSs, since the one that has already been assigned may no longer be ready or Assigned
+	// (e.g. the game server may have reported that it is Running before the allocation is retried)
+	dgss, err := ListDedicatedGameServers(dgsClient, namespace, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if dgsAlloc.UID != "" {
+		for i := range dgss {
+			if dgss[i].Status.AllocationUID == dgsAlloc.UID {
+				log.WithFields(log.Fields{"DGSName": dgss[i].Name, "DGSAllocationName": dgsAlloc.Name}).Info("DGS has already been assigned to the allocation")
+				return &dgss[i], nil
+			}
+		}
+	}
+
+	candidates := make([]dgsv1alpha1.DedicatedGameServer, 0)
+	for _, dgs := range dgss {
+		if IsDGSReady(&dgs) && dgs.Status.DGSState == dgsv1alpha1.DGSIdle && selector.Matches(labels.Set(dgs.Labels)) {
+			candidates = append(candidates, dgs)
+		}
+	}
+
+	// try the candidates in random order, so concurrent allocations are less likely to compete for the same DGS
+	assigned := dgsv1alpha1.DGSAssigned
+	for _, i := range rand.Perm(len(candidates)) {
+		dgsToUpdate := candidates[i].DeepCopy()
+		setDGSStatusFields(dgsToUpdate, DGSStatusFields{DGSState: &assigned})
+		dgsToUpdate.Status.AllocationUID = dgsAlloc.UID
+
+		// dgsToUpdate carries the resourceVersion we listed, so the update will fail if the DGS has changed since then
+		dgsUpdated, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).UpdateStatus(dgsToUpdate)
+		if err != nil {
+			if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+				log.WithField("DGSName", dgsToUpdate.Name).Info("DGS was modified during allocation, trying the next one")
+				continue
+			}
+			return nil, err
+		}
+		return dgsUpdated, nil
+	}
+
+	return nil, ErrNoCapacity
+}
+
+// NewAllocatedStatus returns the status of a DedicatedGameServerAllocation that was fulfilled by the designated DGS
+func NewAllocatedStatus(dgs *dgsv1alpha1.DedicatedGameServer) dgsv1alpha1.DedicatedGameServerAllocationStatus {
+	return dgsv1alpha1.DedicatedGameServerAllocationStatus{
+		State:                   dgsv1alpha1.DGSAllocationAllocated,
+		DedicatedGameServerName: dgs.Name,
+		PublicIP:                dgs.Status.PublicIP,
+		NodeName:                dgs.Status.NodeName,
+		Ports:                   GetDGSPorts(dgs),
+		Address:                 dgs.Status.Address,
+	}
+}
+
+// GetDGSPorts returns the container ports of the DGS that are exposed on the Node
+func GetDGSPorts(dgs *dgsv1alpha1.DedicatedGameServer) []dgsv1alpha1.DGSPort {
+	ports := make([]dgsv1alpha1.DGSPort, 0)
+	for k, container := range dgs.Spec.Template.Containers {
+		for _, portInfo := range container.Ports {
+			if portInfo.HostPort != 0 && IsPortExposed(&dgs.Spec.Template.Containers[k], portInfo.ContainerPort, dgs.Spec.PortsToExpose) {
+				ports = append(ports, dgsv1alpha1.DGSPort{
+					Name:          portInfo.Name,
+					ContainerPort: portInfo.ContainerPort,
+					HostPort:      portInfo.HostPort,
+					Protocol:      portInfo.Protocol,
+				})
+			}
+		}
+	}
+	return ports
+}
+
+// getAllocationSelector returns the label selector that the allocated DGS should match
+func getAllocationSelector(dgsAlloc *dgsv1alpha1.DedicatedGameServerAllocation) (labels.Selector, error) {
+	selector := labels.Everything()
+	if dgsAlloc.Spec.Selector != nil {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(dgsAlloc.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dgsAlloc.Spec.DedicatedGameServerCollectionName != "" {
+		requirement, err := labels.NewRequirement(LabelDedicatedGameServerCollectionName, selection.Equals, []string{dgsAlloc.Spec.DedicatedGameServerCollectionName})
+		if err != nil {
+			return nil, err
+		}
+		selector = selector.Add(*requirement)
+	}
+
+	return selector, nil
+}
@@ -0,0 +1,180 @@
+package shared
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	core "k8s.io/client-go/testing"
+)
+
+func newReadyDGS(name string, dgsColName string, state dgsv1alpha1.DGSState) *dgsv1alpha1.DedicatedGameServer {
+	dgs := NewDedicatedGameServerWithNoParent(GameNamespace, name, corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name: "game",
+				Ports: []corev1.ContainerPort{
+					{Name: "gameport", ContainerPort: 7777, HostPort: 20001, Protocol: corev1.ProtocolUDP},
+					{Name: "metrics", ContainerPort: 9090},
+				},
+			},
+		},
+	}, []intstr.IntOrString{intstr.FromInt(7777)})
+	dgs.Labels = map[string]string{LabelDedicatedGameServerCollectionName: dgsColName}
+	dgs.Status.Health = dgsv1alpha1.DGSHealthy
+	dgs.Status.PodPhase = corev1.PodRunning
+	dgs.Status.DGSState = state
+	dgs.Status.PublicIP = "1.2.3.4"
+	dgs.Status.NodeName = "node1"
+	dgs.Status.Address = "1.2.3.4:20001"
+	return dgs
+}
+
+func TestAllocateDedicatedGameServer(t *testing.T) {
+	dgsAssigned := newReadyDGS("assigned", "col1", dgsv1alpha1.DGSAssigned)
+	dgsIdle := newReadyDGS("idle", "col1", dgsv1alpha1.DGSIdle)
+	dgsOtherCol := newReadyDGS("othercol", "col2", dgsv1alpha1.DGSIdle)
+	dgsMarked := newReadyDGS("marked", "col1", dgsv1alpha1.DGSIdle)
+	dgsMarked.Status.MarkedForDeletion = true
+
+	dgsClient := fake.NewSimpleClientset(dgsAssigned, dgsIdle, dgsOtherCol, dgsMarked)
+
+	dgsAlloc := &dgsv1alpha1.DedicatedGameServerAllocation{
+		Spec: dgsv1alpha1.DedicatedGameServerAllocationSpec{DedicatedGameServerCollectionName: "col1"},
+	}
+
+	dgs, err := AllocateDedicatedGameServer(dgsClient, dgsAlloc)
+	if err != nil {
+		t.Fatalf("Unexpected error allocating DGS: %v", err)
+	}
+	if dgs.Name != "idle" {
+		t.Errorf("Expected DGS idle to be allocated, got %s", dgs.Name)
+	}
+	if dgs.Status.DGSState != dgsv1alpha1.DGSAssigned {
+		t.Errorf("Expected allocated DGS to be Assigned, got %s", dgs.Status.DGSState)
+	}
+
+	status := NewAllocatedStatus(dgs)
+	if status.State != dgsv1alpha1.DGSAllocationAllocated || status.PublicIP != "1.2.3.4" || status.NodeName != "node1" || status.Address != "1.2.3.4:20001" {
+		t.Errorf("Unexpected allocation status %#v", status)
+	}
+	if len(status.Ports) != 1 || status.Ports[0].HostPort != 20001 || status.Ports[0].ContainerPort != 7777 || status.Ports[0].Name != "gameport" {
+		t.Errorf("Unexpected allocation ports %#v", status.Ports)
+	}
+
+	// the only Idle DGS of the collection is now Assigned
+	_, err = AllocateDedicatedGameServer(dgsClient, dgsAlloc)
+	if err != ErrNoCapacity {
+		t.Errorf("Expected ErrNoCapacity, got %v", err)
+	}
+}
+
+func TestAllocateDedicatedGameServerWithSelector(t *testing.T) {
+	dgsIdle := newReadyDGS("idle", "col1", dgsv1alpha1.DGSIdle)
+	dgsIdle.Labels["map"] = "desert"
+	dgsOther := newReadyDGS("other", "col1", dgsv1alpha1.DGSIdle)
+	dgsOther.Labels["map"] = "forest"
+
+	dgsClient := fake.NewSimpleClientset(dgsIdle, dgsOther)
+
+	dgsAlloc := &dgsv1alpha1.DedicatedGameServerAllocation{
+		Spec: dgsv1alpha1.DedicatedGameServerAllocationSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"map": "forest"}},
+		},
+	}
+
+	dgs, err := AllocateDedicatedGameServer(dgsClient, dgsAlloc)
+	if err != nil {
+		t.Fatalf("Unexpected error allocating DGS: %v", err)
+	}
+	if dgs.Name != "other" {
+		t.Errorf("Expected DGS other to be allocated, got %s", dgs.Name)
+	}
+}
+
+func TestAllocateDedicatedGameServerWithConflict(t *testing.T) {
+	dgsIdle1 := newReadyDGS("idle1", "col1", dgsv1alpha1.DGSIdle)
+	dgsIdle2 := newReadyDGS("idle2", "col1", dgsv1alpha1.DGSIdle)
+
+	dgsClient := fake.NewSimpleClientset(dgsIdle1, dgsIdle2)
+
+	// simulate a concurrent allocation that got idle1 first
+	dgsClient.PrependReactor("update", "dedicatedgameservers", func(action core.Action) (bool, runtime.Object, error) {
+		dgs := action.(core.UpdateAction).GetObject().(*dgsv1alpha1.DedicatedGameServer)
+		if dgs.Name == "idle1" {
+			return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "dedicatedgameservers"}, dgs.Name, nil)
+		}
+		return false, nil, nil
+	})
+
+	dgsAlloc := &dgsv1alpha1.DedicatedGameServerAllocation{}
+
+	dgs, err := AllocateDedicatedGameServer(dgsClient, dgsAlloc)
+	if err != nil {
+		t.Fatalf("Unexpected error allocating DGS: %v", err)
+	}
+	if dgs.Name != "idle2" {
+		t.Errorf("Expected DGS idle2 to be allocated, got %s", dgs.Name)
+	}
+}
+
+func TestAllocateDedicatedGameServerIsIdempotent(t *testing.T) {
+	dgsIdle1 := newReadyDGS("idle1", "col1", dgsv1alpha1.DGSIdle)
+	dgsIdle2 := newReadyDGS("idle2", "col1", dgsv1alpha1.DGSIdle)
+
+	dgsClient := fake.NewSimpleClientset(dgsIdle1, dgsIdle2)
+
+	dgsAlloc := &dgsv1alpha1.DedicatedGameServerAllocation{ObjectMeta: metav1.ObjectMeta{Name: "alloc", UID: "uid1"}}
+
+	dgs, err := AllocateDedicatedGameServer(dgsClient, dgsAlloc)
+	if err != nil {
+		t.Fatalf("Unexpected error allocating DGS: %v", err)
+	}
+	if dgs.Status.AllocationUID != "uid1" {
+		t.Errorf("Expected the allocated DGS to record the allocation UID, got %s", dgs.Status.AllocationUID)
+	}
+
+	// the allocation is retried, e.g. because its status could not be updated
+	dgsRetried, err := AllocateDedicatedGameServer(dgsClient, dgsAlloc)
+	if err != nil {
+		t.Fatalf("Unexpected error allocating DGS: %v", err)
+	}
+	if dgsRetried.Name != dgs.Name {
+		t.Errorf("Expected DGS %s to be allocated again, got %s", dgs.Name, dgsRetried.Name)
+	}
+
+	dgss, err := ListDedicatedGameServers(dgsClient, GameNamespace, false)
+	if err != nil {
+		t.Fatalf("Unexpected error listing DGSs: %v", err)
+	}
+	for _, dgs := range dgss {
+		if dgs.Name != dgsRetried.Name && dgs.Status.DGSState != dgsv1alpha1.DGSIdle {
+			t.Errorf("Expected DGS %s to stay Idle, got %s", dgs.Name, dgs.Status.DGSState)
+		}
+	}
+
+	// allocations without a UID, e.g. the ones of the API Server, never reuse a DGS
+	dgs, err = AllocateDedicatedGameServer(dgsClient, &dgsv1alpha1.DedicatedGameServerAllocation{})
+	if err != nil {
+		t.Fatalf("Unexpected error allocating DGS: %v", err)
+	}
+	if dgs.Name == dgsRetried.Name {
+		t.Errorf("Expected another DGS than %s to be allocated", dgsRetried.Name)
+	}
+}
+
+func TestAllocateDedicatedGameServerNoCapacity(t *testing.T) {
+	dgsClient := fake.NewSimpleClientset()
+
+	_, err := AllocateDedicatedGameServer(dgsClient, &dgsv1alpha1.DedicatedGameServerAllocation{})
+	if err != ErrNoCapacity {
+		t.Errorf("Expected ErrNoCapacity, got %v", err)
+	}
+}
@@ -8,8 +8,9 @@ const (
 )
 
 const (
-	DedicatedGameServerKind = "DedicatedGameServer"
-	GameNamespace           = "default"
+	DedicatedGameServerKind           = "DedicatedGameServer"
+	DedicatedGameServerCollectionKind = "DedicatedGameServerCollection"
+	GameNamespace                     = "default"
 
 	// MinPort is minimum Port Number
 	MinPort int32 = 20000
@@ -17,6 +18,12 @@ const (
 	MaxPort int32 = 30000
 
 	RandStringSize = 5
+
+	// DefaultRevisionHistoryLimit is the number of old Templates that are kept for a DedicatedGameServerCollection
+	DefaultRevisionHistoryLimit int32 = 10
+
+	// HostPortsFinalizer keeps a DedicatedGameServer until its Pod is gone and its HostPorts are released
+	HostPortsFinalizer = "azuregaming.com/hostports"
 )
 
 const (
@@ -24,6 +31,7 @@ const (
 	LabelDedicatedGameServerName                   = "DedicatedGameServerName"
 	LabelDedicatedGameServerCollectionName         = "DedicatedGameServerCollectionName"
 	LabelOriginalDedicatedGameServerCollectionName = "OriginalDedicatedGameServerCollectionName"
+	LabelDedicatedGameServerTemplateHash           = "DedicatedGameServerTemplateHash"
 )
 
 const (
@@ -47,4 +55,39 @@ const (
 
 	MessageMarkedForDeletionDedicatedGameServerDeleted = "Dedicated Game Server %s that was MarkedForDeletion with 0 Active Players was deleted"
 	MessageAutoscalingNotConfigured                    = "Autoscaling is not configured for DedicatedGameServerCollection %s"
+
+	DedicatedGameServerCollectionTemplateUpdated = "Dedicated Game Server Collection Template Updated"
+
+	MessageOldTemplateDedicatedGameServersReplaced = "%s with name %s replaced %d DedicatedGameServers that run an old template"
+
+	ActivePlayersAutoScalerScaled        = "ActivePlayers AutoScaler Scaled"
+	ActivePlayersAutoScalerMisconfigured = "ActivePlayers AutoScaler Misconfigured"
+
+	MessageActivePlayersAutoScalerScaled    = "DedicatedGameServerCollection %s was scaled from %d to %d replicas, %d ActivePlayers on %d DedicatedGameServers with %d MaxPlayersPerServer (load %d%%, ScaleInThreshold %d%%, ScaleOutThreshold %d%%)"
+	MessageActivePlayersAutoScalerScheduled = "DedicatedGameServerCollection %s was scaled from %d to %d replicas to be within the replica limits %d-%d (active scaling schedule: %s)"
+
+	BufferAutoScalerScaled        = "Buffer AutoScaler Scaled"
+	BufferAutoScalerMisconfigured = "Buffer AutoScaler Misconfigured"
+
+	MessageBufferAutoScalerScaled = "DedicatedGameServerCollection %s was scaled from %d to %d replicas, %d DedicatedGameServers were busy and %d were Idle"
+
+	WebhookAutoScalerScaled = "Webhook AutoScaler Scaled"
+	WebhookAutoScalerFailed = "Webhook AutoScaler Failed"
+
+	MessageWebhookAutoScalerScaled   = "DedicatedGameServerCollection %s was scaled from %d to %d replicas, webhook returned %d replicas"
+	MessageWebhookAutoScalerFailed   = "Webhook autoscaler call to %s failed: %s"
+	MessageWebhookAutoScalerFallback = "DedicatedGameServerCollection %s was scaled from %d to %d fallback replicas, since the webhook failed"
+
+	HostPortsReleased = "HostPorts Released"
+	PortPolicyInvalid = "Port Policy Invalid"
+
+	AddressTemplateInvalid = "Address Template Invalid"
+
+	MessageHostPortsReleased = "HostPorts of DedicatedGameServer %s were released, since its Pod is gone"
+
+	DedicatedGameServerAllocated   = "Dedicated Game Server Allocated"
+	DedicatedGameServerUnAllocated = "Dedicated Game Server UnAllocated"
+
+	MessageDedicatedGameServerAllocated   = "Dedicated Game Server %s was allocated for DedicatedGameServerAllocation %s"
+	MessageDedicatedGameServerUnAllocated = "No Idle Dedicated Game Server was available for DedicatedGameServerAllocation %s"
 )
@@ -0,0 +1,268 @@
+package shared
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DGSTokenKeysSecretName is the name of the Secret that contains the keys that sign the DedicatedGameServer tokens
+	// Every entry of the Secret is a key, named by its key ID, apart from the DGSTokenCurrentKeyField
+	DGSTokenKeysSecretName = "dgstokenkeys"
+	// DGSTokenCurrentKeyField is the entry of the Secret that contains the ID of the key that signs the new tokens
+	DGSTokenCurrentKeyField = "current"
+	// DGSTokenMinKeySize is the minimum size of a signing key, in bytes
+	DGSTokenMinKeySize = 32
+	// DGSTokenKeysRefreshInterval is the interval that the keys are read again from the Secret
+	DGSTokenKeysRefreshInterval = time.Minute
+	// dgsTokenKeysMinRefreshInterval is the minimum interval between two reads of the Secret, when a token is signed by an unknown key
+	dgsTokenKeysMinRefreshInterval = 5 * time.Second
+)
+
+// DGSIdentity is the DedicatedGameServer that a token is bound to
+type DGSIdentity struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// dgsTokenPayload is the signed part of a DedicatedGameServer token
+type dgsTokenPayload struct {
+	KeyID string `json:"kid"`
+	DGSIdentity
+}
+
+// DGSTokenKeys are the keys of the DGSTokenKeysSecretName Secret
+type DGSTokenKeys struct {
+	// CurrentKeyID is the ID of the key that signs the new tokens
+	CurrentKeyID string
+	// Keys contains all the keys that verify tokens, by key ID
+	Keys map[string][]byte
+}
+
+// NewDGSTokenKeys returns the keys of a DGSTokenKeysSecretName Secret
+func NewDGSTokenKeys(secret *corev1.Secret) (*DGSTokenKeys, error) {
+	keys := &DGSTokenKeys{
+		CurrentKeyID: string(secret.Data[DGSTokenCurrentKeyField]),
+		Keys:         make(map[string][]byte),
+	}
+	for keyID, key := range secret.Data {
+		if keyID == DGSTokenCurrentKeyField {
+			continue
+		}
+		if len(key) < DGSTokenMinKeySize {
+			return nil, fmt.Errorf("key %s of Secret %s must be at least %d bytes long", keyID, secret.Name, DGSTokenMinKeySize)
+		}
+		keys.Keys[keyID] = key
+	}
+	if _, ok := keys.Keys[keys.CurrentKeyID]; !ok {
+		return nil, fmt.Errorf("current key %q does not exist in Secret %s", keys.CurrentKeyID, secret.Name)
+	}
+	return keys, nil
+}
+
+// Mint returns a token that is bound to the DedicatedGameServer with the given namespace and name, signed by the current key
+// The token is the base64 encoded payload, followed by a dot and the base64 encoded HMAC-SHA256 signature of the payload
+func (k *DGSTokenKeys) Mint(namespace, name string) (string, error) {
+	key, ok := k.Keys[k.CurrentKeyID]
+	if !ok {
+		return "", fmt.Errorf("current key %q does not exist", k.CurrentKeyID)
+	}
+	payloadBytes, err := json.Marshal(dgsTokenPayload{
+		KeyID:       k.CurrentKeyID,
+		DGSIdentity: DGSIdentity{Namespace: namespace, Name: name},
+	})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signDGSToken(key, payload)), nil
+}
+
+// Verify returns the DedicatedGameServer that the token is bound to
+// It returns nil if the token is malformed, or signed by a key that does not exist or does not match
+func (k *DGSTokenKeys) Verify(token string) *DGSIdentity {
+	payload, ok := parseDGSToken(token)
+	if !ok {
+		return nil
+	}
+	key, ok := k.Keys[payload.KeyID]
+	if !ok || !verifyDGSTokenSignature(key, token) {
+		return nil
+	}
+	return &payload.DGSIdentity
+}
+
+// DGSTokenKeyStore reads the DGSTokenKeysSecretName Secret and keeps its keys, so that the keys can be rotated without restarts
+// The keys are read again every DGSTokenKeysRefreshInterval, or when a token is signed by a key that is not known yet
+type DGSTokenKeyStore struct {
+	client    kubernetes.Interface
+	namespace string
+	// createIfMissing creates the Secret with a random key, if it does not exist
+	createIfMissing bool
+	clock           clockwork.Clock
+
+	mu          sync.Mutex
+	keys        *DGSTokenKeys
+	lastRefresh time.Time
+}
+
+// NewDGSTokenKeyStore returns a DGSTokenKeyStore for the Secret of the given namespace
+// The DedicatedGameServer controller sets createIfMissing, so that the Secret does not have to be created during the installation
+func NewDGSTokenKeyStore(client kubernetes.Interface, namespace string, createIfMissing bool) *DGSTokenKeyStore {
+	return &DGSTokenKeyStore{
+		client:          client,
+		namespace:       namespace,
+		createIfMissing: createIfMissing,
+		clock:           clockwork.NewRealClock(),
+	}
+}
+
+// MintDGSToken returns a token that is bound to the DedicatedGameServer with the given namespace and name
+func (s *DGSTokenKeyStore) MintDGSToken(namespace, name string) (string, error) {
+	keys, err := s.getKeys(false)
+	if err != nil {
+		return "", err
+	}
+	if keys == nil {
+		return "", fmt.Errorf("Secret %s/%s does not exist", s.namespace, DGSTokenKeysSecretName)
+	}
+	return keys.Mint(namespace, name)
+}
+
+// VerifyDGSToken returns the DedicatedGameServer that the token is bound to, or nil if the token is not valid
+// An error is returned only if the keys cannot be read
+func (s *DGSTokenKeyStore) VerifyDGSToken(token string) (*DGSIdentity, error) {
+	keys, err := s.getKeys(false)
+	if err != nil {
+		return nil, err
+	}
+	if keys != nil {
+		if identity := keys.Verify(token); identity != nil {
+			return identity, nil
+		}
+	}
+
+	// the token may be signed by a key that was added after the last refresh
+	payload, ok := parseDGSToken(token)
+	if !ok || (keys != nil && keys.Keys[payload.KeyID] != nil) {
+		return nil, nil
+	}
+	keys, err = s.getKeys(true)
+	if err != nil || keys == nil {
+		return nil, err
+	}
+	return keys.Verify(token), nil
+}
+
+// getKeys returns the keys of the Secret, reading it again if the keys are older than DGSTokenKeysRefreshInterval
+// force reads the Secret again if the keys are older than dgsTokenKeysMinRefreshInterval
+// It returns nil keys if the Secret does not exist and createIfMissing is not set
+func (s *DGSTokenKeyStore) getKeys(force bool) (*DGSTokenKeys, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := s.clock.Now().Sub(s.lastRefresh)
+	if !s.lastRefresh.IsZero() && age < DGSTokenKeysRefreshInterval && (!force || age < dgsTokenKeysMinRefreshInterval) {
+		return s.keys, nil
+	}
+
+	secret, err := s.client.CoreV1().Secrets(s.namespace).Get(DGSTokenKeysSecretName, metav1.GetOptions{})
+	if errors.IsNotFound(err) && s.createIfMissing {
+		secret, err = s.createSecret()
+	}
+	if errors.IsNotFound(err) {
+		// the Secret may be created later, tokens cannot exist before it anyway
+		s.keys = nil
+		s.lastRefresh = s.clock.Now()
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Cannot get Secret %s/%s due to %s", s.namespace, DGSTokenKeysSecretName, err.Error())
+	}
+
+	keys, err := NewDGSTokenKeys(secret)
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+	s.lastRefresh = s.clock.Now()
+	return keys, nil
+}
+
+// createSecret creates the Secret with a random key. If another controller created it first, its Secret is returned
+func (s *DGSTokenKeyStore) createSecret() (*corev1.Secret, error) {
+	key := make([]byte, DGSTokenMinKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	keyID := "key-" + strconv.FormatInt(s.clock.Now().Unix(), 10)
+
+	secret, err := s.client.CoreV1().Secrets(s.namespace).Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DGSTokenKeysSecretName,
+			Namespace: s.namespace,
+		},
+		Data: map[string][]byte{
+			DGSTokenCurrentKeyField: []byte(keyID),
+			keyID:                   key,
+		},
+	})
+	if errors.IsAlreadyExists(err) {
+		return s.client.CoreV1().Secrets(s.namespace).Get(DGSTokenKeysSecretName, metav1.GetOptions{})
+	}
+	return secret, err
+}
+
+// IsDGSToken returns true if the token has the format of a DedicatedGameServer token, without verifying its signature
+func IsDGSToken(token string) bool {
+	_, ok := parseDGSToken(token)
+	return ok
+}
+
+// parseDGSToken returns the payload of the token, without verifying its signature
+func parseDGSToken(token string) (*dgsTokenPayload, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, false
+	}
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, false
+	}
+	var payload dgsTokenPayload
+	err = json.Unmarshal(payloadBytes, &payload)
+	if err != nil || payload.Namespace == "" || payload.Name == "" {
+		return nil, false
+	}
+	return &payload, true
+}
+
+func verifyDGSTokenSignature(key []byte, token string) bool {
+	parts := strings.Split(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, signDGSToken(key, parts[0]))
+}
+
+func signDGSToken(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
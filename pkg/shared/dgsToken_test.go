@@ -0,0 +1,138 @@
+package shared
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	testDGSTokenKey1 = []byte(strings.Repeat("1", DGSTokenMinKeySize))
+	testDGSTokenKey2 = []byte(strings.Repeat("2", DGSTokenMinKeySize))
+)
+
+func newDGSTokenKeysSecret(current string, keys map[string][]byte) *corev1.Secret {
+	data := map[string][]byte{DGSTokenCurrentKeyField: []byte(current)}
+	for keyID, key := range keys {
+		data[keyID] = key
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: DGSTokenKeysSecretName, Namespace: GameNamespace},
+		Data:       data,
+	}
+}
+
+func TestMintAndVerifyDGSToken(t *testing.T) {
+	keys, err := NewDGSTokenKeys(newDGSTokenKeysSecret("key1", map[string][]byte{"key1": testDGSTokenKey1}))
+	assert.NoError(t, err)
+
+	token, err := keys.Mint("games", "dgs1")
+	assert.NoError(t, err)
+	assert.Equal(t, &DGSIdentity{Namespace: "games", Name: "dgs1"}, keys.Verify(token))
+
+	otherToken, err := keys.Mint("games", "dgs2")
+	assert.NoError(t, err)
+	parts := strings.Split(token, ".")
+	otherParts := strings.Split(otherToken, ".")
+
+	// the payload of another DGS with the signature of this one
+	assert.Nil(t, keys.Verify(otherParts[0]+"."+parts[1]))
+	assert.Nil(t, keys.Verify(parts[0]))
+	assert.Nil(t, keys.Verify(token+"."))
+	assert.Nil(t, keys.Verify("not-a-token"))
+	assert.Nil(t, keys.Verify(""))
+
+	otherKeys, err := NewDGSTokenKeys(newDGSTokenKeysSecret("key1", map[string][]byte{"key1": testDGSTokenKey2}))
+	assert.NoError(t, err)
+	assert.Nil(t, otherKeys.Verify(token))
+}
+
+func TestNewDGSTokenKeysValidation(t *testing.T) {
+	_, err := NewDGSTokenKeys(newDGSTokenKeysSecret("key2", map[string][]byte{"key1": testDGSTokenKey1}))
+	assert.Error(t, err)
+
+	_, err = NewDGSTokenKeys(newDGSTokenKeysSecret("key1", map[string][]byte{"key1": []byte("short")}))
+	assert.Error(t, err)
+}
+
+func TestDGSTokenKeyRotation(t *testing.T) {
+	client := k8sfake.NewSimpleClientset(newDGSTokenKeysSecret("key1", map[string][]byte{"key1": testDGSTokenKey1}))
+	clock := clockwork.NewFakeClock()
+	store := NewDGSTokenKeyStore(client, GameNamespace, false)
+	store.clock = clock
+
+	oldToken, err := store.MintDGSToken(GameNamespace, "dgs1")
+	assert.NoError(t, err)
+
+	// the controller of another replica mints tokens with a new key, the old key is kept to verify the tokens of the running Pods
+	newKeys, err := NewDGSTokenKeys(newDGSTokenKeysSecret("key2", map[string][]byte{"key1": testDGSTokenKey1, "key2": testDGSTokenKey2}))
+	assert.NoError(t, err)
+	newToken, err := newKeys.Mint(GameNamespace, "dgs2")
+	assert.NoError(t, err)
+	_, err = client.CoreV1().Secrets(GameNamespace).Update(newDGSTokenKeysSecret("key2", map[string][]byte{"key1": testDGSTokenKey1, "key2": testDGSTokenKey2}))
+	assert.NoError(t, err)
+
+	// the unknown key is read from the Secret, but not more often than the minimum refresh interval
+	identity, err := store.VerifyDGSToken(newToken)
+	assert.NoError(t, err)
+	assert.Nil(t, identity)
+	clock.Advance(dgsTokenKeysMinRefreshInterval)
+	identity, err = store.VerifyDGSToken(newToken)
+	assert.NoError(t, err)
+	assert.Equal(t, &DGSIdentity{Namespace: GameNamespace, Name: "dgs2"}, identity)
+	identity, err = store.VerifyDGSToken(oldToken)
+	assert.NoError(t, err)
+	assert.Equal(t, &DGSIdentity{Namespace: GameNamespace, Name: "dgs1"}, identity)
+
+	// new tokens are signed with the new key
+	token, err := store.MintDGSToken(GameNamespace, "dgs3")
+	assert.NoError(t, err)
+	assert.Nil(t, newDGSTokenKeysOrFail(t, "key1", testDGSTokenKey1).Verify(token))
+
+	// the old key is removed, its tokens are rejected after the next refresh
+	_, err = client.CoreV1().Secrets(GameNamespace).Update(newDGSTokenKeysSecret("key2", map[string][]byte{"key2": testDGSTokenKey2}))
+	assert.NoError(t, err)
+	clock.Advance(DGSTokenKeysRefreshInterval)
+	identity, err = store.VerifyDGSToken(oldToken)
+	assert.NoError(t, err)
+	assert.Nil(t, identity)
+}
+
+func TestDGSTokenKeyStoreWithoutSecret(t *testing.T) {
+	client := k8sfake.NewSimpleClientset()
+	store := NewDGSTokenKeyStore(client, GameNamespace, false)
+
+	_, err := store.MintDGSToken(GameNamespace, "dgs1")
+	assert.Error(t, err)
+	identity, err := store.VerifyDGSToken("token.signature")
+	assert.NoError(t, err)
+	assert.Nil(t, identity)
+
+	// the controller creates the Secret with a random key
+	controllerStore := NewDGSTokenKeyStore(client, GameNamespace, true)
+	controllerStore.clock = clockwork.NewFakeClockAt(time.Unix(1538352000, 0))
+	token, err := controllerStore.MintDGSToken(GameNamespace, "dgs1")
+	assert.NoError(t, err)
+
+	secret, err := client.CoreV1().Secrets(GameNamespace).Get(DGSTokenKeysSecretName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1538352000", string(secret.Data[DGSTokenCurrentKeyField]))
+	assert.Len(t, secret.Data["key-1538352000"], DGSTokenMinKeySize)
+
+	identity, err = NewDGSTokenKeyStore(client, GameNamespace, false).VerifyDGSToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, &DGSIdentity{Namespace: GameNamespace, Name: "dgs1"}, identity)
+}
+
+func newDGSTokenKeysOrFail(t *testing.T, keyID string, key []byte) *DGSTokenKeys {
+	keys, err := NewDGSTokenKeys(newDGSTokenKeysSecret(keyID, map[string][]byte{keyID: key}))
+	assert.NoError(t, err)
+	return keys
+}
@@ -1,11 +1,17 @@
 package shared
 
 import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
 	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	dgsclientsetversioned "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/util/retry"
 )
 
@@ -38,7 +44,10 @@ func NewDedicatedGameServer(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, t
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      generateName(dgsCol.Name),
 			Namespace: dgsCol.Namespace,
-			Labels:    map[string]string{LabelDedicatedGameServerCollectionName: dgsCol.Name},
+			Labels: map[string]string{
+				LabelDedicatedGameServerCollectionName: dgsCol.Name,
+				LabelDedicatedGameServerTemplateHash:   GetTemplateHash(dgsCol.Spec.Template),
+			},
 			OwnerReferences: []metav1.OwnerReference{
 				*metav1.NewControllerRef(dgsCol, schema.GroupVersionKind{
 					Group:   dgsv1alpha1.SchemeGroupVersion.Group,
@@ -48,8 +57,10 @@ func NewDedicatedGameServer(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, t
 			},
 		},
 		Spec: dgsv1alpha1.DedicatedGameServerSpec{
-			Template:      *template.DeepCopy(),
-			PortsToExpose: dgsCol.Spec.PortsToExpose,
+			Template:           *template.DeepCopy(),
+			PortsToExpose:      dgsCol.Spec.PortsToExpose,
+			SchedulingStrategy: dgsCol.Spec.SchedulingStrategy,
+			AddressTemplate:    dgsCol.Spec.AddressTemplate,
 		},
 		Status: dgsv1alpha1.DedicatedGameServerStatus{
 			Health:        initialHealth,
@@ -61,8 +72,18 @@ func NewDedicatedGameServer(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, t
 	return dedicatedgameserver
 }
 
+// GetTemplateHash returns a hash of the Pod template of a DedicatedGameServerCollection
+// DedicatedGameServers carry it as a label, so we can find the ones that run an old template
+func GetTemplateHash(template corev1.PodSpec) string {
+	hasher := fnv.New32a()
+	// json encoding of a struct is deterministic, since fields are encoded in the order they are declared
+	templateBytes, _ := json.Marshal(template)
+	hasher.Write(templateBytes)
+	return fmt.Sprint(hasher.Sum32())
+}
+
 // NewDedicatedGameServerWithNoParent creates a new DedicatedGameServer that is not part of a DedicatedGameServerCollection
-func NewDedicatedGameServerWithNoParent(namespace string, name string, template corev1.PodSpec, portsToExpose []int32) *dgsv1alpha1.DedicatedGameServer {
+func NewDedicatedGameServerWithNoParent(namespace string, name string, template corev1.PodSpec, portsToExpose []intstr.IntOrString) *dgsv1alpha1.DedicatedGameServer {
 	initialHealth := dgsv1alpha1.DGSCreating // dgsv1alpha1.DedicatedGameServerStateRunning //TODO: change to Creating
 	initialState := dgsv1alpha1.DGSIdle
 	dedicatedgameserver := &dgsv1alpha1.DedicatedGameServer{
@@ -87,7 +108,8 @@ func NewDedicatedGameServerWithNoParent(namespace string, name string, template
 // APIDetails contains the information that allows our DedicatedGameServer to communicate with the API Server
 type APIDetails struct {
 	APIServerURL string
-	Code         string
+	// Token is the token of the DedicatedGameServer, which only allows it to update its own status
+	Token string
 }
 
 // NewPod returns a Kubernetes Pod struct
@@ -110,12 +132,17 @@ func NewPod(dgs *dgsv1alpha1.DedicatedGameServer, apiDetails APIDetails) *corev1
 		Spec: dgs.Spec.Template,
 	}
 
+	// the Pods of a DGSCol match the Selector of its scale subresource, e.g. for the HorizontalPodAutoscaler
+	if dgsColName, ok := dgs.Labels[LabelDedicatedGameServerCollectionName]; ok {
+		pod.Labels[LabelDedicatedGameServerCollectionName] = dgsColName
+	}
+
 	for i := 0; i < len(pod.Spec.Containers); i++ {
 		// assign special ENV
 		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, corev1.EnvVar{Name: "SERVER_NAME", Value: dgs.Name})
 		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, corev1.EnvVar{Name: "SERVER_NAMESPACE", Value: dgs.Namespace})
 		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, corev1.EnvVar{Name: "API_SERVER_URL", Value: apiDetails.APIServerURL})
-		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, corev1.EnvVar{Name: "API_SERVER_CODE", Value: apiDetails.Code})
+		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, corev1.EnvVar{Name: "API_SERVER_TOKEN", Value: apiDetails.Token})
 	}
 
 	pod.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet //https://kubernetes.io/docs/concepts/services-networking/dns-pod-service/
@@ -159,59 +186,83 @@ type DGSStatusFields struct {
 }
 
 func UpdateDGSStatus(serverName string, namespace string, fields DGSStatusFields) error {
+	_, dgsClient, err := GetClientSet()
+	if err != nil {
+		return err
+	}
+	_, err = UpdateDedicatedGameServerStatus(dgsClient, namespace, serverName, fields)
+	return err
+}
+
+// UpdateDedicatedGameServerStatus sets the non-nil fields on the Status of the DedicatedGameServer and returns the updated DedicatedGameServer
+func UpdateDedicatedGameServerStatus(dgsClient dgsclientsetversioned.Interface, namespace string, name string, fields DGSStatusFields) (*dgsv1alpha1.DedicatedGameServer, error) {
+	var updated *dgsv1alpha1.DedicatedGameServer
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		_, dgsClient, err := GetClientSet()
-		if err != nil {
-			return err
-		}
-		dgs, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Get(serverName, metav1.GetOptions{})
+		dgs, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Get(name, metav1.GetOptions{})
 		if err != nil {
 			return err
 		}
 
-		if fields.DGSHealth != nil {
-			dgs.Status.Health = *fields.DGSHealth
-		}
-		if fields.MarkedForDeletion != nil {
-			dgs.Status.MarkedForDeletion = *fields.MarkedForDeletion
-		}
-		if fields.DGSState != nil {
-			dgs.Status.DGSState = *fields.DGSState
-		}
-		if fields.ActivePlayers != nil {
-			dgs.Status.ActivePlayers = *fields.ActivePlayers
-		}
+		setDGSStatusFields(dgs, fields)
 
-		_, err = dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).Update(dgs)
-		if err != nil {
-			return err
-		}
-		return nil
+		updated, err = dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).UpdateStatus(dgs)
+		return err
 	})
-	return retryErr
+	return updated, retryErr
+}
+
+// setDGSStatusFields sets the non-nil fields on the DGS Status
+func setDGSStatusFields(dgs *dgsv1alpha1.DedicatedGameServer, fields DGSStatusFields) {
+	if fields.DGSHealth != nil {
+		dgs.Status.Health = *fields.DGSHealth
+	}
+	if fields.MarkedForDeletion != nil {
+		dgs.Status.MarkedForDeletion = *fields.MarkedForDeletion
+	}
+	if fields.DGSState != nil {
+		dgs.Status.DGSState = *fields.DGSState
+	}
+	if fields.ActivePlayers != nil {
+		dgs.Status.ActivePlayers = *fields.ActivePlayers
+	}
 }
 
 // GetReadyDGSs returns a list of DGS that are "PodRunning", "Healthy" and not "MarkedForDeletion"
-func GetReadyDGSs() ([]dgsv1alpha1.DedicatedGameServer, error) {
+// in the given namespace, or in all the game namespaces if namespace is empty
+func GetReadyDGSs(namespace string) ([]dgsv1alpha1.DedicatedGameServer, error) {
 	_, dgsClient, err := GetClientSet()
 	if err != nil {
 		return nil, err
 	}
+	return ListDedicatedGameServers(dgsClient, namespace, true)
+}
 
-	dgss, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers(GameNamespace).List(metav1.ListOptions{})
-	if err != nil {
-		return nil, err
+// ListDedicatedGameServers returns the DGSs of the given namespace, or of all the game namespaces if namespace is empty
+// If readyOnly is set, only the ready DGSs are returned
+func ListDedicatedGameServers(dgsClient dgsclientsetversioned.Interface, namespace string, readyOnly bool) ([]dgsv1alpha1.DedicatedGameServer, error) {
+	namespaces := []string{namespace}
+	if namespace == "" && GetGameNamespaces() != nil {
+		namespaces = GetGameNamespaces()
 	}
 
 	dgsToReturn := make([]dgsv1alpha1.DedicatedGameServer, 0)
-
-	for _, dgs := range dgss.Items {
-		if dgs.Status.Health == dgsv1alpha1.DGSHealthy &&
-			dgs.Status.PodPhase == corev1.PodRunning &&
-			!dgs.Status.MarkedForDeletion {
-			dgsToReturn = append(dgsToReturn, dgs)
+	for _, namespace := range namespaces {
+		dgss, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServers(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, dgs := range dgss.Items {
+			if !readyOnly || IsDGSReady(&dgs) {
+				dgsToReturn = append(dgsToReturn, dgs)
+			}
 		}
 	}
-
 	return dgsToReturn, nil
 }
+
+// IsDGSReady returns true if the DGS is "PodRunning", "Healthy" and not "MarkedForDeletion"
+func IsDGSReady(dgs *dgsv1alpha1.DedicatedGameServer) bool {
+	return dgs.Status.Health == dgsv1alpha1.DGSHealthy &&
+		dgs.Status.PodPhase == corev1.PodRunning &&
+		!dgs.Status.MarkedForDeletion
+}
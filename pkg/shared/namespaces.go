@@ -0,0 +1,85 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// GameNamespacesEnvVar is the environment variable that sets the game namespaces, if the -gamenamespaces argument is not set
+	GameNamespacesEnvVar = "GAME_NAMESPACES"
+	// AllGameNamespaces is the value of the game namespaces that makes every namespace a game namespace
+	AllGameNamespaces = "*"
+)
+
+// gameNamespaces are the namespaces of the DedicatedGameServers that the controllers and the API Server manage
+// nil means all namespaces
+var gameNamespaces = []string{GameNamespace}
+
+// GameNamespacesFromEnv returns the game namespaces of the GAME_NAMESPACES environment variable, or defaultValue if it is not set
+func GameNamespacesFromEnv(defaultValue string) string {
+	if value := os.Getenv(GameNamespacesEnvVar); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// SetGameNamespaces sets the game namespaces from a comma separated list of namespaces, or "*" for all namespaces
+func SetGameNamespaces(value string) error {
+	if strings.TrimSpace(value) == AllGameNamespaces {
+		gameNamespaces = nil
+		return nil
+	}
+
+	var namespaces []string
+	for _, namespace := range strings.Split(value, ",") {
+		namespace = strings.TrimSpace(namespace)
+		if namespace == "" {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return fmt.Errorf("invalid namespace %q: %s", namespace, strings.Join(errs, ", "))
+		}
+		if !stringSliceContains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	if len(namespaces) == 0 {
+		return fmt.Errorf("no game namespaces in %q", value)
+	}
+
+	gameNamespaces = namespaces
+	return nil
+}
+
+// GetGameNamespaces returns the game namespaces, or nil if every namespace is a game namespace
+func GetGameNamespaces() []string {
+	return gameNamespaces
+}
+
+// IsGameNamespace returns true if the DedicatedGameServers of the namespace are managed by the controllers and the API Server
+func IsGameNamespace(namespace string) bool {
+	return gameNamespaces == nil || stringSliceContains(gameNamespaces, namespace)
+}
+
+// DefaultGameNamespace returns the namespace of the API Server requests that do not set one
+// This is the first game namespace, or "default" if every namespace is a game namespace
+func DefaultGameNamespace() string {
+	if gameNamespaces == nil {
+		return GameNamespace
+	}
+	return gameNamespaces[0]
+}
+
+// InformerNamespace returns the namespace that the informers watch: the game namespace if there is only one,
+// otherwise all namespaces. In the latter case, objects of namespaces that are not game namespaces must be filtered out
+func InformerNamespace() string {
+	if len(gameNamespaces) == 1 {
+		return gameNamespaces[0]
+	}
+	return metav1.NamespaceAll
+}
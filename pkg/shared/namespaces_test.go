@@ -0,0 +1,94 @@
+package shared
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+
+	"github.com/stretchr/testify/assert"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetGameNamespaces(t *testing.T) {
+	defer SetGameNamespaces(GameNamespace)
+
+	tests := []struct {
+		name             string
+		value            string
+		expected         []string
+		expectedDefault  string
+		expectedInformer string
+		expectError      bool
+	}{
+		{name: "single", value: "games", expected: []string{"games"}, expectedDefault: "games", expectedInformer: "games"},
+		{name: "list", value: "games, tournaments,games", expected: []string{"games", "tournaments"}, expectedDefault: "games", expectedInformer: metav1.NamespaceAll},
+		{name: "all", value: "*", expected: nil, expectedDefault: GameNamespace, expectedInformer: metav1.NamespaceAll},
+		{name: "invalid", value: "Games", expectError: true},
+		{name: "empty", value: " , ", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetGameNamespaces(GameNamespace)
+			err := SetGameNamespaces(tt.value)
+			if tt.expectError {
+				assert.Error(t, err)
+				// the previous game namespaces are kept
+				assert.Equal(t, []string{GameNamespace}, GetGameNamespaces())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, GetGameNamespaces())
+			assert.Equal(t, tt.expectedDefault, DefaultGameNamespace())
+			assert.Equal(t, tt.expectedInformer, InformerNamespace())
+		})
+	}
+}
+
+func TestIsGameNamespace(t *testing.T) {
+	defer SetGameNamespaces(GameNamespace)
+
+	assert.NoError(t, SetGameNamespaces("games,tournaments"))
+	assert.True(t, IsGameNamespace("tournaments"))
+	assert.False(t, IsGameNamespace(GameNamespace))
+
+	assert.NoError(t, SetGameNamespaces(AllGameNamespaces))
+	assert.True(t, IsGameNamespace("anything"))
+}
+
+func TestGetReadyDGSsInGameNamespaces(t *testing.T) {
+	defer SetGameNamespaces(GameNamespace)
+
+	dgsGames := newReadyDGS("games", "col1", dgsv1alpha1.DGSIdle)
+	dgsGames.Namespace = "games"
+	dgsTournaments := newReadyDGS("tournaments", "col1", dgsv1alpha1.DGSIdle)
+	dgsTournaments.Namespace = "tournaments"
+	dgsOther := newReadyDGS("other", "col1", dgsv1alpha1.DGSIdle)
+	dgsOther.Namespace = "other"
+
+	dgsClient := fake.NewSimpleClientset(dgsGames, dgsTournaments, dgsOther)
+
+	assert.NoError(t, SetGameNamespaces("games,tournaments"))
+	dgss, err := ListDedicatedGameServers(dgsClient, "", true)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"games", "tournaments"}, dgsNames(dgss))
+
+	dgss, err = ListDedicatedGameServers(dgsClient, "tournaments", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tournaments"}, dgsNames(dgss))
+
+	assert.NoError(t, SetGameNamespaces(AllGameNamespaces))
+	dgss, err = ListDedicatedGameServers(dgsClient, "", true)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"games", "tournaments", "other"}, dgsNames(dgss))
+}
+
+func dgsNames(dgss []dgsv1alpha1.DedicatedGameServer) []string {
+	var names []string
+	for _, dgs := range dgss {
+		names = append(names, dgs.Name)
+	}
+	return names
+}
@@ -0,0 +1,184 @@
+package shared
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"text/template"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// GetPortsToExpose returns pointers to the container ports of the Pod Template that are exposed, in the order of the Template
+func GetPortsToExpose(podSpec *corev1.PodSpec, portsToExpose []intstr.IntOrString) []*corev1.ContainerPort {
+	var ports []*corev1.ContainerPort
+	for k := range podSpec.Containers {
+		for j := range podSpec.Containers[k].Ports {
+			if IsPortExposed(&podSpec.Containers[k], podSpec.Containers[k].Ports[j].ContainerPort, portsToExpose) {
+				ports = append(ports, &podSpec.Containers[k].Ports[j])
+			}
+		}
+	}
+	return ports
+}
+
+// IsPortExposed returns true if the given ContainerPort of the container is referenced by portsToExpose
+// A ContainerPort that is declared for many protocols is exposed if any of its declarations is referenced
+func IsPortExposed(container *corev1.Container, containerPort int32, portsToExpose []intstr.IntOrString) bool {
+	for _, port := range container.Ports {
+		if port.ContainerPort != containerPort {
+			continue
+		}
+		for _, portToExpose := range portsToExpose {
+			if portMatches(container.Name, port, portToExpose) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// portMatches returns true if the port of the container is referenced by portToExpose, which can be
+// a port number of any container, a port name of any container or a container/portName pair
+func portMatches(containerName string, port corev1.ContainerPort, portToExpose intstr.IntOrString) bool {
+	if portToExpose.Type == intstr.Int {
+		return port.ContainerPort == portToExpose.IntVal
+	}
+	if port.Name == "" {
+		return false
+	}
+	parts := strings.SplitN(portToExpose.StrVal, "/", 2)
+	if len(parts) == 2 {
+		return parts[0] == containerName && parts[1] == port.Name
+	}
+	return portToExpose.StrVal == port.Name
+}
+
+// ValidatePortsToExpose checks that every item of portsToExpose references a port of the Pod Template
+func ValidatePortsToExpose(podSpec *corev1.PodSpec, portsToExpose []intstr.IntOrString) error {
+	for _, portToExpose := range portsToExpose {
+		found := false
+		for _, container := range podSpec.Containers {
+			for _, port := range container.Ports {
+				if portMatches(container.Name, port, portToExpose) {
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("port %s does not exist in the Pod template", portToExpose.String())
+		}
+	}
+	return nil
+}
+
+// ValidatePortPolicy checks that the PortPolicy is valid for the given number of exposed ports
+// A nil PortPolicy is valid
+func ValidatePortPolicy(policy *dgsv1alpha1.DGSPortPolicy, exposedPorts int) error {
+	if policy == nil {
+		return nil
+	}
+
+	switch policy.Mode {
+	case "", dgsv1alpha1.DynamicPortPolicyMode:
+	case dgsv1alpha1.PassthroughPortPolicyMode:
+		if policy.MinPort != 0 || policy.MaxPort != 0 || policy.BlockSize > 1 {
+			return fmt.Errorf("port range and block size cannot be set in %s mode", policy.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown port policy mode %s", policy.Mode)
+	}
+
+	switch policy.Protocol {
+	case "", dgsv1alpha1.TCPPortProtocol, dgsv1alpha1.UDPPortProtocol, dgsv1alpha1.TCPAndUDPPortProtocol:
+	default:
+		return fmt.Errorf("unknown port protocol %s", policy.Protocol)
+	}
+
+	if (policy.MinPort == 0) != (policy.MaxPort == 0) {
+		return fmt.Errorf("minPort and maxPort must be set together")
+	}
+	if policy.MinPort < 0 || policy.MaxPort > 65535 || policy.MinPort > policy.MaxPort {
+		return fmt.Errorf("invalid port range %d-%d", policy.MinPort, policy.MaxPort)
+	}
+
+	if policy.BlockSize < 0 {
+		return fmt.Errorf("invalid block size %d", policy.BlockSize)
+	}
+	if policy.BlockSize > 1 && exposedPorts%int(policy.BlockSize) != 0 {
+		return fmt.Errorf("the %d exposed ports cannot be split in blocks of %d ports", exposedPorts, policy.BlockSize)
+	}
+	if policy.MinPort != 0 && policy.BlockSize > policy.MaxPort-policy.MinPort+1 {
+		return fmt.Errorf("a block of %d ports does not fit in the port range %d-%d", policy.BlockSize, policy.MinPort, policy.MaxPort)
+	}
+
+	return nil
+}
+
+// DGSAddressFields are the fields that the AddressTemplate of a DGS can use
+type DGSAddressFields struct {
+	Name      string
+	Namespace string
+	PublicIP  string
+	NodeName  string
+	// Port is the HostPort of the first exposed port
+	Port int32
+	// Ports contains the HostPorts of the named exposed ports, by name
+	Ports map[string]int32
+}
+
+// ParseAddressTemplate parses the AddressTemplate of a DGS or a DGSCol
+func ParseAddressTemplate(addressTemplate string) (*template.Template, error) {
+	return template.New("address").Option("missingkey=error").Parse(addressTemplate)
+}
+
+// RenderDGSAddress returns the address that clients use to connect to the DGS, with the given Public IP and exposed ports
+// The address is empty while the DGS has no Public IP
+func RenderDGSAddress(dgs *dgsv1alpha1.DedicatedGameServer, publicIP string, ports []dgsv1alpha1.DGSPort) (string, error) {
+	if publicIP == "" {
+		return "", nil
+	}
+
+	fields := DGSAddressFields{
+		Name:      dgs.Name,
+		Namespace: dgs.Namespace,
+		PublicIP:  publicIP,
+		NodeName:  dgs.Status.NodeName,
+		Ports:     make(map[string]int32, len(ports)),
+	}
+	for _, port := range ports {
+		if fields.Port == 0 {
+			fields.Port = port.HostPort
+		}
+		if port.Name != "" {
+			fields.Ports[port.Name] = port.HostPort
+		}
+	}
+
+	if dgs.Spec.AddressTemplate == "" {
+		if fields.Port == 0 {
+			return publicIP, nil
+		}
+		return net.JoinHostPort(publicIP, strconv.Itoa(int(fields.Port))), nil
+	}
+
+	tmpl, err := ParseAddressTemplate(dgs.Spec.AddressTemplate)
+	if err != nil {
+		return "", err
+	}
+	var address bytes.Buffer
+	err = tmpl.Execute(&address, fields)
+	if err != nil {
+		return "", err
+	}
+	return address.String(), nil
+}
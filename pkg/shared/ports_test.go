@@ -0,0 +1,190 @@
+package shared
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGetPortsToExpose(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "game", Ports: []corev1.ContainerPort{{ContainerPort: 7777}, {ContainerPort: 8080}}},
+			{Name: "sidecar", Ports: []corev1.ContainerPort{{ContainerPort: 7778}}},
+		},
+	}
+
+	ports := GetPortsToExpose(podSpec, []intstr.IntOrString{intstr.FromInt(7778), intstr.FromInt(7777)})
+	if assert.Len(t, ports, 2) {
+		// the order of the Template is kept
+		assert.Equal(t, int32(7777), ports[0].ContainerPort)
+		assert.Equal(t, int32(7778), ports[1].ContainerPort)
+	}
+
+	// the pointers refer to the Template
+	ports[0].HostPort = 20000
+	assert.Equal(t, int32(20000), podSpec.Containers[0].Ports[0].HostPort)
+
+	assert.Len(t, GetPortsToExpose(podSpec, nil), 0)
+}
+
+func TestGetPortsToExposeByName(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "game", Ports: []corev1.ContainerPort{{Name: "game", ContainerPort: 7777}, {Name: "metrics", ContainerPort: 8080}}},
+			{Name: "sidecar", Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 8080}, {Name: "query", ContainerPort: 7778}}},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		portsToExpose []intstr.IntOrString
+		expected      []string
+	}{
+		{name: "number", portsToExpose: []intstr.IntOrString{intstr.FromInt(8080)}, expected: []string{"game/metrics", "sidecar/metrics"}},
+		{name: "name", portsToExpose: []intstr.IntOrString{intstr.FromString("game"), intstr.FromString("query")}, expected: []string{"game/game", "sidecar/query"}},
+		{name: "container and name", portsToExpose: []intstr.IntOrString{intstr.FromString("sidecar/metrics")}, expected: []string{"sidecar/metrics"}},
+		{name: "mixed", portsToExpose: []intstr.IntOrString{intstr.FromInt(7777), intstr.FromString("game/metrics")}, expected: []string{"game/game", "game/metrics"}},
+		{name: "unknown container", portsToExpose: []intstr.IntOrString{intstr.FromString("other/metrics")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var actual []string
+			for k, container := range podSpec.Containers {
+				for _, port := range GetPortsToExpose(&corev1.PodSpec{Containers: podSpec.Containers[k : k+1]}, tt.portsToExpose) {
+					actual = append(actual, container.Name+"/"+port.Name)
+				}
+			}
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestIsPortExposedWithManyProtocols(t *testing.T) {
+	container := &corev1.Container{
+		Name: "game",
+		Ports: []corev1.ContainerPort{
+			{Name: "game", ContainerPort: 7777, Protocol: corev1.ProtocolTCP},
+			{Name: "game-udp", ContainerPort: 7777, Protocol: corev1.ProtocolUDP},
+		},
+	}
+
+	// the UDP declaration of the port is exposed along with the TCP one
+	assert.True(t, IsPortExposed(container, 7777, []intstr.IntOrString{intstr.FromString("game")}))
+	assert.False(t, IsPortExposed(container, 7777, []intstr.IntOrString{intstr.FromString("query")}))
+	assert.Len(t, GetPortsToExpose(&corev1.PodSpec{Containers: []corev1.Container{*container}}, []intstr.IntOrString{intstr.FromString("game")}), 2)
+}
+
+func TestValidatePortsToExpose(t *testing.T) {
+	podSpec := &corev1.PodSpec{
+		Containers: []corev1.Container{
+			{Name: "game", Ports: []corev1.ContainerPort{{Name: "game", ContainerPort: 7777}, {ContainerPort: 8080}}},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		portsToExpose []intstr.IntOrString
+		valid         bool
+	}{
+		{name: "none", valid: true},
+		{name: "number", portsToExpose: []intstr.IntOrString{intstr.FromInt(8080)}, valid: true},
+		{name: "name", portsToExpose: []intstr.IntOrString{intstr.FromString("game")}, valid: true},
+		{name: "container and name", portsToExpose: []intstr.IntOrString{intstr.FromString("game/game")}, valid: true},
+		{name: "missing number", portsToExpose: []intstr.IntOrString{intstr.FromInt(7778)}},
+		{name: "missing name", portsToExpose: []intstr.IntOrString{intstr.FromString("query")}},
+		{name: "missing container", portsToExpose: []intstr.IntOrString{intstr.FromString("sidecar/game")}},
+		{name: "empty name", portsToExpose: []intstr.IntOrString{intstr.FromString("")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePortsToExpose(podSpec, tt.portsToExpose)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidatePortPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       *dgsv1alpha1.DGSPortPolicy
+		exposedPorts int
+		valid        bool
+	}{
+		{name: "nil", policy: nil, exposedPorts: 1, valid: true},
+		{name: "empty", policy: &dgsv1alpha1.DGSPortPolicy{}, exposedPorts: 1, valid: true},
+		{name: "range", policy: &dgsv1alpha1.DGSPortPolicy{MinPort: 7000, MaxPort: 7100}, exposedPorts: 1, valid: true},
+		{name: "blocks", policy: &dgsv1alpha1.DGSPortPolicy{BlockSize: 2}, exposedPorts: 4, valid: true},
+		{name: "passthrough", policy: &dgsv1alpha1.DGSPortPolicy{Mode: dgsv1alpha1.PassthroughPortPolicyMode, Protocol: dgsv1alpha1.TCPAndUDPPortProtocol}, exposedPorts: 1, valid: true},
+		{name: "unknown mode", policy: &dgsv1alpha1.DGSPortPolicy{Mode: "Static"}, exposedPorts: 1, valid: false},
+		{name: "unknown protocol", policy: &dgsv1alpha1.DGSPortPolicy{Protocol: "SCTP"}, exposedPorts: 1, valid: false},
+		{name: "passthrough with range", policy: &dgsv1alpha1.DGSPortPolicy{Mode: dgsv1alpha1.PassthroughPortPolicyMode, MinPort: 7000, MaxPort: 7100}, exposedPorts: 1, valid: false},
+		{name: "passthrough with blocks", policy: &dgsv1alpha1.DGSPortPolicy{Mode: dgsv1alpha1.PassthroughPortPolicyMode, BlockSize: 2}, exposedPorts: 2, valid: false},
+		{name: "only minPort", policy: &dgsv1alpha1.DGSPortPolicy{MinPort: 7000}, exposedPorts: 1, valid: false},
+		{name: "reversed range", policy: &dgsv1alpha1.DGSPortPolicy{MinPort: 7100, MaxPort: 7000}, exposedPorts: 1, valid: false},
+		{name: "range out of bounds", policy: &dgsv1alpha1.DGSPortPolicy{MinPort: 7000, MaxPort: 70000}, exposedPorts: 1, valid: false},
+		{name: "ports not a multiple of block size", policy: &dgsv1alpha1.DGSPortPolicy{BlockSize: 2}, exposedPorts: 3, valid: false},
+		{name: "block larger than range", policy: &dgsv1alpha1.DGSPortPolicy{MinPort: 7000, MaxPort: 7001, BlockSize: 3}, exposedPorts: 3, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePortPolicy(tt.policy, tt.exposedPorts)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestRenderDGSAddress(t *testing.T) {
+	ports := []dgsv1alpha1.DGSPort{
+		{Name: "game", ContainerPort: 7777, HostPort: 20001, Protocol: corev1.ProtocolUDP},
+		{Name: "query", ContainerPort: 7778, HostPort: 20002, Protocol: corev1.ProtocolTCP},
+	}
+
+	tests := []struct {
+		name            string
+		addressTemplate string
+		publicIP        string
+		ports           []dgsv1alpha1.DGSPort
+		expected        string
+		expectError     bool
+	}{
+		{name: "default", publicIP: "1.2.3.4", ports: ports, expected: "1.2.3.4:20001"},
+		{name: "default without ports", publicIP: "1.2.3.4", expected: "1.2.3.4"},
+		{name: "default IPv6", publicIP: "2001:db8::1", ports: ports, expected: "[2001:db8::1]:20001"},
+		{name: "not scheduled", addressTemplate: "{{.PublicIP}}", expected: ""},
+		{name: "named ports", addressTemplate: `{{.PublicIP}}:{{index .Ports "game"}}?query={{index .Ports "query"}}`, publicIP: "1.2.3.4", ports: ports, expected: "1.2.3.4:20001?query=20002"},
+		{name: "metadata", addressTemplate: "{{.Name}}.{{.Namespace}}:{{.Port}}", publicIP: "1.2.3.4", ports: ports, expected: "test.default:20001"},
+		{name: "unknown field", addressTemplate: "{{.Hostname}}", publicIP: "1.2.3.4", ports: ports, expectError: true},
+		{name: "invalid template", addressTemplate: "{{.PublicIP", publicIP: "1.2.3.4", ports: ports, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dgs := NewDedicatedGameServerWithNoParent("default", "test", corev1.PodSpec{}, nil)
+			dgs.Spec.AddressTemplate = tt.addressTemplate
+			address, err := RenderDGSAddress(dgs, tt.publicIP, tt.ports)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, address)
+		})
+	}
+}
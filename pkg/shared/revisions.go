@@ -0,0 +1,197 @@
+package shared
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+	dgsclientsetversioned "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// ErrRevisionNotFound is returned when the requested revision does not exist in the history of a DedicatedGameServerCollection
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// DedicatedGameServerCollectionRevision describes a revision of the Template of a DedicatedGameServerCollection
+type DedicatedGameServerCollectionRevision struct {
+	Revision          int64       `json:"revision"`
+	TemplateHash      string      `json:"templateHash"`
+	Current           bool        `json:"current"`
+	CreationTimestamp metav1.Time `json:"creationTimestamp"`
+}
+
+// NewControllerRevision returns a ControllerRevision that stores the current Template of the DedicatedGameServerCollection
+func NewControllerRevision(dgsCol *dgsv1alpha1.DedicatedGameServerCollection, revision int64) (*appsv1.ControllerRevision, error) {
+	templateBytes, err := json.Marshal(dgsCol.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	templateHash := GetTemplateHash(dgsCol.Spec.Template)
+
+	controllerRevision := &appsv1.ControllerRevision{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      GetControllerRevisionName(dgsCol.Name, templateHash),
+			Namespace: dgsCol.Namespace,
+			Labels: map[string]string{
+				LabelDedicatedGameServerCollectionName: dgsCol.Name,
+				LabelDedicatedGameServerTemplateHash:   templateHash,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(dgsCol, schema.GroupVersionKind{
+					Group:   dgsv1alpha1.SchemeGroupVersion.Group,
+					Version: dgsv1alpha1.SchemeGroupVersion.Version,
+					Kind:    "DedicatedGameServerCollection",
+				}),
+			},
+		},
+		Data:     runtime.RawExtension{Raw: templateBytes},
+		Revision: revision,
+	}
+	return controllerRevision, nil
+}
+
+// GetControllerRevisionName returns the name of the ControllerRevision for the DedicatedGameServerCollection Template with the specified hash
+func GetControllerRevisionName(dgsColName string, templateHash string) string {
+	return fmt.Sprintf("%s-%s", dgsColName, templateHash)
+}
+
+// GetControllerRevisionTemplate returns the Template that is stored in the ControllerRevision
+func GetControllerRevisionTemplate(controllerRevision *appsv1.ControllerRevision) (corev1.PodSpec, error) {
+	var template corev1.PodSpec
+	err := json.Unmarshal(controllerRevision.Data.Raw, &template)
+	return template, err
+}
+
+// GetControllerRevisionSelector returns the label selector for the ControllerRevisions of a DedicatedGameServerCollection
+func GetControllerRevisionSelector(dgsColName string) labels.Selector {
+	return labels.SelectorFromSet(labels.Set{LabelDedicatedGameServerCollectionName: dgsColName})
+}
+
+// SortControllerRevisions sorts the ControllerRevisions by ascending revision number
+func SortControllerRevisions(controllerRevisions []*appsv1.ControllerRevision) {
+	sort.Slice(controllerRevisions, func(i, j int) bool {
+		return controllerRevisions[i].Revision < controllerRevisions[j].Revision
+	})
+}
+
+// listControllerRevisions returns the ControllerRevisions of a DedicatedGameServerCollection, sorted by ascending revision number
+func listControllerRevisions(client kubernetes.Interface, namespace string, dgsColName string) ([]*appsv1.ControllerRevision, error) {
+	list, err := client.AppsV1().ControllerRevisions(namespace).List(metav1.ListOptions{LabelSelector: GetControllerRevisionSelector(dgsColName).String()})
+	if err != nil {
+		return nil, err
+	}
+
+	controllerRevisions := make([]*appsv1.ControllerRevision, 0, len(list.Items))
+	for i := range list.Items {
+		controllerRevisions = append(controllerRevisions, &list.Items[i])
+	}
+	SortControllerRevisions(controllerRevisions)
+	return controllerRevisions, nil
+}
+
+// GetDedicatedGameServerCollectionRevisions returns the Template history of a DedicatedGameServerCollection, sorted by ascending revision number
+func GetDedicatedGameServerCollectionRevisions(client kubernetes.Interface, dgsClient dgsclientsetversioned.Interface, namespace string, dgsColName string) ([]DedicatedGameServerCollectionRevision, error) {
+	dgsCol, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Get(dgsColName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	controllerRevisions, err := listControllerRevisions(client, namespace, dgsColName)
+	if err != nil {
+		return nil, err
+	}
+
+	currentHash := GetTemplateHash(dgsCol.Spec.Template)
+	revisions := make([]DedicatedGameServerCollectionRevision, 0, len(controllerRevisions))
+	for _, controllerRevision := range controllerRevisions {
+		templateHash := controllerRevision.Labels[LabelDedicatedGameServerTemplateHash]
+		revisions = append(revisions, DedicatedGameServerCollectionRevision{
+			Revision:          controllerRevision.Revision,
+			TemplateHash:      templateHash,
+			Current:           templateHash == currentHash,
+			CreationTimestamp: controllerRevision.CreationTimestamp,
+		})
+	}
+	return revisions, nil
+}
+
+// RollbackDedicatedGameServerCollection sets the Template of the DedicatedGameServerCollection to the one stored in the specified revision
+// If toRevision is 0, the DedicatedGameServerCollection is rolled back to the revision before the current one
+// The DedicatedGameServerCollection controller will then replace the DedicatedGameServers according to the update strategy
+func RollbackDedicatedGameServerCollection(client kubernetes.Interface, dgsClient dgsclientsetversioned.Interface, namespace string, dgsColName string, toRevision int64) (*dgsv1alpha1.DedicatedGameServerCollection, error) {
+	controllerRevisions, err := listControllerRevisions(client, namespace, dgsColName)
+	if err != nil {
+		return nil, err
+	}
+
+	var dgsColToReturn *dgsv1alpha1.DedicatedGameServerCollection
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		dgsCol, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Get(dgsColName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		target := findRollbackRevision(controllerRevisions, GetTemplateHash(dgsCol.Spec.Template), toRevision)
+		if target == nil {
+			return ErrRevisionNotFound
+		}
+
+		template, err := GetControllerRevisionTemplate(target)
+		if err != nil {
+			return err
+		}
+
+		// rolling back to the current Template is a no-op
+		if GetTemplateHash(template) == GetTemplateHash(dgsCol.Spec.Template) {
+			dgsColToReturn = dgsCol
+			return nil
+		}
+
+		dgsColToUpdate := dgsCol.DeepCopy()
+		dgsColToUpdate.Spec.Template = template
+		dgsColToReturn, err = dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(namespace).Update(dgsColToUpdate)
+		return err
+	})
+	return dgsColToReturn, retryErr
+}
+
+// findRollbackRevision returns the ControllerRevision to roll back to, or nil if there is none
+// controllerRevisions must be sorted by ascending revision number
+func findRollbackRevision(controllerRevisions []*appsv1.ControllerRevision, currentHash string, toRevision int64) *appsv1.ControllerRevision {
+	if toRevision != 0 {
+		for _, controllerRevision := range controllerRevisions {
+			if controllerRevision.Revision == toRevision {
+				return controllerRevision
+			}
+		}
+		return nil
+	}
+
+	// find the revision right before the current one
+	var current *appsv1.ControllerRevision
+	for _, controllerRevision := range controllerRevisions {
+		if controllerRevision.Labels[LabelDedicatedGameServerTemplateHash] == currentHash {
+			current = controllerRevision
+		}
+	}
+	var previous *appsv1.ControllerRevision
+	for _, controllerRevision := range controllerRevisions {
+		if controllerRevision == current {
+			continue
+		}
+		if current == nil || controllerRevision.Revision < current.Revision {
+			previous = controllerRevision
+		}
+	}
+	return previous
+}
@@ -0,0 +1,120 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/client/clientset/versioned/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+func newRevisionTemplate(image string) corev1.PodSpec {
+	return corev1.PodSpec{Containers: []corev1.Container{{Name: "game", Image: image}}}
+}
+
+// newRevisionFixture returns clients with a DGSCol that runs the currentImage
+// and one ControllerRevision per image, numbered from 1
+func newRevisionFixture(t *testing.T, currentImage string, images ...string) (*k8sfake.Clientset, *fake.Clientset) {
+	dgsCol := NewDedicatedGameServerCollection("col", GameNamespace, 1, newRevisionTemplate(currentImage))
+
+	k8sObjects := []runtime.Object{}
+	for i, image := range images {
+		dgsColWithTemplate := dgsCol.DeepCopy()
+		dgsColWithTemplate.Spec.Template = newRevisionTemplate(image)
+		controllerRevision, err := NewControllerRevision(dgsColWithTemplate, int64(i+1))
+		if err != nil {
+			t.Fatalf("Error creating ControllerRevision: %v", err)
+		}
+		k8sObjects = append(k8sObjects, controllerRevision)
+	}
+
+	return k8sfake.NewSimpleClientset(k8sObjects...), fake.NewSimpleClientset(dgsCol)
+}
+
+func getDGSColImage(t *testing.T, dgsClient *fake.Clientset) string {
+	dgsCol, err := dgsClient.AzuregamingV1alpha1().DedicatedGameServerCollections(GameNamespace).Get("col", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Error getting DGSCol: %v", err)
+	}
+	return dgsCol.Spec.Template.Containers[0].Image
+}
+
+func TestRollbackToPreviousRevision(t *testing.T) {
+	client, dgsClient := newRevisionFixture(t, "game:3", "game:1", "game:2", "game:3")
+
+	dgsCol, err := RollbackDedicatedGameServerCollection(client, dgsClient, GameNamespace, "col", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error rolling back: %v", err)
+	}
+	if dgsCol.Spec.Template.Containers[0].Image != "game:2" {
+		t.Errorf("Expected returned DGSCol to run game:2, got %s", dgsCol.Spec.Template.Containers[0].Image)
+	}
+	if image := getDGSColImage(t, dgsClient); image != "game:2" {
+		t.Errorf("Expected DGSCol to run game:2, got %s", image)
+	}
+}
+
+func TestRollbackToRevision(t *testing.T) {
+	client, dgsClient := newRevisionFixture(t, "game:3", "game:1", "game:2", "game:3")
+
+	_, err := RollbackDedicatedGameServerCollection(client, dgsClient, GameNamespace, "col", 1)
+	if err != nil {
+		t.Fatalf("Unexpected error rolling back: %v", err)
+	}
+	if image := getDGSColImage(t, dgsClient); image != "game:1" {
+		t.Errorf("Expected DGSCol to run game:1, got %s", image)
+	}
+}
+
+func TestRollbackToCurrentRevisionIsNoop(t *testing.T) {
+	client, dgsClient := newRevisionFixture(t, "game:2", "game:1", "game:2")
+
+	_, err := RollbackDedicatedGameServerCollection(client, dgsClient, GameNamespace, "col", 2)
+	if err != nil {
+		t.Fatalf("Unexpected error rolling back: %v", err)
+	}
+	for _, action := range dgsClient.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("Expected no update, got %#v", action)
+		}
+	}
+}
+
+func TestRollbackToMissingRevision(t *testing.T) {
+	client, dgsClient := newRevisionFixture(t, "game:1", "game:1")
+
+	_, err := RollbackDedicatedGameServerCollection(client, dgsClient, GameNamespace, "col", 5)
+	if err != ErrRevisionNotFound {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+
+	// there is no revision before the current one
+	_, err = RollbackDedicatedGameServerCollection(client, dgsClient, GameNamespace, "col", 0)
+	if err != ErrRevisionNotFound {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+func TestGetDedicatedGameServerCollectionRevisions(t *testing.T) {
+	client, dgsClient := newRevisionFixture(t, "game:1", "game:1", "game:2")
+
+	revisions, err := GetDedicatedGameServerCollectionRevisions(client, dgsClient, GameNamespace, "col")
+	if err != nil {
+		t.Fatalf("Unexpected error getting revisions: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+	}
+	if revisions[0].Revision != 1 || !revisions[0].Current {
+		t.Errorf("Expected revision 1 to be current, got %#v", revisions[0])
+	}
+	if revisions[1].Revision != 2 || revisions[1].Current {
+		t.Errorf("Expected revision 2 not to be current, got %#v", revisions[1])
+	}
+	if revisions[0].TemplateHash != GetTemplateHash(newRevisionTemplate("game:1")) {
+		t.Errorf("Unexpected template hash %s", revisions[0].TemplateHash)
+	}
+}
@@ -98,6 +98,16 @@ func SliceContains(slice []int32, value int32) bool {
 	return false
 }
 
+// stringSliceContains returns true if the specific string value is contained in the slice
+func stringSliceContains(slice []string, value string) bool {
+	for _, item := range slice {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
 func generateName(prefix string) string {
 	return prefix + "-" + randString(RandStringSize)
 }
@@ -0,0 +1,57 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValidatePodSpecResources checks that every container of the PodSpec has CPU and memory requests and limits
+func ValidatePodSpecResources(podSpec *corev1.PodSpec) error {
+	for _, container := range podSpec.Containers {
+		//check for requests
+		if container.Resources.Requests.Cpu() == nil ||
+			container.Resources.Requests.Cpu().IsZero() ||
+			container.Resources.Requests.Memory() == nil ||
+			container.Resources.Requests.Memory().IsZero() {
+			return fmt.Errorf("Container called %s does not have Cpu and/or Memory requests defined", container.Name)
+		}
+
+		//check for limits
+		if container.Resources.Limits.Cpu() == nil ||
+			container.Resources.Limits.Cpu().IsZero() ||
+			container.Resources.Limits.Memory() == nil ||
+			container.Resources.Limits.Memory().IsZero() {
+			return fmt.Errorf("Container called %s does not have Cpu and/or Memory limits defined", container.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateDedicatedGameServerCollection performs the checks of the admission webhook on a DedicatedGameServerCollection,
+// along with the checks of its name and its numeric fields, so that it can be validated before it is sent to Kubernetes
+func ValidateDedicatedGameServerCollection(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) error {
+	if errs := validation.IsDNS1123Subdomain(dgsCol.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name %q: %s", dgsCol.Name, strings.Join(errs, ", "))
+	}
+	if dgsCol.Spec.Replicas < 0 {
+		return fmt.Errorf("replicas cannot be negative")
+	}
+	if dgsCol.Spec.DGSMaxFailures < 0 {
+		return fmt.Errorf("dgsMaxFailures cannot be negative")
+	}
+	if err := ValidatePortsToExpose(&dgsCol.Spec.Template, dgsCol.Spec.PortsToExpose); err != nil {
+		return fmt.Errorf("Invalid portsToExpose: %s", err.Error())
+	}
+	if err := ValidatePortPolicy(dgsCol.Spec.PortPolicy, len(GetPortsToExpose(&dgsCol.Spec.Template, dgsCol.Spec.PortsToExpose))); err != nil {
+		return fmt.Errorf("Invalid portPolicy: %s", err.Error())
+	}
+	if _, err := ParseAddressTemplate(dgsCol.Spec.AddressTemplate); err != nil {
+		return fmt.Errorf("Invalid addressTemplate: %s", err.Error())
+	}
+	return ValidatePodSpecResources(&dgsCol.Spec.Template)
+}
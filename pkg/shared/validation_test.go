@@ -0,0 +1,79 @@
+package shared
+
+import (
+	"testing"
+
+	dgsv1alpha1 "github.com/dgkanatsios/azuregameserversscalingkubernetes/pkg/apis/azuregaming/v1alpha1"
+
+	"github.com/stretchr/testify/assert"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newValidDGSCol() *dgsv1alpha1.DedicatedGameServerCollection {
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("100m"),
+		corev1.ResourceMemory: resource.MustParse("64Mi"),
+	}
+	dgsCol := NewDedicatedGameServerCollection("col1", GameNamespace, 2, corev1.PodSpec{
+		Containers: []corev1.Container{
+			{
+				Name:      "game",
+				Ports:     []corev1.ContainerPort{{Name: "game", ContainerPort: 7777}},
+				Resources: corev1.ResourceRequirements{Requests: resources, Limits: resources},
+			},
+		},
+	})
+	dgsCol.Spec.PortsToExpose = []intstr.IntOrString{intstr.FromInt(7777)}
+	return dgsCol
+}
+
+func TestValidateDedicatedGameServerCollection(t *testing.T) {
+	tests := []struct {
+		name          string
+		modify        func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection)
+		expectedError string
+	}{
+		{name: "valid", modify: func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) {}},
+		{name: "invalid name", modify: func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) { dgsCol.Name = "Col_1" }, expectedError: "invalid name"},
+		{name: "negative replicas", modify: func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) { dgsCol.Spec.Replicas = -1 }, expectedError: "replicas cannot be negative"},
+		{name: "negative max failures", modify: func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) { dgsCol.Spec.DGSMaxFailures = -1 }, expectedError: "dgsMaxFailures cannot be negative"},
+		{
+			name: "missing port",
+			modify: func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) {
+				dgsCol.Spec.PortsToExpose = []intstr.IntOrString{intstr.FromInt(8888)}
+			},
+			expectedError: "Invalid portsToExpose",
+		},
+		{
+			name: "invalid port policy",
+			modify: func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) {
+				dgsCol.Spec.PortPolicy = &dgsv1alpha1.DGSPortPolicy{Mode: "Unknown"}
+			},
+			expectedError: "Invalid portPolicy",
+		},
+		{name: "invalid address template", modify: func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) { dgsCol.Spec.AddressTemplate = "{{" }, expectedError: "Invalid addressTemplate"},
+		{
+			name: "missing limits",
+			modify: func(dgsCol *dgsv1alpha1.DedicatedGameServerCollection) {
+				dgsCol.Spec.Template.Containers[0].Resources.Limits = nil
+			},
+			expectedError: "Container called game does not have Cpu and/or Memory limits defined",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dgsCol := newValidDGSCol()
+			tt.modify(dgsCol)
+			err := ValidateDedicatedGameServerCollection(dgsCol)
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.expectedError)
+			}
+		})
+	}
+}